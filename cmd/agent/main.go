@@ -1,10 +1,22 @@
 // Command agent is a lightweight FIPS posture reporting agent for endpoints.
 //
 // It performs OS-level FIPS compliance checks and reports results to a
-// fleet controller. Designed to be small (~5MB), with no embedded frontend.
+// fleet controller. Checks are re-run continuously, and any item changing
+// status is reported immediately rather than waiting for the next interval.
+// Designed to be small (~5MB), with no embedded frontend.
+//
+// On first start with --enroll-token the agent enrolls itself and saves its
+// node ID and API key to --state-file (mode 0600); later runs need no
+// credentials. Tokens are single-use by default, so the one used to enroll
+// cannot re-enroll the node after the controller revokes its key. With
+// --enroll-token-file the agent re-reads the file and waits for a new
+// token; otherwise it exits unless the token still has uses left.
 //
 // Usage:
 //
+//	cloudflared-fips-agent --controller-url https://ctrl:8080 --enroll-token TOKEN
+//	cloudflared-fips-agent --controller-url https://ctrl:8080 --enroll-token-file /etc/cloudflared-fips/enroll-token
+//	cloudflared-fips-agent                      # start from the saved state file
 //	cloudflared-fips-agent --controller-url https://ctrl:8080 --node-id ID --api-key KEY
 //	cloudflared-fips-agent --check              # run checks once and print results
 //	cloudflared-fips-agent --remediate          # run checks and fix what's possible
@@ -15,12 +27,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -35,12 +50,28 @@ func main() {
 	controllerURL := flag.String("controller-url", "", "URL of fleet controller (required for reporting mode)")
 	nodeID := flag.String("node-id", "", "node ID from enrollment (or set NODE_ID env)")
 	apiKey := flag.String("api-key", "", "API key from enrollment (or set NODE_API_KEY env)")
+	enrollToken := flag.String("enroll-token", "", "enrollment token used to enroll; re-enrolling after revocation needs a multi-use token or --enroll-token-file (or set ENROLLMENT_TOKEN env)")
+	enrollTokenFile := flag.String("enroll-token-file", "", "file holding the enrollment token, re-read on every attempt so a new token can be supplied after revocation (or set ENROLLMENT_TOKEN_FILE env)")
+	stateFile := flag.String("state-file", "/var/lib/cloudflared-fips/agent-state.json", "file holding the node ID and API key after self-enrollment")
+	nodeName := flag.String("node-name", "", "node name to enroll with (default: hostname)")
+	nodeRegion := flag.String("node-region", "", "region to enroll with")
+	serviceName := flag.String("service-name", "", "origin service to register when enrolling as a server")
+	serviceHost := flag.String("service-host", "", "origin service host (with --service-name)")
+	servicePort := flag.Int("service-port", 0, "origin service port (with --service-name)")
+	serviceTLS := flag.Bool("service-tls", false, "origin service speaks TLS (with --service-name)")
 	interval := flag.Duration("interval", 60*time.Second, "report interval")
+	checkInterval := flag.Duration("check-interval", 15*time.Second, "how often posture checks re-run between reports (status changes are reported immediately)")
+	checkTimeout := flag.Duration("check-timeout", 10*time.Second, "maximum time allowed for each posture check")
+	spoolDir := flag.String("spool-dir", "/var/lib/cloudflared-fips/spool", "directory for encrypted reports queued while the controller is unreachable (empty disables)")
+	spoolMax := flag.Int("spool-max", 5000, "maximum queued reports and heartbeats before the oldest are dropped")
+	spoolKeyFile := flag.String("spool-key-file", "/var/lib/cloudflared-fips/spool.key", "file holding the spool encryption key (created with mode 0600 if missing)")
 	checkOnly := flag.Bool("check", false, "run checks once and print results (no reporting)")
 	jsonOutput := flag.Bool("json", false, "output checks as JSON (with --check)")
 	version := flag.Bool("version", false, "print version and exit")
 	remediateFlag := flag.Bool("remediate", false, "run checks, fix auto-remediable issues, and exit")
 	enableRemediation := flag.Bool("enable-remediation", false, "accept controller-driven remediation requests")
+	customActions := flag.String("custom-actions", "", "YAML file of operator-defined remediation actions (signed scripts)")
+	trustedKeys := flag.String("trusted-keys", "", "PEM file of ECDSA public keys trusted to sign custom action scripts (required with --custom-actions)")
 
 	flag.Parse()
 
@@ -53,6 +84,11 @@ func main() {
 
 	agentChecks := fleet.NewAgentChecks()
 
+	executor, err := newExecutor(logger, *customActions, *trustedKeys)
+	if err != nil {
+		logger.Fatalf("Custom remediation actions: %v", err)
+	}
+
 	// Check-only mode: run checks and exit
 	if *checkOnly {
 		section := agentChecks.RunChecks()
@@ -69,7 +105,6 @@ func main() {
 	// Remediate mode: run checks, fix what's possible, exit
 	if *remediateFlag {
 		section := agentChecks.RunChecks()
-		executor := remediate.NewExecutor(logger)
 		plan := executor.Plan(section)
 
 		if len(plan) == 0 {
@@ -143,9 +178,63 @@ func main() {
 	nID := envOrFlag(*nodeID, "NODE_ID")
 	nKey := envOrFlag(*apiKey, "NODE_API_KEY")
 	ctrlURL := envOrFlag(*controllerURL, "CONTROLLER_URL")
+	tokens := tokenSource{
+		value: envOrFlag(*enrollToken, "ENROLLMENT_TOKEN"),
+		file:  envOrFlag(*enrollTokenFile, "ENROLLMENT_TOKEN_FILE"),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	enrollReq := fleet.EnrollmentRequest{
+		Name:        *nodeName,
+		Region:      *nodeRegion,
+		Version:     buildinfo.Version,
+		FIPSBackend: fipsbackend.DetectInfo().Name,
+	}
+	if enrollReq.Name == "" {
+		enrollReq.Name, _ = os.Hostname()
+	}
+	if *serviceName != "" {
+		enrollReq.Service = &fleet.ServiceRegistration{
+			Name: *serviceName,
+			Host: *serviceHost,
+			Port: *servicePort,
+			TLS:  *serviceTLS,
+		}
+	}
 
-	if ctrlURL == "" || nID == "" || nKey == "" {
-		fmt.Fprintln(os.Stderr, "Usage: cloudflared-fips-agent --controller-url URL --node-id ID --api-key KEY")
+	var state *fleet.AgentState
+	switch {
+	case nID != "" && nKey != "":
+		// Explicit credentials take precedence and are not persisted.
+		state = &fleet.AgentState{NodeID: nID, APIKey: nKey, ControllerURL: ctrlURL}
+	default:
+		var err error
+		state, err = fleet.LoadAgentState(*stateFile)
+		switch {
+		case err == nil:
+			if ctrlURL != "" {
+				state.ControllerURL = ctrlURL
+			}
+			if tokens.configured() {
+				logger.Printf("Already enrolled as %s (%s); ignoring enrollment token until credentials are revoked", state.NodeID, *stateFile)
+			}
+		case errors.Is(err, os.ErrNotExist) && tokens.configured() && ctrlURL != "":
+			state, err = enroll(ctx, logger, ctrlURL, enrollReq, tokens, *stateFile)
+			if err != nil {
+				logger.Fatalf("Enrollment failed: %v", err)
+			}
+		case errors.Is(err, os.ErrNotExist):
+			state = nil
+		default:
+			logger.Fatalf("Load agent state: %v", err)
+		}
+	}
+
+	if state == nil || state.ControllerURL == "" {
+		fmt.Fprintln(os.Stderr, "Usage: cloudflared-fips-agent --controller-url URL --enroll-token TOKEN")
+		fmt.Fprintln(os.Stderr, "       cloudflared-fips-agent --controller-url URL --node-id ID --api-key KEY")
 		fmt.Fprintln(os.Stderr, "       cloudflared-fips-agent --check              (run checks locally)")
 		fmt.Fprintln(os.Stderr, "       cloudflared-fips-agent --remediate          (fix auto-remediable issues)")
 		fmt.Fprintln(os.Stderr, "       cloudflared-fips-agent --enable-remediation (accept controller remediation)")
@@ -153,48 +242,233 @@ func main() {
 	}
 
 	logger.Printf("%s", buildinfo.String())
-	logger.Printf("Controller: %s", ctrlURL)
-	logger.Printf("Node ID: %s", nID)
 	logger.Printf("Report interval: %s", *interval)
+	logger.Printf("Check interval: %s (per-check timeout %s)", *checkInterval, *checkTimeout)
 	if *enableRemediation {
 		logger.Printf("Remediation: enabled (accepting controller requests)")
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	opts := agentOptions{
+		interval:          *interval,
+		checkInterval:     *checkInterval,
+		checkTimeout:      *checkTimeout,
+		spoolDir:          *spoolDir,
+		spoolKeyFile:      *spoolKeyFile,
+		spoolMax:          *spoolMax,
+		enableRemediation: *enableRemediation,
+		executor:          executor,
+	}
+
+	for {
+		if !runAgent(ctx, logger, state, agentChecks, opts) {
+			break
+		}
+
+		// The controller rejected our credentials: the node was removed
+		// or its key revoked. Re-enroll if a token can be had.
+		revokedPath := *stateFile + ".revoked"
+		if _, err := os.Stat(*stateFile); err == nil {
+			if err := os.Rename(*stateFile, revokedPath); err != nil {
+				logger.Printf("Failed to retire revoked state file: %v", err)
+			}
+		}
+		if !tokens.configured() {
+			logger.Fatalf("Credentials for node %s were revoked; re-run with --enroll-token to re-enroll", state.NodeID)
+		}
+		logger.Printf("Credentials for node %s were revoked; re-enrolling", state.NodeID)
+		next, err := enroll(ctx, logger, state.ControllerURL, enrollReq, tokens, *stateFile)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Fatalf("Re-enrollment failed: %v; the enrollment token is spent or expired, so supply a new one (see --enroll-token-file)", err)
+		}
+		state = next
+	}
 
-	// Build a checker with agent sections
+	logger.Printf("Agent stopped")
+}
+
+// agentOptions holds the reporting-mode settings that survive re-enrollment.
+type agentOptions struct {
+	interval          time.Duration
+	checkInterval     time.Duration
+	checkTimeout      time.Duration
+	spoolDir          string
+	spoolKeyFile      string
+	spoolMax          int
+	enableRemediation bool
+	executor          *remediate.Executor
+}
+
+// runAgent reports to the controller with the given credentials until ctx
+// is cancelled or the controller rejects them. It returns true in the
+// latter case.
+func runAgent(ctx context.Context, logger *log.Logger, state *fleet.AgentState, agentChecks *fleet.AgentChecks, opts agentOptions) bool {
+	logger.Printf("Controller: %s", state.ControllerURL)
+	logger.Printf("Node ID: %s", state.NodeID)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var revokedOnce sync.Once
+	revoked := false
+
+	// The reporter re-runs the agent checks before every report and every
+	// check interval, so the checker starts empty.
 	checker := compliance.NewChecker()
-	checker.AddSection(agentChecks.RunChecks())
+
+	// Offline spool: keeps continuous-monitoring evidence while the
+	// controller is unreachable and replays it once connectivity returns.
+	var spool *fleet.Spool
+	if opts.spoolDir != "" {
+		var err error
+		spool, err = openSpool(logger, opts.spoolDir, opts.spoolKeyFile, state.APIKey, opts.spoolMax)
+		if err != nil {
+			logger.Printf("Offline spool disabled: %v", err)
+		} else if n := spool.Len(); n > 0 {
+			logger.Printf("Offline spool: %s (%d queued for replay)", opts.spoolDir, n)
+		} else {
+			logger.Printf("Offline spool: %s", opts.spoolDir)
+		}
+	}
 
 	reporter := fleet.NewReporter(fleet.ReporterConfig{
-		ControllerURL: ctrlURL,
-		NodeID:        nID,
-		APIKey:        nKey,
+		ControllerURL: state.ControllerURL,
+		NodeID:        state.NodeID,
+		APIKey:        state.APIKey,
 		Checker:       checker,
-		Interval:      *interval,
+		Interval:      opts.interval,
 		Logger:        logger,
+		Refresh: func(ctx context.Context) []compliance.Section {
+			return []compliance.Section{agentChecks.RunChecksContext(ctx, opts.checkTimeout)}
+		},
+		CheckInterval: opts.checkInterval,
+		Spool:         spool,
+		OnAuthFailure: func(int) {
+			revokedOnce.Do(func() {
+				revoked = true
+				cancel()
+			})
+		},
 	})
 
-	// Start reporter in background
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		logger.Printf("Agent started, reporting every %s", *interval)
-		reporter.Run(ctx)
+		defer wg.Done()
+		logger.Printf("Agent started, reporting every %s", opts.interval)
+		reporter.Run(runCtx)
 	}()
 
 	// If remediation enabled, also poll for controller-driven requests
-	if *enableRemediation {
-		go pollRemediations(ctx, logger, ctrlURL, nID, nKey, agentChecks, *interval)
+	if opts.enableRemediation {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pollRemediations(runCtx, logger, opts.executor, state.ControllerURL, state.NodeID, state.APIKey, agentChecks, opts.interval, opts.checkTimeout)
+		}()
 	}
 
-	<-ctx.Done()
-	logger.Printf("Agent stopped")
+	<-runCtx.Done()
+	wg.Wait()
+	return revoked && ctx.Err() == nil
+}
+
+// enroll redeems the enrollment token, retrying while the controller is
+// unreachable, and saves the resulting credentials to stateFile.
+func enroll(ctx context.Context, logger *log.Logger, ctrlURL string, req fleet.EnrollmentRequest, tokens tokenSource, stateFile string) (*fleet.AgentState, error) {
+	backoff := enrollBackoff
+	rejected := "" // a token the controller refused; not retried
+	for {
+		token, err := tokens.token()
+		switch {
+		case err != nil:
+			logger.Printf("Read enrollment token: %v (retrying in %s)", err, backoff)
+		case token == "" || token == rejected:
+			// Waiting for an operator to write a new token to the file.
+		default:
+			req.Token = token
+			state, err := fleet.RequestEnrollment(ctx, nil, ctrlURL, req)
+			if err == nil {
+				if err := fleet.SaveAgentState(stateFile, state); err != nil {
+					return nil, fmt.Errorf("save agent state: %w", err)
+				}
+				logger.Printf("Enrolled as node %s (role %s); credentials saved to %s", state.NodeID, state.Role, stateFile)
+				return state, nil
+			}
+			if !errors.Is(err, fleet.ErrEnrollmentRejected) {
+				logger.Printf("Enrollment attempt failed: %v (retrying in %s)", err, backoff)
+				break
+			}
+			if tokens.file == "" {
+				return nil, err
+			}
+			rejected = token
+			logger.Printf("Enrollment token rejected: %v; waiting for a new token in %s", err, tokens.file)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 5*time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// enrollBackoff is the first delay between enrollment attempts.
+var enrollBackoff = 5 * time.Second
+
+// tokenSource yields the enrollment token: the contents of file when set,
+// re-read on every attempt so that a new token can be supplied after the
+// previous one is spent, and otherwise value.
+type tokenSource struct {
+	value string
+	file  string
+}
+
+func (ts tokenSource) configured() bool {
+	return ts.value != "" || ts.file != ""
+}
+
+func (ts tokenSource) token() (string, error) {
+	if ts.file == "" {
+		return ts.value, nil
+	}
+	data, err := os.ReadFile(ts.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// newExecutor creates the remediation executor, adding the operator-defined
+// actions in customPath (if set) signed by the keys in keysPath.
+func newExecutor(logger *log.Logger, customPath, keysPath string) (*remediate.Executor, error) {
+	if customPath == "" {
+		return remediate.NewExecutor(logger), nil
+	}
+	if keysPath == "" {
+		return nil, fmt.Errorf("--trusted-keys is required with --custom-actions")
+	}
+	actions, err := remediate.LoadCustomActions(customPath)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := remediate.LoadTrustedKeys(keysPath)
+	if err != nil {
+		return nil, err
+	}
+	logger.Printf("Custom remediation actions: %d (%d trusted signing keys)", len(actions), len(keys))
+	return remediate.NewExecutor(logger, remediate.WithCustomActions(actions, keys)), nil
 }
 
 // pollRemediations periodically checks the controller for pending remediation requests.
-func pollRemediations(ctx context.Context, logger *log.Logger, ctrlURL, nodeID, apiKey string, checks *fleet.AgentChecks, interval time.Duration) {
+func pollRemediations(ctx context.Context, logger *log.Logger, executor *remediate.Executor, ctrlURL, nodeID, apiKey string, checks *fleet.AgentChecks, interval, checkTimeout time.Duration) {
 	client := &http.Client{Timeout: 10 * time.Second}
-	executor := remediate.NewExecutor(logger)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -218,9 +492,11 @@ func pollRemediations(ctx context.Context, logger *log.Logger, ctrlURL, nodeID,
 			}
 
 			var pending []struct {
-				ID      string   `json:"id"`
-				Actions []string `json:"actions"`
-				DryRun  bool     `json:"dry_run"`
+				ID       string                       `json:"id"`
+				Actions  []string                     `json:"actions"`
+				DryRun   bool                         `json:"dry_run"`
+				Type     remediate.RequestType        `json:"type"`
+				Previous *remediate.RemediationResult `json:"previous"`
 			}
 			if err := json.NewDecoder(resp.Body).Decode(&pending); err != nil {
 				logger.Printf("Failed to decode pending remediations: %v", err)
@@ -237,9 +513,6 @@ func pollRemediations(ctx context.Context, logger *log.Logger, ctrlURL, nodeID,
 			for _, p := range pending {
 				logger.Printf("processing remediation request %s (%d actions)", p.ID, len(p.Actions))
 
-				section := checks.RunChecks()
-				plan := executor.Plan(section)
-
 				// Convert string actions to ActionIDs
 				var actionIDs []remediate.ActionID
 				for _, a := range p.Actions {
@@ -247,12 +520,22 @@ func pollRemediations(ctx context.Context, logger *log.Logger, ctrlURL, nodeID,
 				}
 
 				remReq := remediate.RemediationRequest{
-					ID:      p.ID,
-					NodeID:  nodeID,
-					Actions: actionIDs,
-					DryRun:  p.DryRun,
+					ID:       p.ID,
+					NodeID:   nodeID,
+					Actions:  actionIDs,
+					DryRun:   p.DryRun,
+					Type:     p.Type,
+					Previous: p.Previous,
+				}
+				var result remediate.RemediationResult
+				if p.Type == remediate.RequestRollback {
+					result = executor.Rollback(remReq, func() compliance.Section {
+						return checks.RunChecksContext(ctx, checkTimeout)
+					})
+				} else {
+					section := checks.RunChecksContext(ctx, checkTimeout)
+					result = executor.Execute(remReq, executor.Plan(section))
 				}
-				result := executor.Execute(remReq, plan)
 
 				// Post result back to controller
 				resultJSON, _ := json.Marshal(result)
@@ -281,6 +564,30 @@ func pollRemediations(ctx context.Context, logger *log.Logger, ctrlURL, nodeID,
 	}
 }
 
+// openSpool opens the offline report spool, encrypted under the key in
+// keyFile, which outlives the node's API key.
+func openSpool(logger *log.Logger, dir, keyFile, apiKey string, maxEntries int) (*fleet.Spool, error) {
+	key, created, err := fleet.LoadSpoolKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	spool, err := fleet.NewSpool(dir, key, maxEntries)
+	if err != nil || !created {
+		return spool, err
+	}
+	// Earlier agents encrypted the spool under a key derived from the API
+	// key; move what they queued to the new key before it can be lost to a
+	// re-enrollment.
+	if oldKey, err := fleet.SpoolKeyFromAPIKey(apiKey); err == nil {
+		if n, err := spool.Reencrypt(oldKey); err != nil {
+			logger.Printf("Offline spool: re-encrypt queued entries: %v", err)
+		} else if n > 0 {
+			logger.Printf("Offline spool: re-encrypted %d queued entries under %s", n, keyFile)
+		}
+	}
+	return spool, nil
+}
+
 func printSection(section compliance.Section) {
 	fmt.Printf("=== %s ===\n", section.Name)
 	for _, item := range section.Items {
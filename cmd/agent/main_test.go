@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/internal/dashboard"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
 )
 
 // ---------------------------------------------------------------------------
@@ -190,3 +200,94 @@ func searchSubstring(s, sub string) bool {
 	}
 	return false
 }
+
+// ---------------------------------------------------------------------------
+// enroll — enroll, revoke, and re-enroll against a real controller
+// ---------------------------------------------------------------------------
+
+func TestEnroll_ReenrollWithFreshTokenAfterRevocation(t *testing.T) {
+	defer func(d time.Duration) { enrollBackoff = d }(enrollBackoff)
+	enrollBackoff = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	store, err := fleet.NewSQLiteStore(filepath.Join(dir, "fleet.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	mux := http.NewServeMux()
+	dashboard.RegisterFleetRoutes(mux, dashboard.NewFleetHandler(dashboard.FleetHandlerConfig{Store: store}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	enrollment := fleet.NewEnrollment(store)
+	newToken := func() string {
+		tok, err := enrollment.CreateToken(ctx, fleet.CreateTokenRequest{Role: fleet.RoleServer})
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		return tok.Token
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	stateFile := filepath.Join(dir, "agent-state.json")
+	tokenFile := filepath.Join(dir, "enroll-token")
+	tokens := tokenSource{file: tokenFile}
+	req := fleet.EnrollmentRequest{Name: "node-a"}
+
+	first := newToken()
+	if err := os.WriteFile(tokenFile, []byte(first+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := enroll(ctx, logger, srv.URL, req, tokens, stateFile)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+
+	// Revoke the node. Its single-use token is spent, so re-enrolling
+	// with it alone must fail rather than loop.
+	if err := store.DeleteNode(ctx, state.NodeID); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	if _, err := enroll(ctx, logger, srv.URL, req, tokenSource{value: first}, stateFile); !errors.Is(err, fleet.ErrEnrollmentRejected) {
+		t.Fatalf("re-enroll with spent token: err = %v, want ErrEnrollmentRejected", err)
+	}
+
+	// With a token file, the agent waits for a new token and re-enrolls.
+	type result struct {
+		state *fleet.AgentState
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		st, err := enroll(ctx, logger, srv.URL, req, tokens, stateFile)
+		done <- result{st, err}
+	}()
+	select {
+	case r := <-done:
+		t.Fatalf("enroll returned before a new token was supplied: %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := os.WriteFile(tokenFile, []byte(newToken()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("re-enroll: %v", r.err)
+	}
+	if r.state.NodeID == state.NodeID {
+		t.Errorf("re-enrolled with revoked node ID %s", state.NodeID)
+	}
+	saved, err := fleet.LoadAgentState(stateFile)
+	if err != nil {
+		t.Fatalf("LoadAgentState: %v", err)
+	}
+	if saved.NodeID != r.state.NodeID || saved.APIKey != r.state.APIKey {
+		t.Errorf("saved state = %+v, want %+v", saved, r.state)
+	}
+	if _, err := store.GetNode(ctx, r.state.NodeID); err != nil {
+		t.Errorf("GetNode(%s): %v", r.state.NodeID, err)
+	}
+}
@@ -13,10 +13,12 @@
 //	cloudflared-fips agent [flags]      Start endpoint agent
 //	cloudflared-fips provision [flags]  Run provisioning script
 //	cloudflared-fips unprovision [flags] Run unprovisioning script
+//	cloudflared-fips audit verify [flags] Verify the audit log hash chain
 //	cloudflared-fips version            Show version info
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -29,6 +31,7 @@ import (
 	"github.com/cloudflared-fips/cloudflared-fips/internal/tui/menu"
 	"github.com/cloudflared-fips/cloudflared-fips/internal/tui/status"
 	"github.com/cloudflared-fips/cloudflared-fips/internal/tui/wizard"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/buildinfo"
 )
 
@@ -57,6 +60,8 @@ func main() {
 		execScript(common.FindProvisionScript(), os.Args[2:])
 	case "unprovision":
 		execScript(common.FindUnprovisionScript(), os.Args[2:])
+	case "audit":
+		runAudit(os.Args[2:])
 	case "version", "--version", "-v":
 		fmt.Println(buildinfo.String())
 	case "help", "--help", "-h":
@@ -102,6 +107,61 @@ func runStatus(args []string) {
 	}
 }
 
+func runAudit(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "Usage: cloudflared-fips audit verify [--log PATH] [--key FILE] [--json]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	logPath := fs.String("log", os.Getenv("AUDIT_LOG_PATH"), "Audit log file (env: AUDIT_LOG_PATH)")
+	keyFile := fs.String("key", os.Getenv("AUDIT_CHAIN_KEY_FILE"), "HMAC chain key file (env: AUDIT_CHAIN_KEY_FILE)")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		os.Exit(1)
+	}
+	if *logPath == "" || *keyFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: --log and --key are required")
+		os.Exit(2)
+	}
+
+	key, err := audit.LoadChainKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	report, err := audit.VerifyLog(*logPath, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		fmt.Printf("Audit log:  %s\n", report.Path)
+		fmt.Printf("Entries:    %d (%d before chaining)\n", report.Entries, report.Unchained)
+		fmt.Printf("Chain:      seq %d-%d\n", report.FirstSeq, report.LastSeq)
+		if cp := report.Checkpoint; cp != nil {
+			fmt.Printf("Checkpoint: seq %d at %s\n", cp.Seq, cp.Timestamp)
+		} else {
+			fmt.Println("Checkpoint: none")
+		}
+		if report.OK() {
+			fmt.Println("Result:     OK")
+		} else {
+			fmt.Printf("Result:     FAILED (%d problems)\n", len(report.Problems))
+			for _, p := range report.Problems {
+				fmt.Printf("  - %s\n", p)
+			}
+		}
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
 // execBinary finds and executes a companion binary, passing through
 // stdin/stdout/stderr and propagating the exit code.
 func execBinary(name, devPkg string, args []string) {
@@ -160,6 +220,7 @@ func printUsage() {
 	fmt.Println("  agent            Start endpoint FIPS posture agent")
 	fmt.Println("  provision        Run provisioning script")
 	fmt.Println("  unprovision      Run unprovisioning script")
+	fmt.Println("  audit verify     Verify the audit log hash chain")
 	fmt.Println("  version          Show version information")
 	fmt.Println("  help             Show this help message")
 	fmt.Println()
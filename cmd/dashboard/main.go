@@ -9,6 +9,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -16,6 +17,8 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -24,13 +27,16 @@ import (
 	"github.com/cloudflared-fips/cloudflared-fips/internal/dashboard"
 	"github.com/cloudflared-fips/cloudflared-fips/internal/ipc"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts/alertdb"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit/auditdb"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/buildinfo"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/cfapi"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/clientdetect"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/deployment"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/fipsbackend"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/signing"
 )
 
@@ -83,18 +89,65 @@ func main() {
 	nodeName := flag.String("node-name", "", "name for this node in fleet (defaults to hostname)")
 	nodeRegion := flag.String("node-region", "", "region label for this node")
 	nodeID := flag.String("node-id", "", "node ID from enrollment (or set NODE_ID env)")
+	remediationApprovals := flag.Int("remediation-approvals", 0, "approvals required before node remediation is released to agents and edge remediation is applied; 0 disables (or set REMEDIATION_APPROVALS env)")
+	remediationApprovers := flag.String("remediation-approvers", "", "comma-separated name:key approver credentials (or set REMEDIATION_APPROVERS env)")
+	remediationApprovalTTL := flag.Duration("remediation-approval-ttl", dashboard.DefaultApprovalTTL, "how long a remediation request may await approval")
+	rebootMaxConcurrent := flag.Int("reboot-max-concurrent", 1, "nodes the reboot coordinator may restart at once")
+	rebootMinHealthy := flag.Int("reboot-min-healthy-servers", 1, "other routable servers required before a routable server is rebooted")
+
+	// Federation flags (this controller reports to a parent controller)
+	parentURL := flag.String("parent-url", "", "URL of a parent controller to federate into (or set PARENT_CONTROLLER_URL env; requires --fleet-mode)")
+	parentEnrollToken := flag.String("parent-enroll-token", "", "controller-role enrollment token for the parent (or set PARENT_ENROLLMENT_TOKEN env)")
+	parentStateFile := flag.String("parent-state-file", "/var/lib/cloudflared-fips/parent-state.json", "file holding this controller's credentials at the parent")
+	siteName := flag.String("site-name", "", "site name shown at the parent (defaults to --node-name or hostname)")
+	siteAPIURL := flag.String("site-api-url", "", "URL at which the parent can reach this controller for drill-down (empty disables)")
+	siteAPIToken := flag.String("site-api-token", "", "bearer token the parent sends on drill-down requests (or set SITE_API_TOKEN env)")
+	federationSiteURLs := flag.String("federation-site-urls", "", "comma-separated --site-api-url values of child controllers this parent may proxy drill-downs to (or set FEDERATION_SITE_URLS env)")
 
 	// Version flag
 	showVersion := flag.Bool("version", false, "print version and exit")
 
 	// Security Operations flags
 	auditLogPath := flag.String("audit-log", "", "path to audit log file (enables AU-2 compliance; e.g., /var/log/cloudflared-fips/audit.json)")
-	syslogAddr := flag.String("syslog-addr", "", "syslog address for audit log forwarding (e.g., tcp://siem:514)")
+	auditFormat := flag.String("audit-format", "json", "audit log file format: json, cef (ArcSight), or leef (QRadar); the hash chain and history index need json")
+	auditChainKey := flag.String("audit-chain-key", "", "HMAC key file for the tamper-evident audit log hash chain (AU-9; or set AUDIT_CHAIN_KEY_FILE env)")
+	auditChainAlg := flag.String("audit-chain-alg", "sha256", "audit log hash chain HMAC algorithm: sha256 or sha384")
+	auditCheckpointInterval := flag.Duration("audit-checkpoint-interval", audit.DefaultCheckpointInterval, "maximum time between signed audit log checkpoints")
+	auditRotateSize := flag.Int64("audit-rotate-size", 0, "rotate the audit log once it reaches this many MiB (0 disables)")
+	auditRotateInterval := flag.Duration("audit-rotate-interval", 0, "rotate the audit log after this long, e.g. 24h (0 disables)")
+	auditRetention := flag.Duration("audit-retention", 0, "delete archived audit log segments older than this, e.g. 8760h for one year (AU-11; 0 keeps them)")
+	auditArchiveDir := flag.String("audit-archive-dir", "", "directory for rotated, gzipped audit log segments and their SHA-256 manifest (default: the audit log's directory)")
+	auditBundleDir := flag.String("audit-bundle-dir", "", "directory to write a signed bundle of each archived audit log segment for offload")
+	auditBundleKey := flag.String("audit-bundle-key", "", "PEM ECDSA private key signing audit archive bundles (required with --audit-bundle-dir)")
+	auditQueueSize := flag.Int("audit-queue-size", audit.DefaultQueueSize, "events queued for the background audit writer (0 writes synchronously in each request)")
+	auditOverflow := flag.String("audit-overflow", "block", "when the audit queue is full: block (callers wait, no event lost) or drop (count and discard; so-14 fails)")
+	auditFsync := flag.String("audit-fsync", "interval", "fsync the audit log after every write (always), periodically (interval), or never (none)")
+	auditFsyncInterval := flag.Duration("audit-fsync-interval", audit.DefaultFsyncInterval, "maximum time between audit log fsyncs with --audit-fsync interval")
+	auditIndexPath := flag.String("audit-index-path", "", "SQLite index serving audit history queries (default: <audit-log>-index.db beside the audit log; \"none\" disables)")
+	syslogAddr := flag.String("syslog-addr", "", "syslog address for audit log forwarding (e.g., tls://siem:6514; tcp:// and udp:// send cleartext)")
+	syslogFormat := flag.String("syslog-format", "rfc5424", "syslog message format: rfc5424 (structured data), cef, leef, json, or legacy (log/syslog with the --audit-format body, tcp/udp only)")
+	syslogCA := flag.String("syslog-ca", "", "PEM CA certificates trusted for the tls:// syslog collector (default: system roots)")
+	syslogPins := flag.String("syslog-pin", "", "comma-separated hex SHA-256 SubjectPublicKeyInfo pins for the syslog collector certificate")
+	syslogCert := flag.String("syslog-cert", "", "client certificate for the tls:// syslog collector")
+	syslogKey := flag.String("syslog-key", "", "client certificate key for the tls:// syslog collector")
+	syslogSpool := flag.String("syslog-spool", "", "file buffering audit events the syslog collector has not received (default: <audit-log>.syslog-spool; \"none\" disables)")
+	auditHTTPURL := flag.String("audit-http-url", "", "HTTP event collector for audit events, e.g. https://splunk:8088/services/collector/event or https://es:9200/_bulk (or set AUDIT_HTTP_URL env)")
+	auditHTTPFormat := flag.String("audit-http-format", "splunk", "HTTP collector protocol: splunk (HTTP Event Collector) or elasticsearch (_bulk API)")
+	auditHTTPToken := flag.String("audit-http-token", "", "Splunk HEC token or Elasticsearch API key for the HTTP collector (or set AUDIT_HTTP_TOKEN env)")
+	auditHTTPIndex := flag.String("audit-http-index", "", "Splunk index or Elasticsearch index/data stream for audit events")
+	auditHTTPCA := flag.String("audit-http-ca", "", "PEM CA certificates trusted for the https:// collector (default: system roots)")
+	auditHTTPBuffer := flag.String("audit-http-buffer", "", "file buffering audit events the HTTP collector has not received (default: <audit-log>.http-buffer; \"none\" disables)")
 	dashboardToken := flag.String("dashboard-token", "", "Bearer token for dashboard API auth (or set DASHBOARD_TOKEN env)")
 	alertWebhooks := flag.String("alert-webhook", "", "comma-separated webhook URLs for compliance alerts")
+	alertWebhookSecret := flag.String("alert-webhook-secret", "", "shared secret (at least 16 bytes) signing --alert-webhook deliveries with HMAC-SHA-256 (or set ALERT_WEBHOOK_SECRET env)")
+	alertQueuePath := flag.String("alert-queue-path", "", "SQLite queue holding alert deliveries until their webhook accepts them (default: <audit-log>-alerts.db beside the audit log; \"none\" keeps the queue in memory)")
+	alertMaxAge := flag.Duration("alert-max-age", alerts.DefaultMaxDeliveryAge, "how long an alert delivery is retried before it is dead-lettered")
+	alertMaxBackoff := flag.Duration("alert-max-backoff", alerts.DefaultMaxBackoff, "maximum delay between alert delivery attempts")
+	alertConfig := flag.String("alert-config", "", "YAML file whose alerts section defines webhooks and routing rules, e.g. /etc/cloudflared-fips/cloudflared-fips.yaml (or set ALERT_CONFIG env)")
 	tokenPathFlag := flag.String("token-path", "", "path to tunnel token file for expiry monitoring")
 	certPathsFlag := flag.String("cert-paths", "", "comma-separated TLS certificate paths to monitor for expiry")
 	secretsPathsFlag := flag.String("secrets-paths", "", "comma-separated directories to scan for secret file permissions")
+	secretsOwner := flag.String("secrets-owner", "", "user:group that host remediation chowns secret files and the audit log to (empty leaves ownership unchanged)")
 	upstreamChecksum := flag.String("upstream-checksum", "", "expected SHA-256 hash of upstream cloudflared binary")
 	enforcementMode := flag.String("enforcement-mode", "audit", "security policy enforcement mode: enforce, audit, disabled")
 
@@ -142,24 +195,152 @@ func main() {
 
 	// --- Audit Logger (AU-2, AU-3, AU-6) ---
 	var auditLogger *audit.AuditLogger
+	var auditIndex *auditdb.Index
 	auditPath := envOrFlag(*auditLogPath, "AUDIT_LOG_PATH")
 	if auditPath != "" {
 		var auditOpts []audit.Option
+		fileFormat, err := audit.ParseFormat(*auditFormat)
+		if err != nil {
+			logger.Fatalf("Invalid --audit-format: %v", err)
+		}
+		auditOpts = append(auditOpts, audit.WithFormat(fileFormat))
+		fsync, err := audit.ParseFsyncPolicy(*auditFsync)
+		if err != nil {
+			logger.Fatalf("Invalid --audit-fsync: %v", err)
+		}
+		auditOpts = append(auditOpts, audit.WithFsync(fsync, *auditFsyncInterval))
+		if *auditQueueSize > 0 {
+			overflow, err := audit.ParseOverflowPolicy(*auditOverflow)
+			if err != nil {
+				logger.Fatalf("Invalid --audit-overflow: %v", err)
+			}
+			auditOpts = append(auditOpts, audit.WithAsync(audit.AsyncConfig{QueueSize: *auditQueueSize, Overflow: overflow}))
+			if overflow == audit.OverflowDrop {
+				logger.Printf("Warning: --audit-overflow drop discards audit events when the queue is full (AU-5)")
+			}
+		}
 		sysAddr := envOrFlag(*syslogAddr, "SYSLOG_ADDR")
 		if sysAddr != "" {
-			// Parse "tcp://host:port" or "udp://host:port"
-			if u, err := url.Parse(sysAddr); err == nil {
+			// Parse "tls://host:port", "tcp://host:port" or "udp://host:port"
+			u, err := url.Parse(sysAddr)
+			if err != nil {
+				logger.Fatalf("Invalid --syslog-addr: %v", err)
+			}
+			if *syslogFormat == "legacy" {
 				auditOpts = append(auditOpts, audit.WithSyslog(u.Scheme, u.Host))
-				logger.Printf("Syslog forwarding: %s → %s", u.Scheme, u.Host)
+			} else {
+				spool := *syslogSpool
+				switch spool {
+				case "":
+					spool = auditPath + ".syslog-spool"
+				case "none":
+					spool = ""
+				}
+				var msgFormat audit.Formatter
+				if *syslogFormat != "rfc5424" {
+					if msgFormat, err = audit.ParseFormat(*syslogFormat); err != nil {
+						logger.Fatalf("Invalid --syslog-format: %v", err)
+					}
+				}
+				cfg := audit.SyslogConfig{
+					Format:    msgFormat,
+					Network:   u.Scheme,
+					Addr:      u.Host,
+					CAFile:    *syslogCA,
+					CertFile:  *syslogCert,
+					KeyFile:   *syslogKey,
+					SpoolPath: spool,
+				}
+				if *syslogPins != "" {
+					cfg.PinSHA256 = strings.Split(*syslogPins, ",")
+				}
+				auditOpts = append(auditOpts, audit.WithRemoteSyslog(cfg))
+			}
+			logger.Printf("Syslog forwarding: %s → %s (%s)", u.Scheme, u.Host, *syslogFormat)
+			if u.Scheme != "tls" {
+				logger.Printf("Warning: syslog over %s sends audit events in cleartext; use tls:// (SC-8)", u.Scheme)
+			}
+		}
+		if collectorURL := envOrFlag(*auditHTTPURL, "AUDIT_HTTP_URL"); collectorURL != "" {
+			buffer := *auditHTTPBuffer
+			switch buffer {
+			case "":
+				buffer = auditPath + ".http-buffer"
+			case "none":
+				buffer = ""
+			}
+			auditOpts = append(auditOpts, audit.WithHTTPSink(audit.HTTPSinkConfig{
+				Protocol:  *auditHTTPFormat,
+				URL:       collectorURL,
+				Token:     envOrFlag(*auditHTTPToken, "AUDIT_HTTP_TOKEN"),
+				Index:     *auditHTTPIndex,
+				CAFile:    *auditHTTPCA,
+				SpoolPath: buffer,
+			}))
+			logger.Printf("HTTP audit forwarding: %s (%s)", collectorURL, *auditHTTPFormat)
+			if !strings.HasPrefix(collectorURL, "https://") {
+				logger.Printf("Warning: HTTP collector %s receives audit events in cleartext; use https:// (SC-8)", collectorURL)
+			}
+		}
+		if keyFile := envOrFlag(*auditChainKey, "AUDIT_CHAIN_KEY_FILE"); keyFile != "" {
+			key, err := audit.LoadChainKey(keyFile)
+			if err != nil {
+				logger.Fatalf("Failed to load audit chain key: %v", err)
+			}
+			auditOpts = append(auditOpts, audit.WithHashChain(audit.ChainConfig{
+				Key:                key,
+				Algorithm:          *auditChainAlg,
+				CheckpointInterval: *auditCheckpointInterval,
+			}))
+			logger.Printf("Audit log hash chain enabled (%s)", *auditChainAlg)
+		}
+		if *auditRotateSize > 0 || *auditRotateInterval > 0 || *auditRetention > 0 || *auditBundleDir != "" {
+			rotation := audit.RotationConfig{
+				MaxSize:    *auditRotateSize << 20,
+				MaxAge:     *auditRotateInterval,
+				Retention:  *auditRetention,
+				ArchiveDir: *auditArchiveDir,
+				BundleDir:  *auditBundleDir,
+			}
+			if *auditBundleKey != "" {
+				key, err := audit.LoadBundleKey(*auditBundleKey)
+				if err != nil {
+					logger.Fatalf("Failed to load audit bundle key: %v", err)
+				}
+				rotation.BundleKey = key
 			}
+			auditOpts = append(auditOpts, audit.WithRotation(rotation))
+			logger.Printf("Audit log rotation enabled (size %d MiB, interval %s, retention %s)", *auditRotateSize, *auditRotateInterval, *auditRetention)
 		}
-		var err error
 		auditLogger, err = audit.NewAuditLogger(auditPath, auditOpts...)
 		if err != nil {
 			logger.Fatalf("Failed to create audit logger: %v", err)
 		}
 		defer auditLogger.Close()
 		logger.Printf("Audit logging enabled: %s", auditPath)
+
+		// Index before the first event so history queries see everything.
+		if *auditIndexPath != "none" {
+			indexPath := *auditIndexPath
+			if indexPath == "" {
+				indexPath = strings.TrimSuffix(auditPath, filepath.Ext(auditPath)) + "-index.db"
+			}
+			auditIndex, err = auditdb.Open(indexPath)
+			if err != nil {
+				logger.Fatalf("Failed to open audit index: %v", err)
+			}
+			defer auditIndex.Close()
+			if n, err := auditIndex.Backfill(auditPath, *auditArchiveDir); err != nil {
+				logger.Printf("Warning: audit index backfill: %v", err)
+			} else if n > 0 {
+				logger.Printf("Audit index: imported %d existing events", n)
+			}
+			auditIndex.Attach(auditLogger)
+			if *auditRetention > 0 {
+				go pruneAuditIndex(ctx, auditIndex, *auditRetention, logger)
+			}
+			logger.Printf("Audit index: %s", indexPath)
+		}
 		auditLogger.Log(audit.AuditEvent{
 			EventType: "system_event",
 			Severity:  "info",
@@ -171,20 +352,42 @@ func main() {
 	}
 
 	// --- Alert Manager (CA-7, SI-4) ---
-	var alertManager *alerts.AlertManager
-	webhookURLs := envOrFlag(*alertWebhooks, "ALERT_WEBHOOKS")
-	if webhookURLs != "" {
-		var webhooks []alerts.WebhookConfig
-		for _, u := range strings.Split(webhookURLs, ",") {
-			u = strings.TrimSpace(u)
-			if u != "" {
-				webhooks = append(webhooks, alerts.WebhookConfig{URL: u})
+	var webhooks []alerts.WebhookConfig
+	for _, u := range strings.Split(envOrFlag(*alertWebhooks, "ALERT_WEBHOOKS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			wh := alerts.WebhookConfig{URL: u, Secret: envOrFlag(*alertWebhookSecret, "ALERT_WEBHOOK_SECRET")}
+			if err := wh.Validate(); err != nil {
+				logger.Fatalf("Invalid --alert-webhook: %v", err)
 			}
+			webhooks = append(webhooks, wh)
+		}
+	}
+	if path := envOrFlag(*alertConfig, "ALERT_CONFIG"); path != "" {
+		routed, err := alerts.LoadWebhooks(path)
+		if err != nil {
+			logger.Fatalf("Invalid --alert-config: %v", err)
+		}
+		webhooks = append(webhooks, routed...)
+	}
+	alertManager := alerts.NewAlertManager(auditLogger, webhooks)
+	queueCfg := alerts.QueueConfig{MaxAge: *alertMaxAge, MaxBackoff: *alertMaxBackoff}
+	queuePath := *alertQueuePath
+	if queuePath == "" && auditPath != "" {
+		queuePath = strings.TrimSuffix(auditPath, filepath.Ext(auditPath)) + "-alerts.db"
+	}
+	if queuePath != "" && queuePath != "none" {
+		alertQueue, err := alertdb.Open(queuePath)
+		if err != nil {
+			logger.Fatalf("Failed to open alert queue: %v", err)
 		}
-		alertManager = alerts.NewAlertManager(auditLogger, webhooks)
+		defer alertQueue.Close()
+		queueCfg.Store = alertQueue
+		logger.Printf("Alert queue: %s", queuePath)
+	}
+	alertManager.ConfigureQueue(queueCfg)
+	defer alertManager.Close()
+	if len(webhooks) > 0 {
 		logger.Printf("Alerting enabled: %d webhook(s)", len(webhooks))
-	} else {
-		alertManager = alerts.NewAlertManager(auditLogger, nil)
 	}
 
 	// --- Dashboard Auth Token ---
@@ -219,12 +422,43 @@ func main() {
 		compliance.WithEnforcementMode(*enforcementMode),
 	)
 
-	// Build compliance sections from live checks
 	checker := compliance.NewChecker()
-	checker.AddSection(liveChecker.RunTunnelChecks())
-	checker.AddSection(liveChecker.RunLocalServiceChecks())
-	checker.AddSection(liveChecker.RunBuildSupplyChainChecks())
-	checker.AddSection(liveChecker.RunSecurityOpsChecks())
+	handler := dashboard.NewHandler(*manifestPath, checker)
+	handler.AuditLogger = auditLogger
+	handler.AuditIndex = auditIndex
+	handler.AlertManager = alertManager
+
+	// Prometheus metrics, served at /metrics
+	dashMetrics := dashboard.NewMetrics(dashboard.MetricsConfig{
+		Checker:      checker,
+		Handler:      handler,
+		AuditLogger:  auditLogger,
+		AlertManager: alertManager,
+	})
+
+	// Build compliance sections from live checks
+	checker.AddSection(dashMetrics.RunSection(liveChecker.RunTunnelChecks))
+	checker.AddSection(dashMetrics.RunSection(liveChecker.RunLocalServiceChecks))
+	checker.AddSection(dashMetrics.RunSection(liveChecker.RunBuildSupplyChainChecks))
+	checker.AddSection(dashMetrics.RunSection(liveChecker.RunSecurityOpsChecks))
+
+	// Host remediation: tighten secret file and audit log permissions
+	permRemediator, err := remediate.NewPermRemediator(remediate.PermRemediatorConfig{
+		SecretFiles:  liveChecker.SecretFiles,
+		AuditLogPath: liveChecker.AuditLogPath,
+		Owner:        *secretsOwner,
+		// Re-running the checks also refreshes the dashboard's section.
+		Check: func() compliance.Section {
+			section := dashMetrics.RunSection(liveChecker.RunSecurityOpsChecks)
+			checker.ReplaceSection(section)
+			return section
+		},
+		Logger: logger,
+	})
+	if err != nil {
+		logger.Fatalf("Invalid --secrets-owner: %v", err)
+	}
+	handler.PermRemediator = permRemediator
 
 	// Cloudflare API integration (if token provided)
 	token := envOrFlag(*cfToken, "CF_API_TOKEN")
@@ -232,11 +466,23 @@ func main() {
 	accountID := envOrFlag(*cfAccountID, "CF_ACCOUNT_ID")
 	tunnelID := envOrFlag(*cfTunnelID, "CF_TUNNEL_ID")
 
+	var edgeRemediator *remediate.EdgeRemediator
 	if token != "" && zoneID != "" {
 		logger.Printf("Cloudflare API integration enabled (zone: %s)", zoneID)
 		cfClient := cfapi.NewClient(token)
 		cfChecker := cfapi.NewComplianceChecker(cfClient, zoneID, accountID, tunnelID)
-		checker.AddSection(cfChecker.RunEdgeChecks())
+		checker.AddSection(dashMetrics.RunSection(cfChecker.RunEdgeChecks))
+		edgeRemediator = remediate.NewEdgeRemediator(remediate.EdgeRemediatorConfig{
+			Client: cfClient,
+			ZoneID: zoneID,
+			// Re-running the checks also refreshes the dashboard's edge section.
+			Check: func() compliance.Section {
+				section := dashMetrics.RunSection(cfChecker.RunEdgeChecks)
+				checker.ReplaceSection(section)
+				return section
+			},
+			Logger: logger,
+		})
 	} else {
 		logger.Printf("Cloudflare API integration disabled (set --cf-api-token and --cf-zone-id to enable)")
 	}
@@ -247,21 +493,18 @@ func main() {
 
 	// Add client posture section from TLS inspection + device reports
 	clientChecker := clientdetect.NewComplianceChecker(inspector, postureCollector)
-	checker.AddSection(clientChecker.RunClientPostureChecks())
+	checker.AddSection(dashMetrics.RunSection(clientChecker.RunClientPostureChecks))
 
 	// Gateway proxy stats (fetches client TLS inspection data from fips-proxy)
 	if *proxyAddr != "" {
 		logger.Printf("Gateway proxy stats enabled: fetching from %s", *proxyAddr)
 		proxyChecker := compliance.NewProxyStatsChecker(*proxyAddr)
-		checker.AddSection(proxyChecker.RunGatewayClientChecks())
+		checker.AddSection(dashMetrics.RunSection(proxyChecker.RunGatewayClientChecks))
 	}
 
-	handler := dashboard.NewHandler(*manifestPath, checker)
-	handler.AuditLogger = auditLogger
-	handler.AlertManager = alertManager
-
 	mux := http.NewServeMux()
 	dashboard.RegisterRoutes(mux, handler)
+	mux.Handle("GET /metrics", dashMetrics.Registry())
 
 	mux.HandleFunc("GET /api/v1/clients", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -367,17 +610,48 @@ func main() {
 		}
 		fleetStore = store
 		defer store.Close()
+		alertManager.SetNodeLabels(func(id string) map[string]string {
+			node, err := store.GetNode(context.Background(), id)
+			if err != nil {
+				return nil
+			}
+			return node.Labels
+		})
 
 		adminKey := envOrFlag(*adminAPIKey, "FLEET_ADMIN_KEY")
 		eventCh := make(chan fleet.FleetEvent, 256)
 
-		fleetHandler := dashboard.NewFleetHandler(dashboard.FleetHandlerConfig{
+		approval, err := parseApprovalConfig(*remediationApprovals, envOrFlag(*remediationApprovers, "REMEDIATION_APPROVERS"), *remediationApprovalTTL)
+		if err != nil {
+			logger.Fatalf("Invalid remediation approval config: %v", err)
+		}
+
+		// Reboots that complete needs_reboot remediation, scheduled inside
+		// maintenance windows. Routability follows the handler's policy.
+		var fleetHandler *dashboard.FleetHandler
+		rebooter := fleet.NewRebootCoordinator(fleet.RebootCoordinatorConfig{
+			Store:             store,
+			MaxConcurrent:     *rebootMaxConcurrent,
+			MinHealthyServers: *rebootMinHealthy,
+			Routable:          func(n fleet.Node) bool { return fleetHandler.Routable(n) },
+			Logger:            logger,
+			EventCh:           eventCh,
+		})
+
+		fleetHandler = dashboard.NewFleetHandler(dashboard.FleetHandlerConfig{
 			Store:    store,
 			AdminKey: adminKey,
 			Logger:   logger,
 			EventCh:  eventCh,
+
+			EdgeRemediator: edgeRemediator,
+			AuditLogger:    auditLogger,
+			Approval:       approval,
+			Reboots:        rebooter,
+			SiteURLs:       strings.Split(envOrFlag(*federationSiteURLs, "FEDERATION_SITE_URLS"), ","),
 		})
 		dashboard.RegisterFleetRoutes(mux, fleetHandler)
+		dashMetrics.AttachFleet(fleetHandler)
 
 		// Start fleet event broadcaster
 		go fleetHandler.BroadcastEvents(ctx.Done())
@@ -389,8 +663,39 @@ func main() {
 			EventCh: eventCh,
 		})
 		go monitor.Run(ctx)
+		go rebooter.Run(ctx)
+		go fleetHandler.RunApprovalExpiry(ctx, time.Minute)
 
 		logger.Printf("Fleet controller ready: %d API endpoints registered", 12)
+
+		// Federation: push this site's fleet state to a parent controller
+		if pURL := envOrFlag(*parentURL, "PARENT_CONTROLLER_URL"); pURL != "" {
+			name := *siteName
+			if name == "" {
+				name = *nodeName
+			}
+			if name == "" {
+				name, _ = os.Hostname()
+			}
+			state, err := loadParentState(ctx, pURL, envOrFlag(*parentEnrollToken, "PARENT_ENROLLMENT_TOKEN"), name, *nodeRegion, *parentStateFile)
+			if err != nil {
+				logger.Printf("Fleet federation disabled: %v", err)
+			} else {
+				federator := fleet.NewFederator(fleet.FederatorConfig{
+					ParentURL: state.ControllerURL,
+					APIKey:    state.APIKey,
+					SiteName:  name,
+					Store:     store,
+					Logger:    logger,
+					APIURL:    *siteAPIURL,
+					APIToken:  envOrFlag(*siteAPIToken, "SITE_API_TOKEN"),
+				})
+				go federator.Run(ctx)
+				logger.Printf("Fleet federation active → %s (site: %s)", state.ControllerURL, state.NodeID)
+			}
+		}
+	} else if envOrFlag(*parentURL, "PARENT_CONTROLLER_URL") != "" {
+		logger.Printf("Fleet federation disabled: --parent-url requires --fleet-mode")
 	}
 
 	// Fleet reporter mode: push compliance reports to a controller
@@ -484,6 +789,59 @@ func main() {
 	logger.Printf("Server stopped gracefully")
 }
 
+// loadParentState returns this controller's credentials at its parent,
+// enrolling with the controller-role token on first start.
+func loadParentState(ctx context.Context, parentURL, token, name, region, stateFile string) (*fleet.AgentState, error) {
+	state, err := fleet.LoadAgentState(stateFile)
+	if err == nil {
+		state.ControllerURL = parentURL
+		return state, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("not enrolled with parent; --parent-enroll-token required")
+	}
+
+	state, err = fleet.RequestEnrollment(ctx, nil, parentURL, fleet.EnrollmentRequest{
+		Token:       token,
+		Name:        name,
+		Region:      region,
+		Version:     buildinfo.Version,
+		FIPSBackend: fipsbackend.DetectInfo().Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if state.Role != fleet.RoleController {
+		return nil, fmt.Errorf("parent enrolled this controller as %q; a controller-role token is required", state.Role)
+	}
+	if err := fleet.SaveAgentState(stateFile, state); err != nil {
+		return nil, fmt.Errorf("save parent state: %w", err)
+	}
+	return state, nil
+}
+
+// pruneAuditIndex drops indexed audit events older than retention, daily,
+// to match archive retention.
+func pruneAuditIndex(ctx context.Context, ix *auditdb.Index, retention time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := ix.Prune(time.Now().Add(-retention)); err != nil {
+			logger.Printf("Warning: audit index prune: %v", err)
+		} else if n > 0 {
+			logger.Printf("Audit index: pruned %d events past retention", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // envOrFlag returns the flag value if non-empty, otherwise the environment variable.
 func envOrFlag(flagVal, envKey string) string {
 	if flagVal != "" {
@@ -492,6 +850,40 @@ func envOrFlag(flagVal, envKey string) string {
 	return os.Getenv(envKey)
 }
 
+// parseApprovalConfig builds the remediation approval config from flags.
+// REMEDIATION_APPROVALS overrides a zero flag value. It returns nil when
+// approval is disabled.
+func parseApprovalConfig(required int, approvers string, ttl time.Duration) (*dashboard.ApprovalConfig, error) {
+	if required == 0 {
+		if v := os.Getenv("REMEDIATION_APPROVALS"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("REMEDIATION_APPROVALS: %w", err)
+			}
+			required = n
+		}
+	}
+	if required <= 0 {
+		return nil, nil
+	}
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(approvers, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("approver %q: want name:key", entry)
+		}
+		keys[name] = key
+	}
+	if len(keys) < required {
+		return nil, fmt.Errorf("%d approvals required but only %d approvers configured", required, len(keys))
+	}
+	return &dashboard.ApprovalConfig{Required: required, TTL: ttl, Approvers: keys}, nil
+}
+
 // runSetupTunnel is a one-shot mode that creates a Cloudflare Tunnel (if needed),
 // sets up a DNS CNAME record, and configures tunnel ingress, then exits.
 // Called by the provision script. When no tunnel ID is provided, it auto-creates
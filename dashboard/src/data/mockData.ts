@@ -646,6 +646,17 @@ export const mockSections: ChecklistSection[] = [
         remediation: 'Set enforcement_mode: enforce in config or --enforcement-mode enforce on CLI',
         nistRef: 'PL-1',
       },
+      {
+        id: 'so-14',
+        name: 'Audit Pipeline Overflow',
+        status: 'pass',
+        severity: 'medium',
+        verificationMethod: 'direct',
+        what: 'Async audit queue (0/4096); callers wait when full, so no event is dropped; fsync: interval',
+        why: 'AU-5 requires a defined response to audit logging process failures. Dropping events under load leaves gaps in the audit trail.',
+        remediation: 'Run the dashboard with --audit-overflow block, or raise --audit-queue-size if dropping is required to protect latency',
+        nistRef: 'AU-5',
+      },
     ],
   },
 ]
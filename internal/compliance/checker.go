@@ -1,9 +1,14 @@
 package compliance
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Checker aggregates compliance state from multiple sources.
+// It is safe for concurrent use.
 type Checker struct {
+	mu       sync.RWMutex
 	sections []Section
 }
 
@@ -14,14 +19,34 @@ func NewChecker() *Checker {
 
 // AddSection adds a compliance section to the checker.
 func (c *Checker) AddSection(section Section) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sections = append(c.sections, section)
+}
+
+// ReplaceSection swaps in a fresh copy of the section with the same ID,
+// or appends it if no such section exists. Used by callers that re-run
+// their checks periodically.
+func (c *Checker) ReplaceSection(section Section) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.sections {
+		if c.sections[i].ID == section.ID {
+			c.sections[i] = section
+			return
+		}
+	}
 	c.sections = append(c.sections, section)
 }
 
 // GenerateReport produces a compliance report from all registered sections.
 func (c *Checker) GenerateReport() *ComplianceReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	report := &ComplianceReport{
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Sections:  c.sections,
+		Sections:  append([]Section(nil), c.sections...),
 	}
 
 	for _, section := range c.sections {
@@ -45,6 +70,9 @@ func (c *Checker) GenerateReport() *ComplianceReport {
 
 // OverallStatus returns the worst-case status across all items.
 func (c *Checker) OverallStatus() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	worst := StatusPass
 	for _, section := range c.sections {
 		for _, item := range section.Items {
@@ -396,3 +396,26 @@ func TestChecklistItemFieldsPreserved(t *testing.T) {
 		t.Errorf("NISTRef = %q, want %q", got.NISTRef, item.NISTRef)
 	}
 }
+
+func TestReplaceSection(t *testing.T) {
+	c := NewChecker()
+	c.AddSection(makeSection("a", "A", makeItem("a-1", StatusPass)))
+	c.AddSection(makeSection("b", "B", makeItem("b-1", StatusPass)))
+
+	c.ReplaceSection(makeSection("a", "A", makeItem("a-1", StatusFail)))
+	report := c.GenerateReport()
+	if len(report.Sections) != 2 {
+		t.Fatalf("expected 2 sections after replace, got %d", len(report.Sections))
+	}
+	if report.Sections[0].ID != "a" || report.Sections[0].Items[0].Status != StatusFail {
+		t.Errorf("section a not replaced in place: %+v", report.Sections[0])
+	}
+	if report.Summary.Failed != 1 || report.Summary.Passed != 1 {
+		t.Errorf("summary = %+v, want 1 passed 1 failed", report.Summary)
+	}
+
+	c.ReplaceSection(makeSection("c", "C", makeItem("c-1", StatusWarning)))
+	if got := len(c.GenerateReport().Sections); got != 3 {
+		t.Errorf("expected unknown section to be appended, got %d sections", got)
+	}
+}
@@ -1032,7 +1032,7 @@ func (lc *LiveChecker) checkFIPSModuleSunset() ChecklistItem {
 // --- Security Operations checks (Part 4) ---
 
 // RunSecurityOpsChecks produces the "Security Operations" compliance section
-// with 13 checks covering audit logging, authentication, alerting, credential
+// with 14 checks covering audit logging, authentication, alerting, credential
 // lifecycle, and security policy enforcement.
 func (lc *LiveChecker) RunSecurityOpsChecks() Section {
 	lc.lastScanTime = time.Now()
@@ -1056,6 +1056,7 @@ func (lc *LiveChecker) RunSecurityOpsChecks() Section {
 	section.Items = append(section.Items, lc.checkAuditLogIntegrity())
 	section.Items = append(section.Items, lc.checkComplianceScanRecent())
 	section.Items = append(section.Items, lc.checkSecurityPolicyEnforced())
+	section.Items = append(section.Items, lc.checkAuditPipeline())
 
 	return section
 }
@@ -1154,6 +1155,11 @@ func (lc *LiveChecker) checkAlertingConfigured() ChecklistItem {
 	if lc.alertManager != nil && lc.alertManager.Configured() {
 		item.Status = StatusPass
 		item.What = fmt.Sprintf("Alerting active: %d webhook(s) configured", lc.alertManager.WebhookCount())
+		if counts, err := lc.alertManager.DeliveryCounts(); err == nil && counts[alerts.DeliveryDead] > 0 {
+			item.Status = StatusWarning
+			item.What += fmt.Sprintf("; %d alert delivery(ies) dead-lettered", counts[alerts.DeliveryDead])
+			item.Remediation = "Fix the failing webhook, then replay dead-lettered alerts: GET /api/v1/alerts/deliveries?state=dead, POST /api/v1/alerts/deliveries/{id}/replay"
+		}
 	} else {
 		item.Status = StatusWarning
 		item.What = "No webhooks configured (alerts logged only, no external notification)"
@@ -1457,10 +1463,10 @@ func (lc *LiveChecker) checkLogForwarding() ChecklistItem {
 		Name:               "Log Forwarding (SIEM)",
 		Severity:           "high",
 		VerificationMethod: VerifyDirect,
-		What:               "Checks whether audit logs are forwarded to an external SIEM via syslog",
-		Why:                "AU-4/AU-9 require audit log storage with integrity protection. Forwarding to a SIEM prevents local log tampering.",
-		Remediation:        "Set --syslog-addr to forward audit events (e.g., --syslog-addr tcp://siem:514)",
-		NISTRef:            "AU-4, AU-9",
+		What:               "Checks whether audit logs are forwarded to an external SIEM (syslog or HTTP collector) over TLS",
+		Why:                "AU-4/AU-9 require audit log storage with integrity protection. Forwarding to a SIEM prevents local log tampering; SC-8 requires the records to be protected in transit.",
+		Remediation:        "Set --syslog-addr or --audit-http-url to forward audit events over TLS (e.g., --syslog-addr tls://siem:6514 --syslog-ca /etc/pki/siem-ca.pem)",
+		NISTRef:            "AU-4, AU-9, SC-8",
 	}
 
 	if lc.auditLogger == nil {
@@ -1469,9 +1475,16 @@ func (lc *LiveChecker) checkLogForwarding() ChecklistItem {
 		return item
 	}
 
-	if lc.auditLogger.SyslogActive() {
+	if lc.auditLogger.ForwardingEncrypted() {
 		item.Status = StatusPass
-		item.What = "Audit events forwarded to syslog (SIEM integration active)"
+		item.What = "Audit events forwarded over TLS (SIEM integration active)"
+		if n := lc.auditLogger.ForwardingDropped(); n > 0 {
+			item.Status = StatusWarning
+			item.What = fmt.Sprintf("Audit events forwarded over TLS, but %d events were dropped while the collector was unreachable", n)
+		}
+	} else if lc.auditLogger.ForwardingActive() {
+		item.Status = StatusWarning
+		item.What = "Audit events forwarded in cleartext (SC-8); use a tls:// syslog address or an https:// collector"
 	} else {
 		item.Status = StatusWarning
 		item.What = "Audit log active but no syslog forwarding (local log only)"
@@ -1486,9 +1499,9 @@ func (lc *LiveChecker) checkAuditLogIntegrity() ChecklistItem {
 		Name:               "Audit Log Integrity",
 		Severity:           "high",
 		VerificationMethod: VerifyDirect,
-		What:               "Checks audit log file permissions to prevent unauthorized modification",
-		Why:                "AU-9 requires protection of audit information. World-readable or writable logs can be tampered with.",
-		Remediation:        "chmod 0640 on audit log files; chown to cloudflared-fips:cloudflared-fips",
+		What:               "Checks audit log file permissions and verifies the audit log hash chain",
+		Why:                "AU-9 requires protection of audit information. World-readable or writable logs can be tampered with, and permissions alone do not stop root from editing entries.",
+		Remediation:        "chmod 0640 on audit log files; chown to cloudflared-fips:cloudflared-fips; enable hash chaining with --audit-chain-key and investigate any chain break with 'cloudflared-fips audit verify'",
 		NISTRef:            "AU-9",
 	}
 
@@ -1523,6 +1536,22 @@ func (lc *LiveChecker) checkAuditLogIntegrity() ChecklistItem {
 		item.Status = StatusPass
 		item.What = fmt.Sprintf("Audit log permissions: %04o", perm)
 	}
+
+	if !lc.auditLogger.ChainActive() {
+		item.What += "; log is not hash-chained"
+		return item
+	}
+	report, err := lc.auditLogger.VerifyChain()
+	switch {
+	case err != nil:
+		item.Status = StatusFail
+		item.What = fmt.Sprintf("Cannot verify audit log hash chain: %v", err)
+	case !report.OK():
+		item.Status = StatusFail
+		item.What = fmt.Sprintf("Audit log hash chain broken (%d problems): %s", len(report.Problems), report.Problems[0])
+	default:
+		item.What += fmt.Sprintf("; hash chain verified through seq %d", report.LastSeq)
+	}
 	return item
 }
 
@@ -1594,8 +1623,61 @@ func (lc *LiveChecker) checkSecurityPolicyEnforced() ChecklistItem {
 	return item
 }
 
+// so-14: Audit Pipeline Overflow
+func (lc *LiveChecker) checkAuditPipeline() ChecklistItem {
+	item := ChecklistItem{
+		ID:                 "so-14",
+		Name:               "Audit Pipeline Overflow",
+		Severity:           "medium",
+		VerificationMethod: VerifyDirect,
+		What:               "Checks what the audit pipeline does when its queue is full, and whether events have been dropped",
+		Why:                "AU-5 requires a defined response to audit logging process failures. Dropping events under load leaves gaps in the audit trail.",
+		Remediation:        "Run the dashboard with --audit-overflow block, or raise --audit-queue-size if dropping is required to protect latency",
+		NISTRef:            "AU-5",
+	}
+
+	if lc.auditLogger == nil {
+		item.Status = StatusWarning
+		item.What = "No audit logger configured"
+		return item
+	}
+
+	st := lc.auditLogger.PipelineStats()
+	switch {
+	case !st.Async:
+		item.Status = StatusPass
+		item.What = "Audit events written synchronously; none are dropped"
+	case st.Overflow == audit.OverflowBlock:
+		item.Status = StatusPass
+		item.What = fmt.Sprintf("Async audit queue (%d/%d); callers wait when full, so no event is dropped", st.Queued, st.QueueSize)
+	case st.Dropped > 0:
+		item.Status = StatusFail
+		item.What = fmt.Sprintf("%d audit events dropped because the async queue (%d) was full", st.Dropped, st.QueueSize)
+	default:
+		item.Status = StatusWarning
+		item.What = fmt.Sprintf("Async audit queue (%d/%d) drops events when full; none dropped so far", st.Queued, st.QueueSize)
+	}
+	item.What += fmt.Sprintf("; fsync: %s", st.Fsync)
+	return item
+}
+
 // --- Helpers for Security Operations checks ---
 
+// SecretFiles returns the secret files checked by so-5: the default
+// secret locations that exist plus matching files under --secrets-paths.
+func (lc *LiveChecker) SecretFiles() []string {
+	return lc.collectSecretFiles()
+}
+
+// AuditLogPath returns the audit log file checked by so-11, or "" when no
+// file-backed audit logger is configured.
+func (lc *LiveChecker) AuditLogPath() string {
+	if lc.auditLogger == nil {
+		return ""
+	}
+	return lc.auditLogger.FilePath()
+}
+
 // collectSecretFiles returns paths to files that likely contain secrets.
 func (lc *LiveChecker) collectSecretFiles() []string {
 	var files []string
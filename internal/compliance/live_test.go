@@ -14,6 +14,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/manifest"
 )
 
@@ -371,6 +372,92 @@ func TestCheckConfigDrift_ConfigPresent(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// checkLogForwarding
+// ---------------------------------------------------------------------------
+
+func TestCheckLogForwarding_Cleartext(t *testing.T) {
+	al, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"),
+		audit.WithRemoteSyslog(audit.SyslogConfig{Network: "udp", Addr: "127.0.0.1:5514"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	item := NewLiveChecker(WithAuditLogger(al)).checkLogForwarding()
+	if item.Status != StatusWarning || !strings.Contains(item.What, "cleartext") {
+		t.Errorf("udp syslog: got %s %q", item.Status, item.What)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// checkAuditPipeline
+// ---------------------------------------------------------------------------
+
+func TestCheckAuditPipeline(t *testing.T) {
+	al, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"),
+		audit.WithAsync(audit.AsyncConfig{Overflow: audit.OverflowDrop}),
+		audit.WithFsync(audit.FsyncInterval, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	item := NewLiveChecker(WithAuditLogger(al)).checkAuditPipeline()
+	if item.Status != StatusWarning || !strings.Contains(item.What, "drops events") || !strings.HasSuffix(item.What, "fsync: interval") {
+		t.Errorf("drop policy: got %s %q", item.Status, item.What)
+	}
+
+	al2, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"), audit.WithAsync(audit.AsyncConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al2.Close()
+	if item := NewLiveChecker(WithAuditLogger(al2)).checkAuditPipeline(); item.Status != StatusPass {
+		t.Errorf("block policy: got %s %q", item.Status, item.What)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// checkAuditLogIntegrity
+// ---------------------------------------------------------------------------
+
+func TestCheckAuditLogIntegrity_HashChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	key := []byte(strings.Repeat("k", 32))
+	al, err := audit.NewAuditLogger(path, audit.WithHashChain(audit.ChainConfig{Key: key}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	for _, action := range []string{"started", "login_success", "stopped"} {
+		al.Log(audit.AuditEvent{EventType: "system_event", Severity: "info", Actor: "system", Action: action})
+	}
+
+	item := NewLiveChecker(WithAuditLogger(al)).checkAuditLogIntegrity()
+	if item.Status != StatusPass || !strings.Contains(item.What, "hash chain verified through seq 3") {
+		t.Fatalf("intact chain: got %s %q", item.Status, item.What)
+	}
+
+	// Drop the middle entry, as an attacker with root might. A fresh
+	// logger is used since chain verification results are cached.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(path, []byte(lines[0]+lines[2]), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := audit.NewAuditLogger(path, audit.WithHashChain(audit.ChainConfig{Key: key}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tampered.Close()
+	item = NewLiveChecker(WithAuditLogger(tampered)).checkAuditLogIntegrity()
+	if item.Status != StatusFail || !strings.Contains(item.What, "seq jumps from 1 to 3") {
+		t.Errorf("tampered chain: got %s %q", item.Status, item.What)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // checkBinaryIntegrity
 // ---------------------------------------------------------------------------
@@ -61,8 +61,8 @@ func (am *AuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Static assets never require auth
-	if !strings.HasPrefix(r.URL.Path, "/api/v1/") {
+	// Static assets never require auth; /metrics does
+	if !strings.HasPrefix(r.URL.Path, "/api/v1/") && r.URL.Path != "/metrics" {
 		am.next.ServeHTTP(w, r)
 		return
 	}
@@ -140,6 +140,28 @@ func TestAuthMiddleware_StaticAssetsPublic(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware_MetricsRequiresToken(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := NewAuthMiddleware(AuthConfig{Token: "secret123"}, inner)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated /metrics = %d, want 401", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret123")
+	w = httptest.NewRecorder()
+	mw.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("authenticated /metrics = %d, want 200", w.Code)
+	}
+}
+
 func TestAuthMiddleware_Lockout(t *testing.T) {
 	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
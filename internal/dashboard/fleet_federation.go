@@ -0,0 +1,135 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+)
+
+// maxSiteResponse caps the size of a proxied drill-down response.
+const maxSiteResponse = 10 << 20
+
+// HandleFederationSync receives a rolled-up summary and node deltas from a
+// child controller (API key auth, controller role only).
+func (fh *FleetHandler) HandleFederationSync(w http.ResponseWriter, r *http.Request) {
+	node, ok := fh.authenticateNode(w, r)
+	if !ok {
+		return
+	}
+	if node.Role != fleet.RoleController {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "only controller nodes may federate"})
+		return
+	}
+
+	var sync fleet.FederationSync
+	if err := json.NewDecoder(r.Body).Decode(&sync); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	existing, siteErr := fh.store.GetSite(r.Context(), node.ID)
+	if sync.APIURL != "" {
+		u, err := url.Parse(sync.APIURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "api_url must be an absolute http(s) URL"})
+			return
+		}
+		// The parent sends the site's token to api_url and relays the
+		// response, so only URLs configured on the parent are accepted,
+		// and a site cannot move to another one.
+		sync.APIURL = strings.TrimRight(sync.APIURL, "/")
+		if !fh.siteURLs[sync.APIURL] {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "api_url is not an allowed site URL on the parent (--federation-site-urls)"})
+			return
+		}
+		if siteErr == nil && existing.APIURL != "" && existing.APIURL != sync.APIURL {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "api_url differs from the site's registered URL"})
+			return
+		}
+	}
+	if sync.SiteName == "" {
+		sync.SiteName = node.Name
+	}
+
+	// A delta from a site we hold no baseline for cannot be applied
+	// faithfully; record it but ask for a full sync.
+	resync := !sync.Full && siteErr != nil
+
+	if err := fh.store.ApplyFederationSync(r.Context(), node.ID, &sync); err != nil {
+		fh.logger.Printf("fleet: federation sync from %s failed: %v", node.ID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to apply sync"})
+		return
+	}
+	// The sync doubles as the child controller's heartbeat.
+	_ = fh.store.UpdateNodeHeartbeat(r.Context(), node.ID, time.Now().UTC())
+
+	writeJSON(w, http.StatusOK, fleet.FederationSyncResponse{Status: "ok", Resync: resync})
+}
+
+// HandleListSites returns the child controllers federated into this one.
+func (fh *FleetHandler) HandleListSites(w http.ResponseWriter, r *http.Request) {
+	sites, err := fh.store.ListSites(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list sites"})
+		return
+	}
+	if sites == nil {
+		sites = []fleet.Site{}
+	}
+	writeJSON(w, http.StatusOK, sites)
+}
+
+// HandleGetSite returns a single federated child controller.
+func (fh *FleetHandler) HandleGetSite(w http.ResponseWriter, r *http.Request) {
+	site, err := fh.store.GetSite(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "site not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, site)
+}
+
+// proxyToSite forwards a read-only drill-down request to the child
+// controller that owns a federated node.
+func (fh *FleetHandler) proxyToSite(w http.ResponseWriter, r *http.Request, siteID, path string) {
+	site, err := fh.store.GetSite(r.Context(), siteID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "site not found"})
+		return
+	}
+	if site.APIURL == "" {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "site does not expose drill-down"})
+		return
+	}
+	if !fh.siteURLs[site.APIURL] {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "site URL is not allowed for drill-down"})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), "GET", site.APIURL+path, nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "invalid site URL"})
+		return
+	}
+	if site.APIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+site.APIToken)
+	}
+	resp, err := fh.siteClient.Do(req)
+	if err != nil {
+		fh.logger.Printf("fleet: drill-down to site %s failed: %v", siteID, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "site unreachable"})
+		return
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("X-Fleet-Site", siteID)
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, io.LimitReader(resp.Body, maxSiteResponse))
+}
@@ -1,16 +1,25 @@
 package dashboard
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
 )
 
 // FleetHandler serves the fleet management API endpoints.
@@ -23,6 +32,13 @@ type FleetHandler struct {
 	sseClients map[chan fleet.FleetEvent]struct{}
 	sseMu      sync.Mutex
 	policy     *fleet.CompliancePolicy
+	siteClient *http.Client // drill-down requests to child controllers
+	siteURLs   map[string]bool
+	metrics    *Metrics     // set by Metrics.AttachFleet
+	edge       *remediate.EdgeRemediator
+	audit      *audit.AuditLogger
+	approval   *ApprovalConfig
+	reboots    *fleet.RebootCoordinator
 }
 
 // FleetHandlerConfig holds configuration for the fleet handler.
@@ -32,8 +48,42 @@ type FleetHandlerConfig struct {
 	Logger   *log.Logger
 	EventCh  chan fleet.FleetEvent
 	Policy   *fleet.CompliancePolicy // Compliance enforcement policy
+	// EdgeRemediator applies edge (Cloudflare zone) remediation from the
+	// controller. Nil disables the edge remediation endpoints.
+	EdgeRemediator *remediate.EdgeRemediator
+	// AuditLogger records token, enrollment, node, policy, and
+	// remediation operations (AU-2). Optional.
+	AuditLogger *audit.AuditLogger
+	// Approval enables two-person approval of node and edge remediation.
+	// Nil releases requests to agents, or applies edge requests, at once.
+	Approval *ApprovalConfig
+	// Reboots schedules the reboots that needs_reboot actions reported by
+	// agents are waiting on. Nil leaves those nodes for manual reboot.
+	Reboots *fleet.RebootCoordinator
+	// SiteURLs are the base URLs child controllers may register for
+	// drill-down. The parent only proxies to these, so a controller key
+	// cannot point it at another host. Empty disables drill-down.
+	SiteURLs []string
 }
 
+// ApprovalConfig configures two-person approval of node and edge
+// remediation and rollback requests. Dry runs are released without approval.
+type ApprovalConfig struct {
+	// Required is the number of distinct approvers, other than the
+	// requester, needed before agents can see a request.
+	Required int
+	// TTL is how long a request may await approval (default 24h).
+	TTL time.Duration
+	// Approvers maps approver names to their API keys. An approver
+	// authenticates with their own key to request, list, and approve
+	// remediation; approvals made with the admin key are rejected.
+	Approvers map[string]string
+}
+
+// DefaultApprovalTTL is how long a remediation request awaits approval when
+// ApprovalConfig.TTL is unset.
+const DefaultApprovalTTL = 24 * time.Hour
+
 // NewFleetHandler creates a new fleet handler.
 func NewFleetHandler(cfg FleetHandlerConfig) *FleetHandler {
 	if cfg.Logger == nil {
@@ -43,6 +93,12 @@ func NewFleetHandler(cfg FleetHandlerConfig) *FleetHandler {
 	if policy == nil {
 		policy = &fleet.CompliancePolicy{EnforcementMode: "audit"}
 	}
+	siteURLs := make(map[string]bool, len(cfg.SiteURLs))
+	for _, u := range cfg.SiteURLs {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			siteURLs[u] = true
+		}
+	}
 	return &FleetHandler{
 		store:      cfg.Store,
 		enrollment: fleet.NewEnrollment(cfg.Store),
@@ -51,6 +107,12 @@ func NewFleetHandler(cfg FleetHandlerConfig) *FleetHandler {
 		eventCh:    cfg.EventCh,
 		sseClients: make(map[chan fleet.FleetEvent]struct{}),
 		policy:     policy,
+		siteClient: &http.Client{Timeout: 15 * time.Second},
+		siteURLs:   siteURLs,
+		edge:       cfg.EdgeRemediator,
+		audit:      cfg.AuditLogger,
+		approval:   cfg.Approval,
+		reboots:    cfg.Reboots,
 	}
 }
 
@@ -68,6 +130,7 @@ func RegisterFleetRoutes(mux *http.ServeMux, fh *FleetHandler) {
 	mux.HandleFunc("GET /api/v1/fleet/summary", fh.HandleSummary)
 	mux.HandleFunc("GET /api/v1/fleet/events", fh.HandleFleetSSE)
 	mux.HandleFunc("GET /api/v1/fleet/nodes/{id}/report", fh.HandleGetNodeReport)
+	mux.HandleFunc("GET /api/v1/fleet/nodes/{id}/history", fh.HandleGetNodeHistory)
 	mux.HandleFunc("GET /api/v1/fleet/policy", fh.HandleGetPolicy)
 	mux.HandleFunc("PUT /api/v1/fleet/policy", fh.HandleUpdatePolicy)
 	mux.HandleFunc("GET /api/v1/fleet/routes", fh.HandleGetRoutes)
@@ -75,7 +138,24 @@ func RegisterFleetRoutes(mux *http.ServeMux, fh *FleetHandler) {
 	mux.HandleFunc("POST /api/v1/fleet/nodes/{id}/remediate", fh.HandleRequestRemediation)
 	mux.HandleFunc("GET /api/v1/fleet/nodes/{id}/remediate", fh.HandlePollRemediations)
 	mux.HandleFunc("POST /api/v1/fleet/nodes/{id}/remediate/result", fh.HandlePostRemediationResult)
+	mux.HandleFunc("POST /api/v1/fleet/nodes/{id}/remediate/{reqID}/rollback", fh.HandleRequestRollback)
+	mux.HandleFunc("POST /api/v1/fleet/nodes/{id}/remediate/{reqID}/approve", fh.HandleApproveRemediation)
+	mux.HandleFunc("GET /api/v1/fleet/remediate/requests", fh.HandleListRemediations)
 	mux.HandleFunc("GET /api/v1/fleet/remediate/plan/{id}", fh.HandleGetRemediationPlan)
+	mux.HandleFunc("GET /api/v1/fleet/remediate/edge/plan", fh.HandleGetEdgeRemediationPlan)
+	mux.HandleFunc("POST /api/v1/fleet/remediate/edge", fh.HandleEdgeRemediation)
+	mux.HandleFunc("POST /api/v1/fleet/remediate/edge/{reqID}/rollback", fh.HandleEdgeRollback)
+	mux.HandleFunc("POST /api/v1/fleet/remediate/edge/{reqID}/approve", fh.HandleApproveEdgeRemediation)
+	mux.HandleFunc("GET /api/v1/fleet/remediate/edge/requests", fh.HandleListEdgeRemediations)
+	// Reboot coordination
+	mux.HandleFunc("GET /api/v1/fleet/maintenance-windows", fh.HandleListMaintenanceWindows)
+	mux.HandleFunc("POST /api/v1/fleet/maintenance-windows", fh.HandleCreateMaintenanceWindow)
+	mux.HandleFunc("DELETE /api/v1/fleet/maintenance-windows/{id}", fh.HandleDeleteMaintenanceWindow)
+	mux.HandleFunc("GET /api/v1/fleet/reboots", fh.HandleListReboots)
+	// Federation endpoints (child controllers)
+	mux.HandleFunc("POST /api/v1/fleet/federation/sync", fh.HandleFederationSync)
+	mux.HandleFunc("GET /api/v1/fleet/sites", fh.HandleListSites)
+	mux.HandleFunc("GET /api/v1/fleet/sites/{id}", fh.HandleGetSite)
 }
 
 // BroadcastEvents fans out fleet events from the event channel to all SSE clients.
@@ -119,6 +199,18 @@ func (fh *FleetHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	meta := *token
+	meta.Token = "" // never log the secret
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "credential_lifecycle",
+		Severity:  "info",
+		Actor:     fh.adminActor(),
+		Resource:  "token:" + token.ID,
+		Action:    "token_created",
+		Detail:    fmt.Sprintf("enrollment token %s created for role %s (max uses %d)", token.ID, token.Role, token.MaxUses),
+		After:     auditValue(meta),
+		NISTRef:   "IA-5, AC-2",
+	})
 	writeJSON(w, http.StatusCreated, token)
 }
 
@@ -136,6 +228,15 @@ func (fh *FleetHandler) HandleListTokens(w http.ResponseWriter, r *http.Request)
 	if tokens == nil {
 		tokens = []fleet.EnrollmentToken{}
 	}
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "api_access",
+		Severity:  "info",
+		Actor:     fh.adminActor(),
+		Resource:  "tokens",
+		Action:    "accessed",
+		Detail:    fmt.Sprintf("listed %d enrollment tokens", len(tokens)),
+		NISTRef:   "AU-2, AC-6",
+	})
 	writeJSON(w, http.StatusOK, tokens)
 }
 
@@ -151,10 +252,28 @@ func (fh *FleetHandler) HandleDeleteToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	var before string
+	if tokens, err := fh.enrollment.ListTokens(r.Context()); err == nil {
+		for _, t := range tokens {
+			if t.ID == id {
+				before = auditValue(t)
+			}
+		}
+	}
 	if err := fh.enrollment.DeleteToken(r.Context(), id); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to delete token"})
 		return
 	}
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "credential_lifecycle",
+		Severity:  "info",
+		Actor:     fh.adminActor(),
+		Resource:  "token:" + id,
+		Action:    "token_revoked",
+		Detail:    fmt.Sprintf("enrollment token %s deleted", id),
+		Before:    before,
+		NISTRef:   "IA-5, AC-2",
+	})
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -168,6 +287,15 @@ func (fh *FleetHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := fh.enrollment.Enroll(r.Context(), req)
 	if err != nil {
+		fh.auditRequest(r, audit.AuditEvent{
+			EventType: "auth_attempt",
+			Severity:  "warning",
+			Actor:     "api:" + extractIP(r),
+			Resource:  "enrollment",
+			Action:    "enroll_failed",
+			Detail:    fmt.Sprintf("enrollment of %q rejected: %v", req.Name, err),
+			NISTRef:   "IA-3, AC-7",
+		})
 		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
 		return
 	}
@@ -176,6 +304,19 @@ func (fh *FleetHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
 
 	// Emit event
 	node, _ := fh.store.GetNode(r.Context(), resp.NodeID)
+	evt := audit.AuditEvent{
+		EventType: "credential_lifecycle",
+		Severity:  "info",
+		Actor:     "node:" + resp.NodeID,
+		Resource:  "node:" + resp.NodeID,
+		Action:    "node_enrolled",
+		Detail:    fmt.Sprintf("node %s (%s) enrolled as %s; API key issued", resp.NodeID, req.Name, resp.Role),
+		NISTRef:   "IA-3, IA-5, AC-2",
+	}
+	if node != nil {
+		evt.After = auditValue(nodeAuditState(node))
+	}
+	fh.auditRequest(r, evt)
 	if node != nil && fh.eventCh != nil {
 		select {
 		case fh.eventCh <- fleet.FleetEvent{
@@ -198,7 +339,7 @@ func (fh *FleetHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var payload fleet.ComplianceReportPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := decodeFleetBody(r, &payload); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid report"})
 		return
 	}
@@ -209,10 +350,21 @@ func (fh *FleetHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Store report
+	// Reports replayed from the agent's offline spool are history: keep them
+	// under their original timestamp but leave current status alone, since
+	// a newer live report follows the replay.
+	if payload.Replayed {
+		fh.storeReplayedReport(w, r, node.ID, payload)
+		return
+	}
+
+	// Store the report only if its content changed since the last one;
+	// compliance state is still refreshed so policy changes apply.
 	reportJSON, _ := json.Marshal(payload.Report)
-	if err := fh.store.StoreReport(r.Context(), node.ID, reportJSON); err != nil {
+	changed, err := fh.store.StoreReportIfChanged(r.Context(), node.ID, fleet.ReportHash(payload.Report), reportJSON)
+	if err != nil {
 		fh.logger.Printf("fleet: store report error: %v", err)
+		changed = true
 	}
 
 	// Update node compliance counts and heartbeat
@@ -227,6 +379,16 @@ func (fh *FleetHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 	}
 	_ = fh.store.UpdateNodeStatus(r.Context(), node.ID, status)
 
+	// Evaluate compliance against policy
+	fh.evaluateNodeCompliance(r.Context(), node.ID, payload)
+
+	if !changed {
+		fh.metrics.reportIngested("unchanged")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unchanged"})
+		return
+	}
+	fh.metrics.reportIngested("stored")
+
 	// Emit event
 	updated, _ := fh.store.GetNode(r.Context(), node.ID)
 	if updated != nil && fh.eventCh != nil {
@@ -240,12 +402,32 @@ func (fh *FleetHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Evaluate compliance against policy
-	fh.evaluateNodeCompliance(r.Context(), node.ID, payload)
-
 	writeJSON(w, http.StatusOK, map[string]string{"status": "accepted"})
 }
 
+// storeReplayedReport files a spooled report as history.
+func (fh *FleetHandler) storeReplayedReport(w http.ResponseWriter, r *http.Request, nodeID string, payload fleet.ComplianceReportPayload) {
+	ts, err := time.Parse(time.RFC3339, payload.Report.Timestamp)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "replayed report requires an RFC 3339 timestamp"})
+		return
+	}
+	if ts.After(time.Now().UTC().Add(5 * time.Minute)) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "replayed report timestamp is in the future"})
+		return
+	}
+
+	reportJSON, _ := json.Marshal(payload.Report)
+	if err := fh.store.StoreReportAt(r.Context(), nodeID, ts, reportJSON); err != nil {
+		fh.logger.Printf("fleet: store replayed report error: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to store report"})
+		return
+	}
+	fh.metrics.reportIngested("replayed")
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "stored"})
+}
+
 // evaluateNodeCompliance checks a node's report against the current policy
 // and updates its compliance status.
 func (fh *FleetHandler) evaluateNodeCompliance(ctx context.Context, nodeID string, payload fleet.ComplianceReportPayload) {
@@ -289,27 +471,88 @@ func (fh *FleetHandler) HandleHeartbeat(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The body is optional for live heartbeats; replayed ones are recorded
+	// as history without marking the node online.
+	var hb fleet.HeartbeatRequest
+	_ = decodeFleetBody(r, &hb)
+	if hb.Replayed {
+		if hb.Timestamp.IsZero() || hb.Timestamp.After(time.Now().UTC().Add(5*time.Minute)) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "replayed heartbeat requires a valid timestamp"})
+			return
+		}
+		if err := fh.store.StoreHeartbeatAt(r.Context(), node.ID, hb.Timestamp); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "heartbeat history update failed"})
+			return
+		}
+		fh.metrics.heartbeatReceived("replayed")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "stored"})
+		return
+	}
+
 	if err := fh.store.UpdateNodeHeartbeat(r.Context(), node.ID, time.Now().UTC()); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "heartbeat update failed"})
 		return
 	}
 
+	// 304: the controller already holds the report the node last sent,
+	// so the node can skip re-uploading it.
+	if hb.ReportHash != "" {
+		if stored, err := fh.store.GetReportHash(r.Context(), node.ID); err == nil && stored == hb.ReportHash {
+			fh.metrics.heartbeatReceived("report_unchanged")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	fh.metrics.heartbeatReceived("live")
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-// HandleListNodes returns all nodes with optional filtering.
+// maxFleetBody caps decoded fleet request bodies, including after gzip
+// decompression.
+const maxFleetBody = 10 << 20
+
+// decodeFleetBody decodes a JSON request body, inflating it first when the
+// node sent it with Content-Encoding: gzip.
+func decodeFleetBody(r *http.Request, v interface{}) error {
+	var body io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		body = zr
+	}
+	return json.NewDecoder(io.LimitReader(body, maxFleetBody)).Decode(v)
+}
+
+// HandleListNodes returns all nodes with optional filtering, including
+// nodes federated from child controllers. ?site=local restricts the list
+// to directly enrolled nodes; ?site=ID to one child's nodes.
 func (fh *FleetHandler) HandleListNodes(w http.ResponseWriter, r *http.Request) {
 	filter := fleet.NodeFilter{
 		Role:   fleet.NodeRole(r.URL.Query().Get("role")),
 		Region: r.URL.Query().Get("region"),
 		Status: fleet.NodeStatus(r.URL.Query().Get("status")),
+		Site:   r.URL.Query().Get("site"),
 	}
 
-	nodes, err := fh.store.ListNodes(r.Context(), filter)
+	var nodes []fleet.Node
+	if filter.Site == "" || filter.Site == fleet.SiteLocal {
+		local, err := fh.store.ListNodes(r.Context(), filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list nodes"})
+			return
+		}
+		nodes = local
+	}
+	federated, err := fh.store.ListFederatedNodes(r.Context(), filter)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list nodes"})
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list federated nodes"})
 		return
 	}
+	nodes = append(nodes, federated...)
 	if nodes == nil {
 		nodes = []fleet.Node{}
 	}
@@ -325,6 +568,15 @@ func (fh *FleetHandler) HandleGetNode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	node, err := fh.store.GetNode(r.Context(), id)
+	if err == nil {
+		node.Availability, err = fleet.NodeAvailability(r.Context(), fh.store, node, time.Now().UTC())
+		if err != nil {
+			fh.logger.Printf("fleet: availability for %s: %v", id, err)
+		}
+		writeJSON(w, http.StatusOK, node)
+		return
+	}
+	node, err = fh.store.GetFederatedNode(r.Context(), id)
 	if err != nil {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
 		return
@@ -332,6 +584,61 @@ func (fh *FleetHandler) HandleGetNode(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, node)
 }
 
+// HandleGetNodeHistory returns a node's status transitions and availability.
+// The optional "since" query parameter (RFC3339) bounds the transitions
+// returned; it defaults to the last 24 hours.
+func (fh *FleetHandler) HandleGetNodeHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "node id required"})
+		return
+	}
+
+	now := time.Now().UTC()
+	since := now.Add(-fleet.AvailabilityDay)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC3339 timestamp"})
+			return
+		}
+		since = t
+	}
+
+	node, err := fh.store.GetNode(r.Context(), id)
+	if err != nil {
+		// History of federated nodes stays with their child controller.
+		if fnode, ferr := fh.store.GetFederatedNode(r.Context(), id); ferr == nil {
+			fh.proxyToSite(w, r, fnode.Site, "/api/v1/fleet/nodes/"+url.PathEscape(id)+"/history?"+r.URL.RawQuery)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+
+	transitions, err := fh.store.ListStatusTransitions(r.Context(), id, since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load status history"})
+		return
+	}
+	if transitions == nil {
+		transitions = []fleet.StatusTransition{}
+	}
+	avail, err := fleet.NodeAvailability(r.Context(), fh.store, node, now)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to compute availability"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"node_id":      id,
+		"status":       node.Status,
+		"flapping":     node.Flapping,
+		"transitions":  transitions,
+		"availability": avail,
+	})
+}
+
 // HandleGetNodeReport returns the latest compliance report for a node.
 func (fh *FleetHandler) HandleGetNodeReport(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -342,6 +649,11 @@ func (fh *FleetHandler) HandleGetNodeReport(w http.ResponseWriter, r *http.Reque
 
 	report, err := fh.store.GetLatestReport(r.Context(), id)
 	if err != nil {
+		// Reports of federated nodes stay with their child controller.
+		if node, ferr := fh.store.GetFederatedNode(r.Context(), id); ferr == nil {
+			fh.proxyToSite(w, r, node.Site, "/api/v1/fleet/nodes/"+url.PathEscape(id)+"/report")
+			return
+		}
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no report found for node"})
 		return
 	}
@@ -375,6 +687,16 @@ func (fh *FleetHandler) HandleDeleteNode(w http.ResponseWriter, r *http.Request)
 	}
 
 	fh.logger.Printf("fleet: node removed: %s (name=%s)", id, node.Name)
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "credential_lifecycle",
+		Severity:  "info",
+		Actor:     fh.adminActor(),
+		Resource:  "node:" + id,
+		Action:    "node_removed",
+		Detail:    fmt.Sprintf("node %s (%s) removed from the fleet; API key revoked", id, node.Name),
+		Before:    auditValue(nodeAuditState(node)),
+		NISTRef:   "AC-2, IA-5",
+	})
 
 	// Emit event
 	if fh.eventCh != nil {
@@ -391,16 +713,34 @@ func (fh *FleetHandler) HandleDeleteNode(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
-// HandleSummary returns fleet-wide aggregate statistics.
+// HandleSummary returns fleet-wide aggregate statistics, rolled up across
+// child controllers unless ?site=local is given.
 func (fh *FleetHandler) HandleSummary(w http.ResponseWriter, r *http.Request) {
 	summary, err := fh.store.GetSummary(r.Context())
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to compute summary"})
 		return
 	}
+	if r.URL.Query().Get("site") != fleet.SiteLocal {
+		sites, err := fh.store.ListSites(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to compute summary"})
+			return
+		}
+		for _, site := range sites {
+			fleet.MergeSummary(summary, site.Summary)
+		}
+	}
 	writeJSON(w, http.StatusOK, summary)
 }
 
+// SSEClientCount returns the number of connected fleet event stream clients.
+func (fh *FleetHandler) SSEClientCount() int {
+	fh.sseMu.Lock()
+	defer fh.sseMu.Unlock()
+	return len(fh.sseClients)
+}
+
 // HandleFleetSSE provides a Server-Sent Events stream for fleet changes.
 func (fh *FleetHandler) HandleFleetSSE(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
@@ -474,12 +814,38 @@ func (fh *FleetHandler) requireAdmin(w http.ResponseWriter, r *http.Request) boo
 	}
 	key := strings.TrimPrefix(auth, "Bearer ")
 	if key != fh.adminKey {
+		fh.auditRequest(r, audit.AuditEvent{
+			EventType: "auth_attempt",
+			Severity:  "warning",
+			Actor:     "api:" + extractIP(r),
+			Resource:  r.Method + " " + r.URL.Path,
+			Action:    "login_failed",
+			Detail:    "invalid fleet admin credentials",
+			NISTRef:   "AC-7, IA-2",
+		})
 		writeJSON(w, http.StatusForbidden, map[string]string{"error": "invalid admin credentials"})
 		return false
 	}
 	return true
 }
 
+// requireOperator authenticates an admin or approver key and returns the
+// caller's identity: the admin key ID (see adminActor) or "approver:<name>".
+func (fh *FleetHandler) requireOperator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if fh.approval != nil {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		for name, k := range fh.approval.Approvers {
+			if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				return "approver:" + name, true
+			}
+		}
+	}
+	if !fh.requireAdmin(w, r) {
+		return "", false
+	}
+	return fh.adminActor(), true
+}
+
 // authenticateNode validates the node API key from the Authorization header.
 func (fh *FleetHandler) authenticateNode(w http.ResponseWriter, r *http.Request) (*fleet.Node, bool) {
 	auth := r.Header.Get("Authorization")
@@ -524,8 +890,24 @@ func (fh *FleetHandler) HandleUpdatePolicy(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	before := fh.policy
 	fh.policy = &policy
 	fh.logger.Printf("fleet: compliance policy updated: mode=%s", policy.EnforcementMode)
+	severity := "info"
+	if policy.EnforcementMode == "disabled" {
+		severity = "warning"
+	}
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "config_change",
+		Severity:  severity,
+		Actor:     fh.adminActor(),
+		Resource:  "fleet_policy",
+		Action:    "modified",
+		Detail:    fmt.Sprintf("compliance policy updated: enforcement mode %s -> %s", before.EnforcementMode, policy.EnforcementMode),
+		Before:    auditValue(before),
+		After:     auditValue(policy),
+		NISTRef:   "CM-3, CM-6",
+	})
 	writeJSON(w, http.StatusOK, fh.policy)
 }
 
@@ -548,17 +930,13 @@ func (fh *FleetHandler) HandleGetRoutes(w http.ResponseWriter, r *http.Request)
 
 	var routes []route
 	for _, n := range nodes {
-		routable := n.Status == fleet.StatusOnline
-		if fh.policy != nil && fh.policy.EnforcementMode == "enforce" {
-			routable = routable && n.ComplianceStatus == fleet.ComplianceCompliant
-		}
 		routes = append(routes, route{
 			NodeID:           n.ID,
 			NodeName:         n.Name,
 			Service:          n.Service,
 			Status:           n.Status,
 			ComplianceStatus: n.ComplianceStatus,
-			Routable:         routable,
+			Routable:         fh.Routable(n),
 		})
 	}
 
@@ -568,6 +946,16 @@ func (fh *FleetHandler) HandleGetRoutes(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, routes)
 }
 
+// Routable reports whether a server node receives traffic: it is online
+// and, when the policy enforces compliance, compliant.
+func (fh *FleetHandler) Routable(n fleet.Node) bool {
+	routable := n.Status == fleet.StatusOnline
+	if fh.policy != nil && fh.policy.EnforcementMode == "enforce" {
+		routable = routable && n.ComplianceStatus == fleet.ComplianceCompliant
+	}
+	return routable
+}
+
 // FleetMode returns true if the handler is initialized for fleet mode.
 func (fh *FleetHandler) FleetMode() bool {
 	return fh.store != nil
@@ -582,7 +970,8 @@ func (fh *FleetHandler) FleetModeInfo() map[string]interface{} {
 
 // HandleRequestRemediation creates a remediation request for a node (admin only).
 func (fh *FleetHandler) HandleRequestRemediation(w http.ResponseWriter, r *http.Request) {
-	if !fh.requireAdmin(w, r) {
+	actor, ok := fh.requireOperator(w, r)
+	if !ok {
 		return
 	}
 
@@ -610,16 +999,21 @@ func (fh *FleetHandler) HandleRequestRemediation(w http.ResponseWriter, r *http.
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "actions list required"})
 		return
 	}
+	if !fh.requireNoReboot(w, body.Actions) {
+		return
+	}
 
 	reqID := generateID()
 	req := &fleet.RemediationRequest{
-		ID:        reqID,
-		NodeID:    nodeID,
-		Actions:   body.Actions,
-		DryRun:    body.DryRun,
-		Status:    fleet.RemediationPending,
-		CreatedAt: time.Now().UTC(),
+		ID:          reqID,
+		NodeID:      nodeID,
+		Actions:     body.Actions,
+		DryRun:      body.DryRun,
+		Status:      fleet.RemediationPending,
+		CreatedAt:   time.Now().UTC(),
+		RequestedBy: actor,
 	}
+	fh.requireApproval(req)
 
 	if err := fh.store.CreateRemediationRequest(r.Context(), req); err != nil {
 		fh.logger.Printf("fleet: create remediation request error: %v", err)
@@ -628,6 +1022,12 @@ func (fh *FleetHandler) HandleRequestRemediation(w http.ResponseWriter, r *http.
 	}
 
 	fh.logger.Printf("fleet: remediation requested for node %s: %v (dry_run=%v)", nodeID, body.Actions, body.DryRun)
+	detail := fmt.Sprintf("remediation %s (%s) requested (dry_run=%v)", req.ID, strings.Join(req.Actions, ", "), req.DryRun)
+	if req.Status == fleet.RemediationAwaitingApproval {
+		detail = fmt.Sprintf("remediation %s (%s) awaiting %d approval(s) until %s",
+			req.ID, strings.Join(req.Actions, ", "), req.RequiredApprovals, req.ApprovalExpiresAt.Format(time.RFC3339))
+	}
+	fh.auditApproval(r, req, actor, "remediation_requested", detail)
 
 	// Emit SSE event
 	if fh.eventCh != nil {
@@ -647,6 +1047,19 @@ func (fh *FleetHandler) HandleRequestRemediation(w http.ResponseWriter, r *http.
 	writeJSON(w, http.StatusCreated, req)
 }
 
+// requireNoReboot refuses requests for the reboot action, which only the
+// reboot coordinator schedules (inside a maintenance window, once enough
+// other servers are routable). It reports whether actions may proceed.
+func (fh *FleetHandler) requireNoReboot(w http.ResponseWriter, actions []string) bool {
+	for _, a := range actions {
+		if remediate.ActionID(a) == remediate.ActionReboot {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "reboots are scheduled by the reboot coordinator and cannot be requested"})
+			return false
+		}
+	}
+	return true
+}
+
 // HandlePollRemediations returns pending remediation requests for a node (node auth).
 func (fh *FleetHandler) HandlePollRemediations(w http.ResponseWriter, r *http.Request) {
 	node, ok := fh.authenticateNode(w, r)
@@ -668,6 +1081,15 @@ func (fh *FleetHandler) HandlePollRemediations(w http.ResponseWriter, r *http.Re
 	if reqs == nil {
 		reqs = []fleet.RemediationRequest{}
 	}
+	// Rollbacks carry the result they undo, with its captured prior state.
+	for i := range reqs {
+		if reqs[i].Type != fleet.RemediationTypeRollback {
+			continue
+		}
+		if prev, err := fh.store.GetRemediationRequest(r.Context(), reqs[i].RollbackOf); err == nil {
+			reqs[i].Previous = prev.Result
+		}
+	}
 	writeJSON(w, http.StatusOK, reqs)
 }
 
@@ -704,7 +1126,12 @@ func (fh *FleetHandler) HandlePostRemediationResult(w http.ResponseWriter, r *ht
 		return
 	}
 
-	if err := fh.store.CompleteRemediation(r.Context(), body.RequestID, body.Result); err != nil {
+	// Only requests released to the node can complete: one awaiting
+	// approval or expired must not be reported as done.
+	if err := fh.store.CompleteRemediation(r.Context(), body.RequestID, body.Result); errors.Is(err, fleet.ErrNotPending) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "remediation request is " + string(req.Status) + ", not pending"})
+		return
+	} else if err != nil {
 		fh.logger.Printf("fleet: complete remediation error: %v", err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to complete remediation"})
 		return
@@ -712,11 +1139,22 @@ func (fh *FleetHandler) HandlePostRemediationResult(w http.ResponseWriter, r *ht
 
 	fh.logger.Printf("fleet: remediation completed for node %s (request %s)", nodeID, body.RequestID)
 
+	var result remediate.RemediationResult
+	_ = json.Unmarshal(body.Result, &result)
+	eventType := "remediation_completed"
+	if req.Type == fleet.RemediationTypeRollback {
+		eventType = "remediation_rolled_back"
+		fh.auditRollback(r, "node:"+nodeID, "node:"+nodeID, "rolled_back", req.ID, req.RollbackOf, &result)
+	} else if !req.DryRun {
+		fh.auditRemediation(r, "node:"+nodeID, "node:"+nodeID, "remediation_completed", req.ID, &result)
+	}
+	fh.trackReboot(r.Context(), req, &result)
+
 	// Emit SSE event
 	if fh.eventCh != nil {
 		select {
 		case fh.eventCh <- fleet.FleetEvent{
-			Type: "remediation_completed",
+			Type: eventType,
 			Node: *node,
 			Time: time.Now().UTC(),
 		}:
@@ -815,6 +1253,661 @@ func (fh *FleetHandler) HandleGetRemediationPlan(w http.ResponseWriter, r *http.
 	writeJSON(w, http.StatusOK, actions)
 }
 
+// HandleGetEdgeRemediationPlan re-runs the edge compliance checks and returns
+// the edge actions available for failing items, with current vs desired zone
+// settings (admin only).
+func (fh *FleetHandler) HandleGetEdgeRemediationPlan(w http.ResponseWriter, r *http.Request) {
+	if !fh.requireAdmin(w, r) {
+		return
+	}
+	if fh.edge == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "edge remediation not configured (requires Cloudflare API token and zone)"})
+		return
+	}
+
+	actions := fh.edge.Plan(fh.edge.Current())
+	if actions == nil {
+		actions = []remediate.RemediationAction{}
+	}
+	writeJSON(w, http.StatusOK, actions)
+}
+
+// HandleEdgeRemediation applies edge remediation actions through the
+// Cloudflare API (admin or approver key). With two-person approval enabled,
+// the request is returned with 202 and applied once approved. Otherwise it
+// is applied at once and the result returned; with dry_run set, only the
+// diff is returned, without approval.
+func (fh *FleetHandler) HandleEdgeRemediation(w http.ResponseWriter, r *http.Request) {
+	actor, ok := fh.requireOperator(w, r)
+	if !ok {
+		return
+	}
+	if fh.edge == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "edge remediation not configured (requires Cloudflare API token and zone)"})
+		return
+	}
+
+	var body struct {
+		Actions []remediate.ActionID `json:"actions"`
+		DryRun  bool                 `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(body.Actions) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "actions list required"})
+		return
+	}
+	for _, a := range body.Actions {
+		if !remediate.IsEdgeAction(a) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s is not an edge action", a)})
+			return
+		}
+	}
+
+	req := &fleet.RemediationRequest{
+		ID:          generateID(),
+		ZoneID:      fh.edge.ZoneID(),
+		Actions:     make([]string, len(body.Actions)),
+		DryRun:      body.DryRun,
+		Status:      fleet.RemediationPending,
+		CreatedAt:   time.Now().UTC(),
+		Type:        fleet.RemediationTypeRemediate,
+		RequestedBy: actor,
+	}
+	for i, a := range body.Actions {
+		req.Actions[i] = string(a)
+	}
+	fh.submitEdgeRemediation(w, r, req)
+}
+
+// submitEdgeRemediation stores a new edge request and applies it, unless
+// it must first be approved.
+func (fh *FleetHandler) submitEdgeRemediation(w http.ResponseWriter, r *http.Request, req *fleet.RemediationRequest) {
+	fh.requireApproval(req)
+	if err := fh.store.CreateEdgeRemediation(r.Context(), req); err != nil {
+		fh.logger.Printf("fleet: create edge remediation error: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create edge remediation"})
+		return
+	}
+	if req.Status == fleet.RemediationAwaitingApproval {
+		fh.logger.Printf("fleet: edge %s %s awaiting approval: %v", req.Type, req.ID, req.Actions)
+		fh.auditApproval(r, req, req.RequestedBy, "remediation_requested",
+			fmt.Sprintf("edge %s %s (%s) awaiting %d approval(s) until %s", req.Type,
+				req.ID, strings.Join(req.Actions, ", "), req.RequiredApprovals, req.ApprovalExpiresAt.Format(time.RFC3339)))
+		writeJSON(w, http.StatusAccepted, req)
+		return
+	}
+	result, err := fh.applyEdgeRemediation(r, req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleApproveEdgeRemediation records an approver's sign-off on an edge
+// request awaiting approval (approver key). The controller applies the
+// request once enough distinct approvers, other than the requester, have
+// approved it.
+func (fh *FleetHandler) HandleApproveEdgeRemediation(w http.ResponseWriter, r *http.Request) {
+	actor, ok := fh.requireApprover(w, r)
+	if !ok {
+		return
+	}
+	if fh.edge == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "edge remediation not configured (requires Cloudflare API token and zone)"})
+		return
+	}
+
+	existing, err := fh.store.GetEdgeRemediation(r.Context(), r.PathValue("reqID"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "edge remediation not found"})
+		return
+	}
+
+	req, err := fh.store.ApproveEdgeRemediation(r.Context(), existing.ID, fleet.RemediationApproval{
+		Approver:   actor,
+		ApprovedAt: time.Now().UTC(),
+	})
+	if !fh.approved(w, r, req, actor, err, "applied to zone") {
+		return
+	}
+	if req.Status == fleet.RemediationPending {
+		if _, err := fh.applyEdgeRemediation(r, req); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if applied, err := fh.store.GetEdgeRemediation(r.Context(), req.ID); err == nil {
+			req = applied
+		}
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+// HandleListEdgeRemediations lists edge remediation and rollback requests,
+// optionally filtered by ?status= (admin or approver key). Requests whose
+// approval window has passed are expired first.
+func (fh *FleetHandler) HandleListEdgeRemediations(w http.ResponseWriter, r *http.Request) {
+	if _, ok := fh.requireOperator(w, r); !ok {
+		return
+	}
+	fh.expireApprovals(r.Context())
+
+	reqs, err := fh.store.ListEdgeRemediations(r.Context(), fleet.RemediationFilter{
+		Status: fleet.RemediationStatus(r.URL.Query().Get("status")),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list edge remediations"})
+		return
+	}
+	if reqs == nil {
+		reqs = []fleet.RemediationRequest{}
+	}
+	writeJSON(w, http.StatusOK, reqs)
+}
+
+// applyEdgeRemediation applies a stored edge remediation or rollback
+// request to the zone and stores the result, whose prior state a later
+// rollback restores. Changes other than dry runs are audited. A request
+// that cannot be applied is stored as failed with the error.
+func (fh *FleetHandler) applyEdgeRemediation(r *http.Request, req *fleet.RemediationRequest) (remediate.RemediationResult, error) {
+	edgeReq := remediate.RemediationRequest{ID: req.ID, DryRun: req.DryRun}
+	for _, a := range req.Actions {
+		edgeReq.Actions = append(edgeReq.Actions, remediate.ActionID(a))
+	}
+
+	var result remediate.RemediationResult
+	if req.Type == fleet.RemediationTypeRollback {
+		orig, err := fh.store.GetEdgeRemediation(r.Context(), req.RollbackOf)
+		if err != nil {
+			return result, fh.failEdgeRemediation(r, req, fmt.Errorf("edge remediation %s not found", req.RollbackOf))
+		}
+		var prev remediate.RemediationResult
+		if err := json.Unmarshal(orig.Result, &prev); err != nil {
+			return result, fh.failEdgeRemediation(r, req, fmt.Errorf("edge remediation %s has no stored result", req.RollbackOf))
+		}
+		edgeReq.Type = remediate.RequestRollback
+		edgeReq.Previous = &prev
+		result = fh.edge.Rollback(edgeReq)
+	} else {
+		result = fh.edge.Execute(edgeReq, fh.edge.Plan(fh.edge.Current()))
+	}
+	fh.logger.Printf("fleet: edge %s %s: %v (dry_run=%v)", req.Type, req.ID, req.Actions, req.DryRun)
+
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = fh.store.CompleteEdgeRemediation(r.Context(), req.ID, data)
+	}
+	if err != nil {
+		// The zone has changed; the result is still returned and audited.
+		fh.logger.Printf("fleet: store edge remediation result %s error: %v", req.ID, err)
+	}
+
+	if req.DryRun {
+		return result, nil
+	}
+	resource := "zone:" + req.ZoneID
+	if req.Type == fleet.RemediationTypeRollback {
+		fh.auditRollback(r, req.RequestedBy, resource, "rolled_back", req.ID, req.RollbackOf, &result)
+	} else {
+		fh.auditRemediation(r, req.RequestedBy, resource, "edge_remediation_applied", req.ID, &result)
+	}
+	return result, nil
+}
+
+// failEdgeRemediation stores req as failed with cause and returns cause.
+func (fh *FleetHandler) failEdgeRemediation(r *http.Request, req *fleet.RemediationRequest, cause error) error {
+	data, _ := json.Marshal(map[string]string{"error": cause.Error()})
+	if err := fh.store.FailEdgeRemediation(r.Context(), req.ID, data); err != nil {
+		fh.logger.Printf("fleet: store edge remediation failure %s error: %v", req.ID, err)
+	}
+	fh.logger.Printf("fleet: edge %s %s failed: %v", req.Type, req.ID, cause)
+	return cause
+}
+
+// HandleRequestRollback queues a rollback of a completed remediation request
+// for the node (admin only). The agent restores the prior state captured in
+// the request's result. An optional {"actions": [...]} limits the rollback.
+func (fh *FleetHandler) HandleRequestRollback(w http.ResponseWriter, r *http.Request) {
+	actor, ok := fh.requireOperator(w, r)
+	if !ok {
+		return
+	}
+
+	nodeID := r.PathValue("id")
+	orig, err := fh.store.GetRemediationRequest(r.Context(), r.PathValue("reqID"))
+	if err != nil || orig.NodeID != nodeID {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "remediation request not found"})
+		return
+	}
+	switch {
+	case orig.Type == fleet.RemediationTypeRollback:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cannot roll back a rollback"})
+		return
+	case orig.Status != fleet.RemediationCompleted || len(orig.Result) == 0:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "remediation request has not completed"})
+		return
+	case orig.DryRun:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "dry-run requests changed nothing"})
+		return
+	}
+
+	var body struct {
+		Actions []string `json:"actions"`
+		DryRun  bool     `json:"dry_run"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+
+	if !fh.requireNoReboot(w, body.Actions) {
+		return
+	}
+
+	req := &fleet.RemediationRequest{
+		ID:         generateID(),
+		NodeID:     nodeID,
+		Actions:    body.Actions,
+		DryRun:     body.DryRun,
+		Status:     fleet.RemediationPending,
+		CreatedAt:  time.Now().UTC(),
+		Type:       fleet.RemediationTypeRollback,
+		RollbackOf: orig.ID,
+
+		RequestedBy: actor,
+	}
+	if req.Actions == nil {
+		req.Actions = []string{}
+	}
+	fh.requireApproval(req)
+	if err := fh.store.CreateRemediationRequest(r.Context(), req); err != nil {
+		fh.logger.Printf("fleet: create rollback request error: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create rollback request"})
+		return
+	}
+
+	fh.logger.Printf("fleet: rollback of %s requested for node %s (dry_run=%v)", orig.ID, nodeID, body.DryRun)
+	fh.auditRollback(r, actor, "node:"+nodeID, "rollback_requested", req.ID, orig.ID, nil)
+	writeJSON(w, http.StatusCreated, req)
+}
+
+// HandleApproveRemediation records an approver's sign-off on a request
+// awaiting approval (approver key). The request is released to the agent
+// once enough distinct approvers, other than the requester, have approved.
+func (fh *FleetHandler) HandleApproveRemediation(w http.ResponseWriter, r *http.Request) {
+	actor, ok := fh.requireApprover(w, r)
+	if !ok {
+		return
+	}
+
+	nodeID := r.PathValue("id")
+	existing, err := fh.store.GetRemediationRequest(r.Context(), r.PathValue("reqID"))
+	if err != nil || existing.NodeID != nodeID {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "remediation request not found"})
+		return
+	}
+
+	req, err := fh.store.ApproveRemediation(r.Context(), existing.ID, fleet.RemediationApproval{
+		Approver:   actor,
+		ApprovedAt: time.Now().UTC(),
+	})
+	if !fh.approved(w, r, req, actor, err, "released to agent") {
+		return
+	}
+
+	if req.Status == fleet.RemediationPending && fh.eventCh != nil {
+		if node, _ := fh.store.GetNode(r.Context(), nodeID); node != nil {
+			select {
+			case fh.eventCh <- fleet.FleetEvent{Type: "remediation_approved", Node: *node, Time: time.Now().UTC()}:
+			default:
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+// requireApprover authenticates an approval, which needs an approver key,
+// and returns the approver's actor name.
+func (fh *FleetHandler) requireApprover(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor, ok := fh.requireOperator(w, r)
+	if !ok {
+		return "", false
+	}
+	if fh.approval == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "remediation approval is not enabled"})
+		return "", false
+	}
+	if !strings.HasPrefix(actor, "approver:") {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "approvals require an approver key"})
+		return "", false
+	}
+	return actor, true
+}
+
+// approved reports whether an approval was recorded, auditing it, or
+// writes the error response for err. released describes what happens
+// once the request has all its approvals.
+func (fh *FleetHandler) approved(w http.ResponseWriter, r *http.Request, req *fleet.RemediationRequest, actor string, err error, released string) bool {
+	switch {
+	case errors.Is(err, fleet.ErrApprovalExpired):
+		fh.auditApproval(r, req, actor, "approval_expired", fmt.Sprintf("remediation %s expired before approval", req.ID))
+		writeJSON(w, http.StatusGone, map[string]string{"error": err.Error()})
+		return false
+	case errors.Is(err, fleet.ErrSelfApproval), errors.Is(err, fleet.ErrDuplicateApproval):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return false
+	case errors.Is(err, fleet.ErrNotAwaitingApproval):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return false
+	case err != nil:
+		fh.logger.Printf("fleet: approve remediation error: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to approve remediation"})
+		return false
+	}
+
+	fh.logger.Printf("fleet: remediation %s approved by %s (%d/%d)", req.ID, actor, len(req.Approvals), req.RequiredApprovals)
+	detail := fmt.Sprintf("remediation %s approved by %s (%d/%d)", req.ID, actor, len(req.Approvals), req.RequiredApprovals)
+	if req.Status == fleet.RemediationPending {
+		detail += "; " + released + "; chain: " + approvalChain(req)
+	}
+	fh.auditApproval(r, req, actor, "remediation_approved", detail)
+	return true
+}
+
+// HandleListRemediations lists remediation requests, optionally filtered by
+// ?node= and ?status= (admin or approver key). Requests whose approval
+// window has passed are expired first.
+func (fh *FleetHandler) HandleListRemediations(w http.ResponseWriter, r *http.Request) {
+	if _, ok := fh.requireOperator(w, r); !ok {
+		return
+	}
+	fh.expireApprovals(r.Context())
+
+	reqs, err := fh.store.ListRemediationRequests(r.Context(), fleet.RemediationFilter{
+		NodeID: r.URL.Query().Get("node"),
+		Status: fleet.RemediationStatus(r.URL.Query().Get("status")),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list remediation requests"})
+		return
+	}
+	if reqs == nil {
+		reqs = []fleet.RemediationRequest{}
+	}
+	writeJSON(w, http.StatusOK, reqs)
+}
+
+// requireApproval holds a non-dry-run request for approval when the
+// approval workflow is enabled.
+func (fh *FleetHandler) requireApproval(req *fleet.RemediationRequest) {
+	if fh.approval == nil || fh.approval.Required <= 0 || req.DryRun {
+		return
+	}
+	ttl := fh.approval.TTL
+	if ttl <= 0 {
+		ttl = DefaultApprovalTTL
+	}
+	expires := req.CreatedAt.Add(ttl)
+	req.Status = fleet.RemediationAwaitingApproval
+	req.RequiredApprovals = fh.approval.Required
+	req.ApprovalExpiresAt = &expires
+}
+
+// RunApprovalExpiry expires requests whose approval window has passed
+// every interval until ctx is done, so an unattended controller still
+// expires and audits them. Should be run as a goroutine.
+func (fh *FleetHandler) RunApprovalExpiry(ctx context.Context, interval time.Duration) {
+	if fh.approval == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fh.expireApprovals(ctx)
+		}
+	}
+}
+
+// expireApprovals expires requests whose approval window has passed and
+// audits each one.
+func (fh *FleetHandler) expireApprovals(ctx context.Context) {
+	if fh.approval == nil {
+		return
+	}
+	expired, err := fh.store.ExpireRemediationApprovals(ctx, time.Now().UTC())
+	if err != nil {
+		fh.logger.Printf("fleet: expire remediation approvals error: %v", err)
+		return
+	}
+	for i := range expired {
+		req := &expired[i]
+		fh.auditApproval(nil, req, "system", "approval_expired",
+			fmt.Sprintf("remediation %s expired with %d/%d approvals; chain: %s",
+				req.ID, len(req.Approvals), req.RequiredApprovals, approvalChain(req)))
+	}
+}
+
+// approvalChain renders who requested and approved a request, and when.
+func approvalChain(req *fleet.RemediationRequest) string {
+	parts := []string{fmt.Sprintf("requested by %s at %s", req.RequestedBy, req.CreatedAt.Format(time.RFC3339))}
+	for _, a := range req.Approvals {
+		parts = append(parts, fmt.Sprintf("approved by %s at %s", a.Approver, a.ApprovedAt.Format(time.RFC3339)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// auditApproval records a step of the approval workflow in the audit log.
+// r is nil for steps the controller takes on its own.
+func (fh *FleetHandler) auditApproval(r *http.Request, req *fleet.RemediationRequest, actor, action, detail string) {
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "config_change",
+		Severity:  "info",
+		Actor:     actor,
+		Resource:  remediationResource(req),
+		Action:    action,
+		Detail:    detail,
+		NISTRef:   "CM-3, AC-5",
+	})
+}
+
+// remediationResource names the node or zone a request changes in audit
+// events.
+func remediationResource(req *fleet.RemediationRequest) string {
+	if req.ZoneID != "" {
+		return "zone:" + req.ZoneID
+	}
+	return "node:" + req.NodeID
+}
+
+// logAudit writes an audit event if an audit logger is configured.
+func (fh *FleetHandler) logAudit(evt audit.AuditEvent) {
+	if fh.audit != nil {
+		fh.audit.Log(evt)
+	}
+}
+
+// auditRequest writes an audit event for an API request, recording the
+// caller's address. r may be nil.
+func (fh *FleetHandler) auditRequest(r *http.Request, evt audit.AuditEvent) {
+	if r != nil {
+		evt.SourceIP = extractIP(r)
+	}
+	fh.logAudit(evt)
+}
+
+// adminActor identifies the admin key in audit events by a short SHA-256
+// fingerprint, so a key rotation is visible without logging the key.
+func (fh *FleetHandler) adminActor() string {
+	if fh.adminKey == "" {
+		return "admin"
+	}
+	sum := sha256.Sum256([]byte(fh.adminKey))
+	return "admin:" + hex.EncodeToString(sum[:4])
+}
+
+// auditValue renders v as JSON for an event's Before or After field.
+func auditValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// nodeAuditState is the part of a node recorded when it joins or leaves.
+func nodeAuditState(n *fleet.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         n.Name,
+		"role":         n.Role,
+		"region":       n.Region,
+		"version":      n.Version,
+		"fips_backend": n.FIPSBackend,
+		"enrolled_at":  n.EnrolledAt,
+	}
+}
+
+// HandleEdgeRollback restores the zone settings changed by an earlier edge
+// remediation (admin or approver key), from the prior state stored with its
+// result. Rollbacks are approved like remediation.
+func (fh *FleetHandler) HandleEdgeRollback(w http.ResponseWriter, r *http.Request) {
+	actor, ok := fh.requireOperator(w, r)
+	if !ok {
+		return
+	}
+	if fh.edge == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "edge remediation not configured (requires Cloudflare API token and zone)"})
+		return
+	}
+
+	orig, err := fh.store.GetEdgeRemediation(r.Context(), r.PathValue("reqID"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "edge remediation not found"})
+		return
+	}
+	switch {
+	case orig.Type == fleet.RemediationTypeRollback:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cannot roll back a rollback"})
+		return
+	case orig.Status != fleet.RemediationCompleted || len(orig.Result) == 0:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "edge remediation has not completed"})
+		return
+	case orig.DryRun:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "dry-run requests changed nothing"})
+		return
+	}
+
+	var body struct {
+		Actions []remediate.ActionID `json:"actions"`
+		DryRun  bool                 `json:"dry_run"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+
+	req := &fleet.RemediationRequest{
+		ID:         generateID(),
+		ZoneID:     orig.ZoneID,
+		Actions:    make([]string, len(body.Actions)),
+		DryRun:     body.DryRun,
+		Status:     fleet.RemediationPending,
+		CreatedAt:  time.Now().UTC(),
+		Type:       fleet.RemediationTypeRollback,
+		RollbackOf: orig.ID,
+
+		RequestedBy: actor,
+	}
+	for i, a := range body.Actions {
+		req.Actions[i] = string(a)
+	}
+	fh.submitEdgeRemediation(w, r, req)
+}
+
+// auditRollback records a rollback request or outcome in the audit log.
+// result is nil when the rollback has only been requested.
+func (fh *FleetHandler) auditRollback(r *http.Request, actor, resource, action, reqID, rollbackOf string, result *remediate.RemediationResult) {
+	evt := audit.AuditEvent{
+		EventType: "config_change",
+		Severity:  "info",
+		Actor:     actor,
+		Resource:  resource,
+		Action:    action,
+		Detail:    fmt.Sprintf("rollback %s of remediation %s", reqID, rollbackOf),
+		NISTRef:   "CM-3, AU-2",
+	}
+	if result != nil {
+		var summary string
+		summary, evt.Severity = summarizeActions(result.Actions)
+		evt.Detail += "; " + summary
+		evt.Before, evt.After = changeValues(result.Actions)
+	}
+	fh.auditRequest(r, evt)
+}
+
+// auditRemediation records an applied remediation, with the settings it
+// changed as before and after values.
+func (fh *FleetHandler) auditRemediation(r *http.Request, actor, resource, action, reqID string, result *remediate.RemediationResult) {
+	summary, severity := summarizeActions(result.Actions)
+	before, after := changeValues(result.Actions)
+	fh.auditRequest(r, audit.AuditEvent{
+		EventType: "config_change",
+		Severity:  severity,
+		Actor:     actor,
+		Resource:  resource,
+		Action:    action,
+		Detail:    fmt.Sprintf("remediation %s: %s", reqID, summary),
+		Before:    before,
+		After:     after,
+		NISTRef:   "CM-3, SI-2",
+	})
+}
+
+// changeValues collects the current and desired value of each setting the
+// actions changed, keyed by setting name.
+func changeValues(actions []remediate.RemediationAction) (string, string) {
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+	for _, a := range actions {
+		for _, c := range a.Changes {
+			before[c.Setting] = c.Current
+			after[c.Setting] = c.Desired
+		}
+	}
+	if len(before) == 0 {
+		return "", ""
+	}
+	return auditValue(before), auditValue(after)
+}
+
+// summarizeActions renders each action's status and verification for the
+// audit log, returning "warning" severity if any action failed.
+func summarizeActions(actions []remediate.RemediationAction) (string, string) {
+	severity := "info"
+	var parts []string
+	for _, a := range actions {
+		part := fmt.Sprintf("%s: %s", a.ID, a.Status)
+		if v := a.Verification; v != nil {
+			part += fmt.Sprintf(" (verified %s=%s)", v.CheckID, v.Status)
+		}
+		if a.Status == remediate.StatusFailed {
+			severity = "warning"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", "), severity
+}
+
 // generateID produces a simple unique ID for remediation requests.
 func generateID() string {
 	return fmt.Sprintf("rem-%d", time.Now().UnixNano())
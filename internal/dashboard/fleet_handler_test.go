@@ -2,15 +2,24 @@ package dashboard
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/cfapi"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
 )
 
 func testFleetHandler(t *testing.T) (*FleetHandler, fleet.Store) {
@@ -376,3 +385,1181 @@ func TestFleetHandler_EmptyListsReturnArrays(t *testing.T) {
 		t.Error("empty tokens list should return [], not null")
 	}
 }
+
+func TestFleetHandler_ReplayedReportStoredAsHistory(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	ctx := context.Background()
+
+	enrollment := fleet.NewEnrollment(store)
+	tok, _ := enrollment.CreateToken(ctx, fleet.CreateTokenRequest{Role: fleet.RoleServer, MaxUses: 1, ExpiresIn: 3600})
+	resp, err := enrollment.Enroll(ctx, fleet.EnrollmentRequest{Token: tok.Token, Name: "srv"})
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+
+	post := func(path string, v interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+resp.APIKey)
+		w := httptest.NewRecorder()
+		if path == "/api/v1/fleet/report" {
+			fh.HandleReport(w, req)
+		} else {
+			fh.HandleHeartbeat(w, req)
+		}
+		return w
+	}
+
+	// Live passing report.
+	live := fleet.ComplianceReportPayload{NodeID: resp.NodeID}
+	live.Report.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	live.Report.Summary.Passed = 5
+	if w := post("/api/v1/fleet/report", live); w.Code != http.StatusOK {
+		t.Fatalf("live report status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// Older failing report replayed from the spool.
+	replayed := fleet.ComplianceReportPayload{NodeID: resp.NodeID, Replayed: true}
+	replayed.Report.Timestamp = time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	replayed.Report.Summary.Failed = 3
+	if w := post("/api/v1/fleet/report", replayed); w.Code != http.StatusOK {
+		t.Fatalf("replayed report status = %d: %s", w.Code, w.Body.String())
+	}
+
+	node, _ := store.GetNode(ctx, resp.NodeID)
+	if node.ComplianceFail != 0 || node.Status != fleet.StatusOnline {
+		t.Errorf("replayed report changed current state: fail=%d status=%s", node.ComplianceFail, node.Status)
+	}
+	latest, _ := store.GetLatestReport(ctx, resp.NodeID)
+	var latestReport struct {
+		Summary struct {
+			Failed int `json:"failed"`
+		} `json:"summary"`
+	}
+	_ = json.Unmarshal(latest, &latestReport)
+	if latestReport.Summary.Failed != 0 {
+		t.Error("replayed report overtook the live report as latest")
+	}
+
+	// Replayed heartbeat is accepted; missing timestamp is rejected.
+	if w := post("/api/v1/fleet/heartbeat", fleet.HeartbeatRequest{NodeID: resp.NodeID, Replayed: true, Timestamp: time.Now().UTC().Add(-time.Hour)}); w.Code != http.StatusOK {
+		t.Errorf("replayed heartbeat status = %d", w.Code)
+	}
+	if w := post("/api/v1/fleet/heartbeat", fleet.HeartbeatRequest{NodeID: resp.NodeID, Replayed: true}); w.Code != http.StatusBadRequest {
+		t.Errorf("replayed heartbeat without timestamp status = %d, want 400", w.Code)
+	}
+
+	// Replayed report without a parseable timestamp is rejected.
+	bad := fleet.ComplianceReportPayload{NodeID: resp.NodeID, Replayed: true}
+	if w := post("/api/v1/fleet/report", bad); w.Code != http.StatusBadRequest {
+		t.Errorf("replayed report without timestamp status = %d, want 400", w.Code)
+	}
+}
+
+func TestFleetHandler_Federation(t *testing.T) {
+	ctx := context.Background()
+
+	// Child controller with one local node and a drill-down API.
+	childFH, childStore := testFleetHandler(t)
+	childMux := http.NewServeMux()
+	RegisterFleetRoutes(childMux, childFH)
+	var drillAuth string
+	child := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		drillAuth = r.Header.Get("Authorization")
+		childMux.ServeHTTP(w, r)
+	}))
+	defer child.Close()
+
+	now := time.Now().UTC()
+	if err := childStore.CreateNode(ctx, &fleet.Node{
+		ID: "edge-1", Name: "edge-1", Role: fleet.RoleServer, Region: "eu",
+		EnrolledAt: now, LastHeartbeat: now, Status: fleet.StatusOnline,
+	}, "hash-edge-1"); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	if err := childStore.StoreReport(ctx, "edge-1", []byte(`{"sections":[]}`)); err != nil {
+		t.Fatalf("StoreReport: %v", err)
+	}
+
+	// Parent controller with one local node, allowed to drill down into
+	// the child.
+	parentFH, parentStore := testFleetHandler(t)
+	parentFH.siteURLs = map[string]bool{child.URL: true}
+	parentMux := http.NewServeMux()
+	RegisterFleetRoutes(parentMux, parentFH)
+	parent := httptest.NewServer(parentMux)
+	defer parent.Close()
+
+	if err := parentStore.CreateNode(ctx, &fleet.Node{
+		ID: "hq-1", Name: "hq-1", Role: fleet.RoleServer,
+		EnrolledAt: now, LastHeartbeat: now, Status: fleet.StatusOnline,
+	}, "hash-hq-1"); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	enroll := func(role fleet.NodeRole) *fleet.AgentState {
+		tok, err := fleet.NewEnrollment(parentStore).CreateToken(ctx, fleet.CreateTokenRequest{Role: role, MaxUses: 1, ExpiresIn: 60})
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		st, err := fleet.RequestEnrollment(ctx, nil, parent.URL, fleet.EnrollmentRequest{Token: tok.Token, Name: "dc1-" + string(role)})
+		if err != nil {
+			t.Fatalf("RequestEnrollment: %v", err)
+		}
+		return st
+	}
+
+	// Non-controller nodes may not federate.
+	server := enroll(fleet.RoleServer)
+	req := httptest.NewRequest("POST", "/api/v1/fleet/federation/sync", bytes.NewBufferString(`{"full":true}`))
+	req.Header.Set("Authorization", "Bearer "+server.APIKey)
+	w := httptest.NewRecorder()
+	parentFH.HandleFederationSync(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("server-role sync status = %d, want 403", w.Code)
+	}
+
+	site := enroll(fleet.RoleController)
+	federator := fleet.NewFederator(fleet.FederatorConfig{
+		ParentURL: parent.URL,
+		APIKey:    site.APIKey,
+		SiteName:  "dc1",
+		Store:     childStore,
+		APIURL:    child.URL,
+		APIToken:  "child-dash-token",
+	})
+	if err := federator.Push(ctx); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// Federated node list covers both controllers, with site attribution.
+	w = httptest.NewRecorder()
+	parentFH.HandleListNodes(w, httptest.NewRequest("GET", "/api/v1/fleet/nodes", nil))
+	var nodes []fleet.Node
+	_ = json.Unmarshal(w.Body.Bytes(), &nodes)
+	sites := map[string]string{}
+	for _, n := range nodes {
+		sites[n.ID] = n.Site
+	}
+	if s, ok := sites["edge-1"]; !ok || s != site.NodeID {
+		t.Errorf("edge-1 site = %q (present %v), want %q", s, ok, site.NodeID)
+	}
+	if s, ok := sites["hq-1"]; !ok || s != "" {
+		t.Errorf("hq-1 site = %q (present %v), want local", s, ok)
+	}
+
+	w = httptest.NewRecorder()
+	parentFH.HandleListNodes(w, httptest.NewRequest("GET", "/api/v1/fleet/nodes?site=local", nil))
+	nodes = nil
+	_ = json.Unmarshal(w.Body.Bytes(), &nodes)
+	for _, n := range nodes {
+		if n.ID == "edge-1" {
+			t.Error("site=local should exclude federated nodes")
+		}
+	}
+
+	// Summary rolls up the child's counts.
+	w = httptest.NewRecorder()
+	parentFH.HandleSummary(w, httptest.NewRequest("GET", "/api/v1/fleet/summary", nil))
+	var summary fleet.FleetSummary
+	_ = json.Unmarshal(w.Body.Bytes(), &summary)
+	if summary.ByRegion["eu"] != 1 {
+		t.Errorf("summary ByRegion = %v, want child's eu node", summary.ByRegion)
+	}
+
+	// Drill-down: node details from the parent, report proxied to the child.
+	resp, err := http.Get(parent.URL + "/api/v1/fleet/nodes/edge-1")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET federated node: %v %v", err, resp)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(parent.URL + "/api/v1/fleet/nodes/edge-1/report")
+	if err != nil {
+		t.Fatalf("GET federated report: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("federated report status = %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Fleet-Site") != site.NodeID {
+		t.Errorf("X-Fleet-Site = %q", resp.Header.Get("X-Fleet-Site"))
+	}
+	if drillAuth != "Bearer child-dash-token" {
+		t.Errorf("drill-down Authorization = %q", drillAuth)
+	}
+
+	// A controller key cannot point the parent at another host, nor move
+	// its site to another allowed URL.
+	sync := func(apiURL string) int {
+		req := httptest.NewRequest("POST", "/api/v1/fleet/federation/sync",
+			bytes.NewBufferString(`{"full":true,"api_url":"`+apiURL+`","api_token":"t"}`))
+		req.Header.Set("Authorization", "Bearer "+site.APIKey)
+		w := httptest.NewRecorder()
+		parentFH.HandleFederationSync(w, req)
+		return w.Code
+	}
+	if code := sync("http://169.254.169.254/latest"); code != http.StatusForbidden {
+		t.Errorf("sync with unlisted api_url = %d, want 403", code)
+	}
+	parentFH.siteURLs["https://dc2.internal:8080"] = true
+	if code := sync("https://dc2.internal:8080"); code != http.StatusConflict {
+		t.Errorf("sync with changed api_url = %d, want 409", code)
+	}
+	if s, err := parentStore.GetSite(ctx, site.NodeID); err != nil || s.APIURL != child.URL {
+		t.Errorf("site after rejected syncs = %+v, %v", s, err)
+	}
+}
+
+// enrollTestNode enrolls a server node and returns its enrollment response.
+func enrollTestNode(tb testing.TB, store fleet.Store) *fleet.EnrollmentResponse {
+	tb.Helper()
+	ctx := context.Background()
+	enrollment := fleet.NewEnrollment(store)
+	tok, _ := enrollment.CreateToken(ctx, fleet.CreateTokenRequest{Role: fleet.RoleServer, MaxUses: 1, ExpiresIn: 3600})
+	resp, err := enrollment.Enroll(ctx, fleet.EnrollmentRequest{Token: tok.Token, Name: "srv"})
+	if err != nil {
+		tb.Fatalf("Enroll: %v", err)
+	}
+	return resp
+}
+
+// fleetRequest builds an authenticated node request, gzip-compressing the
+// body when compress is set.
+func fleetRequest(tb testing.TB, path, apiKey string, v interface{}, compress bool) *http.Request {
+	tb.Helper()
+	body, _ := json.Marshal(v)
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		body = buf.Bytes()
+	}
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req
+}
+
+func TestFleetHandler_DeltaReporting(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	ctx := context.Background()
+	node := enrollTestNode(t, store)
+
+	payload := fleet.ComplianceReportPayload{NodeID: node.NodeID}
+	payload.Report.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	payload.Report.Summary.Passed = 4
+	payload.Report.Summary.Failed = 1
+
+	// Gzip-compressed report is accepted and stored.
+	w := httptest.NewRecorder()
+	fh.HandleReport(w, fleetRequest(t, "/api/v1/fleet/report", node.APIKey, payload, true))
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("accepted")) {
+		t.Fatalf("report status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// Same content, later timestamp: not stored again.
+	payload.Report.Timestamp = time.Now().UTC().Add(time.Minute).Format(time.RFC3339)
+	w = httptest.NewRecorder()
+	fh.HandleReport(w, fleetRequest(t, "/api/v1/fleet/report", node.APIKey, payload, true))
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("unchanged")) {
+		t.Errorf("unchanged report = %d: %s", w.Code, w.Body.String())
+	}
+	hash, _ := store.GetReportHash(ctx, node.NodeID)
+	if hash != fleet.ReportHash(payload.Report) {
+		t.Errorf("stored hash = %q", hash)
+	}
+
+	// Heartbeat with the current hash gets 304 and keeps the node degraded.
+	w = httptest.NewRecorder()
+	fh.HandleHeartbeat(w, fleetRequest(t, "/api/v1/fleet/heartbeat", node.APIKey,
+		fleet.HeartbeatRequest{NodeID: node.NodeID, ReportHash: hash}, false))
+	if w.Code != http.StatusNotModified {
+		t.Errorf("matching heartbeat status = %d, want 304", w.Code)
+	}
+	got, _ := store.GetNode(ctx, node.NodeID)
+	if got.Status != fleet.StatusDegraded {
+		t.Errorf("status after heartbeat = %s, want degraded", got.Status)
+	}
+
+	// A stale hash gets 200, telling the agent to upload in full.
+	w = httptest.NewRecorder()
+	fh.HandleHeartbeat(w, fleetRequest(t, "/api/v1/fleet/heartbeat", node.APIKey,
+		fleet.HeartbeatRequest{NodeID: node.NodeID, ReportHash: "stale"}, false))
+	if w.Code != http.StatusOK {
+		t.Errorf("stale heartbeat status = %d, want 200", w.Code)
+	}
+
+	// Corrupt gzip is rejected.
+	req := httptest.NewRequest("POST", "/api/v1/fleet/report", bytes.NewBufferString("not gzip"))
+	req.Header.Set("Authorization", "Bearer "+node.APIKey)
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	fh.HandleReport(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("corrupt gzip status = %d, want 400", w.Code)
+	}
+}
+
+// benchmarkReport builds a report of roughly agent size (~40 items).
+func benchmarkReport(nodeID string) fleet.ComplianceReportPayload {
+	p := fleet.ComplianceReportPayload{NodeID: nodeID, Version: "1.0.0", Backend: "BoringCrypto"}
+	for s := 0; s < 4; s++ {
+		sec := compliance.Section{ID: fmt.Sprintf("sec-%d", s), Name: "Section", Description: "Benchmark section"}
+		for i := 0; i < 10; i++ {
+			sec.Items = append(sec.Items, compliance.ChecklistItem{
+				ID:                 fmt.Sprintf("s%d-%d", s, i),
+				Name:               "Benchmark check",
+				Status:             compliance.StatusPass,
+				Severity:           "high",
+				What:               "Verifies a FIPS-relevant control on the node",
+				Why:                "Required for FedRAMP continuous monitoring evidence",
+				Remediation:        "Follow the hardening guide for this control",
+				NISTRef:            "SC-13",
+				VerificationMethod: "direct",
+			})
+			p.Report.Summary.Passed++
+			p.Report.Summary.Total++
+		}
+		p.Report.Sections = append(p.Report.Sections, sec)
+	}
+	return p
+}
+
+// BenchmarkFleetIngest compares controller ingest per report interval:
+// the previous behaviour (every report uploaded uncompressed and stored),
+// an unchanged report uploaded gzip-compressed and deduplicated, and the
+// hash-carrying heartbeat an agent sends instead when nothing changed.
+// wire-B/op is the request body size.
+func BenchmarkFleetIngest(b *testing.B) {
+	run := func(b *testing.B, mkReq func(fh *FleetHandler, node *fleet.EnrollmentResponse, i int) (*http.Request, int), handle func(fh *FleetHandler) http.HandlerFunc) {
+		fh, store := testFleetHandlerB(b)
+		node := enrollTestNode(b, store)
+		fh.logger = log.New(io.Discard, "", 0)
+		// Seed the stored report so the unchanged cases have a baseline.
+		seed := benchmarkReport(node.NodeID)
+		w := httptest.NewRecorder()
+		fh.HandleReport(w, fleetRequest(b, "/api/v1/fleet/report", node.APIKey, seed, false))
+
+		var wire int
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			req, n := mkReq(fh, node, i)
+			wire += n
+			handle(fh)(httptest.NewRecorder(), req)
+		}
+		b.ReportMetric(float64(wire)/float64(b.N), "wire-B/op")
+	}
+
+	b.Run("full-uncompressed", func(b *testing.B) {
+		run(b, func(fh *FleetHandler, node *fleet.EnrollmentResponse, i int) (*http.Request, int) {
+			p := benchmarkReport(node.NodeID)
+			p.Report.Summary.Unknown = i + 1 // distinct content: stored every time
+			req := fleetRequest(b, "/api/v1/fleet/report", node.APIKey, p, false)
+			return req, int(req.ContentLength)
+		}, func(fh *FleetHandler) http.HandlerFunc { return fh.HandleReport })
+	})
+	b.Run("unchanged-gzip", func(b *testing.B) {
+		run(b, func(fh *FleetHandler, node *fleet.EnrollmentResponse, i int) (*http.Request, int) {
+			req := fleetRequest(b, "/api/v1/fleet/report", node.APIKey, benchmarkReport(node.NodeID), true)
+			return req, int(req.ContentLength)
+		}, func(fh *FleetHandler) http.HandlerFunc { return fh.HandleReport })
+	})
+	b.Run("unchanged-heartbeat-304", func(b *testing.B) {
+		hash := fleet.ReportHash(benchmarkReport("").Report)
+		run(b, func(fh *FleetHandler, node *fleet.EnrollmentResponse, i int) (*http.Request, int) {
+			req := fleetRequest(b, "/api/v1/fleet/heartbeat", node.APIKey,
+				fleet.HeartbeatRequest{NodeID: node.NodeID, ReportHash: hash}, false)
+			return req, int(req.ContentLength)
+		}, func(fh *FleetHandler) http.HandlerFunc { return fh.HandleHeartbeat })
+	})
+}
+
+func testFleetHandlerB(b *testing.B) (*FleetHandler, fleet.Store) {
+	b.Helper()
+	store, err := fleet.NewSQLiteStore(filepath.Join(b.TempDir(), "bench-fleet.db"))
+	if err != nil {
+		b.Fatalf("NewSQLiteStore: %v", err)
+	}
+	b.Cleanup(func() { store.Close() })
+	return NewFleetHandler(FleetHandlerConfig{Store: store, AdminKey: "admin-secret"}), store
+}
+
+func TestFleetHandler_NodeHistory(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	ctx := context.Background()
+	node := enrollTestNode(t, store)
+	_ = store.UpdateNodeStatus(ctx, node.NodeID, fleet.StatusOffline)
+	_ = store.UpdateNodeHeartbeat(ctx, node.NodeID, time.Now().UTC())
+
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/fleet/nodes/"+node.NodeID, nil))
+	var got fleet.Node
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("get node = %d: %s", w.Code, w.Body.String())
+	}
+	if got.Availability == nil {
+		t.Fatal("node response missing availability")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/fleet/nodes/"+node.NodeID+"/history", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("history = %d: %s", w.Code, w.Body.String())
+	}
+	var hist struct {
+		Transitions  []fleet.StatusTransition `json:"transitions"`
+		Availability *fleet.Availability      `json:"availability"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &hist); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(hist.Transitions) != 3 {
+		t.Errorf("transitions = %+v, want enrollment, offline, online", hist.Transitions)
+	}
+	if hist.Availability == nil {
+		t.Error("history missing availability")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/fleet/nodes/"+node.NodeID+"/history?since=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad since = %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/fleet/nodes/missing/history", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown node = %d, want 404", w.Code)
+	}
+}
+
+// fakeZoneAPI serves the Cloudflare zone settings endpoints used by edge
+// remediation from an in-memory map.
+func fakeZoneAPI(t *testing.T, settings map[string]json.RawMessage) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if r.Method == http.MethodPatch {
+			var body struct {
+				Value json.RawMessage `json:"value"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			settings[name] = body.Value
+		}
+		value, ok := settings[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":1001,"message":"unknown setting"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"success":true,"result":{"id":%q,"value":%s}}`, name, value)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFleetHandler_EdgeRemediation(t *testing.T) {
+	settings := map[string]json.RawMessage{
+		"min_tls_version": json.RawMessage(`"1.0"`),
+		"ciphers":         json.RawMessage(`[]`),
+		"security_header": json.RawMessage(`{"strict_transport_security":{"enabled":true,"max_age":31536000,"include_subdomains":true,"nosniff":true}}`),
+	}
+	srv := fakeZoneAPI(t, settings)
+	client := cfapi.NewClient("token", cfapi.WithBaseURL(srv.URL))
+	check := func() compliance.Section {
+		status := compliance.StatusFail
+		if v, _ := client.GetMinTLSVersion("zone-1"); v == "1.2" {
+			status = compliance.StatusPass
+		}
+		return compliance.Section{ID: "edge", Items: []compliance.ChecklistItem{
+			{ID: "ce-6", Name: "Minimum TLS version", Status: status},
+			{ID: "ce-8", Name: "HSTS", Status: compliance.StatusPass},
+		}}
+	}
+
+	fh, _ := testFleetHandler(t)
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+
+	// Not configured: endpoints report it.
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/fleet/remediate/edge/plan", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unconfigured plan = %d, want 404", w.Code)
+	}
+
+	fh.edge = remediate.NewEdgeRemediator(remediate.EdgeRemediatorConfig{
+		Client: client, ZoneID: "zone-1", Check: check, Logger: log.New(io.Discard, "", 0),
+	})
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var plan []remediate.RemediationAction
+	if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("plan = %d: %s", w.Code, w.Body.String())
+	}
+	if len(plan) != 1 || plan[0].ID != remediate.ActionFixMinTLS || len(plan[0].Changes) != 1 {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/fleet/remediate/edge", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(`{"actions":["enable_os_fips"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("node action = %d, want 400", w.Code)
+	}
+
+	w = post(`{"actions":["fix_min_tls"],"dry_run":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("dry run = %d: %s", w.Code, w.Body.String())
+	}
+	if string(settings["min_tls_version"]) != `"1.0"` {
+		t.Errorf("dry run changed the zone: %s", settings["min_tls_version"])
+	}
+
+	w = post(`{"actions":["fix_min_tls"]}`)
+	var result remediate.RemediationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("execute = %d: %s", w.Code, w.Body.String())
+	}
+	if string(settings["min_tls_version"]) != `"1.2"` {
+		t.Errorf("min_tls_version = %s, want \"1.2\"", settings["min_tls_version"])
+	}
+	a := result.Actions[0]
+	if a.Status != remediate.StatusSuccess || a.Verification == nil || a.Verification.Status != "pass" {
+		t.Errorf("action = %+v", a)
+	}
+}
+
+func TestFleetHandler_RemediationRejectsReboot(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	node := enrollTestNode(t, store)
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+
+	for _, body := range []string{`{"actions":["reboot"]}`, `{"actions":["enable_os_fips","reboot"],"dry_run":true}`} {
+		req := httptest.NewRequest("POST", "/api/v1/fleet/nodes/"+node.NodeID+"/remediate", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "reboot coordinator") {
+			t.Errorf("%s = %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+	if reqs, _ := store.ListRemediationRequests(context.Background(), fleet.RemediationFilter{NodeID: node.NodeID}); len(reqs) != 0 {
+		t.Errorf("reboot request stored: %+v", reqs)
+	}
+}
+
+func TestFleetHandler_RemediationRollback(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	auditLogger, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLogger.Close() })
+	fh.audit = auditLogger
+	node := enrollTestNode(t, store)
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	base := "/api/v1/fleet/nodes/" + node.NodeID + "/remediate"
+
+	w := admin("POST", base, `{"actions":["enable_os_fips"]}`)
+	var orig fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &orig); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("request remediation = %d: %s", w.Code, w.Body.String())
+	}
+
+	// Not completed yet.
+	if w := admin("POST", base+"/"+orig.ID+"/rollback", ""); w.Code != http.StatusConflict {
+		t.Errorf("rollback of pending request = %d, want 409", w.Code)
+	}
+
+	// The agent reports success with the captured prior state.
+	prevResult := remediate.RemediationResult{
+		RequestID: orig.ID,
+		NodeID:    node.NodeID,
+		Actions: []remediate.RemediationAction{{
+			ID: remediate.ActionEnableOSFIPS, CheckID: "ag-fips", Status: remediate.StatusNeedsReboot,
+			PriorState: &remediate.PriorState{CryptoPolicy: "FUTURE", KernelArgs: "ro quiet"},
+		}},
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, fleetRequest(t, base+"/result", node.APIKey, map[string]interface{}{
+		"request_id": orig.ID, "result": prevResult,
+	}, false))
+	if w.Code != http.StatusOK {
+		t.Fatalf("post result = %d: %s", w.Code, w.Body.String())
+	}
+
+	w = admin("POST", base+"/"+orig.ID+"/rollback", "")
+	var rb fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &rb); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("request rollback = %d: %s", w.Code, w.Body.String())
+	}
+	if rb.Type != fleet.RemediationTypeRollback || rb.RollbackOf != orig.ID {
+		t.Errorf("rollback request = %+v", rb)
+	}
+	if w := admin("POST", base+"/"+rb.ID+"/rollback", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("rollback of rollback = %d, want 400", w.Code)
+	}
+
+	// The agent's poll carries the previous result and its prior state.
+	req := httptest.NewRequest("GET", base, nil)
+	req.Header.Set("Authorization", "Bearer "+node.APIKey)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var pending []struct {
+		Type     remediate.RequestType        `json:"type"`
+		Previous *remediate.RemediationResult `json:"previous"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &pending); err != nil || len(pending) != 1 {
+		t.Fatalf("poll = %s", w.Body.String())
+	}
+	if pending[0].Type != remediate.RequestRollback || pending[0].Previous == nil ||
+		pending[0].Previous.Actions[0].PriorState.CryptoPolicy != "FUTURE" {
+		t.Errorf("pending rollback = %+v", pending[0])
+	}
+
+	rbResult := remediate.RemediationResult{
+		RequestID: rb.ID, NodeID: node.NodeID, Type: remediate.RequestRollback, RollbackOf: orig.ID,
+		Actions: []remediate.RemediationAction{{
+			ID: remediate.ActionEnableOSFIPS, Status: remediate.StatusNeedsReboot,
+			Verification: &remediate.Verification{CheckID: "ag-fips", Status: "pass"},
+		}},
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, fleetRequest(t, base+"/result", node.APIKey, map[string]interface{}{
+		"request_id": rb.ID, "result": rbResult,
+	}, false))
+	if w.Code != http.StatusOK {
+		t.Fatalf("post rollback result = %d: %s", w.Code, w.Body.String())
+	}
+
+	audited := make(map[string]audit.AuditEvent)
+	for _, e := range auditLogger.RecentEvents(10) {
+		audited[e.Action] = e
+	}
+	if e, ok := audited["rollback_requested"]; !ok || e.Actor != fh.adminActor() || e.SourceIP == "" {
+		t.Errorf("rollback request not audited: %+v", audited)
+	}
+	if e, ok := audited["rolled_back"]; !ok || !strings.Contains(e.Detail, "verified ag-fips=pass") {
+		t.Errorf("rollback outcome not audited: %+v", e)
+	}
+}
+
+func TestFleetHandler_AuditsAdminOperations(t *testing.T) {
+	fh, _ := testFleetHandler(t)
+	auditLogger, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLogger.Close() })
+	fh.audit = auditLogger
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+	call := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.RemoteAddr = "198.51.100.7:4242"
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	var token fleet.EnrollmentToken
+	w := call("admin-secret", "POST", "/api/v1/fleet/tokens", `{"role":"server","max_uses":1}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create token = %d: %s", w.Code, w.Body.String())
+	}
+	var enrolled fleet.EnrollmentResponse
+	w = call("", "POST", "/api/v1/fleet/enroll", `{"token":"`+token.Token+`","name":"s1"}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &enrolled); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("enroll = %d: %s", w.Code, w.Body.String())
+	}
+	call("", "POST", "/api/v1/fleet/enroll", `{"token":"bogus","name":"s2"}`)
+	call("admin-secret", "PUT", "/api/v1/fleet/policy", `{"enforcement_mode":"enforce"}`)
+	call("admin-secret", "DELETE", "/api/v1/fleet/nodes/"+enrolled.NodeID, "")
+	call("admin-secret", "DELETE", "/api/v1/fleet/tokens/"+token.ID, "")
+	call("wrong-key", "GET", "/api/v1/fleet/tokens", "")
+
+	audited := make(map[string]audit.AuditEvent)
+	for _, e := range auditLogger.RecentEvents(20) {
+		if e.SourceIP != "198.51.100.7" {
+			t.Errorf("%s: source IP = %q", e.Action, e.SourceIP)
+		}
+		if strings.Contains(e.After, token.Token) || strings.Contains(e.Detail, token.Token) {
+			t.Errorf("%s: token secret logged", e.Action)
+		}
+		audited[e.Action] = e
+	}
+	for action, want := range map[string]struct{ eventType, actor string }{
+		"token_created": {"credential_lifecycle", fh.adminActor()},
+		"node_enrolled": {"credential_lifecycle", "node:" + enrolled.NodeID},
+		"enroll_failed": {"auth_attempt", "api:198.51.100.7"},
+		"modified":      {"config_change", fh.adminActor()},
+		"node_removed":  {"credential_lifecycle", fh.adminActor()},
+		"token_revoked": {"credential_lifecycle", fh.adminActor()},
+		"login_failed":  {"auth_attempt", "api:198.51.100.7"},
+	} {
+		e, ok := audited[action]
+		if !ok || e.EventType != want.eventType || e.Actor != want.actor {
+			t.Errorf("%s: got %+v, want %s by %s", action, e, want.eventType, want.actor)
+		}
+	}
+	if e := audited["modified"]; !strings.Contains(e.Before, `"enforcement_mode":"audit"`) || !strings.Contains(e.After, `"enforcement_mode":"enforce"`) {
+		t.Errorf("policy before/after = %q / %q", e.Before, e.After)
+	}
+	if e := audited["node_removed"]; !strings.Contains(e.Before, `"name":"s1"`) {
+		t.Errorf("node before = %q", e.Before)
+	}
+	if !strings.HasPrefix(fh.adminActor(), "admin:") || strings.Contains(fh.adminActor(), "admin-secret") {
+		t.Errorf("adminActor = %q", fh.adminActor())
+	}
+}
+
+func TestFleetHandler_EdgeRollback(t *testing.T) {
+	settings := map[string]json.RawMessage{
+		"min_tls_version": json.RawMessage(`"1.0"`),
+	}
+	srv := fakeZoneAPI(t, settings)
+	client := cfapi.NewClient("token", cfapi.WithBaseURL(srv.URL))
+	check := func() compliance.Section {
+		status := compliance.StatusFail
+		if v, _ := client.GetMinTLSVersion("zone-1"); v == "1.2" {
+			status = compliance.StatusPass
+		}
+		return compliance.Section{ID: "edge", Items: []compliance.ChecklistItem{{ID: "ce-6", Status: status}}}
+	}
+	fh, store := testFleetHandler(t)
+	edge := remediate.NewEdgeRemediator(remediate.EdgeRemediatorConfig{
+		Client: client, ZoneID: "zone-1", Check: check, Logger: log.New(io.Discard, "", 0),
+	})
+	fh.edge = edge
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/fleet/remediate/edge", `{"actions":["fix_min_tls"]}`)
+	var applied remediate.RemediationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &applied); err != nil || string(settings["min_tls_version"]) != `"1.2"` {
+		t.Fatalf("apply = %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := post("/api/v1/fleet/remediate/edge/rem-unknown/rollback", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown rollback = %d, want 404", w.Code)
+	}
+
+	// The applied result is in the fleet store, so a restarted controller
+	// can still roll it back.
+	fh = NewFleetHandler(FleetHandlerConfig{Store: store, AdminKey: "admin-secret", EdgeRemediator: edge})
+	mux = http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+
+	w = post("/api/v1/fleet/remediate/edge/"+applied.RequestID+"/rollback", "")
+	var result remediate.RemediationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("rollback = %d: %s", w.Code, w.Body.String())
+	}
+	if string(settings["min_tls_version"]) != `"1.0"` {
+		t.Errorf("min_tls_version = %s, want \"1.0\"", settings["min_tls_version"])
+	}
+	a := result.Actions[0]
+	if result.RollbackOf != applied.RequestID || a.Status != remediate.StatusSuccess ||
+		a.Verification == nil || a.Verification.Status != "fail" {
+		t.Errorf("rollback result = %+v", result)
+	}
+	if w := post("/api/v1/fleet/remediate/edge/"+result.RequestID+"/rollback", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("rollback of rollback = %d, want 400", w.Code)
+	}
+}
+
+func TestFleetHandler_RemediationApproval(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	auditLogger, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLogger.Close() })
+	fh.audit = auditLogger
+	fh.approval = &ApprovalConfig{
+		Required:  2,
+		Approvers: map[string]string{"alice": "alice-key", "bob": "bob-key", "carol": "carol-key"},
+	}
+	node := enrollTestNode(t, store)
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+
+	as := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	poll := func() []fleet.RemediationRequest {
+		var got []fleet.RemediationRequest
+		w := as(node.APIKey, "GET", "/api/v1/fleet/nodes/"+node.NodeID+"/remediate", "")
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("poll = %d: %s", w.Code, w.Body.String())
+		}
+		return got
+	}
+	base := "/api/v1/fleet/nodes/" + node.NodeID + "/remediate"
+
+	if w := as("wrong", "POST", base, `{"actions":["enable_os_fips"]}`); w.Code != http.StatusForbidden {
+		t.Errorf("unknown key = %d, want 403", w.Code)
+	}
+
+	// Dry runs are not gated.
+	if w := as("alice-key", "POST", base, `{"actions":["enable_os_fips"],"dry_run":true}`); w.Code != http.StatusCreated {
+		t.Fatalf("dry run = %d: %s", w.Code, w.Body.String())
+	}
+	if got := poll(); len(got) != 1 || !got[0].DryRun {
+		t.Fatalf("dry run should be released immediately, poll = %+v", got)
+	}
+
+	w := as("alice-key", "POST", base, `{"actions":["enable_os_fips"]}`)
+	var req fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &req); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("request = %d: %s", w.Code, w.Body.String())
+	}
+	if req.Status != fleet.RemediationAwaitingApproval || req.RequestedBy != "approver:alice" ||
+		req.RequiredApprovals != 2 || req.ApprovalExpiresAt == nil {
+		t.Fatalf("request = %+v", req)
+	}
+	if got := poll(); len(got) != 1 {
+		t.Errorf("unapproved request visible to agent: %+v", got)
+	}
+	// Nor can the node report it done.
+	result := base + "/result"
+	if w := as(node.APIKey, "POST", result, `{"request_id":"`+req.ID+`","result":{}}`); w.Code != http.StatusConflict {
+		t.Errorf("result for unapproved request = %d, want 409", w.Code)
+	}
+
+	approve := base + "/" + req.ID + "/approve"
+	if w := as("admin-secret", "POST", approve, ""); w.Code != http.StatusForbidden {
+		t.Errorf("admin approval = %d, want 403", w.Code)
+	}
+	if w := as("alice-key", "POST", approve, ""); w.Code != http.StatusForbidden {
+		t.Errorf("self approval = %d, want 403", w.Code)
+	}
+	if w := as("bob-key", "POST", approve, ""); w.Code != http.StatusOK {
+		t.Fatalf("bob approval = %d: %s", w.Code, w.Body.String())
+	}
+	if w := as("bob-key", "POST", approve, ""); w.Code != http.StatusForbidden {
+		t.Errorf("duplicate approval = %d, want 403", w.Code)
+	}
+	if got := poll(); len(got) != 1 {
+		t.Errorf("request released after one approval: %+v", got)
+	}
+	w = as("carol-key", "POST", approve, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &req); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("carol approval = %d: %s", w.Code, w.Body.String())
+	}
+	if req.Status != fleet.RemediationPending || len(req.Approvals) != 2 {
+		t.Errorf("approved request = %+v", req)
+	}
+	if got := poll(); len(got) != 2 {
+		t.Errorf("approved request not released, poll = %+v", got)
+	}
+	if w := as("carol-key", "POST", approve, ""); w.Code != http.StatusConflict {
+		t.Errorf("approval of released request = %d, want 409", w.Code)
+	}
+
+	// An expired request can no longer be approved and is listed as expired.
+	past := time.Now().UTC().Add(-time.Hour)
+	stale := fleet.RemediationRequest{
+		ID: "stale-1", NodeID: node.NodeID, Actions: []string{"connect_warp"},
+		Status: fleet.RemediationAwaitingApproval, CreatedAt: past.Add(-time.Hour),
+		RequestedBy: "admin", RequiredApprovals: 2, ApprovalExpiresAt: &past,
+	}
+	if err := store.CreateRemediationRequest(context.Background(), &stale); err != nil {
+		t.Fatal(err)
+	}
+	// The controller expires it on its own, without an operator listing
+	// requests.
+	ctx, cancel := context.WithCancel(context.Background())
+	go fh.RunApprovalExpiry(ctx, 10*time.Millisecond)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if got, _ := store.GetRemediationRequest(context.Background(), stale.ID); got.Status == fleet.RemediationExpired {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("request not expired by RunApprovalExpiry")
+		}
+	}
+	cancel()
+	if w := as(node.APIKey, "POST", result, `{"request_id":"`+stale.ID+`","result":{}}`); w.Code != http.StatusConflict {
+		t.Errorf("result for expired request = %d, want 409", w.Code)
+	}
+	w = as("admin-secret", "GET", "/api/v1/fleet/remediate/requests?status=expired", "")
+	var listed []fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list = %d: %s", w.Code, w.Body.String())
+	}
+	if len(listed) != 1 || listed[0].ID != stale.ID {
+		t.Errorf("expired list = %+v", listed)
+	}
+	if w := as("bob-key", "POST", base+"/"+stale.ID+"/approve", ""); w.Code != http.StatusConflict && w.Code != http.StatusGone {
+		t.Errorf("approval of expired request = %d, want 409 or 410", w.Code)
+	}
+
+	var released, expired bool
+	for _, e := range auditLogger.RecentEvents(20) {
+		switch e.Action {
+		case "remediation_approved":
+			released = released || (strings.Contains(e.Detail, "released") &&
+				strings.Contains(e.Detail, "requested by approver:alice") &&
+				strings.Contains(e.Detail, "approved by approver:bob") &&
+				strings.Contains(e.Detail, "approved by approver:carol"))
+		case "approval_expired":
+			expired = true
+		}
+	}
+	if !released {
+		t.Error("approval chain not audited on release")
+	}
+	if !expired {
+		t.Error("expiry not audited")
+	}
+}
+
+func TestFleetHandler_EdgeRemediationApproval(t *testing.T) {
+	settings := map[string]json.RawMessage{
+		"min_tls_version": json.RawMessage(`"1.0"`),
+	}
+	srv := fakeZoneAPI(t, settings)
+	client := cfapi.NewClient("token", cfapi.WithBaseURL(srv.URL))
+	check := func() compliance.Section {
+		status := compliance.StatusFail
+		if v, _ := client.GetMinTLSVersion("zone-1"); v == "1.2" {
+			status = compliance.StatusPass
+		}
+		return compliance.Section{ID: "edge", Items: []compliance.ChecklistItem{{ID: "ce-6", Status: status}}}
+	}
+	fh, store := testFleetHandler(t)
+	fh.edge = remediate.NewEdgeRemediator(remediate.EdgeRemediatorConfig{
+		Client: client, ZoneID: "zone-1", Check: check, Logger: log.New(io.Discard, "", 0),
+	})
+	fh.approval = &ApprovalConfig{
+		Required:  1,
+		Approvers: map[string]string{"alice": "alice-key", "bob": "bob-key"},
+	}
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+	as := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Dry runs are not gated.
+	if w := as("admin-secret", "POST", "/api/v1/fleet/remediate/edge", `{"actions":["fix_min_tls"],"dry_run":true}`); w.Code != http.StatusOK {
+		t.Fatalf("dry run = %d: %s", w.Code, w.Body.String())
+	}
+
+	w := as("alice-key", "POST", "/api/v1/fleet/remediate/edge", `{"actions":["fix_min_tls"]}`)
+	var req fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &req); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("request = %d: %s", w.Code, w.Body.String())
+	}
+	if req.Status != fleet.RemediationAwaitingApproval || req.ZoneID != "zone-1" || req.RequestedBy != "approver:alice" {
+		t.Errorf("request = %+v", req)
+	}
+
+	approve := "/api/v1/fleet/remediate/edge/" + req.ID + "/approve"
+	if w := as("admin-secret", "POST", approve, ""); w.Code != http.StatusForbidden {
+		t.Errorf("admin approval = %d, want 403", w.Code)
+	}
+	if w := as("alice-key", "POST", approve, ""); w.Code != http.StatusForbidden {
+		t.Errorf("self approval = %d, want 403", w.Code)
+	}
+	if string(settings["min_tls_version"]) != `"1.0"` {
+		t.Fatalf("edge change applied before approval: %s", settings["min_tls_version"])
+	}
+	var awaiting []fleet.RemediationRequest
+	w = as("bob-key", "GET", "/api/v1/fleet/remediate/edge/requests?status=awaiting_approval", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &awaiting); err != nil || len(awaiting) != 1 || awaiting[0].ID != req.ID {
+		t.Fatalf("awaiting = %d: %s", w.Code, w.Body.String())
+	}
+
+	// A second operator's approval applies the change.
+	w = as("bob-key", "POST", approve, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &req); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("bob approval = %d: %s", w.Code, w.Body.String())
+	}
+	if string(settings["min_tls_version"]) != `"1.2"` {
+		t.Errorf("min_tls_version = %s, want \"1.2\"", settings["min_tls_version"])
+	}
+	if req.Status != fleet.RemediationCompleted || len(req.Approvals) != 1 || len(req.Result) == 0 {
+		t.Errorf("approved request = %+v", req)
+	}
+	if w := as("bob-key", "POST", approve, ""); w.Code != http.StatusConflict {
+		t.Errorf("approval of applied request = %d, want 409", w.Code)
+	}
+
+	// Rolling it back needs approval too.
+	w = as("admin-secret", "POST", "/api/v1/fleet/remediate/edge/"+req.ID+"/rollback", "")
+	var rb fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &rb); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("rollback = %d: %s", w.Code, w.Body.String())
+	}
+	if string(settings["min_tls_version"]) != `"1.2"` {
+		t.Errorf("rollback applied before approval: %s", settings["min_tls_version"])
+	}
+	if w := as("alice-key", "POST", "/api/v1/fleet/remediate/edge/"+rb.ID+"/approve", ""); w.Code != http.StatusOK {
+		t.Fatalf("rollback approval = %d: %s", w.Code, w.Body.String())
+	}
+	if string(settings["min_tls_version"]) != `"1.0"` {
+		t.Errorf("min_tls_version after rollback = %s, want \"1.0\"", settings["min_tls_version"])
+	}
+
+	// A request the controller cannot apply is stored as failed.
+	orphan := fleet.RemediationRequest{
+		ID: "edge-orphan", ZoneID: "zone-1", Actions: []string{"fix_min_tls"},
+		Type: fleet.RemediationTypeRollback, RollbackOf: "edge-missing",
+		Status: fleet.RemediationPending, CreatedAt: time.Now().UTC(), RequestedBy: "admin",
+	}
+	if err := store.CreateEdgeRemediation(context.Background(), &orphan); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fh.applyEdgeRemediation(httptest.NewRequest("POST", "/", nil), &orphan); err == nil {
+		t.Fatal("expected error applying rollback of unknown request")
+	}
+	failed, err := store.GetEdgeRemediation(context.Background(), orphan.ID)
+	if err != nil || failed.Status != fleet.RemediationFailed || !strings.Contains(string(failed.Result), "edge-missing not found") {
+		t.Errorf("failed request = %+v, %v", failed, err)
+	}
+}
+
+func TestFleetHandler_RebootCoordination(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	fh.reboots = fleet.NewRebootCoordinator(fleet.RebootCoordinatorConfig{
+		Store:  store,
+		Logger: log.New(io.Discard, "", 0),
+	})
+	node := enrollTestNode(t, store)
+	mux := http.NewServeMux()
+	RegisterFleetRoutes(mux, fh)
+	ctx := context.Background()
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+	postResult := func(reqID string, result remediate.RemediationResult) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, fleetRequest(t, "/api/v1/fleet/nodes/"+node.NodeID+"/remediate/result", node.APIKey,
+			map[string]interface{}{"request_id": reqID, "result": result}, false))
+		if w.Code != http.StatusOK {
+			t.Fatalf("post result = %d: %s", w.Code, w.Body.String())
+		}
+	}
+	reboots := func() []fleet.Reboot {
+		t.Helper()
+		var got []fleet.Reboot
+		w := admin("GET", "/api/v1/fleet/reboots?node="+node.NodeID, "")
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("list reboots = %d: %s", w.Code, w.Body.String())
+		}
+		return got
+	}
+
+	// Maintenance windows
+	if w := admin("POST", "/api/v1/fleet/maintenance-windows", `{"node_id":"x","start":"25:00","duration_minutes":60}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid window = %d, want 400", w.Code)
+	}
+	w := admin("POST", "/api/v1/fleet/maintenance-windows",
+		`{"labels":{"env":"prod"},"days":["Sat","sun"],"start":"02:00","duration_minutes":120,"timezone":"UTC"}`)
+	var window fleet.MaintenanceWindow
+	if err := json.Unmarshal(w.Body.Bytes(), &window); err != nil || w.Code != http.StatusCreated || window.ID == "" {
+		t.Fatalf("create window = %d: %s", w.Code, w.Body.String())
+	}
+	var windows []fleet.MaintenanceWindow
+	w = admin("GET", "/api/v1/fleet/maintenance-windows", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &windows); err != nil || len(windows) != 1 || windows[0].Days[0] != "sat" {
+		t.Fatalf("list windows = %d: %s", w.Code, w.Body.String())
+	}
+
+	// A needs_reboot result queues a reboot verifying its check.
+	w = admin("POST", "/api/v1/fleet/nodes/"+node.NodeID+"/remediate", `{"actions":["enable_os_fips"]}`)
+	var req fleet.RemediationRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &req); err != nil {
+		t.Fatal(err)
+	}
+	postResult(req.ID, remediate.RemediationResult{RequestID: req.ID, Actions: []remediate.RemediationAction{{
+		ID: remediate.ActionEnableOSFIPS, CheckID: "ag-fips", Status: remediate.StatusNeedsReboot,
+	}}})
+	got := reboots()
+	if len(got) != 1 || got[0].Status != fleet.RebootPending || got[0].RemediationID != req.ID ||
+		len(got[0].CheckIDs) != 1 || got[0].CheckIDs[0] != "ag-fips" {
+		t.Fatalf("reboots = %+v", got)
+	}
+
+	// The agent acknowledges the coordinator's reboot request.
+	rebootReq := &fleet.RemediationRequest{
+		ID: "reboot-1", NodeID: node.NodeID, Actions: []string{"reboot"}, Status: fleet.RemediationPending,
+		CreatedAt: time.Now().UTC(), RequestedBy: fleet.RebootCoordinatorActor,
+	}
+	if err := store.CreateRemediationRequest(ctx, rebootReq); err != nil {
+		t.Fatal(err)
+	}
+	scheduledAt := time.Now().UTC()
+	rb := got[0]
+	rb.Status, rb.RebootRequestID, rb.ScheduledAt = fleet.RebootScheduled, rebootReq.ID, &scheduledAt
+	if err := store.UpdateReboot(ctx, &rb); err != nil {
+		t.Fatal(err)
+	}
+	completed := time.Now().UTC().Truncate(time.Second)
+	postResult(rebootReq.ID, remediate.RemediationResult{RequestID: rebootReq.ID, CompletedAt: completed,
+		Actions: []remediate.RemediationAction{{ID: remediate.ActionReboot, Status: remediate.StatusSuccess}}})
+	got = reboots()
+	if len(got) != 1 || got[0].Status != fleet.RebootRebooting || got[0].RebootAt == nil ||
+		!got[0].RebootAt.Equal(completed.Add(remediate.RebootDelay)) {
+		t.Fatalf("after acknowledgement: %+v", got)
+	}
+
+	if w := admin("DELETE", "/api/v1/fleet/maintenance-windows/"+window.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("delete window = %d", w.Code)
+	}
+	if w := admin("DELETE", "/api/v1/fleet/maintenance-windows/"+window.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete missing window = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
+)
+
+// HandleListMaintenanceWindows returns the maintenance windows in which the
+// reboot coordinator may restart nodes (admin only).
+func (fh *FleetHandler) HandleListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	if !fh.requireAdmin(w, r) {
+		return
+	}
+	windows, err := fh.store.ListMaintenanceWindows(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list maintenance windows"})
+		return
+	}
+	if windows == nil {
+		windows = []fleet.MaintenanceWindow{}
+	}
+	writeJSON(w, http.StatusOK, windows)
+}
+
+// HandleCreateMaintenanceWindow adds a per-node or per-label maintenance
+// window (admin only).
+func (fh *FleetHandler) HandleCreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if !fh.requireAdmin(w, r) {
+		return
+	}
+	var window fleet.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := window.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	window.ID = fmt.Sprintf("mw-%d", time.Now().UnixNano())
+	window.CreatedAt = time.Now().UTC()
+	if err := fh.store.CreateMaintenanceWindow(r.Context(), &window); err != nil {
+		fh.logger.Printf("fleet: create maintenance window error: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create maintenance window"})
+		return
+	}
+	fh.logger.Printf("fleet: maintenance window %s created (node=%q labels=%v start=%s duration=%dm)",
+		window.ID, window.NodeID, window.Labels, window.Start, window.DurationMinutes)
+	writeJSON(w, http.StatusCreated, window)
+}
+
+// HandleDeleteMaintenanceWindow removes a maintenance window (admin only).
+func (fh *FleetHandler) HandleDeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if !fh.requireAdmin(w, r) {
+		return
+	}
+	err := fh.store.DeleteMaintenanceWindow(r.Context(), r.PathValue("id"))
+	if errors.Is(err, fleet.ErrWindowNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "maintenance window not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to delete maintenance window"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// HandleListReboots lists coordinated reboots, optionally filtered by
+// ?node= and ?status= (admin only).
+func (fh *FleetHandler) HandleListReboots(w http.ResponseWriter, r *http.Request) {
+	if !fh.requireAdmin(w, r) {
+		return
+	}
+	filter := fleet.RebootFilter{NodeID: r.URL.Query().Get("node")}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter.Statuses = []fleet.RebootStatus{fleet.RebootStatus(status)}
+	}
+	reboots, err := fh.store.ListReboots(r.Context(), filter)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to list reboots"})
+		return
+	}
+	if reboots == nil {
+		reboots = []fleet.Reboot{}
+	}
+	writeJSON(w, http.StatusOK, reboots)
+}
+
+// trackReboot hands needs_reboot actions in a remediation result to the
+// reboot coordinator, and records the agent's answer to a reboot request
+// the coordinator made.
+func (fh *FleetHandler) trackReboot(ctx context.Context, req *fleet.RemediationRequest, result *remediate.RemediationResult) {
+	if fh.reboots == nil || req.DryRun {
+		return
+	}
+	if req.RequestedBy == fleet.RebootCoordinatorActor {
+		var rebootErr error
+		for _, a := range result.Actions {
+			if a.ID == remediate.ActionReboot && a.Status != remediate.StatusSuccess {
+				rebootErr = fmt.Errorf("%s: %s", a.Status, a.Output)
+			}
+		}
+		if len(result.Actions) == 0 {
+			rebootErr = errors.New("no result for reboot action")
+		}
+		rebootAt := result.CompletedAt
+		if rebootAt.IsZero() {
+			rebootAt = time.Now().UTC()
+		}
+		if err := fh.reboots.RebootStarted(ctx, req.NodeID, req.ID, rebootAt.Add(remediate.RebootDelay), rebootErr); err != nil {
+			fh.logger.Printf("fleet: record reboot of node %s: %v", req.NodeID, err)
+		}
+		return
+	}
+
+	var checks []string
+	needsReboot := false
+	for _, a := range result.Actions {
+		if a.Status != remediate.StatusNeedsReboot {
+			continue
+		}
+		needsReboot = true
+		// A rolled-back fix is expected to fail its check again, so only
+		// the node's return is verified.
+		if req.Type != fleet.RemediationTypeRollback {
+			checks = append(checks, a.CheckID)
+		}
+	}
+	if !needsReboot {
+		return
+	}
+	if _, err := fh.reboots.RequestReboot(ctx, req.NodeID, req.ID, checks); err != nil {
+		fh.logger.Printf("fleet: request reboot of node %s: %v", req.NodeID, err)
+	}
+}
@@ -6,19 +6,25 @@
 package dashboard
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
 	"github.com/cloudflared-fips/cloudflared-fips/internal/selftest"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit/auditdb"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/buildinfo"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
 	"github.com/cloudflared-fips/cloudflared-fips/pkg/manifest"
 )
 
@@ -27,7 +33,18 @@ type Handler struct {
 	ManifestPath string
 	Checker      *compliance.Checker
 	AuditLogger  *audit.AuditLogger
+	// AuditIndex serves audit history queries. Without it queries only see
+	// the logger's in-memory ring buffer.
+	AuditIndex   *auditdb.Index
 	AlertManager *alerts.AlertManager
+	// PermRemediator fixes secret file and audit log permissions on this
+	// host. Nil disables the remediation endpoints.
+	PermRemediator *remediate.PermRemediator
+
+	sseClients atomic.Int64
+
+	remMu      sync.Mutex
+	remResults []remediate.RemediationResult // recent host remediations, for rollback
 }
 
 // NewHandler creates a new dashboard handler.
@@ -130,6 +147,9 @@ func (h *Handler) HandleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
 
+	h.sseClients.Add(1)
+	defer h.sseClients.Add(-1)
+
 	// Send initial compliance state
 	if err := writeSSEEvent(w, flusher, "compliance", h.Checker.GenerateReport()); err != nil {
 		return // Client disconnected
@@ -152,6 +172,12 @@ func (h *Handler) HandleSSE(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// SSEClientCount returns the number of connected compliance event stream
+// clients.
+func (h *Handler) SSEClientCount() int {
+	return int(h.sseClients.Load())
+}
+
 // writeSSEEvent marshals data and writes it as an SSE event. Returns an error
 // if the write fails (e.g., client disconnected).
 func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, event string, data interface{}) error {
@@ -194,9 +220,13 @@ func detectCloudflaredVersion() string {
 	return ""
 }
 
-// HandleAuditEvents returns recent audit events from the ring buffer.
+// HandleAuditEvents returns audit events, newest first. Query parameters
+// event_type, actor, severity, resource, and nist_ref filter by exact
+// match; since and until (RFC 3339) bound the time range; limit and offset
+// paginate. format=csv exports the matches as CSV, up to
+// audit.MaxQueryLimit rows unless limit is given.
 func (h *Handler) HandleAuditEvents(w http.ResponseWriter, r *http.Request) {
-	if h.AuditLogger == nil {
+	if h.AuditLogger == nil && h.AuditIndex == nil {
 		writeJSON(w, http.StatusOK, map[string]interface{}{
 			"events": []interface{}{},
 			"status": "audit logging not configured",
@@ -204,20 +234,104 @@ func (h *Handler) HandleAuditEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if n, err := strconv.Atoi(l); err == nil && n > 0 {
-			limit = n
+	params := r.URL.Query()
+	csvOut := params.Get("format") == "csv"
+	q := audit.EventQuery{
+		EventType: params.Get("event_type"),
+		Actor:     params.Get("actor"),
+		Severity:  params.Get("severity"),
+		Resource:  params.Get("resource"),
+		NISTRef:   params.Get("nist_ref"),
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if v := params.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": p.name + " must be an RFC 3339 timestamp"})
+				return
+			}
+			*p.dst = t
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &q.Limit}, {"offset", &q.Offset}} {
+		if v := params.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": p.name + " must be a non-negative integer"})
+				return
+			}
+			*p.dst = n
 		}
 	}
+	if csvOut && q.Limit == 0 {
+		q.Limit = audit.MaxQueryLimit
+	}
 
-	events := h.AuditLogger.RecentEvents(limit)
+	var events []audit.AuditEvent
+	var total int
+	source := "index"
+	if h.AuditIndex != nil {
+		var err error
+		events, total, err = h.AuditIndex.Query(r.Context(), q)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "audit query failed: " + err.Error()})
+			return
+		}
+	} else {
+		source = "ring"
+		events, total = q.Filter(h.AuditLogger.RecentEvents(0))
+	}
+
+	if csvOut {
+		writeAuditCSV(w, events)
+		return
+	}
+	limit, offset := q.Page()
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"events": events,
 		"count":  len(events),
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+		"source": source,
 	})
 }
 
+// writeAuditCSV writes audit events as a CSV attachment.
+func writeAuditCSV(w http.ResponseWriter, events []audit.AuditEvent) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-events.csv"`)
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"timestamp", "seq", "event_type", "severity", "actor", "resource", "action", "detail", "nist_ref", "source_ip", "before", "after"})
+	for _, e := range events {
+		seq := ""
+		if e.Seq > 0 {
+			seq = strconv.FormatUint(e.Seq, 10)
+		}
+		row := []string{e.Timestamp, seq, e.EventType, e.Severity, e.Actor, e.Resource, e.Action, e.Detail, e.NISTRef, e.SourceIP, e.Before, e.After}
+		for i, v := range row {
+			row[i] = csvSafe(v)
+		}
+		_ = cw.Write(row)
+	}
+	cw.Flush()
+}
+
+// csvSafe keeps spreadsheet applications from evaluating a cell as a
+// formula, since audit fields can carry attacker-controlled text.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
 // HandleAuditSSE provides SSE stream of audit events.
 func (h *Handler) HandleAuditSSE(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
@@ -298,7 +412,7 @@ func (h *Handler) HandleCredentialsStatus(w http.ResponseWriter, r *http.Request
 
 // HandleAlertTest sends a test alert to all configured webhooks.
 func (h *Handler) HandleAlertTest(w http.ResponseWriter, r *http.Request) {
-	if h.AlertManager == nil || !h.AlertManager.Configured() {
+	if !h.AlertManager.Configured() {
 		writeJSON(w, http.StatusBadRequest, map[string]string{
 			"error": "no webhooks configured (use --alert-webhook)",
 		})
@@ -327,6 +441,93 @@ func (h *Handler) HandleAlertTest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleAlertDeliveries lists alert deliveries, newest first, with counts
+// by state. Query parameters: state (pending, delivered, or dead), limit,
+// offset.
+func (h *Handler) HandleAlertDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.AlertManager.Configured() {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"deliveries": []interface{}{},
+			"status":     "alerting not configured",
+		})
+		return
+	}
+	params := r.URL.Query()
+	q := alerts.DeliveryQuery{State: params.Get("state")}
+	switch q.State {
+	case "", alerts.DeliveryPending, alerts.DeliveryDelivered, alerts.DeliveryDead:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "state must be pending, delivered, or dead"})
+		return
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &q.Limit}, {"offset", &q.Offset}} {
+		if v := params.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": p.name + " must be a non-negative integer"})
+				return
+			}
+			*p.dst = n
+		}
+	}
+
+	deliveries, total, err := h.AlertManager.Deliveries(q)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "delivery query failed: " + err.Error()})
+		return
+	}
+	counts, err := h.AlertManager.DeliveryCounts()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "delivery query failed: " + err.Error()})
+		return
+	}
+	if deliveries == nil {
+		deliveries = []alerts.Delivery{}
+	}
+	limit, offset := q.Page()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+		"states":     counts,
+	})
+}
+
+// HandleAlertReplay re-queues a dead-lettered alert delivery.
+func (h *Handler) HandleAlertReplay(w http.ResponseWriter, r *http.Request) {
+	if !h.AlertManager.Configured() {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "alerting not configured"})
+		return
+	}
+	d, err := h.AlertManager.Replay(r.PathValue("id"))
+	switch {
+	case errors.Is(err, alerts.ErrDeliveryNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "delivery not found"})
+		return
+	case errors.Is(err, alerts.ErrNotDeadLettered):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "delivery is " + d.State + "; only dead-lettered deliveries can be replayed"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "replay failed: " + err.Error()})
+		return
+	}
+	h.logAudit(audit.AuditEvent{
+		EventType: alerts.EventTypeDelivery,
+		Severity:  "info",
+		Actor:     "dashboard",
+		Resource:  d.Target,
+		Action:    "replayed",
+		Detail:    fmt.Sprintf("Dead-lettered alert delivery %s (%s) re-queued", d.ID, d.Payload.Summary),
+		NISTRef:   "SI-4",
+	})
+	writeJSON(w, http.StatusAccepted, d)
+}
+
 // ComplianceInfoData holds static AO compliance posture information.
 type ComplianceInfoData struct {
 	ProductName      string         `json:"product_name"`
@@ -3,6 +3,7 @@ package dashboard
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +14,10 @@ import (
 	"time"
 
 	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit/auditdb"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
 )
 
 func testChecker() *compliance.Checker {
@@ -670,3 +675,249 @@ func TestProxyToDashboardIntegration_SSE(t *testing.T) {
 		t.Error("expected gw-1 item in SSE data")
 	}
 }
+
+func TestHostRemediation(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "node-api-key")
+	if err := os.WriteFile(secret, []byte("k"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(secret, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	check := func() compliance.Section {
+		status := compliance.StatusPass
+		if info, _ := os.Stat(secret); info.Mode().Perm()&0o007 != 0 {
+			status = compliance.StatusFail
+		}
+		return compliance.Section{ID: "security-ops", Items: []compliance.ChecklistItem{{ID: "so-5", Status: status}}}
+	}
+	pr, err := remediate.NewPermRemediator(remediate.PermRemediatorConfig{
+		SecretFiles: func() []string { return []string{secret} },
+		Check:       check,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewHandler("", testChecker())
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, handler)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("GET", "/api/v1/remediate/plan", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unconfigured plan = %d, want 404", w.Code)
+	}
+	handler.PermRemediator = pr
+
+	if w := do("POST", "/api/v1/remediate", `{"actions":["enable_os_fips"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("non-host action = %d, want 400", w.Code)
+	}
+
+	w := do("POST", "/api/v1/remediate", `{"actions":["fix_secret_permissions"]}`)
+	var result remediate.RemediationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("remediate = %d: %s", w.Code, w.Body.String())
+	}
+	if a := result.Actions[0]; a.Status != remediate.StatusSuccess || a.Verification == nil || a.Verification.Status != "pass" {
+		t.Errorf("action = %+v", a)
+	}
+	if info, _ := os.Stat(secret); info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %04o, want 0640", info.Mode().Perm())
+	}
+
+	if w := do("POST", "/api/v1/remediate/"+result.RequestID+"/rollback", ""); w.Code != http.StatusOK {
+		t.Fatalf("rollback = %d: %s", w.Code, w.Body.String())
+	}
+	if info, _ := os.Stat(secret); info.Mode().Perm() != 0o644 {
+		t.Errorf("mode after rollback = %04o, want 0644", info.Mode().Perm())
+	}
+	if w := do("POST", "/api/v1/remediate/unknown/rollback", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown rollback = %d, want 404", w.Code)
+	}
+}
+
+func TestHostRemediationRollbackLimit(t *testing.T) {
+	pr, err := remediate.NewPermRemediator(remediate.PermRemediatorConfig{
+		SecretFiles: func() []string { return nil },
+		Check:       func() compliance.Section { return compliance.Section{ID: "security-ops"} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler("", testChecker())
+	handler.PermRemediator = pr
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, handler)
+	rollback := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/remediate/"+id+"/rollback", nil))
+		return w
+	}
+
+	for i := 0; i <= maxRemediationResults; i++ {
+		handler.recordRemediationResult(remediate.RemediationResult{RequestID: fmt.Sprintf("rem-%d", i)})
+	}
+	// Only the newest maxRemediationResults can be rolled back.
+	w := rollback("rem-0")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "last 100 host remediations") {
+		t.Errorf("evicted rollback = %d: %s", w.Code, w.Body.String())
+	}
+	if w := rollback("rem-1"); w.Code != http.StatusOK {
+		t.Errorf("oldest kept rollback = %d: %s", w.Code, w.Body.String())
+	}
+
+	// Results do not survive a restart.
+	restarted := NewHandler("", testChecker())
+	restarted.PermRemediator = pr
+	mux = http.NewServeMux()
+	RegisterRoutes(mux, restarted)
+	if w := rollback(fmt.Sprintf("rem-%d", maxRemediationResults)); w.Code != http.StatusNotFound {
+		t.Errorf("rollback after restart = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleAuditEvents(t *testing.T) {
+	al, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	ix, err := auditdb.Open(filepath.Join(t.TempDir(), "audit-index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	ix.Attach(al)
+
+	al.Log(audit.AuditEvent{EventType: "auth_attempt", Severity: "warning", Actor: "api:10.0.0.1", Action: "login_failed", Detail: "=HYPERLINK(\"x\")", NISTRef: "AC-7"})
+	al.Log(audit.AuditEvent{EventType: "auth_attempt", Severity: "info", Actor: "api:10.0.0.2", Action: "login_success", NISTRef: "IA-2"})
+	al.Log(audit.AuditEvent{EventType: "system_event", Severity: "info", Actor: "system", Action: "started"})
+
+	handler := NewHandler("", testChecker())
+	handler.AuditLogger = al
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.HandleAuditEvents(w, httptest.NewRequest(http.MethodGet, "/api/v1/audit/events?"+query, nil))
+		return w
+	}
+	type page struct {
+		Events []audit.AuditEvent `json:"events"`
+		Total  int                `json:"total"`
+		Source string             `json:"source"`
+	}
+	decode := func(w *httptest.ResponseRecorder) page {
+		t.Helper()
+		var p page
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("response %d: %s", w.Code, w.Body.String())
+		}
+		return p
+	}
+
+	// Without an index, queries filter the ring buffer.
+	if p := decode(get("event_type=auth_attempt&limit=1")); p.Source != "ring" || p.Total != 2 || len(p.Events) != 1 || p.Events[0].Action != "login_success" {
+		t.Errorf("ring page = %+v", p)
+	}
+
+	handler.AuditIndex = ix
+	if p := decode(get("nist_ref=AC-7")); p.Source != "index" || p.Total != 1 || p.Events[0].Actor != "api:10.0.0.1" {
+		t.Errorf("index page = %+v", p)
+	}
+	if p := decode(get("since=2000-01-01T00:00:00Z&until=2001-01-01T00:00:00Z")); p.Total != 0 {
+		t.Errorf("time range page = %+v", p)
+	}
+	if w := get("since=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad since = %d, want 400", w.Code)
+	}
+
+	w := get("format=csv&event_type=auth_attempt")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("content type = %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "timestamp,seq,event_type") {
+		t.Fatalf("csv = %q", lines)
+	}
+	if !strings.Contains(lines[2], `'=HYPERLINK`) {
+		t.Errorf("formula not neutralized: %q", lines[2])
+	}
+}
+
+func TestHandleAlertDeliveries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	al, err := audit.NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	am := alerts.NewAlertManager(al, []alerts.WebhookConfig{{URL: srv.URL}})
+	defer am.Close()
+	al.Log(audit.AuditEvent{EventType: "compliance_change", Severity: "critical", Action: "status_changed", Resource: "t-1"})
+
+	handler := NewHandler("", testChecker())
+	handler.AuditLogger = al
+	handler.AlertManager = am
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, handler)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	var page struct {
+		Deliveries []alerts.Delivery `json:"deliveries"`
+		Total      int               `json:"total"`
+		States     map[string]int    `json:"states"`
+	}
+	for deadline := time.Now().Add(5 * time.Second); page.Total == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w := do(http.MethodGet, "/api/v1/alerts/deliveries?state=dead")
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("response %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if page.Total != 1 || page.States["dead"] != 1 || strings.Contains(page.Deliveries[0].LastError, srv.URL) {
+		t.Fatalf("page = %+v", page)
+	}
+	id := page.Deliveries[0].ID
+
+	if w := do(http.MethodGet, "/api/v1/alerts/deliveries?state=lost"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad state: %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/alerts/deliveries/nope/replay"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown replay: %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/alerts/deliveries/"+id+"/replay"); w.Code != http.StatusAccepted {
+		t.Fatalf("replay: %d %s", w.Code, w.Body.String())
+	}
+	if !containsAction(al.RecentEvents(0), "replayed") {
+		t.Error("replay not audited")
+	}
+
+	// A manager without webhooks is reported as not configured.
+	handler.AlertManager = alerts.NewAlertManager(al, nil)
+	defer handler.AlertManager.Close()
+	if w := do(http.MethodGet, "/api/v1/alerts/deliveries"); !strings.Contains(w.Body.String(), "alerting not configured") {
+		t.Errorf("unconfigured deliveries: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/v1/alerts/deliveries/"+id+"/replay"); w.Code != http.StatusNotFound {
+		t.Errorf("unconfigured replay: %d", w.Code)
+	}
+}
+
+func containsAction(events []audit.AuditEvent, action string) bool {
+	for _, e := range events {
+		if e.EventType == alerts.EventTypeDelivery && e.Action == action {
+			return true
+		}
+	}
+	return false
+}
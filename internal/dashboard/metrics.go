@@ -0,0 +1,226 @@
+package dashboard
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/metrics"
+)
+
+// complianceStatuses are the states exported for each checklist item, one
+// series per state with value 1 for the current one.
+var complianceStatuses = []compliance.Status{
+	compliance.StatusPass, compliance.StatusFail, compliance.StatusWarning, compliance.StatusUnknown,
+}
+
+// Metrics exposes dashboard and fleet controller state in Prometheus text
+// format at /metrics. Gauges are computed at scrape time; counters and
+// histograms are fed by the components they describe.
+type Metrics struct {
+	registry          *metrics.Registry
+	checkDuration     *metrics.HistogramVec
+	auditEvents       *metrics.CounterVec
+	webhookDeliveries *metrics.CounterVec
+	reportsIngested   *metrics.CounterVec
+	heartbeats        *metrics.CounterVec
+}
+
+// MetricsConfig holds the components whose state is exported. Nil fields
+// are skipped.
+type MetricsConfig struct {
+	Checker      *compliance.Checker
+	Handler      *Handler // compliance SSE client count
+	WSHub        *WSHub
+	AuditLogger  *audit.AuditLogger
+	AlertManager *alerts.AlertManager
+}
+
+// NewMetrics creates the metrics registry and hooks it into the configured
+// components.
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	reg := metrics.NewRegistry()
+	m := &Metrics{
+		registry: reg,
+		checkDuration: reg.NewHistogram("cloudflared_fips_check_duration_seconds",
+			"Time taken to run a compliance check section.", nil, "section"),
+		auditEvents: reg.NewCounter("cloudflared_fips_audit_events_total",
+			"Audit events logged, by type and severity.", "event_type", "severity"),
+		webhookDeliveries: reg.NewCounter("cloudflared_fips_webhook_deliveries_total",
+			"Alert webhook deliveries, by target host and result.", "target", "result"),
+	}
+
+	if c := cfg.Checker; c != nil {
+		reg.NewGaugeFunc("cloudflared_fips_compliance_item_status",
+			"Compliance checklist item state (1 for the current status).",
+			[]string{"item", "section", "severity", "status"},
+			func(emit func(float64, ...string)) {
+				for _, section := range c.GenerateReport().Sections {
+					for _, item := range section.Items {
+						for _, st := range complianceStatuses {
+							v := 0.0
+							if item.Status == st {
+								v = 1
+							}
+							emit(v, item.ID, section.ID, item.Severity, string(st))
+						}
+					}
+				}
+			})
+		reg.NewGaugeFunc("cloudflared_fips_compliance_items",
+			"Compliance checklist items by status.", []string{"status"},
+			func(emit func(float64, ...string)) {
+				s := c.GenerateReport().Summary
+				emit(float64(s.Passed), string(compliance.StatusPass))
+				emit(float64(s.Failed), string(compliance.StatusFail))
+				emit(float64(s.Warnings), string(compliance.StatusWarning))
+				emit(float64(s.Unknown), string(compliance.StatusUnknown))
+			})
+	}
+
+	if h, hub := cfg.Handler, cfg.WSHub; h != nil || hub != nil {
+		reg.NewGaugeFunc("cloudflared_fips_stream_clients",
+			"Connected real-time clients, by stream.", []string{"stream"},
+			func(emit func(float64, ...string)) {
+				if h != nil {
+					emit(float64(h.SSEClientCount()), "compliance_sse")
+				}
+				if hub != nil {
+					emit(float64(hub.ActiveConnections()), "compliance_ws")
+				}
+			})
+	}
+
+	if cfg.AuditLogger != nil {
+		cfg.AuditLogger.AddListener(func(evt audit.AuditEvent) {
+			m.auditEvents.Inc(evt.EventType, evt.Severity)
+		})
+	}
+
+	if am := cfg.AlertManager; am != nil {
+		reg.NewGaugeFunc("cloudflared_fips_alert_deliveries",
+			"Queued, delivered, and dead-lettered alert deliveries.", []string{"state"},
+			func(emit func(float64, ...string)) {
+				counts, err := am.DeliveryCounts()
+				if err != nil {
+					return
+				}
+				for _, st := range []string{alerts.DeliveryPending, alerts.DeliveryDelivered, alerts.DeliveryDead} {
+					emit(float64(counts[st]), st)
+				}
+			})
+		am.SetDeliveryObserver(func(target string, err error) {
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			// Only the host: webhook URLs often embed credentials.
+			host := target
+			if u, perr := url.Parse(target); perr == nil && u.Host != "" {
+				host = u.Host
+			}
+			m.webhookDeliveries.Inc(host, result)
+		})
+	}
+
+	return m
+}
+
+// Registry returns the underlying registry, which serves /metrics.
+func (m *Metrics) Registry() *metrics.Registry {
+	return m.registry
+}
+
+// RunSection runs a compliance check section and records its duration.
+func (m *Metrics) RunSection(run func() compliance.Section) compliance.Section {
+	start := time.Now()
+	section := run()
+	m.checkDuration.Observe(time.Since(start).Seconds(), section.ID)
+	return section
+}
+
+// AttachFleet exports fleet controller state and starts counting report and
+// heartbeat ingest on fh.
+func (m *Metrics) AttachFleet(fh *FleetHandler) {
+	m.reportsIngested = m.registry.NewCounter("cloudflared_fips_fleet_reports_total",
+		"Compliance reports received from nodes, by result.", "result")
+	m.heartbeats = m.registry.NewCounter("cloudflared_fips_fleet_heartbeats_total",
+		"Heartbeats received from nodes, by result.", "result")
+	fh.metrics = m
+
+	store := fh.store
+	m.registry.NewGaugeFunc("cloudflared_fips_fleet_nodes",
+		"Fleet nodes by role, region, status, compliance status, and site.",
+		[]string{"role", "region", "status", "compliance", "site"},
+		func(emit func(float64, ...string)) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			nodes, err := store.ListNodes(ctx, fleet.NodeFilter{})
+			if err != nil {
+				return
+			}
+			federated, _ := store.ListFederatedNodes(ctx, fleet.NodeFilter{})
+			type group struct{ role, region, status, compliance, site string }
+			counts := make(map[group]int)
+			for _, n := range append(nodes, federated...) {
+				site := n.Site
+				if site == "" {
+					site = fleet.SiteLocal
+				}
+				counts[group{string(n.Role), n.Region, string(n.Status), string(n.ComplianceStatus), site}]++
+			}
+			for g, c := range counts {
+				emit(float64(c), g.role, g.region, g.status, g.compliance, g.site)
+			}
+		})
+	m.registry.NewGaugeFunc("cloudflared_fips_fleet_flapping_nodes",
+		"Locally enrolled nodes currently flagged as flapping.", nil,
+		func(emit func(float64, ...string)) {
+			if s := fleetSummary(store); s != nil {
+				emit(float64(s.Flapping))
+			}
+		})
+	m.registry.NewGaugeFunc("cloudflared_fips_fleet_availability_percent",
+		"Mean availability of locally enrolled nodes, by window.", []string{"window"},
+		func(emit func(float64, ...string)) {
+			if s := fleetSummary(store); s != nil {
+				emit(s.Availability.Day, "24h")
+				emit(s.Availability.Week, "7d")
+				emit(s.Availability.Month, "30d")
+			}
+		})
+	m.registry.NewGaugeFunc("cloudflared_fips_fleet_sse_clients",
+		"Connected fleet event stream clients.", nil,
+		func(emit func(float64, ...string)) {
+			emit(float64(fh.SSEClientCount()))
+		})
+}
+
+func fleetSummary(store fleet.Store) *fleet.FleetSummary {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s, err := store.GetSummary(ctx)
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// reportIngested counts a node report by result; safe on a nil receiver.
+func (m *Metrics) reportIngested(result string) {
+	if m != nil && m.reportsIngested != nil {
+		m.reportsIngested.Inc(result)
+	}
+}
+
+// heartbeatReceived counts a node heartbeat by result; safe on a nil
+// receiver.
+func (m *Metrics) heartbeatReceived(result string) {
+	if m != nil && m.heartbeats != nil {
+		m.heartbeats.Inc(result)
+	}
+}
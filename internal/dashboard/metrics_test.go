@@ -0,0 +1,99 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Registry().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
+
+func assertMetrics(t *testing.T, out string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(out, w+"\n") {
+			t.Errorf("metrics missing %q", w)
+		}
+	}
+}
+
+func TestMetrics_Compliance(t *testing.T) {
+	checker := compliance.NewChecker()
+	al := newTestAudit(t)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer hook.Close()
+	am := alerts.NewAlertManager(al, []alerts.WebhookConfig{{URL: hook.URL + "/secret-path"}})
+
+	m := NewMetrics(MetricsConfig{
+		Checker:      checker,
+		Handler:      NewHandler("", checker),
+		WSHub:        NewWSHub(nil),
+		AuditLogger:  al,
+		AlertManager: am,
+	})
+	checker.AddSection(m.RunSection(func() compliance.Section {
+		return compliance.Section{ID: "tunnel", Items: []compliance.ChecklistItem{
+			{ID: "t-1", Status: compliance.StatusPass, Severity: "critical"},
+			{ID: "t-2", Status: compliance.StatusFail, Severity: "high"},
+		}}
+	}))
+	al.Log(audit.AuditEvent{EventType: "config_change", Severity: "info"})
+	am.TestWebhooks()
+
+	out := scrape(t, m)
+	host := strings.TrimPrefix(hook.URL, "http://")
+	assertMetrics(t, out,
+		`cloudflared_fips_compliance_item_status{item="t-1",section="tunnel",severity="critical",status="pass"} 1`,
+		`cloudflared_fips_compliance_item_status{item="t-1",section="tunnel",severity="critical",status="fail"} 0`,
+		`cloudflared_fips_compliance_item_status{item="t-2",section="tunnel",severity="high",status="fail"} 1`,
+		`cloudflared_fips_compliance_items{status="fail"} 1`,
+		`cloudflared_fips_check_duration_seconds_count{section="tunnel"} 1`,
+		`cloudflared_fips_audit_events_total{event_type="config_change",severity="info"} 1`,
+		`cloudflared_fips_webhook_deliveries_total{target="`+host+`",result="success"} 1`,
+		`cloudflared_fips_alert_deliveries{state="dead"} 0`,
+		`cloudflared_fips_stream_clients{stream="compliance_sse"} 0`,
+		`cloudflared_fips_stream_clients{stream="compliance_ws"} 0`,
+	)
+	if strings.Contains(out, "secret-path") {
+		t.Error("webhook URL path leaked into metrics")
+	}
+}
+
+func TestMetrics_Fleet(t *testing.T) {
+	fh, store := testFleetHandler(t)
+	m := NewMetrics(MetricsConfig{})
+	m.AttachFleet(fh)
+
+	node := enrollTestNode(t, store)
+	payload := fleet.ComplianceReportPayload{NodeID: node.NodeID}
+	payload.Report.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	payload.Report.Summary.Passed = 3
+	for i := 0; i < 2; i++ {
+		fh.HandleReport(httptest.NewRecorder(), fleetRequest(t, "/api/v1/fleet/report", node.APIKey, payload, false))
+	}
+	fh.HandleHeartbeat(httptest.NewRecorder(), fleetRequest(t, "/api/v1/fleet/heartbeat", node.APIKey,
+		fleet.HeartbeatRequest{NodeID: node.NodeID}, false))
+
+	out := scrape(t, m)
+	assertMetrics(t, out,
+		`cloudflared_fips_fleet_reports_total{result="stored"} 1`,
+		`cloudflared_fips_fleet_reports_total{result="unchanged"} 1`,
+		`cloudflared_fips_fleet_heartbeats_total{result="live"} 1`,
+		`cloudflared_fips_fleet_nodes{role="server",region="",status="online",compliance="compliant",site="local"} 1`,
+		`cloudflared_fips_fleet_flapping_nodes 0`,
+		`cloudflared_fips_fleet_availability_percent{window="24h"} 100`,
+		`cloudflared_fips_fleet_sse_clients 0`,
+	)
+}
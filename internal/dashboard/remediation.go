@@ -0,0 +1,157 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/fleet/remediate"
+)
+
+// maxRemediationResults bounds the host remediation results kept for
+// rollback. Results are held in memory only: the dashboard has no state
+// store of its own, so a remediation can be rolled back only until it is
+// one of more than maxRemediationResults newer ones or the dashboard
+// restarts. The audit log keeps the record of every change.
+const maxRemediationResults = 100
+
+// HandleRemediationPlan re-runs the security operations checks and returns
+// the permission actions available for failing items, with the files each
+// would change.
+func (h *Handler) HandleRemediationPlan(w http.ResponseWriter, r *http.Request) {
+	if h.PermRemediator == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "host remediation not configured"})
+		return
+	}
+	actions := h.PermRemediator.Plan(h.PermRemediator.Current())
+	if actions == nil {
+		actions = []remediate.RemediationAction{}
+	}
+	writeJSON(w, http.StatusOK, actions)
+}
+
+// HandleRemediation tightens secret file and audit log permissions. With
+// dry_run set, only the diff is returned.
+func (h *Handler) HandleRemediation(w http.ResponseWriter, r *http.Request) {
+	if h.PermRemediator == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "host remediation not configured"})
+		return
+	}
+
+	var body struct {
+		Actions []remediate.ActionID `json:"actions"`
+		DryRun  bool                 `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(body.Actions) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "actions list required"})
+		return
+	}
+	for _, a := range body.Actions {
+		if !remediate.IsPermAction(a) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s is not a host remediation action", a)})
+			return
+		}
+	}
+
+	req := remediate.RemediationRequest{ID: generateID(), Actions: body.Actions, DryRun: body.DryRun}
+	result := h.PermRemediator.Execute(req, h.PermRemediator.Plan(h.PermRemediator.Current()))
+	if !body.DryRun {
+		h.recordRemediationResult(result)
+		summary, severity := summarizeActions(result.Actions)
+		h.logAudit(audit.AuditEvent{
+			EventType: "config_change",
+			Severity:  severity,
+			Actor:     "dashboard",
+			Resource:  "host",
+			Action:    "remediated",
+			Detail:    fmt.Sprintf("remediation %s; %s", req.ID, summary),
+			NISTRef:   "CM-3, SC-28, AU-9",
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleRemediationRollback restores the file modes and ownership changed
+// by a recent host remediation: one of the last maxRemediationResults since
+// the dashboard started. Older remediations are not found.
+func (h *Handler) HandleRemediationRollback(w http.ResponseWriter, r *http.Request) {
+	if h.PermRemediator == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "host remediation not configured"})
+		return
+	}
+
+	prev, ok := h.remediationResult(r.PathValue("reqID"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf(
+			"remediation not found; only the last %d host remediations since the dashboard started can be rolled back",
+			maxRemediationResults)})
+		return
+	}
+
+	var body struct {
+		Actions []remediate.ActionID `json:"actions"`
+		DryRun  bool                 `json:"dry_run"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+
+	req := remediate.RemediationRequest{
+		ID:       generateID(),
+		Actions:  body.Actions,
+		DryRun:   body.DryRun,
+		Type:     remediate.RequestRollback,
+		Previous: &prev,
+	}
+	result := h.PermRemediator.Rollback(req)
+	if !body.DryRun {
+		summary, severity := summarizeActions(result.Actions)
+		h.logAudit(audit.AuditEvent{
+			EventType: "config_change",
+			Severity:  severity,
+			Actor:     "dashboard",
+			Resource:  "host",
+			Action:    "rolled_back",
+			Detail:    fmt.Sprintf("rollback %s of remediation %s; %s", req.ID, prev.RequestID, summary),
+			NISTRef:   "CM-3, AU-2",
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// recordRemediationResult keeps an applied host remediation for later
+// rollback.
+func (h *Handler) recordRemediationResult(result remediate.RemediationResult) {
+	h.remMu.Lock()
+	defer h.remMu.Unlock()
+	h.remResults = append(h.remResults, result)
+	if len(h.remResults) > maxRemediationResults {
+		h.remResults = h.remResults[len(h.remResults)-maxRemediationResults:]
+	}
+}
+
+func (h *Handler) remediationResult(id string) (remediate.RemediationResult, bool) {
+	h.remMu.Lock()
+	defer h.remMu.Unlock()
+	for _, res := range h.remResults {
+		if res.RequestID == id {
+			return res, true
+		}
+	}
+	return remediate.RemediationResult{}, false
+}
+
+// logAudit writes an audit event if an audit logger is configured.
+func (h *Handler) logAudit(evt audit.AuditEvent) {
+	if h.AuditLogger != nil {
+		h.AuditLogger.Log(evt)
+	}
+}
@@ -17,7 +17,14 @@ func RegisterRoutes(mux *http.ServeMux, h *Handler) {
 	mux.HandleFunc("GET /api/v1/audit/events/stream", h.HandleAuditSSE)
 	mux.HandleFunc("GET /api/v1/credentials/status", h.HandleCredentialsStatus)
 	mux.HandleFunc("POST /api/v1/alerts/test", h.HandleAlertTest)
+	mux.HandleFunc("GET /api/v1/alerts/deliveries", h.HandleAlertDeliveries)
+	mux.HandleFunc("POST /api/v1/alerts/deliveries/{id}/replay", h.HandleAlertReplay)
 	mux.HandleFunc("GET /api/v1/compliance-info", h.HandleComplianceInfo)
+
+	// Host remediation (secret file and audit log permissions)
+	mux.HandleFunc("GET /api/v1/remediate/plan", h.HandleRemediationPlan)
+	mux.HandleFunc("POST /api/v1/remediate", h.HandleRemediation)
+	mux.HandleFunc("POST /api/v1/remediate/{reqID}/rollback", h.HandleRemediationRollback)
 }
 
 // RegisterRoutesWithWS sets up the API routes including WebSocket support.
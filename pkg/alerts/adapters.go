@@ -0,0 +1,235 @@
+package alerts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Webhook types: the payload format a destination expects.
+const (
+	TypeGeneric   = "generic"   // WebhookPayload as JSON
+	TypeSlack     = "slack"     // Slack incoming webhook, Block Kit
+	TypeTeams     = "teams"     // Microsoft Teams workflow webhook, Adaptive Card
+	TypePagerDuty = "pagerduty" // PagerDuty Events API v2
+	TypeTemplate  = "template"  // operator-defined Go text/template
+)
+
+// PagerDutyEventsURL is the default endpoint of pagerduty webhooks.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Validate checks the webhook's URL and type settings. A pagerduty webhook
+// without a URL is given PagerDutyEventsURL.
+func (wh *WebhookConfig) Validate() error {
+	if wh.Type == TypePagerDuty && wh.URL == "" {
+		wh.URL = PagerDutyEventsURL
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", wh.URL)
+	}
+	switch wh.Type {
+	case "", TypeGeneric, TypeSlack, TypeTeams:
+	case TypePagerDuty:
+		if wh.RoutingKey == "" {
+			return fmt.Errorf("pagerduty webhook %s: routing_key required", wh.URL)
+		}
+	case TypeTemplate:
+		if wh.Template == "" {
+			return fmt.Errorf("template webhook %s: template required", wh.URL)
+		}
+		if _, err := parseTemplate(wh.Template); err != nil {
+			return fmt.Errorf("template webhook %s: %w", wh.URL, err)
+		}
+	default:
+		return fmt.Errorf("unknown webhook type %q (want generic, slack, teams, pagerduty, or template)", wh.Type)
+	}
+	if err := validSecret(wh.Secret); err != nil {
+		return err
+	}
+	for i := range wh.Rules {
+		if err := wh.Rules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodePayload renders payload in the webhook's native format and returns
+// the body and its content type.
+func encodePayload(wh WebhookConfig, p WebhookPayload) ([]byte, string, error) {
+	var v any
+	switch wh.Type {
+	case "", TypeGeneric:
+		v = p
+	case TypeSlack:
+		v = slackMessage(p)
+	case TypeTeams:
+		v = teamsMessage(p)
+	case TypePagerDuty:
+		v = pagerDutyEvent(wh.RoutingKey, p)
+	case TypeTemplate:
+		return renderTemplate(wh, p)
+	default:
+		return nil, "", fmt.Errorf("unknown webhook type %q", wh.Type)
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal payload: %w", err)
+	}
+	return body, "application/json", nil
+}
+
+// facts lists the payload fields shown beside the summary.
+func facts(p WebhookPayload) [][2]string {
+	out := [][2]string{{"Severity", p.Severity}, {"Event", p.EventType}}
+	if p.NISTRef != "" {
+		out = append(out, [2]string{"NIST", p.NISTRef})
+	}
+	if p.Rule != "" {
+		out = append(out, [2]string{"Rule", p.Rule})
+	}
+	if p.Grouped > 0 {
+		out = append(out, [2]string{"Grouped", fmt.Sprint(p.Grouped)})
+	}
+	return append(out, [2]string{"Time", p.Timestamp})
+}
+
+var severityEmoji = map[string]string{"critical": ":red_circle:", "warning": ":warning:", "info": ":information_source:"}
+
+// slackMessage builds a Block Kit message. text is the notification
+// fallback.
+func slackMessage(p WebhookPayload) map[string]any {
+	var ctx []map[string]string
+	for _, f := range facts(p) {
+		ctx = append(ctx, map[string]string{"type": "mrkdwn", "text": "*" + f[0] + ":* " + slackEscape(f[1])})
+	}
+	blocks := []map[string]any{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": truncate(p.Summary, 150)}},
+	}
+	if p.Detail != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section", "text": map[string]string{"type": "mrkdwn", "text": truncate(slackEscape(p.Detail), 3000)},
+		})
+	}
+	// facts yields at most six elements; Slack allows ten per context block.
+	blocks = append(blocks, map[string]any{"type": "context", "elements": ctx})
+	return map[string]any{
+		"text":   strings.TrimSpace(severityEmoji[p.Severity] + " " + slackEscape(p.Summary)),
+		"blocks": blocks,
+	}
+}
+
+// slackEscape escapes the characters Slack treats as markup.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+var teamsColor = map[string]string{"critical": "Attention", "warning": "Warning"}
+
+// teamsMessage builds an Adaptive Card message as accepted by Teams
+// workflow and incoming webhooks.
+func teamsMessage(p WebhookPayload) map[string]any {
+	var fs []map[string]string
+	for _, f := range facts(p) {
+		fs = append(fs, map[string]string{"title": f[0], "value": f[1]})
+	}
+	color := teamsColor[p.Severity]
+	if color == "" {
+		color = "Default"
+	}
+	body := []map[string]any{
+		{"type": "TextBlock", "text": p.Summary, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+	}
+	if p.Detail != "" {
+		body = append(body, map[string]any{"type": "TextBlock", "text": p.Detail, "wrap": true})
+	}
+	body = append(body, map[string]any{"type": "FactSet", "facts": fs})
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+}
+
+var pagerDutySeverity = map[string]string{"critical": "critical", "warning": "warning", "info": "info"}
+
+// pagerDutyEvent builds an Events API v2 trigger. The dedup key is derived
+// from the rule, event type, and resource, so repeats of an alert update
+// one incident instead of opening new ones.
+func pagerDutyEvent(routingKey string, p WebhookPayload) map[string]any {
+	severity := pagerDutySeverity[p.Severity]
+	if severity == "" {
+		severity = "error"
+	}
+	sum := sha256.Sum256([]byte(p.Rule + "\x00" + p.EventType + "\x00" + p.Resource))
+	details := map[string]any{"detail": p.Detail}
+	if p.NISTRef != "" {
+		details["nist_ref"] = p.NISTRef
+	}
+	if p.Grouped > 0 {
+		details["grouped"] = p.Grouped
+	}
+	return map[string]any{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    "cloudflared-fips-" + hex.EncodeToString(sum[:16]),
+		"payload": map[string]any{
+			"summary":        truncate(p.Summary, 1024),
+			"source":         "cloudflared-fips",
+			"severity":       severity,
+			"timestamp":      p.Timestamp,
+			"component":      p.Resource,
+			"group":          p.EventType,
+			"class":          p.Rule,
+			"custom_details": details,
+		},
+	}
+}
+
+// parseTemplate parses a template webhook body. The json function quotes a
+// value for safe embedding in a JSON document.
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+}
+
+// renderTemplate executes the webhook's template with the payload.
+func renderTemplate(wh WebhookConfig, p WebhookPayload) ([]byte, string, error) {
+	tmpl, err := parseTemplate(wh.Template)
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, "", fmt.Errorf("render template: %w", err)
+	}
+	contentType := wh.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
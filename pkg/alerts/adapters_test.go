@@ -0,0 +1,175 @@
+package alerts
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var adapterPayload = WebhookPayload{
+	Timestamp: "2026-10-18T12:00:00Z",
+	EventType: "compliance_change",
+	Severity:  "critical",
+	Summary:   "status_changed: t-1",
+	Detail:    "pass -> fail <BoringCrypto inactive>",
+	NISTRef:   "SC-13",
+	Resource:  "t-1",
+	Rule:      "crypto",
+}
+
+// standIn is an httptest stand-in for a chat or paging service that
+// records request bodies and answers with status and reply.
+func standIn(t *testing.T, status int, reply string) (*httptest.Server, func() []map[string]any) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid JSON body: %v", err)
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+}
+
+// dig walks nested JSON maps and arrays by key or index.
+func dig(v any, path ...any) any {
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, _ := v.(map[string]any)
+			v = m[k]
+		case int:
+			a, _ := v.([]any)
+			if k >= len(a) {
+				return nil
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func TestSlackAdapter(t *testing.T) {
+	srv, bodies := standIn(t, http.StatusOK, "ok")
+	if err := sendWebhook(WebhookConfig{URL: srv.URL, Type: TypeSlack}, adapterPayload, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	b := bodies()[0]
+	if text, _ := b["text"].(string); !strings.HasPrefix(text, ":red_circle: status_changed: t-1") {
+		t.Errorf("text = %q", text)
+	}
+	if dig(b, "blocks", 0, "type") != "header" || dig(b, "blocks", 0, "text", "text") != "status_changed: t-1" {
+		t.Errorf("header block = %v", dig(b, "blocks", 0))
+	}
+	if got := dig(b, "blocks", 1, "text", "text"); got != "pass -&gt; fail &lt;BoringCrypto inactive&gt;" {
+		t.Errorf("detail not escaped: %v", got)
+	}
+	if dig(b, "blocks", 2, "type") != "context" || dig(b, "blocks", 2, "elements", 2, "text") != "*NIST:* SC-13" {
+		t.Errorf("context block = %v", dig(b, "blocks", 2))
+	}
+}
+
+func TestTeamsAdapter(t *testing.T) {
+	srv, bodies := standIn(t, http.StatusAccepted, "")
+	if err := sendWebhook(WebhookConfig{URL: srv.URL, Type: TypeTeams}, adapterPayload, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	b := bodies()[0]
+	att := dig(b, "attachments", 0)
+	if b["type"] != "message" || dig(att, "contentType") != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("message = %v", b)
+	}
+	card := dig(att, "content")
+	if dig(card, "type") != "AdaptiveCard" || dig(card, "version") != "1.4" {
+		t.Errorf("card = %v", card)
+	}
+	if dig(card, "body", 0, "color") != "Attention" || dig(card, "body", 2, "facts", 0, "value") != "critical" {
+		t.Errorf("card body = %v", dig(card, "body"))
+	}
+}
+
+func TestPagerDutyAdapter(t *testing.T) {
+	srv, bodies := standIn(t, http.StatusAccepted, `{"status":"success","message":"Event processed"}`)
+	wh := WebhookConfig{URL: srv.URL, Type: TypePagerDuty, RoutingKey: "R0UT1NGKEY"}
+	repeat := adapterPayload
+	repeat.Timestamp = "2026-10-18T12:05:00Z"
+	for _, p := range []WebhookPayload{adapterPayload, repeat} {
+		if err := sendWebhook(wh, p, "d1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := bodies()
+	if b[0]["routing_key"] != "R0UT1NGKEY" || b[0]["event_action"] != "trigger" {
+		t.Errorf("event = %v", b[0])
+	}
+	if key, _ := b[0]["dedup_key"].(string); key == "" || key != b[1]["dedup_key"] {
+		t.Errorf("dedup keys %v and %v should match", b[0]["dedup_key"], b[1]["dedup_key"])
+	}
+	for field, want := range map[string]string{"summary": "status_changed: t-1", "source": "cloudflared-fips", "severity": "critical", "component": "t-1"} {
+		if got := dig(b[0], "payload", field); got != want {
+			t.Errorf("payload.%s = %v, want %q", field, got, want)
+		}
+	}
+	if dig(b[0], "payload", "custom_details", "nist_ref") != "SC-13" {
+		t.Errorf("custom_details = %v", dig(b[0], "payload", "custom_details"))
+	}
+}
+
+func TestTemplateAdapter(t *testing.T) {
+	srv, bodies := standIn(t, http.StatusOK, "")
+	wh := WebhookConfig{
+		URL:      srv.URL,
+		Type:     TypeTemplate,
+		Template: `{"title": {{json .Summary}}, "body": {{json .Detail}}, "level": "{{.Severity}}"}`,
+	}
+	if err := wh.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendWebhook(wh, adapterPayload, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	b := bodies()[0]
+	if b["title"] != "status_changed: t-1" || b["body"] != adapterPayload.Detail || b["level"] != "critical" {
+		t.Errorf("body = %v", b)
+	}
+
+	wh.Template = `{{.Missing}}`
+	if err := sendWebhook(wh, adapterPayload, "d1"); err == nil {
+		t.Error("expected error for unknown template field")
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	pd := WebhookConfig{Type: TypePagerDuty, RoutingKey: "k"}
+	if err := pd.Validate(); err != nil || pd.URL != PagerDutyEventsURL {
+		t.Errorf("pagerduty default: err %v, url %q", err, pd.URL)
+	}
+	for name, wh := range map[string]WebhookConfig{
+		"no routing key": {Type: TypePagerDuty},
+		"unknown type":   {URL: "https://x", Type: "discord"},
+		"no template":    {URL: "https://x", Type: TypeTemplate},
+		"bad template":   {URL: "https://x", Type: TypeTemplate, Template: "{{.Summary"},
+	} {
+		if err := wh.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
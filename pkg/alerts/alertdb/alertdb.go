@@ -0,0 +1,214 @@
+// Package alertdb keeps the alert delivery queue in SQLite, so alerts that
+// have not reached their webhook survive a dashboard restart. It is
+// separate from package alerts so that binaries which only evaluate alert
+// rules do not link the database driver.
+package alertdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
+)
+
+// Store is a SQLite-backed alerts.DeliveryStore.
+type Store struct {
+	db *sql.DB
+}
+
+var _ alerts.DeliveryStore = (*Store)(nil)
+
+// Open opens or creates a delivery store at dbPath.
+func Open(dbPath string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0750); err != nil {
+		return nil, fmt.Errorf("alertdb: create queue dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("alertdb: open queue: %w", err)
+	}
+	// Sends update concurrently; a single connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	schema := `
+	PRAGMA journal_mode=WAL;
+	CREATE TABLE IF NOT EXISTS alert_deliveries (
+		id           TEXT PRIMARY KEY,
+		webhook      TEXT NOT NULL,
+		target       TEXT NOT NULL,
+		payload      TEXT NOT NULL,
+		state        TEXT NOT NULL,
+		attempts     INTEGER NOT NULL DEFAULT 0,
+		last_error   TEXT NOT NULL DEFAULT '',
+		created_at   TEXT NOT NULL,
+		updated_at   TEXT NOT NULL,
+		next_attempt TEXT NOT NULL,
+		retry_until  TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_alert_due ON alert_deliveries(state, next_attempt);
+	CREATE INDEX IF NOT EXISTS idx_alert_created ON alert_deliveries(created_at);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("alertdb: migrate queue: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the queue database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add implements alerts.DeliveryStore.
+func (s *Store) Add(d alerts.Delivery) error {
+	payload, err := json.Marshal(d.Payload)
+	if err != nil {
+		return fmt.Errorf("alertdb: marshal payload: %w", err)
+	}
+	_, err = s.db.Exec(`INSERT INTO alert_deliveries
+		(id, webhook, target, payload, state, attempts, last_error, created_at, updated_at, next_attempt, retry_until)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.Webhook, d.Target, string(payload), d.State, d.Attempts, d.LastError,
+		dbTime(d.CreatedAt), dbTime(d.UpdatedAt), dbTime(d.NextAttempt), dbTime(d.RetryUntil))
+	return err
+}
+
+// Update implements alerts.DeliveryStore. Only the delivery's progress is
+// updated; its destination and payload are fixed.
+func (s *Store) Update(d alerts.Delivery) error {
+	res, err := s.db.Exec(`UPDATE alert_deliveries SET
+		state = ?, attempts = ?, last_error = ?, updated_at = ?, next_attempt = ?, retry_until = ?
+		WHERE id = ?`,
+		d.State, d.Attempts, d.LastError, dbTime(d.UpdatedAt), dbTime(d.NextAttempt), dbTime(d.RetryUntil), d.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return alerts.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+const selectDelivery = `SELECT id, webhook, target, payload, state, attempts, last_error,
+	created_at, updated_at, next_attempt, retry_until FROM alert_deliveries`
+
+// Get implements alerts.DeliveryStore.
+func (s *Store) Get(id string) (alerts.Delivery, error) {
+	rows, err := s.db.Query(selectDelivery+` WHERE id = ?`, id)
+	if err != nil {
+		return alerts.Delivery{}, err
+	}
+	out, err := scanDeliveries(rows)
+	if err != nil {
+		return alerts.Delivery{}, err
+	}
+	if len(out) == 0 {
+		return alerts.Delivery{}, alerts.ErrDeliveryNotFound
+	}
+	return out[0], nil
+}
+
+// Due implements alerts.DeliveryStore.
+func (s *Store) Due(now time.Time, limit int) ([]alerts.Delivery, error) {
+	rows, err := s.db.Query(selectDelivery+` WHERE state = ? AND next_attempt <= ?
+		ORDER BY next_attempt LIMIT ?`, alerts.DeliveryPending, dbTime(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// List implements alerts.DeliveryStore.
+func (s *Store) List(q alerts.DeliveryQuery) ([]alerts.Delivery, int, error) {
+	where, args := "", []interface{}{}
+	if q.State != "" {
+		where, args = ` WHERE state = ?`, append(args, q.State)
+	}
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM alert_deliveries`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	limit, offset := q.Page()
+	rows, err := s.db.Query(selectDelivery+where+` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := scanDeliveries(rows)
+	return out, total, err
+}
+
+// Counts implements alerts.DeliveryStore.
+func (s *Store) Counts() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT state, COUNT(*) FROM alert_deliveries GROUP BY state`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{alerts.DeliveryPending: 0, alerts.DeliveryDelivered: 0, alerts.DeliveryDead: 0}
+	for rows.Next() {
+		var state string
+		var n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		counts[state] = n
+	}
+	return counts, rows.Err()
+}
+
+// Prune implements alerts.DeliveryStore.
+func (s *Store) Prune(before time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM alert_deliveries WHERE state != ? AND updated_at < ?`,
+		alerts.DeliveryPending, dbTime(before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func scanDeliveries(rows *sql.Rows) ([]alerts.Delivery, error) {
+	defer rows.Close()
+	var out []alerts.Delivery
+	for rows.Next() {
+		var d alerts.Delivery
+		var payload, created, updated, next, until string
+		if err := rows.Scan(&d.ID, &d.Webhook, &d.Target, &payload, &d.State, &d.Attempts, &d.LastError,
+			&created, &updated, &next, &until); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &d.Payload); err != nil {
+			return nil, fmt.Errorf("alertdb: delivery %s: %w", d.ID, err)
+		}
+		var perr error
+		for _, f := range []struct {
+			s   string
+			dst *time.Time
+		}{{created, &d.CreatedAt}, {updated, &d.UpdatedAt}, {next, &d.NextAttempt}, {until, &d.RetryUntil}} {
+			t, err := time.Parse(dbTimeFormat, f.s)
+			perr = errors.Join(perr, err)
+			*f.dst = t
+		}
+		if perr != nil {
+			return nil, fmt.Errorf("alertdb: delivery %s: %w", d.ID, perr)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// dbTimeFormat is a fixed-width UTC layout, so timestamps compare as
+// strings.
+const dbTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func dbTime(t time.Time) string {
+	return t.UTC().Format(dbTimeFormat)
+}
@@ -0,0 +1,135 @@
+package alertdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/alerts"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "alert-queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	base := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i, state := range []string{alerts.DeliveryPending, alerts.DeliveryPending, alerts.DeliveryDead, alerts.DeliveryDelivered} {
+		at := base.Add(time.Duration(i) * time.Minute)
+		d := alerts.Delivery{
+			ID: string(rune('a' + i)), Webhook: "w", Target: "hooks.example.com", State: state,
+			Payload:   alerts.WebhookPayload{Summary: "status_changed: t-1", Grouped: i},
+			CreatedAt: at, UpdatedAt: at, NextAttempt: at.Add(-time.Duration(i) * 2 * time.Minute), RetryUntil: at.Add(time.Hour),
+		}
+		if err := s.Add(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due, err := s.Due(base.Add(30*time.Second), 10)
+	if err != nil || len(due) != 2 || due[0].ID != "b" || due[1].ID != "a" {
+		t.Fatalf("Due = %+v, %v", due, err)
+	}
+	d := due[0]
+	d.State, d.Attempts, d.LastError = alerts.DeliveryDead, 3, "HTTP 410"
+	if err := s.Update(d); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get("b")
+	if err != nil || got.State != alerts.DeliveryDead || got.Attempts != 3 || got.Payload.Grouped != 1 || !got.CreatedAt.Equal(d.CreatedAt) {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+	if _, err := s.Get("zz"); err != alerts.ErrDeliveryNotFound {
+		t.Errorf("Get unknown: %v", err)
+	}
+	if err := s.Update(alerts.Delivery{ID: "zz"}); err != alerts.ErrDeliveryNotFound {
+		t.Errorf("Update unknown: %v", err)
+	}
+
+	dead, total, err := s.List(alerts.DeliveryQuery{State: alerts.DeliveryDead})
+	if err != nil || total != 2 || dead[0].ID != "c" {
+		t.Errorf("List dead = %+v (total %d), %v", dead, total, err)
+	}
+	if counts, err := s.Counts(); err != nil || counts[alerts.DeliveryPending] != 1 || counts[alerts.DeliveryDead] != 2 || counts[alerts.DeliveryDelivered] != 1 {
+		t.Errorf("Counts = %v, %v", counts, err)
+	}
+	if n, err := s.Prune(base.Add(150 * time.Second)); err != nil || n != 2 {
+		t.Errorf("Prune = %d, %v", n, err)
+	}
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	al, err := audit.NewAuditLogger(filepath.Join(dir, "audit.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	var up atomic.Bool
+	var ids sync.Map
+	v := &alerts.Verifier{Secret: []byte("rotated-secret-0123")}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids.Store(r.Header.Get(alerts.HeaderDeliveryID), true)
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := v.VerifyRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	start := func(secret string) (*alerts.AlertManager, *Store) {
+		s, err := Open(filepath.Join(dir, "alert-queue.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		am := alerts.NewAlertManager(al, []alerts.WebhookConfig{{URL: srv.URL, Secret: secret}})
+		am.ConfigureQueue(alerts.QueueConfig{Store: s, MinBackoff: 200 * time.Millisecond})
+		return am, s
+	}
+	waitFor := func(am *alerts.AlertManager, cond func(alerts.Delivery) bool) alerts.Delivery {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			if ds, _, _ := am.Deliveries(alerts.DeliveryQuery{}); len(ds) == 1 && cond(ds[0]) {
+				return ds[0]
+			}
+		}
+		t.Fatal("timed out waiting for delivery")
+		return alerts.Delivery{}
+	}
+
+	// The receiver is down; the alert is attempted once before shutdown.
+	am, s := start("original-secret-0123")
+	al.Log(audit.AuditEvent{EventType: "compliance_change", Severity: "critical", Action: "status_changed", Resource: "t-1"})
+	d := waitFor(am, func(d alerts.Delivery) bool { return d.Attempts == 1 })
+	am.Close()
+	s.Close()
+	if d.State != alerts.DeliveryPending || d.LastError == "" {
+		t.Fatalf("after failed attempt: %+v", d)
+	}
+
+	// After a restart with a rotated secret the pending delivery is resumed
+	// with the same ID and signed with the new secret.
+	up.Store(true)
+	time.Sleep(200 * time.Millisecond)
+	am, s = start(string(v.Secret))
+	defer s.Close()
+	defer am.Close()
+	d = waitFor(am, func(d alerts.Delivery) bool { return d.State == alerts.DeliveryDelivered })
+	if d.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", d.Attempts)
+	}
+	n := 0
+	ids.Range(func(any, any) bool { n++; return true })
+	if _, ok := ids.Load(d.ID); !ok || n != 1 {
+		t.Errorf("attempts used %d delivery IDs, want only %s", n, d.ID)
+	}
+}
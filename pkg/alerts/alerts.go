@@ -5,6 +5,7 @@
 package alerts
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -15,26 +16,52 @@ import (
 type WebhookConfig struct {
 	URL    string   `json:"url" yaml:"url"`
 	Events []string `json:"events" yaml:"events"` // empty = all events
+	// Rules route events to the webhook; the first matching rule applies.
+	// Without rules, DefaultRules are used.
+	Rules []Rule `json:"rules,omitempty" yaml:"rules"`
+	// Type selects the payload format: generic (default), slack, teams,
+	// pagerduty, or template.
+	Type string `json:"type,omitempty" yaml:"type"`
+	// RoutingKey is the PagerDuty integration key of pagerduty webhooks.
+	RoutingKey string `json:"-" yaml:"routing_key"`
+	// Template is the Go text/template body of template webhooks, executed
+	// with the WebhookPayload; ContentType defaults to application/json.
+	Template    string `json:"template,omitempty" yaml:"template"`
+	ContentType string `json:"content_type,omitempty" yaml:"content_type"`
+	// Secret, when set, signs every delivery (see HeaderSignature).
+	Secret string `json:"-" yaml:"secret"`
 }
 
 // AlertManager dispatches webhook notifications on audit events.
 type AlertManager struct {
-	webhooks  []WebhookConfig
-	auditLog  *audit.AuditLogger
-	cooldowns map[string]time.Time // key: event_type+resource, prevents alert storms
-	mu        sync.Mutex
-	cooldown  time.Duration
+	webhooks   []WebhookConfig
+	auditLog   *audit.AuditLogger
+	groups     map[string]*alertGroup // key: webhook ID, rule, and group key; prevents alert storms
+	mu         sync.Mutex
+	cooldown   time.Duration // window of rules without their own
+	observer   func(url string, err error)
+	nodeLabels func(nodeID string) map[string]string
+	queue      *deliveryQueue
+}
+
+// alertGroup tracks a rule's grouping window for one group key.
+type alertGroup struct {
+	until      time.Time
+	window     time.Duration
+	suppressed int
+	last       audit.AuditEvent
 }
 
 // NewAlertManager creates an AlertManager and registers it as an audit listener.
 // If auditLog is nil, the manager still tracks webhooks but won't receive events.
 func NewAlertManager(auditLog *audit.AuditLogger, webhooks []WebhookConfig) *AlertManager {
 	am := &AlertManager{
-		webhooks:  webhooks,
-		auditLog:  auditLog,
-		cooldowns: make(map[string]time.Time),
-		cooldown:  5 * time.Minute,
+		webhooks: webhooks,
+		auditLog: auditLog,
+		groups:   make(map[string]*alertGroup),
+		cooldown: DefaultCooldown,
 	}
+	am.queue = newDeliveryQueue(QueueConfig{}, webhooks)
 
 	if auditLog != nil {
 		auditLog.AddListener(am.onEvent)
@@ -43,9 +70,36 @@ func NewAlertManager(auditLog *audit.AuditLogger, webhooks []WebhookConfig) *Ale
 	return am
 }
 
-// Configured returns true if at least one webhook is configured.
+// SetDeliveryObserver registers fn to be called with the outcome of every
+// webhook delivery, once it is delivered or dead-lettered. Used for metrics.
+func (am *AlertManager) SetDeliveryObserver(fn func(url string, err error)) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.observer = fn
+}
+
+// SetNodeLabels registers fn to look up a fleet node's labels for rules
+// that match on node_labels.
+func (am *AlertManager) SetNodeLabels(fn func(nodeID string) map[string]string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.nodeLabels = fn
+}
+
+// observe reports a delivery outcome to the observer, if any.
+func (am *AlertManager) observe(url string, err error) {
+	am.mu.Lock()
+	fn := am.observer
+	am.mu.Unlock()
+	if fn != nil {
+		fn(url, err)
+	}
+}
+
+// Configured returns true if at least one webhook is configured. It is
+// false for a nil manager.
 func (am *AlertManager) Configured() bool {
-	return len(am.webhooks) > 0
+	return am != nil && len(am.webhooks) > 0
 }
 
 // WebhookCount returns the number of configured webhooks.
@@ -66,7 +120,8 @@ func (am *AlertManager) TestWebhooks() map[string]error {
 	}
 
 	for _, wh := range am.webhooks {
-		results[wh.URL] = sendWebhook(wh.URL, payload)
+		results[wh.URL] = sendWebhook(wh, payload, newDeliveryID())
+		am.observe(wh.URL, results[wh.URL])
 	}
 
 	// Log the test
@@ -84,55 +139,91 @@ func (am *AlertManager) TestWebhooks() map[string]error {
 	return results
 }
 
-// onEvent is the audit listener callback. It evaluates whether the event
-// should trigger a webhook alert.
+// onEvent is the audit listener callback. It routes the event to every
+// webhook with a matching rule, subject to the rule's grouping window.
 func (am *AlertManager) onEvent(evt audit.AuditEvent) {
-	// Only alert on compliance changes and critical events
-	if !am.shouldAlert(evt) {
+	if evt.EventType == EventTypeDelivery {
 		return
 	}
+	am.mu.Lock()
+	labels := am.nodeLabels
+	am.mu.Unlock()
 
-	// Check cooldown
-	cooldownKey := evt.EventType + ":" + evt.Resource
+	for _, wh := range am.webhooks {
+		if !am.matchesFilter(wh, evt) {
+			continue
+		}
+		rules := wh.Rules
+		if len(rules) == 0 {
+			rules = DefaultRules
+		}
+		for i := range rules {
+			rule := &rules[i]
+			if !rule.Matches(evt, labels) {
+				continue
+			}
+			key := fmt.Sprintf("%s|%d|%s", wh.id(), i, rule.groupKey(evt))
+			if am.admit(key, wh, rule, evt) {
+				am.deliver(wh, newPayload(evt, rule.Name))
+			}
+			break
+		}
+	}
+}
+
+// admit reports whether evt opens a new grouping window for key. Otherwise
+// the event is counted, and a summary is sent when the window closes.
+func (am *AlertManager) admit(key string, wh WebhookConfig, rule *Rule, evt audit.AuditEvent) bool {
+	window := rule.cooldown(am.cooldown)
+	if window == 0 {
+		return true
+	}
 	am.mu.Lock()
-	if last, ok := am.cooldowns[cooldownKey]; ok && time.Since(last) < am.cooldown {
-		am.mu.Unlock()
-		return
+	defer am.mu.Unlock()
+	now := time.Now()
+	g, ok := am.groups[key]
+	if !ok || !now.Before(g.until) {
+		am.groups[key] = &alertGroup{until: now.Add(window), window: window}
+		return true
 	}
-	am.cooldowns[cooldownKey] = time.Now()
+	if g.suppressed == 0 {
+		time.AfterFunc(g.until.Sub(now), func() { am.flushGroup(key, wh, rule.Name) })
+	}
+	g.suppressed++
+	g.last = evt
+	return false
+}
+
+// flushGroup sends a summary of the events suppressed in key's window.
+func (am *AlertManager) flushGroup(key string, wh WebhookConfig, ruleName string) {
+	am.mu.Lock()
+	g := am.groups[key]
+	delete(am.groups, key)
 	am.mu.Unlock()
+	if g == nil || g.suppressed == 0 {
+		return
+	}
+	payload := newPayload(g.last, ruleName)
+	payload.Summary += fmt.Sprintf(" (%d more within %s)", g.suppressed, g.window)
+	payload.Grouped = g.suppressed
+	am.deliver(wh, payload)
+}
 
-	payload := WebhookPayload{
+// deliver queues payload for wh; the queue retries it with backoff.
+func (am *AlertManager) deliver(wh WebhookConfig, payload WebhookPayload) {
+	am.enqueue(wh, payload)
+}
+
+func newPayload(evt audit.AuditEvent, rule string) WebhookPayload {
+	return WebhookPayload{
 		Timestamp: evt.Timestamp,
 		EventType: evt.EventType,
 		Severity:  evt.Severity,
 		Summary:   evt.Action + ": " + evt.Resource,
 		Detail:    evt.Detail,
 		NISTRef:   evt.NISTRef,
-	}
-
-	for _, wh := range am.webhooks {
-		if am.matchesFilter(wh, evt) {
-			go func(url string) {
-				_ = sendWebhookWithRetry(url, payload, 3)
-			}(wh.URL)
-		}
-	}
-}
-
-// shouldAlert returns true if the event warrants a webhook notification.
-func (am *AlertManager) shouldAlert(evt audit.AuditEvent) bool {
-	switch evt.EventType {
-	case "compliance_change":
-		return true
-	case "auth_attempt":
-		return evt.Action == "login_failed" || evt.Action == "lockout"
-	case "credential_lifecycle":
-		return evt.Severity == "warning" || evt.Severity == "critical"
-	case "system_event":
-		return evt.Severity == "critical"
-	default:
-		return false
+		Resource:  evt.Resource,
+		Rule:      rule,
 	}
 }
 
@@ -166,29 +166,54 @@ func TestEventFilter(t *testing.T) {
 	}
 }
 
-func TestShouldAlert(t *testing.T) {
-	am := &AlertManager{}
-
+func TestDefaultRules(t *testing.T) {
 	tests := []struct {
 		evt  audit.AuditEvent
-		want bool
+		want string // matching rule, or "" for none
 	}{
-		{audit.AuditEvent{EventType: "compliance_change"}, true},
-		{audit.AuditEvent{EventType: "auth_attempt", Action: "login_failed"}, true},
-		{audit.AuditEvent{EventType: "auth_attempt", Action: "login_success"}, false},
-		{audit.AuditEvent{EventType: "auth_attempt", Action: "lockout"}, true},
-		{audit.AuditEvent{EventType: "credential_lifecycle", Severity: "warning"}, true},
-		{audit.AuditEvent{EventType: "credential_lifecycle", Severity: "info"}, false},
-		{audit.AuditEvent{EventType: "system_event", Severity: "critical"}, true},
-		{audit.AuditEvent{EventType: "system_event", Severity: "info"}, false},
-		{audit.AuditEvent{EventType: "api_access"}, false},
+		{audit.AuditEvent{EventType: "compliance_change"}, "compliance-change"},
+		{audit.AuditEvent{EventType: "auth_attempt", Action: "login_failed"}, "auth-failure"},
+		{audit.AuditEvent{EventType: "auth_attempt", Action: "login_success"}, ""},
+		{audit.AuditEvent{EventType: "auth_attempt", Action: "lockout"}, "auth-failure"},
+		{audit.AuditEvent{EventType: "credential_lifecycle", Severity: "warning"}, "credential-warning"},
+		{audit.AuditEvent{EventType: "credential_lifecycle", Severity: "info"}, ""},
+		{audit.AuditEvent{EventType: "system_event", Severity: "critical"}, "system-critical"},
+		{audit.AuditEvent{EventType: "system_event", Severity: "info"}, ""},
+		{audit.AuditEvent{EventType: "api_access"}, ""},
 	}
 
 	for _, tt := range tests {
-		got := am.shouldAlert(tt.evt)
+		got := ""
+		for i := range DefaultRules {
+			if DefaultRules[i].Matches(tt.evt, nil) {
+				got = DefaultRules[i].Name
+				break
+			}
+		}
 		if got != tt.want {
-			t.Errorf("shouldAlert(%s/%s/%s) = %v, want %v",
+			t.Errorf("DefaultRules match for %s/%s/%s = %q, want %q",
 				tt.evt.EventType, tt.evt.Action, tt.evt.Severity, got, tt.want)
 		}
 	}
 }
+
+func TestDeliveryObserver(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	am := NewAlertManager(nil, []WebhookConfig{{URL: ok.URL}, {URL: bad.URL}})
+	outcomes := make(map[string]error)
+	am.SetDeliveryObserver(func(url string, err error) { outcomes[url] = err })
+	am.TestWebhooks()
+
+	if err, seen := outcomes[ok.URL]; !seen || err != nil {
+		t.Errorf("ok webhook outcome = %v (seen %v), want success", err, seen)
+	}
+	if err := outcomes[bad.URL]; err == nil {
+		t.Error("failing webhook reported as success")
+	}
+}
@@ -0,0 +1,536 @@
+package alerts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+// Delivery states.
+const (
+	DeliveryPending   = "pending"   // waiting for its next attempt
+	DeliveryDelivered = "delivered" // accepted by the receiver
+	DeliveryDead      = "dead"      // dead-lettered: retries exhausted or a permanent error
+)
+
+// Queue defaults.
+const (
+	DefaultMinBackoff     = 10 * time.Second
+	DefaultMaxBackoff     = time.Hour
+	DefaultMaxDeliveryAge = 24 * time.Hour
+	DefaultRetention      = 7 * 24 * time.Hour
+)
+
+// EventTypeDelivery is the audit event type of dead-letter and replay
+// events. The AlertManager does not route these, so a failing webhook
+// cannot alert about itself in a loop.
+const EventTypeDelivery = "alert_delivery"
+
+// Delivery errors.
+var (
+	ErrDeliveryNotFound = errors.New("alerts: delivery not found")
+	ErrNotDeadLettered  = errors.New("alerts: only dead-lettered deliveries can be replayed")
+)
+
+// Delivery is one alert queued for one webhook. The ID is sent as
+// X-Delivery-ID on every attempt.
+type Delivery struct {
+	ID string `json:"id"`
+	// Webhook identifies the destination by a hash of its configuration
+	// (see WebhookConfig.id); Target is the URL's host. Webhook URLs and
+	// routing keys are credentials, so neither the store nor the API holds
+	// them.
+	Webhook   string         `json:"webhook"`
+	Target    string         `json:"target"`
+	Payload   WebhookPayload `json:"payload"`
+	State     string         `json:"state"`
+	Attempts  int            `json:"attempts"`
+	LastError string         `json:"last_error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	// NextAttempt is when a pending delivery is next sent; RetryUntil is
+	// when it is dead-lettered if it still fails.
+	NextAttempt time.Time `json:"next_attempt"`
+	RetryUntil  time.Time `json:"retry_until"`
+}
+
+// DeliveryQuery filters deliveries by state (empty matches all) and pages
+// through them newest first. Limit defaults to audit.DefaultQueryLimit.
+type DeliveryQuery struct {
+	State  string
+	Limit  int
+	Offset int
+}
+
+// Page returns the effective limit and offset.
+func (q DeliveryQuery) Page() (int, int) {
+	return audit.EventQuery{Limit: q.Limit, Offset: q.Offset}.Page()
+}
+
+// DeliveryStore holds the delivery queue. The default store is in memory;
+// package alertdb provides one that survives restarts.
+type DeliveryStore interface {
+	Add(d Delivery) error
+	Update(d Delivery) error
+	// Get returns ErrDeliveryNotFound for unknown IDs.
+	Get(id string) (Delivery, error)
+	// Due returns up to limit pending deliveries whose next attempt is not
+	// after now, earliest first.
+	Due(now time.Time, limit int) ([]Delivery, error)
+	// List returns the page of matching deliveries and the total count.
+	List(q DeliveryQuery) ([]Delivery, int, error)
+	// Counts returns the number of deliveries in each state.
+	Counts() (map[string]int, error)
+	// Prune removes delivered and dead deliveries last updated before t.
+	Prune(before time.Time) (int, error)
+}
+
+// QueueConfig configures the outbound delivery queue.
+type QueueConfig struct {
+	// Store holds queued deliveries (default: in memory).
+	Store DeliveryStore
+	// MinBackoff and MaxBackoff bound the exponential delay between
+	// attempts (defaults DefaultMinBackoff and DefaultMaxBackoff).
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxAge is how long a delivery is retried before it is dead-lettered
+	// (default DefaultMaxDeliveryAge).
+	MaxAge time.Duration
+	// Retention is how long delivered and dead deliveries are kept
+	// (default DefaultRetention).
+	Retention time.Duration
+}
+
+const (
+	queuePollInterval  = time.Second
+	queueMaxInFlight   = 8
+	queuePruneInterval = time.Hour
+)
+
+// deliveryQueue sends queued deliveries in the background, retrying with
+// exponential backoff.
+type deliveryQueue struct {
+	cfg      QueueConfig
+	webhooks map[string]WebhookConfig // by WebhookConfig.id
+
+	mu        sync.Mutex
+	inflight  map[string]bool
+	started   bool
+	lastPrune time.Time
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	sends     sync.WaitGroup
+}
+
+func newDeliveryQueue(cfg QueueConfig, webhooks []WebhookConfig) *deliveryQueue {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultMaxDeliveryAge
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	q := &deliveryQueue{
+		cfg:      cfg,
+		webhooks: make(map[string]WebhookConfig),
+		inflight: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, wh := range webhooks {
+		if _, dup := q.webhooks[wh.id()]; !dup {
+			q.webhooks[wh.id()] = wh
+		}
+	}
+	return q
+}
+
+// id identifies a webhook in the queue without storing its URL or keys.
+// Webhooks that share a URL but differ in format or routing key, such as
+// two PagerDuty services, are distinct destinations. The secret and
+// template are not part of the identity: each attempt uses the current
+// ones, so rotating a secret does not strand pending deliveries.
+func (wh WebhookConfig) id() string {
+	typ := wh.Type
+	if typ == "" {
+		typ = TypeGeneric
+	}
+	h := sha256.New()
+	for _, f := range []string{typ, wh.URL, wh.RoutingKey} {
+		fmt.Fprintf(h, "%d:%s;", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// target returns the host of the webhook URL.
+func (wh WebhookConfig) target() string {
+	if u, err := url.Parse(wh.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return wh.id()
+}
+
+// ConfigureQueue replaces the manager's in-memory delivery queue, e.g. with
+// an alertdb.Store, and resumes the deliveries it holds. Call it before
+// events are logged.
+func (am *AlertManager) ConfigureQueue(cfg QueueConfig) {
+	am.mu.Lock()
+	old := am.queue
+	am.queue = newDeliveryQueue(cfg, am.webhooks)
+	q := am.queue
+	am.mu.Unlock()
+	old.close()
+	am.startQueue(q)
+}
+
+// Close stops the delivery queue, waiting for sends in progress. Pending
+// deliveries stay in a persistent store for the next start.
+func (am *AlertManager) Close() {
+	am.mu.Lock()
+	q := am.queue
+	am.mu.Unlock()
+	q.close()
+}
+
+// Deliveries lists queued, delivered, and dead-lettered deliveries.
+func (am *AlertManager) Deliveries(q DeliveryQuery) ([]Delivery, int, error) {
+	return am.deliveryQueue().cfg.Store.List(q)
+}
+
+// DeliveryCounts returns the number of deliveries in each state.
+func (am *AlertManager) DeliveryCounts() (map[string]int, error) {
+	return am.deliveryQueue().cfg.Store.Counts()
+}
+
+// Replay re-queues a dead-lettered delivery for immediate sending, with a
+// fresh retry window. It keeps its delivery ID.
+func (am *AlertManager) Replay(id string) (Delivery, error) {
+	q := am.deliveryQueue()
+	d, err := q.cfg.Store.Get(id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if d.State != DeliveryDead {
+		return d, ErrNotDeadLettered
+	}
+	now := time.Now().UTC()
+	d.State = DeliveryPending
+	d.UpdatedAt = now
+	d.NextAttempt = now
+	d.RetryUntil = now.Add(q.cfg.MaxAge)
+	if err := q.cfg.Store.Update(d); err != nil {
+		return Delivery{}, err
+	}
+	am.startQueue(q)
+	q.signal()
+	return d, nil
+}
+
+func (am *AlertManager) deliveryQueue() *deliveryQueue {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.queue
+}
+
+// enqueue queues payload for wh and wakes the sender.
+func (am *AlertManager) enqueue(wh WebhookConfig, payload WebhookPayload) {
+	q := am.deliveryQueue()
+	now := time.Now().UTC()
+	d := Delivery{
+		ID:          newDeliveryID(),
+		Webhook:     wh.id(),
+		Target:      wh.target(),
+		Payload:     payload,
+		State:       DeliveryPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		NextAttempt: now,
+		RetryUntil:  now.Add(q.cfg.MaxAge),
+	}
+	if err := q.cfg.Store.Add(d); err != nil {
+		// Without a queue entry the alert would be lost; send it directly.
+		fmt.Fprintf(os.Stderr, "alerts: queue delivery: %v\n", err)
+		go func() { am.observe(wh.URL, sendWebhook(wh, payload, d.ID)) }()
+		return
+	}
+	am.startQueue(q)
+	q.signal()
+}
+
+// startQueue starts q's sender once.
+func (am *AlertManager) startQueue(q *deliveryQueue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+	go am.runQueue(q)
+}
+
+func (q *deliveryQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *deliveryQueue) close() {
+	q.mu.Lock()
+	started := q.started
+	select {
+	case <-q.stop:
+	default:
+		close(q.stop)
+	}
+	q.mu.Unlock()
+	if started {
+		<-q.done
+	}
+	q.sends.Wait()
+}
+
+func (am *AlertManager) runQueue(q *deliveryQueue) {
+	defer close(q.done)
+	t := time.NewTicker(queuePollInterval)
+	defer t.Stop()
+	for {
+		am.dispatch(q)
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-t.C:
+		}
+	}
+}
+
+// dispatch starts an attempt for each due delivery, up to
+// queueMaxInFlight at a time, and prunes old deliveries hourly.
+func (am *AlertManager) dispatch(q *deliveryQueue) {
+	now := time.Now().UTC()
+	if now.Sub(q.lastPrune) >= queuePruneInterval {
+		q.lastPrune = now
+		if _, err := q.cfg.Store.Prune(now.Add(-q.cfg.Retention)); err != nil {
+			fmt.Fprintf(os.Stderr, "alerts: prune deliveries: %v\n", err)
+		}
+	}
+	due, err := q.cfg.Store.Due(now, queueMaxInFlight*4)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alerts: read delivery queue: %v\n", err)
+		return
+	}
+	for _, d := range due {
+		q.mu.Lock()
+		if q.inflight[d.ID] || len(q.inflight) >= queueMaxInFlight {
+			q.mu.Unlock()
+			continue
+		}
+		select {
+		case <-q.stop:
+			q.mu.Unlock()
+			return
+		default:
+		}
+		q.inflight[d.ID] = true
+		q.sends.Add(1)
+		q.mu.Unlock()
+		go func(d Delivery) {
+			defer q.sends.Done()
+			am.attempt(q, d)
+			q.mu.Lock()
+			delete(q.inflight, d.ID)
+			q.mu.Unlock()
+			q.signal()
+		}(d)
+	}
+}
+
+// attempt sends d once and records the outcome: delivered, dead-lettered,
+// or pending with the next backoff.
+func (am *AlertManager) attempt(q *deliveryQueue, d Delivery) {
+	wh, ok := q.webhooks[d.Webhook]
+	target := wh.URL
+	var err error
+	if ok {
+		err = sendWebhook(wh, d.Payload, d.ID)
+	} else {
+		target = d.Target
+		err = permanentError{errors.New("webhook no longer configured")}
+	}
+	now := time.Now().UTC()
+	d.Attempts++
+	d.UpdatedAt = now
+	switch {
+	case err == nil:
+		d.State = DeliveryDelivered
+		d.LastError = ""
+	case isPermanent(err) || !now.Before(d.RetryUntil):
+		d.State = DeliveryDead
+	default:
+		d.NextAttempt = now.Add(q.backoff(d.Attempts))
+	}
+	if err != nil {
+		d.LastError = err.Error()
+		if ok {
+			// Errors name the URL; keep only the host.
+			d.LastError = strings.ReplaceAll(d.LastError, wh.URL, d.Target)
+		}
+	}
+	if uerr := q.cfg.Store.Update(d); uerr != nil {
+		fmt.Fprintf(os.Stderr, "alerts: update delivery %s: %v\n", d.ID, uerr)
+	}
+
+	switch d.State {
+	case DeliveryDelivered:
+		am.observe(target, nil)
+	case DeliveryDead:
+		am.observe(target, err)
+		if am.auditLog != nil {
+			am.auditLog.Log(audit.AuditEvent{
+				EventType: EventTypeDelivery,
+				Severity:  "warning",
+				Actor:     "system",
+				Resource:  d.Target,
+				Action:    "dead_lettered",
+				Detail:    fmt.Sprintf("Alert delivery %s (%s) dead-lettered after %d attempt(s): %s", d.ID, d.Payload.Summary, d.Attempts, d.LastError),
+				NISTRef:   "SI-4",
+			})
+		}
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (q *deliveryQueue) backoff(attempts int) time.Duration {
+	d := q.cfg.MinBackoff
+	for i := 1; i < attempts && d < q.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.cfg.MaxBackoff)
+}
+
+// MemoryStore is a DeliveryStore that does not survive restarts.
+type MemoryStore struct {
+	mu         sync.Mutex
+	deliveries map[string]Delivery
+}
+
+// NewMemoryStore returns an empty in-memory delivery store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: make(map[string]Delivery)}
+}
+
+// Add implements DeliveryStore.
+func (s *MemoryStore) Add(d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+// Update implements DeliveryStore.
+func (s *MemoryStore) Update(d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deliveries[d.ID]; !ok {
+		return ErrDeliveryNotFound
+	}
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+// Get implements DeliveryStore.
+func (s *MemoryStore) Get(id string) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// Due implements DeliveryStore.
+func (s *MemoryStore) Due(now time.Time, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	var due []Delivery
+	for _, d := range s.deliveries {
+		if d.State == DeliveryPending && !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// List implements DeliveryStore.
+func (s *MemoryStore) List(q DeliveryQuery) ([]Delivery, int, error) {
+	s.mu.Lock()
+	var out []Delivery
+	for _, d := range s.deliveries {
+		if q.State == "" || d.State == q.State {
+			out = append(out, d)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	total := len(out)
+	limit, offset := q.Page()
+	if offset > total {
+		offset = total
+	}
+	return out[offset:min(offset+limit, total)], total, nil
+}
+
+// Counts implements DeliveryStore.
+func (s *MemoryStore) Counts() (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int{DeliveryPending: 0, DeliveryDelivered: 0, DeliveryDead: 0}
+	for _, d := range s.deliveries {
+		counts[d.State]++
+	}
+	return counts, nil
+}
+
+// Prune implements DeliveryStore.
+func (s *MemoryStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, d := range s.deliveries {
+		if d.State != DeliveryPending && d.UpdatedAt.Before(before) {
+			delete(s.deliveries, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,179 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+// waitDelivery polls am until its only delivery satisfies cond.
+func waitDelivery(t *testing.T, am *AlertManager, cond func(Delivery) bool) Delivery {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if ds, _, _ := am.Deliveries(DeliveryQuery{}); len(ds) == 1 && cond(ds[0]) {
+			return ds[0]
+		}
+	}
+	ds, _, _ := am.Deliveries(DeliveryQuery{})
+	t.Fatalf("timed out waiting for delivery: %+v", ds)
+	return Delivery{}
+}
+
+var queueEvent = audit.AuditEvent{EventType: "compliance_change", Severity: "critical", Action: "status_changed", Resource: "t-1"}
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	observed := make(chan error, 4)
+	al := newTestAuditLogger(t)
+	am := NewAlertManager(al, []WebhookConfig{{URL: srv.URL + "/hook?token=s3cret"}})
+	am.ConfigureQueue(QueueConfig{MinBackoff: 20 * time.Millisecond})
+	am.SetDeliveryObserver(func(_ string, err error) { observed <- err })
+	t.Cleanup(am.Close)
+
+	al.Log(queueEvent)
+	d := waitDelivery(t, am, func(d Delivery) bool { return d.State == DeliveryDelivered })
+	if d.Attempts != 3 || d.LastError != "" || d.Payload.Summary != "status_changed: t-1" {
+		t.Errorf("delivery = %+v", d)
+	}
+	if strings.Contains(d.Target, "s3cret") || d.Target != strings.TrimPrefix(srv.URL, "http://") {
+		t.Errorf("target = %q", d.Target)
+	}
+	select {
+	case err := <-observed:
+		if err != nil || len(observed) != 0 {
+			t.Errorf("observed %v, want one success", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("delivery not observed")
+	}
+
+	q := am.deliveryQueue()
+	var got []time.Duration
+	for n := 1; n <= 4; n++ {
+		got = append(got, q.backoff(n))
+	}
+	q.cfg.MaxBackoff = 50 * time.Millisecond
+	if got[0] != 20*time.Millisecond || got[3] != 160*time.Millisecond || q.backoff(30) != 50*time.Millisecond {
+		t.Errorf("backoff = %v, capped %v", got, q.backoff(30))
+	}
+}
+
+func TestQueueDeadLetterAndReplay(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusGone)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	al := newTestAuditLogger(t)
+	am := NewAlertManager(al, []WebhookConfig{{URL: srv.URL}})
+	am.ConfigureQueue(QueueConfig{MinBackoff: time.Millisecond})
+	t.Cleanup(am.Close)
+
+	// A 4xx other than 408, 425, or 429 is not retried.
+	al.Log(queueEvent)
+	d := waitDelivery(t, am, func(d Delivery) bool { return d.State == DeliveryDead })
+	if d.Attempts != 1 || !strings.Contains(d.LastError, "HTTP 410") || strings.Contains(d.LastError, "http://") {
+		t.Errorf("dead delivery = %+v", d)
+	}
+	if counts, _ := am.DeliveryCounts(); counts[DeliveryDead] != 1 || counts[DeliveryPending] != 0 {
+		t.Errorf("counts = %v", counts)
+	}
+
+	if _, err := am.Replay("unknown"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("Replay unknown: %v", err)
+	}
+	status.Store(http.StatusOK)
+	if _, err := am.Replay(d.ID); err != nil {
+		t.Fatal(err)
+	}
+	d = waitDelivery(t, am, func(d Delivery) bool { return d.State == DeliveryDelivered })
+	if d.Attempts != 2 {
+		t.Errorf("replayed delivery = %+v", d)
+	}
+	if last := al.RecentEvents(1)[0]; last.EventType != EventTypeDelivery || last.Action != "dead_lettered" {
+		t.Errorf("last audit event = %+v", last)
+	}
+	if _, err := am.Replay(d.ID); !errors.Is(err, ErrNotDeadLettered) {
+		t.Errorf("Replay delivered: %v", err)
+	}
+}
+
+func TestQueueDeadLettersAfterMaxAge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	al := newTestAuditLogger(t)
+	am := NewAlertManager(al, []WebhookConfig{{URL: srv.URL}})
+	am.ConfigureQueue(QueueConfig{MinBackoff: 10 * time.Millisecond, MaxAge: 100 * time.Millisecond})
+	t.Cleanup(am.Close)
+
+	al.Log(queueEvent)
+	d := waitDelivery(t, am, func(d Delivery) bool { return d.State == DeliveryDead })
+	if d.Attempts < 2 || !strings.Contains(d.LastError, "HTTP 503") {
+		t.Errorf("delivery = %+v", d)
+	}
+}
+
+func TestQueueSeparatesWebhooksSharingURL(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var evt struct {
+			RoutingKey string `json:"routing_key"`
+		}
+		json.NewDecoder(r.Body).Decode(&evt)
+		mu.Lock()
+		keys = append(keys, evt.RoutingKey)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	// Two PagerDuty services behind the same Events API URL.
+	al := newTestAuditLogger(t)
+	am := NewAlertManager(al, []WebhookConfig{
+		{URL: srv.URL, Type: TypePagerDuty, RoutingKey: "SERVICE1"},
+		{URL: srv.URL, Type: TypePagerDuty, RoutingKey: "SERVICE2"},
+	})
+	t.Cleanup(am.Close)
+
+	al.Log(queueEvent)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if counts, _ := am.DeliveryCounts(); counts[DeliveryDelivered] == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			ds, _, _ := am.Deliveries(DeliveryQuery{})
+			t.Fatalf("timed out waiting for deliveries: %+v", ds)
+		}
+	}
+	ds, _, _ := am.Deliveries(DeliveryQuery{})
+	if len(ds) != 2 || ds[0].Webhook == ds[1].Webhook {
+		t.Errorf("deliveries = %+v", ds)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "SERVICE1,SERVICE2" {
+		t.Errorf("routing keys received = %v", keys)
+	}
+}
@@ -0,0 +1,246 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+// DefaultCooldown is the grouping window of rules that do not set one.
+const DefaultCooldown = 5 * time.Minute
+
+// Rule selects the audit events a webhook receives. Every non-empty field
+// must match. Within a rule's cooldown, further events with the same group
+// key are counted and sent as one summary when the window closes.
+type Rule struct {
+	Name string `json:"name,omitempty" yaml:"name"`
+	// EventTypes and Actions list accepted values; empty accepts any.
+	EventTypes []string `json:"event_types,omitempty" yaml:"event_types"`
+	Actions    []string `json:"actions,omitempty" yaml:"actions"`
+	// MinSeverity is the lowest severity that matches: info, warning, or
+	// critical.
+	MinSeverity string `json:"min_severity,omitempty" yaml:"min_severity"`
+	// Resources and NISTRefs are globs ('*' matches any run of characters).
+	// A NIST pattern matches if any control in the event's reference does,
+	// e.g. "AU-*" or "SC-8".
+	Resources []string `json:"resources,omitempty" yaml:"resources"`
+	NISTRefs  []string `json:"nist_refs,omitempty" yaml:"nist_refs"`
+	// NodeLabels requires the event to concern a fleet node (actor or
+	// resource "node:<id>") that has every listed label.
+	NodeLabels map[string]string `json:"node_labels,omitempty" yaml:"node_labels"`
+	// Cooldown is the grouping window (default DefaultCooldown; negative
+	// sends every event).
+	Cooldown time.Duration `json:"cooldown,omitempty" yaml:"cooldown"`
+	// GroupBy lists the event fields that form the group key: event_type,
+	// action, severity, resource, actor, node, or source_ip (default
+	// event_type and resource).
+	GroupBy []string `json:"group_by,omitempty" yaml:"group_by"`
+}
+
+// DefaultRules apply to webhooks without rules: compliance changes, failed
+// logins and lockouts, credential warnings, and critical system events.
+var DefaultRules = []Rule{
+	{Name: "compliance-change", EventTypes: []string{"compliance_change"}},
+	{Name: "auth-failure", EventTypes: []string{"auth_attempt"}, Actions: []string{"login_failed", "lockout"}},
+	{Name: "credential-warning", EventTypes: []string{"credential_lifecycle"}, MinSeverity: "warning"},
+	{Name: "system-critical", EventTypes: []string{"system_event"}, MinSeverity: "critical"},
+}
+
+var severityRank = map[string]int{"info": 0, "warning": 1, "critical": 2}
+
+var groupFields = map[string]func(audit.AuditEvent) string{
+	"event_type": func(e audit.AuditEvent) string { return e.EventType },
+	"action":     func(e audit.AuditEvent) string { return e.Action },
+	"severity":   func(e audit.AuditEvent) string { return e.Severity },
+	"resource":   func(e audit.AuditEvent) string { return e.Resource },
+	"actor":      func(e audit.AuditEvent) string { return e.Actor },
+	"node":       eventNode,
+	"source_ip":  func(e audit.AuditEvent) string { return e.SourceIP },
+}
+
+// Validate checks the rule's severity and group-by fields.
+func (r *Rule) Validate() error {
+	if _, ok := severityRank[r.MinSeverity]; r.MinSeverity != "" && !ok {
+		return fmt.Errorf("rule %q: invalid min_severity %q (want info, warning, or critical)", r.Name, r.MinSeverity)
+	}
+	for _, f := range r.GroupBy {
+		if groupFields[f] == nil {
+			return fmt.Errorf("rule %q: invalid group_by field %q", r.Name, f)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether evt satisfies the rule. labels returns a fleet
+// node's labels and may be nil.
+func (r *Rule) Matches(evt audit.AuditEvent, labels func(nodeID string) map[string]string) bool {
+	if len(r.EventTypes) > 0 && !contains(r.EventTypes, evt.EventType) {
+		return false
+	}
+	if len(r.Actions) > 0 && !contains(r.Actions, evt.Action) {
+		return false
+	}
+	if r.MinSeverity != "" && severityRank[evt.Severity] < severityRank[r.MinSeverity] {
+		return false
+	}
+	if len(r.Resources) > 0 && !matchAny(r.Resources, evt.Resource) {
+		return false
+	}
+	if len(r.NISTRefs) > 0 {
+		found := false
+		for _, ref := range strings.Split(evt.NISTRef, ",") {
+			if ref = strings.TrimSpace(ref); ref != "" && matchAny(r.NISTRefs, ref) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.NodeLabels) > 0 {
+		node := eventNode(evt)
+		if node == "" || labels == nil {
+			return false
+		}
+		have := labels(node)
+		for k, v := range r.NodeLabels {
+			if have[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cooldown returns the rule's grouping window; zero disables grouping.
+func (r *Rule) cooldown(def time.Duration) time.Duration {
+	switch {
+	case r.Cooldown < 0:
+		return 0
+	case r.Cooldown == 0:
+		return def
+	}
+	return r.Cooldown
+}
+
+// groupKey identifies the events the rule groups together.
+func (r *Rule) groupKey(evt audit.AuditEvent) string {
+	fields := r.GroupBy
+	if len(fields) == 0 {
+		fields = []string{"event_type", "resource"}
+	}
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = groupFields[f](evt)
+	}
+	return strings.Join(parts, ":")
+}
+
+// eventNode returns the fleet node an event concerns, from a "node:<id>"
+// resource or actor.
+func eventNode(evt audit.AuditEvent) string {
+	for _, s := range []string{evt.Resource, evt.Actor} {
+		if id, ok := strings.CutPrefix(s, "node:"); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if globMatch(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches s against pattern, where '*' matches any run of
+// characters, including '/'.
+func globMatch(pattern, s string) bool {
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, pattern[:star]) {
+		return false
+	}
+	rest := pattern[star+1:]
+	for i := star; i <= len(s); i++ {
+		if globMatch(rest, s[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// alertsFile is the layout of the alerts section of the YAML config.
+type alertsFile struct {
+	Alerts struct {
+		Webhooks []WebhookConfig `yaml:"webhooks"`
+	} `yaml:"alerts"`
+}
+
+// LoadWebhooks reads webhook destinations and their routing rules from the
+// alerts section of a YAML file, such as cloudflared-fips.yaml:
+//
+//	alerts:
+//	  webhooks:
+//	    - url: https://hooks.example.com/secops
+//	      rules:
+//	        - name: prod-crypto
+//	          event_types: [compliance_change]
+//	          min_severity: warning
+//	          resources: ["t-*"]
+//	          nist_refs: ["SC-13", "IA-7"]
+//	          node_labels: {env: prod}
+//	          cooldown: 10m
+//	          group_by: [resource, node]
+//	    - type: pagerduty
+//	      routing_key: R0UT1NGKEY
+//
+// See WebhookConfig.Type for the payload formats.
+func LoadWebhooks(path string) ([]WebhookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read alert config: %w", err)
+	}
+	var f alertsFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse alert config: %w", err)
+	}
+	seen := make(map[string]int)
+	for i := range f.Alerts.Webhooks {
+		if err := f.Alerts.Webhooks[i].Validate(); err != nil {
+			return nil, fmt.Errorf("alert webhook %d: %w", i+1, err)
+		}
+		// A duplicate would share the first one's deliveries and never
+		// receive its own alerts.
+		id := f.Alerts.Webhooks[i].id()
+		if j, dup := seen[id]; dup {
+			return nil, fmt.Errorf("alert webhook %d: duplicates webhook %d", i+1, j)
+		}
+		seen[id] = i + 1
+	}
+	if len(f.Alerts.Webhooks) == 0 {
+		return nil, errors.New("alert config has no alerts.webhooks")
+	}
+	return f.Alerts.Webhooks, nil
+}
@@ -0,0 +1,157 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+func TestRuleMatches(t *testing.T) {
+	labels := func(id string) map[string]string {
+		if id == "n1" {
+			return map[string]string{"env": "prod", "team": "edge"}
+		}
+		return nil
+	}
+	rule := Rule{
+		EventTypes:  []string{"compliance_change", "config_change"},
+		MinSeverity: "warning",
+		Resources:   []string{"node:*"},
+		NISTRefs:    []string{"SC-*"},
+		NodeLabels:  map[string]string{"env": "prod"},
+	}
+	base := audit.AuditEvent{EventType: "compliance_change", Severity: "critical", Resource: "node:n1", NISTRef: "CM-3, SC-13"}
+
+	tests := []struct {
+		name string
+		edit func(*audit.AuditEvent)
+		want bool
+	}{
+		{"match", func(*audit.AuditEvent) {}, true},
+		{"event type", func(e *audit.AuditEvent) { e.EventType = "api_access" }, false},
+		{"below severity", func(e *audit.AuditEvent) { e.Severity = "info" }, false},
+		{"resource glob", func(e *audit.AuditEvent) { e.Resource = "fleet_policy"; e.Actor = "node:n1" }, false},
+		{"nist ref", func(e *audit.AuditEvent) { e.NISTRef = "AU-2" }, false},
+		{"node labels", func(e *audit.AuditEvent) { e.Resource = "node:n2" }, false},
+	}
+	for _, tt := range tests {
+		evt := base
+		tt.edit(&evt)
+		if got := rule.Matches(evt, labels); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if rule.Matches(base, nil) {
+		t.Error("node label rule matched without a label lookup")
+	}
+
+	for pattern, s := range map[string]string{"GET /api/*": "GET /api/v1/fleet/nodes", "*-1": "t-1", "a*b*c": "aXXbYc"} {
+		if !globMatch(pattern, s) {
+			t.Errorf("globMatch(%q, %q) = false", pattern, s)
+		}
+	}
+	if globMatch("t-*", "ce-1") {
+		t.Error("globMatch(t-*, ce-1) = true")
+	}
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cloudflared-fips.yaml")
+	os.WriteFile(path, []byte(`role: controller
+alerts:
+  webhooks:
+    - url: https://hooks.example.com/secops
+      rules:
+        - name: prod-crypto
+          event_types: [compliance_change]
+          min_severity: warning
+          nist_refs: ["SC-13"]
+          node_labels: {env: prod}
+          cooldown: 10m
+          group_by: [resource, node]
+    - url: https://hooks.example.com/all
+    - type: pagerduty
+      routing_key: SERVICE1
+    - type: pagerduty
+      routing_key: SERVICE2
+`), 0o600)
+	webhooks, err := LoadWebhooks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks) != 4 || len(webhooks[0].Rules) != 1 || len(webhooks[1].Rules) != 0 {
+		t.Fatalf("webhooks = %+v", webhooks)
+	}
+	r := webhooks[0].Rules[0]
+	if r.Name != "prod-crypto" || r.Cooldown != 10*time.Minute || r.NodeLabels["env"] != "prod" || len(r.GroupBy) != 2 {
+		t.Errorf("rule = %+v", r)
+	}
+
+	for name, body := range map[string]string{
+		"severity":  "alerts:\n  webhooks:\n    - url: https://x\n      rules: [{min_severity: high}]\n",
+		"group_by":  "alerts:\n  webhooks:\n    - url: https://x\n      rules: [{group_by: [host]}]\n",
+		"url":       "alerts:\n  webhooks:\n    - url: ftp://x\n",
+		"empty":     "role: controller\n",
+		"duplicate": "alerts:\n  webhooks:\n    - {type: pagerduty, routing_key: k}\n    - {type: pagerduty, routing_key: k}\n",
+	} {
+		os.WriteFile(path, []byte(body), 0o600)
+		if _, err := LoadWebhooks(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRoutingAndGrouping(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]WebhookPayload)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p WebhookPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], p)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	al := newTestAuditLogger(t)
+	am := NewAlertManager(al, []WebhookConfig{
+		{URL: srv.URL + "/auth", Rules: []Rule{
+			{Name: "failed-logins", EventTypes: []string{"auth_attempt"}, Actions: []string{"login_failed"}, Cooldown: 100 * time.Millisecond, GroupBy: []string{"event_type"}},
+		}},
+		{URL: srv.URL + "/prod", Rules: []Rule{
+			{Name: "prod-nodes", NodeLabels: map[string]string{"env": "prod"}, Cooldown: -1},
+		}},
+	})
+	am.SetNodeLabels(func(id string) map[string]string {
+		return map[string]string{"env": id}
+	})
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		al.Log(audit.AuditEvent{EventType: "auth_attempt", Action: "login_failed", Resource: "/api/v1/fleet", SourceIP: ip})
+	}
+	al.Log(audit.AuditEvent{EventType: "api_access", Actor: "node:prod", Action: "accessed"})
+	al.Log(audit.AuditEvent{EventType: "api_access", Actor: "node:prod", Action: "accessed"})
+	al.Log(audit.AuditEvent{EventType: "api_access", Actor: "node:dev", Action: "accessed"})
+
+	time.Sleep(400 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	auth := received["/auth"]
+	if len(auth) != 2 {
+		t.Fatalf("auth webhook got %d payloads, want alert + summary: %+v", len(auth), auth)
+	}
+	if auth[0].Rule != "failed-logins" || auth[0].Grouped != 0 || auth[1].Grouped != 2 {
+		t.Errorf("auth payloads = %+v", auth)
+	}
+	if n := len(received["/prod"]); n != 2 {
+		t.Errorf("prod webhook got %d payloads, want 2 (no cooldown, dev node excluded)", n)
+	}
+}
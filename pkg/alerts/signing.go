@@ -0,0 +1,206 @@
+package alerts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers of webhook deliveries. A delivery to a webhook with a secret is
+// signed: X-Signature is "sha256=" and the hex HMAC-SHA-256 of the
+// delivery ID, a '.', the timestamp, a '.', and the body, so none of them
+// can be changed to get past replay protection. Every attempt of a queued
+// delivery has the same ID.
+const (
+	HeaderSignature  = "X-Signature"
+	HeaderTimestamp  = "X-Signature-Timestamp" // Unix seconds
+	HeaderDeliveryID = "X-Delivery-ID"
+)
+
+// MinSecretLength is the shortest webhook secret accepted, in bytes. FIPS
+// 140-3 requires HMAC keys of at least 112 bits.
+const MinSecretLength = 16
+
+// DefaultSignatureTolerance is how far a delivery's timestamp may be from
+// the receiver's clock.
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// Verification errors.
+var (
+	ErrMissingSignature = errors.New("alerts: delivery is not signed")
+	ErrBadSignature     = errors.New("alerts: signature mismatch")
+	ErrStaleTimestamp   = errors.New("alerts: signature timestamp outside tolerance")
+	ErrReplayed         = errors.New("alerts: delivery ID already seen")
+)
+
+// Sign returns the X-Signature value for delivery id with body sent at
+// timestamp (Unix seconds, as sent in X-Signature-Timestamp).
+func Sign(secret []byte, id, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(id))
+	m.Write([]byte{'.'})
+	m.Write([]byte(timestamp))
+	m.Write([]byte{'.'})
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// newDeliveryID returns a random 128-bit delivery ID.
+func newDeliveryID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// signRequest sets the delivery ID and, when the webhook has a secret, the
+// timestamp and signature headers.
+func signRequest(req *http.Request, wh WebhookConfig, deliveryID string, body []byte, now time.Time) {
+	req.Header.Set(HeaderDeliveryID, deliveryID)
+	if wh.Secret == "" {
+		return
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign([]byte(wh.Secret), deliveryID, ts, body))
+}
+
+// Verifier checks signed webhook deliveries on the receiving side and
+// rejects replays of a delivery ID within the timestamp tolerance. A
+// delivery ID is only remembered once the receiver calls MarkSeen after
+// handling it, so a retry of a delivery the receiver failed is accepted:
+//
+//	v := &alerts.Verifier{Secret: []byte(os.Getenv("WEBHOOK_SECRET"))}
+//	http.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
+//		body, err := v.VerifyRequest(r)
+//		if errors.Is(err, alerts.ErrReplayed) {
+//			return // already handled; a retry of a delivery that succeeded
+//		}
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusUnauthorized)
+//			return
+//		}
+//		if err := handle(body); err != nil {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//			return // the sender retries with the same delivery ID
+//		}
+//		v.MarkSeen(r.Header)
+//	})
+type Verifier struct {
+	Secret []byte
+	// Tolerance bounds clock skew and delivery delay (default
+	// DefaultSignatureTolerance).
+	Tolerance time.Duration
+	// Now returns the current time (default time.Now).
+	Now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time // delivery ID → when it can be forgotten
+}
+
+// Verify checks the signature headers of a delivery against its body and
+// rejects delivery IDs passed to MarkSeen. It does not record the ID.
+func (v *Verifier) Verify(h http.Header, body []byte) error {
+	sig, ts, id := h.Get(HeaderSignature), h.Get(HeaderTimestamp), h.Get(HeaderDeliveryID)
+	if sig == "" || ts == "" || id == "" {
+		return ErrMissingSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(Sign(v.Secret, id, ts, body))) {
+		return ErrBadSignature
+	}
+	if _, err := v.checkTimestamp(ts); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prune()
+	if _, ok := v.seen[id]; ok {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// MarkSeen records the delivery ID in h as handled, so later deliveries
+// with the same ID fail Verify with ErrReplayed. Call it once the delivery
+// has been verified and processed successfully.
+func (v *Verifier) MarkSeen(h http.Header) {
+	sent, err := v.checkTimestamp(h.Get(HeaderTimestamp))
+	id := h.Get(HeaderDeliveryID)
+	if err != nil || id == "" {
+		return // Verify rejects it anyway
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prune()
+	// A replay needs a timestamp within tolerance of now, so an ID can be
+	// forgotten once its delivery's timestamp is that old.
+	v.seen[id] = sent.Add(v.tolerance())
+}
+
+// checkTimestamp parses ts and checks it is within tolerance of now.
+func (v *Verifier) checkTimestamp(ts string) (time.Time, error) {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrStaleTimestamp, ts)
+	}
+	now, tolerance := v.now(), v.tolerance()
+	sent := time.Unix(sec, 0)
+	if sent.Before(now.Add(-tolerance)) || sent.After(now.Add(tolerance)) {
+		return time.Time{}, ErrStaleTimestamp
+	}
+	return sent, nil
+}
+
+func (v *Verifier) tolerance() time.Duration {
+	if v.Tolerance <= 0 {
+		return DefaultSignatureTolerance
+	}
+	return v.Tolerance
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// prune forgets expired delivery IDs. v.mu must be held.
+func (v *Verifier) prune() {
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	now := v.now()
+	for k, until := range v.seen {
+		if now.After(until) {
+			delete(v.seen, k)
+		}
+	}
+}
+
+// VerifyRequest reads the body of r, up to 1 MiB, and verifies it. Call
+// MarkSeen with r.Header once the body has been handled.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return body, v.Verify(r.Header, body)
+}
+
+// validSecret reports an error for a secret too short for FIPS HMAC.
+func validSecret(secret string) error {
+	if secret != "" && len(secret) < MinSecretLength {
+		return fmt.Errorf("webhook secret must be at least %d bytes", MinSecretLength)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package alerts
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123"
+
+// signedReceiver is a webhook receiver that verifies deliveries with v and
+// records the verification results.
+func signedReceiver(t *testing.T, v *Verifier) (*httptest.Server, func() []error) {
+	t.Helper()
+	var mu sync.Mutex
+	var results []error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := v.VerifyRequest(r)
+		mu.Lock()
+		results = append(results, err)
+		mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		v.MarkSeen(r.Header)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []error {
+		mu.Lock()
+		defer mu.Unlock()
+		return results
+	}
+}
+
+func TestSignedDelivery(t *testing.T) {
+	srv, results := signedReceiver(t, &Verifier{Secret: []byte(testSecret)})
+	wh := WebhookConfig{URL: srv.URL, Secret: testSecret}
+	if err := wh.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendWebhook(wh, adapterPayload, newDeliveryID()); err != nil {
+		t.Fatal(err)
+	}
+	// A delivery signed with another secret is rejected.
+	wh.Secret = "fedcba9876543210fedc"
+	if err := sendWebhook(wh, adapterPayload, newDeliveryID()); err == nil {
+		t.Error("expected rejection of delivery with wrong secret")
+	}
+	// So is an unsigned one.
+	wh.Secret = ""
+	if err := sendWebhook(wh, adapterPayload, newDeliveryID()); err == nil {
+		t.Error("expected rejection of unsigned delivery")
+	}
+	r := results()
+	if len(r) != 3 || r[0] != nil || !errors.Is(r[1], ErrBadSignature) || !errors.Is(r[2], ErrMissingSignature) {
+		t.Errorf("verification results = %v", r)
+	}
+}
+
+func TestUnsignedDeliveryHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+	if err := sendWebhook(WebhookConfig{URL: srv.URL}, adapterPayload, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get(HeaderDeliveryID) != "d1" || got.Get(HeaderSignature) != "" || got.Get(HeaderTimestamp) != "" {
+		t.Errorf("headers = %v", got)
+	}
+}
+
+func TestVerifierTimestampAndReplay(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	v := &Verifier{Secret: []byte(testSecret), Now: func() time.Time { return now }}
+	body := []byte(`{"summary":"x"}`)
+	header := func(id string, sent time.Time) http.Header {
+		ts := strconv.FormatInt(sent.Unix(), 10)
+		h := http.Header{}
+		h.Set(HeaderDeliveryID, id)
+		h.Set(HeaderTimestamp, ts)
+		h.Set(HeaderSignature, Sign([]byte(testSecret), id, ts, body))
+		return h
+	}
+
+	if err := v.Verify(header("a", now.Add(-time.Minute)), body); err != nil {
+		t.Fatal(err)
+	}
+	// Until the receiver marks it handled, a retry is accepted.
+	if err := v.Verify(header("a", now.Add(-time.Minute)), body); err != nil {
+		t.Fatalf("retry before MarkSeen: %v", err)
+	}
+	v.MarkSeen(header("a", now.Add(-time.Minute)))
+	if err := v.Verify(header("a", now.Add(-time.Minute)), body); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replay: err = %v", err)
+	}
+	if err := v.Verify(header("b", now.Add(-10*time.Minute)), body); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("stale: err = %v", err)
+	}
+	if err := v.Verify(header("c", now.Add(10*time.Minute)), body); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("future: err = %v", err)
+	}
+	if err := v.Verify(header("d", now), []byte(`{"summary":"y"}`)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("tampered body: err = %v", err)
+	}
+	// A captured delivery replayed under a new ID is rejected.
+	h := header("f", now)
+	h.Set(HeaderDeliveryID, "f2")
+	if err := v.Verify(h, body); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("changed delivery ID: err = %v", err)
+	}
+
+	// Seen IDs are forgotten once their timestamp falls outside tolerance.
+	now = now.Add(DefaultSignatureTolerance)
+	if err := v.Verify(header("e", now), body); err != nil {
+		t.Fatal(err)
+	}
+	v.MarkSeen(header("e", now))
+	if _, ok := v.seen["a"]; ok {
+		t.Error("expired delivery ID not pruned")
+	}
+}
+
+func TestWebhookSecretLength(t *testing.T) {
+	wh := WebhookConfig{URL: "https://x", Secret: "short"}
+	if err := wh.Validate(); err == nil {
+		t.Error("expected error for short secret")
+	}
+}
+
+func TestVerifierAcceptsRetryAfterReceiverFailure(t *testing.T) {
+	v := &Verifier{Secret: []byte(testSecret)}
+	var calls atomic.Int32
+	results := make(chan error, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := v.VerifyRequest(r)
+		results <- err
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		// The receiver fails to handle the first attempt.
+		if calls.Add(1) == 1 {
+			http.Error(w, "store unavailable", http.StatusInternalServerError)
+			return
+		}
+		v.MarkSeen(r.Header)
+	}))
+	defer srv.Close()
+
+	al := newTestAuditLogger(t)
+	am := NewAlertManager(al, []WebhookConfig{{URL: srv.URL, Secret: testSecret}})
+	am.ConfigureQueue(QueueConfig{MinBackoff: 20 * time.Millisecond})
+	t.Cleanup(am.Close)
+
+	al.Log(queueEvent)
+	d := waitDelivery(t, am, func(d Delivery) bool { return d.State == DeliveryDelivered })
+	if d.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", d.Attempts)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("attempt %d: verify = %v", i+1, err)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package alerts
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -16,40 +16,56 @@ type WebhookPayload struct {
 	Summary   string `json:"summary"`
 	Detail    string `json:"detail"`
 	NISTRef   string `json:"nist_ref,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	// Rule names the routing rule that matched; Grouped counts the events
+	// folded into a summary sent when a grouping window closes.
+	Rule    string `json:"rule,omitempty"`
+	Grouped int    `json:"grouped,omitempty"`
 }
 
-// sendWebhook sends a single webhook POST request.
-func sendWebhook(url string, payload WebhookPayload) error {
-	body, err := json.Marshal(payload)
+// sendWebhook sends a single webhook POST request in the webhook's format,
+// signed if the webhook has a secret.
+func sendWebhook(wh WebhookConfig, payload WebhookPayload, deliveryID string) error {
+	body, contentType, err := encodePayload(wh, payload)
 	if err != nil {
-		return fmt.Errorf("marshal payload: %w", err)
+		return permanentError{err}
 	}
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook POST %s: %w", wh.URL, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	signRequest(req, wh, deliveryID, body, time.Now())
 
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("webhook POST %s: %w", url, err)
+		return fmt.Errorf("webhook POST %s: %w", wh.URL, err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("webhook POST %s: HTTP %d", url, resp.StatusCode)
+		err := fmt.Errorf("webhook POST %s: HTTP %d", wh.URL, resp.StatusCode)
+		switch resp.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		default:
+			if resp.StatusCode < 500 {
+				// The receiver rejected the request itself.
+				return permanentError{err}
+			}
+		}
+		return err
 	}
 	return nil
 }
 
-// sendWebhookWithRetry sends a webhook with exponential backoff retries.
-func sendWebhookWithRetry(url string, payload WebhookPayload, maxRetries int) error {
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 1s, 2s, 4s
-			time.Sleep(time.Duration(1<<uint(attempt-1)) * time.Second)
-		}
-		lastErr = sendWebhook(url, payload)
-		if lastErr == nil {
-			return nil
-		}
-	}
-	return lastErr
+// permanentError marks a delivery failure that retrying cannot fix.
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// isPermanent reports whether err is a permanent delivery failure.
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
 }
@@ -6,7 +6,7 @@
 package audit
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +24,14 @@ type AuditEvent struct {
 	Action    string `json:"action"` // status_changed, accessed, modified, login_success, login_failed
 	Detail    string `json:"detail"`
 	NISTRef   string `json:"nist_ref,omitempty"`
+	// SourceIP, Before, and After describe privileged API operations: the
+	// caller's address and the affected values before and after the change.
+	SourceIP string `json:"source_ip,omitempty"`
+	Before   string `json:"before,omitempty"`
+	After    string `json:"after,omitempty"`
+	// Seq and MAC are set when the hash chain is enabled (see WithHashChain).
+	Seq uint64 `json:"seq,omitempty"`
+	MAC string `json:"mac,omitempty"`
 }
 
 // ringSize is the default in-memory ring buffer capacity.
@@ -39,16 +47,26 @@ type syslogWriter interface {
 }
 
 // AuditLogger writes audit events to a JSON-lines file and optionally
-// forwards to syslog. It also maintains an in-memory ring buffer for
-// the /api/v1/audit/events endpoint.
+// forwards them to further sinks (syslog, HTTP collectors). It also
+// maintains an in-memory ring buffer for the /api/v1/audit/events endpoint.
 type AuditLogger struct {
 	file      *os.File
 	mu        sync.Mutex
-	syslogW   syslogWriter
+	sinks     []Sink // sinks[0] is the log file
 	ring      []AuditEvent
 	ringIdx   int
 	ringFull  bool
 	listeners []func(AuditEvent)
+	chain     *chainState
+	rotation  *rotator
+	format    Formatter
+	optErr    error
+
+	pipe          *pipeline // nil when Log writes synchronously
+	fsync         FsyncPolicy
+	fsyncInterval time.Duration
+	dirty         bool // the file has writes not yet fsynced
+	lastSync      time.Time
 }
 
 // Option configures an AuditLogger.
@@ -67,65 +85,72 @@ func NewAuditLogger(path string, opts ...Option) (*AuditLogger, error) {
 	}
 
 	al := &AuditLogger{
-		file: f,
-		ring: make([]AuditEvent, ringSize),
+		file:  f,
+		ring:  make([]AuditEvent, ringSize),
+		fsync: FsyncNone,
 	}
+	al.sinks = []Sink{fileSink{al}}
 	for _, opt := range opts {
 		opt(al)
 	}
+	if al.optErr == nil && al.chain != nil && !isJSON(al.format) {
+		al.optErr = errors.New("audit: the hash chain requires the json format")
+	}
+	if al.optErr == nil && al.chain != nil {
+		al.optErr = al.resumeChain(path)
+	}
+	if al.optErr != nil {
+		al.Close()
+		return nil, al.optErr
+	}
+	al.startPipeline()
 	return al, nil
 }
 
-// Log writes an audit event to the JSON-lines file, the ring buffer,
-// syslog (if configured), and notifies all listeners.
+// Log writes an audit event to the JSON-lines file and every other sink,
+// adds it to the ring buffer, and notifies all listeners. With WithAsync
+// the event is queued and Log returns without waiting for any of this.
 func (al *AuditLogger) Log(evt AuditEvent) {
 	if evt.Timestamp == "" {
 		evt.Timestamp = time.Now().UTC().Format(time.RFC3339)
 	}
+	if al.pipe != nil && al.pipe.enqueue(evt) {
+		return
+	}
 
 	al.mu.Lock()
-	defer al.mu.Unlock()
+	evt = al.record(evt)
+	al.syncFile(time.Now())
+	// Call listeners without the lock; they may log events themselves.
+	listeners := al.listeners
+	al.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(evt)
+	}
+}
 
-	// Write JSON line
-	if al.file != nil {
-		buf, err := json.Marshal(evt)
-		if err == nil {
-			_, _ = al.file.Write(buf)
-			_, _ = al.file.Write([]byte("\n"))
+// record seals evt in the hash chain, writes it to every sink, and adds it
+// to the ring buffer. Callers hold al.mu.
+func (al *AuditLogger) record(evt AuditEvent) AuditEvent {
+	if al.chain != nil && al.file != nil {
+		if err := al.chainEntry(&evt); err != nil {
+			fmt.Fprintf(os.Stderr, "audit: chain entry: %v\n", err)
+		}
+	}
+	for _, s := range al.sinks {
+		if err := s.Write(evt); err != nil {
+			fmt.Fprintf(os.Stderr, "audit: %v\n", err)
 		}
 	}
 
-	// Ring buffer
 	al.ring[al.ringIdx] = evt
 	al.ringIdx++
 	if al.ringIdx >= ringSize {
 		al.ringIdx = 0
 		al.ringFull = true
 	}
-
-	// Syslog
-	if al.syslogW != nil {
-		line, _ := json.Marshal(evt)
-		switch evt.Severity {
-		case "critical":
-			_ = al.syslogW.Crit(string(line))
-		case "warning":
-			_ = al.syslogW.Warning(string(line))
-		default:
-			_ = al.syslogW.Info(string(line))
-		}
-	}
-
-	// Notify listeners (non-blocking copies to avoid holding lock)
-	listeners := make([]func(AuditEvent), len(al.listeners))
-	copy(listeners, al.listeners)
-
-	// Release lock before calling listeners
-	al.mu.Unlock()
-	for _, fn := range listeners {
-		fn(evt)
-	}
-	al.mu.Lock() // re-acquire for deferred unlock
+	return evt
 }
 
 // AddListener registers a callback invoked for every audit event.
@@ -184,27 +209,30 @@ func (al *AuditLogger) FilePath() string {
 
 // SyslogActive returns true if syslog forwarding is configured.
 func (al *AuditLogger) SyslogActive() bool {
-	return al.syslogW != nil
+	for _, s := range al.sinks {
+		switch s.(type) {
+		case legacySyslogSink, *syslogClient:
+			return true
+		}
+	}
+	return false
 }
 
-// Close flushes and closes the audit log file and syslog connection.
+// Close drains the async queue, flushes and closes the audit log file and
+// every other sink, and waits for rotated segments to be archived. Closing
+// twice is a no-op.
 func (al *AuditLogger) Close() error {
+	al.closePipeline()
+
 	al.mu.Lock()
 	defer al.mu.Unlock()
 
-	var errs []error
-	if al.file != nil {
-		if err := al.file.Close(); err != nil {
-			errs = append(errs, err)
+	var first error
+	for _, s := range al.sinks {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
 		}
 	}
-	if al.syslogW != nil {
-		if err := al.syslogW.Close(); err != nil {
-			errs = append(errs, err)
-		}
-	}
-	if len(errs) > 0 {
-		return errs[0]
-	}
-	return nil
+	al.sinks = nil
+	return first
 }
@@ -0,0 +1,290 @@
+// Package auditdb keeps a SQLite-backed, queryable copy of the audit log.
+// It is separate from package audit so that binaries which only write
+// audit events do not link the database driver.
+package auditdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+// Index is a SQLite-backed, queryable copy of the audit log. Unlike the
+// ring buffer it covers the full history and survives restarts.
+type Index struct {
+	db *sql.DB
+}
+
+// Open opens or creates an audit index at dbPath.
+func Open(dbPath string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0750); err != nil {
+		return nil, fmt.Errorf("auditdb: create index dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("auditdb: open index: %w", err)
+	}
+	// Listeners insert concurrently; a single connection avoids
+	// SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	schema := `
+	PRAGMA journal_mode=WAL;
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		ts         TEXT NOT NULL,
+		seq        INTEGER NOT NULL DEFAULT 0,
+		event_type TEXT NOT NULL,
+		severity   TEXT NOT NULL,
+		actor      TEXT NOT NULL,
+		resource   TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		detail     TEXT NOT NULL,
+		nist_ref   TEXT NOT NULL DEFAULT '',
+		source_ip  TEXT NOT NULL DEFAULT '',
+		before_val TEXT NOT NULL DEFAULT '',
+		after_val  TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_ts ON audit_events(ts);
+	CREATE INDEX IF NOT EXISTS idx_audit_type ON audit_events(event_type, ts);
+	CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_events(actor, ts);
+	CREATE INDEX IF NOT EXISTS idx_audit_resource ON audit_events(resource, ts);
+	CREATE INDEX IF NOT EXISTS idx_audit_nist ON audit_events(nist_ref, ts);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("auditdb: migrate index: %w", err)
+	}
+	if err := addColumns(db, "source_ip", "before_val", "after_val"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("auditdb: migrate index: %w", err)
+	}
+	return &Index{db: db}, nil
+}
+
+// addColumns adds text columns missing from indexes created by older
+// versions.
+func addColumns(db *sql.DB, cols ...string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('audit_events')`)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	for _, col := range cols {
+		if !have[col] {
+			if _, err := db.Exec(`ALTER TABLE audit_events ADD COLUMN ` + col + ` TEXT NOT NULL DEFAULT ''`); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the index database.
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Attach indexes every event the logger writes from now on.
+func (ix *Index) Attach(al *audit.AuditLogger) {
+	al.AddListener(func(evt audit.AuditEvent) {
+		if err := ix.Add(evt); err != nil {
+			fmt.Fprintf(os.Stderr, "auditdb: index event: %v\n", err)
+		}
+	})
+}
+
+// Add indexes one event.
+func (ix *Index) Add(evt audit.AuditEvent) error {
+	_, err := ix.db.Exec(insertEvent, eventArgs(evt)...)
+	return err
+}
+
+const insertEvent = `INSERT INTO audit_events
+	(ts, seq, event_type, severity, actor, resource, action, detail, nist_ref, source_ip, before_val, after_val)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func eventArgs(evt audit.AuditEvent) []interface{} {
+	return []interface{}{indexTime(evt.Timestamp), evt.Seq, evt.EventType, evt.Severity, evt.Actor,
+		evt.Resource, evt.Action, evt.Detail, evt.NISTRef, evt.SourceIP, evt.Before, evt.After}
+}
+
+// indexTime normalizes a timestamp to UTC RFC 3339 so that timestamps
+// compare as strings.
+func indexTime(ts string) string {
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return ts
+}
+
+// Backfill imports the archived segments listed in archiveDir's manifest
+// and then the active log at logPath, but only when the index is empty so
+// events are not indexed twice. It returns the number of events imported.
+func (ix *Index) Backfill(logPath, archiveDir string) (int, error) {
+	var n int
+	if err := ix.db.QueryRow(`SELECT COUNT(*) FROM audit_events`).Scan(&n); err != nil || n > 0 {
+		return 0, err
+	}
+	if archiveDir == "" {
+		archiveDir = filepath.Dir(logPath)
+	}
+	var paths []string
+	if data, err := os.ReadFile(audit.ManifestPath(archiveDir, logPath)); err == nil {
+		var m audit.ArchiveManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return 0, fmt.Errorf("auditdb: parse manifest: %w", err)
+		}
+		for _, s := range m.Segments {
+			if s.DeletedAt == "" {
+				paths = append(paths, filepath.Join(archiveDir, s.File))
+			}
+		}
+	}
+	paths = append(paths, logPath)
+
+	total := 0
+	for _, path := range paths {
+		n, err := ix.importFile(path)
+		total += n
+		if err != nil && !os.IsNotExist(err) {
+			return total, fmt.Errorf("auditdb: import %s: %w", path, err)
+		}
+	}
+	return total, nil
+}
+
+// importFile indexes the events in a JSON-lines file, gzipped if it ends
+// in .gz. Malformed lines are skipped.
+func (ix *Index) importFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(insertEvent)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var evt audit.AuditEvent
+		if json.Unmarshal(scanner.Bytes(), &evt) != nil {
+			continue
+		}
+		if _, err := stmt.Exec(eventArgs(evt)...); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// Query returns matching events newest first, along with the total number
+// of matches ignoring Limit and Offset.
+func (ix *Index) Query(ctx context.Context, q audit.EventQuery) ([]audit.AuditEvent, int, error) {
+	var where []string
+	var args []interface{}
+	for _, f := range []struct {
+		col, val string
+	}{
+		{"event_type", q.EventType},
+		{"actor", q.Actor},
+		{"severity", q.Severity},
+		{"resource", q.Resource},
+		{"nist_ref", q.NISTRef},
+	} {
+		if f.val != "" {
+			where = append(where, f.col+" = ?")
+			args = append(args, f.val)
+		}
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "ts >= ?")
+		args = append(args, q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "ts < ?")
+		args = append(args, q.Until.UTC().Format(time.RFC3339))
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := ix.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_events`+clause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset := q.Page()
+	rows, err := ix.db.QueryContext(ctx, `SELECT ts, seq, event_type, severity, actor, resource, action, detail, nist_ref,
+		source_ip, before_val, after_val FROM audit_events`+clause+` ORDER BY ts DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	events := []audit.AuditEvent{}
+	for rows.Next() {
+		var evt audit.AuditEvent
+		if err := rows.Scan(&evt.Timestamp, &evt.Seq, &evt.EventType, &evt.Severity, &evt.Actor,
+			&evt.Resource, &evt.Action, &evt.Detail, &evt.NISTRef,
+			&evt.SourceIP, &evt.Before, &evt.After); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, evt)
+	}
+	return events, total, rows.Err()
+}
+
+// Prune removes events older than before, matching archive retention.
+func (ix *Index) Prune(before time.Time) (int64, error) {
+	res, err := ix.db.Exec(`DELETE FROM audit_events WHERE ts < ?`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
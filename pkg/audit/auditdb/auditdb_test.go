@@ -0,0 +1,102 @@
+package auditdb
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/audit"
+)
+
+func openIndex(t *testing.T) *Index {
+	t.Helper()
+	ix, err := Open(filepath.Join(t.TempDir(), "audit-index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ix.Close() })
+	return ix
+}
+
+func TestIndexQuery(t *testing.T) {
+	ix := openIndex(t)
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []audit.AuditEvent{
+		{EventType: "auth_attempt", Severity: "warning", Actor: "api:10.0.0.1", Action: "login_failed", NISTRef: "AC-7"},
+		{EventType: "auth_attempt", Severity: "info", Actor: "api:10.0.0.2", Action: "login_success", NISTRef: "IA-2"},
+		{EventType: "config_change", Severity: "critical", Actor: "admin:1a2b3c4d", Resource: "t-1", Action: "status_changed", SourceIP: "10.0.0.9", Before: "audit", After: "enforce"},
+		{EventType: "auth_attempt", Severity: "warning", Actor: "api:10.0.0.1", Action: "login_failed", NISTRef: "AC-7"},
+	} {
+		// Offsets in another zone check timestamps are normalized to UTC.
+		e.Timestamp = base.Add(time.Duration(i) * time.Hour).In(time.FixedZone("EST", -5*3600)).Format(time.RFC3339)
+		if err := ix.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	events, total, err := ix.Query(ctx, audit.EventQuery{Actor: "api:10.0.0.1", NISTRef: "AC-7"})
+	if err != nil || total != 2 || len(events) != 2 {
+		t.Fatalf("actor filter = %d/%d events, %v", len(events), total, err)
+	}
+	if events[0].Timestamp != "2026-10-01T15:00:00Z" {
+		t.Errorf("not newest first: %+v", events)
+	}
+
+	events, total, err = ix.Query(ctx, audit.EventQuery{EventType: "auth_attempt", Limit: 1, Offset: 1})
+	if err != nil || total != 3 || len(events) != 1 || events[0].Action != "login_success" {
+		t.Errorf("paged = %+v (total %d), %v", events, total, err)
+	}
+
+	events, _, err = ix.Query(ctx, audit.EventQuery{Since: base.Add(time.Hour), Until: base.Add(3 * time.Hour)})
+	if err != nil || len(events) != 2 || events[0].Resource != "t-1" || events[0].SourceIP != "10.0.0.9" || events[0].After != "enforce" {
+		t.Errorf("time range = %+v, %v", events, err)
+	}
+
+	if n, err := ix.Prune(base.Add(2 * time.Hour)); err != nil || n != 2 {
+		t.Errorf("Prune = %d, %v", n, err)
+	}
+}
+
+func TestIndexBackfillAndAttach(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "audit.json")
+	al, err := audit.NewAuditLogger(logPath, audit.WithRotation(audit.RotationConfig{MaxSize: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each event lands in its own rotated, gzipped segment.
+	for _, action := range []string{"a", "b", "c"} {
+		al.Log(audit.AuditEvent{EventType: "system_event", Action: action})
+	}
+	al.Close()
+
+	ix := openIndex(t)
+	if n, err := ix.Backfill(logPath, ""); err != nil || n != 3 {
+		t.Fatalf("Backfill = %d, %v", n, err)
+	}
+	// A non-empty index is not backfilled again.
+	if n, err := ix.Backfill(logPath, ""); err != nil || n != 0 {
+		t.Errorf("second Backfill = %d, %v", n, err)
+	}
+
+	al, err = audit.NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	ix.Attach(al)
+	al.Log(audit.AuditEvent{EventType: "system_event", Action: "d"})
+	events, total, err := ix.Query(context.Background(), audit.EventQuery{EventType: "system_event"})
+	if err != nil || total != 4 {
+		t.Fatalf("total = %d, %v", total, err)
+	}
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.Action] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("actions = %v", seen)
+	}
+}
@@ -0,0 +1,511 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Chain defaults.
+const (
+	DefaultCheckpointEvery    = 100
+	DefaultCheckpointInterval = time.Hour
+	// MinChainKeyLen is the minimum HMAC key length in bytes.
+	MinChainKeyLen = 32
+	// verifyCacheTTL bounds how often VerifyChain re-reads the log.
+	verifyCacheTTL = 5 * time.Minute
+)
+
+// ChainConfig enables a tamper-evident hash chain over the audit log (AU-9).
+// Each entry gets a sequence number and an HMAC over its own JSON and the
+// previous entry's MAC, so deleting, reordering, or editing a line breaks
+// the chain. A signed checkpoint of the chain head is written alongside the
+// log (path + ".checkpoint") so truncating the tail is detected too.
+type ChainConfig struct {
+	Key []byte
+	// Algorithm is "sha256" (default) or "sha384".
+	Algorithm string
+	// A checkpoint is written after CheckpointEvery entries or once
+	// CheckpointInterval has passed since the last one, whichever is first,
+	// and on Close.
+	CheckpointEvery    int
+	CheckpointInterval time.Duration
+}
+
+// Checkpoint is a signed record of the chain head.
+type Checkpoint struct {
+	Seq       uint64 `json:"seq"`
+	MAC       string `json:"mac"`
+	Timestamp string `json:"timestamp"`
+	Algorithm string `json:"algorithm"`
+	// BaseSeq and BaseMAC are the chain position the log file starts
+	// after; both are zero until the log is first rotated.
+	BaseSeq   uint64 `json:"base_seq,omitempty"`
+	BaseMAC   string `json:"base_mac,omitempty"`
+	Signature string `json:"signature"`
+}
+
+// chainState is the running chain of an AuditLogger.
+type chainState struct {
+	key             []byte
+	newHash         func() hash.Hash
+	algorithm       string
+	every           int
+	interval        time.Duration
+	seq             uint64
+	head            string // MAC of the last entry
+	baseSeq         uint64 // chain position the current file starts after
+	baseMAC         string
+	sinceCheckpoint int
+	lastCheckpoint  time.Time
+	checkpointed    bool // the log has a checkpoint file
+
+	verified     time.Time
+	verifyReport *VerifyReport
+	verifyErr    error
+}
+
+// WithHashChain enables the hash chain. The logger continues the chain
+// from the last entry already in the file.
+func WithHashChain(cfg ChainConfig) Option {
+	return func(al *AuditLogger) {
+		newHash, alg, err := chainHash(cfg.Algorithm)
+		if err != nil {
+			al.optErr = err
+			return
+		}
+		if len(cfg.Key) < MinChainKeyLen {
+			al.optErr = fmt.Errorf("audit: chain key must be at least %d bytes", MinChainKeyLen)
+			return
+		}
+		if cfg.CheckpointEvery <= 0 {
+			cfg.CheckpointEvery = DefaultCheckpointEvery
+		}
+		if cfg.CheckpointInterval <= 0 {
+			cfg.CheckpointInterval = DefaultCheckpointInterval
+		}
+		al.chain = &chainState{
+			key:            cfg.Key,
+			newHash:        newHash,
+			algorithm:      alg,
+			every:          cfg.CheckpointEvery,
+			interval:       cfg.CheckpointInterval,
+			lastCheckpoint: time.Now(),
+		}
+	}
+}
+
+// LoadChainKey reads an HMAC chain key from a file holding either raw bytes
+// or hex. Generate one with: openssl rand -hex 32
+func LoadChainKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: read chain key: %w", err)
+	}
+	key := data
+	if decoded, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		key = decoded
+	}
+	if len(key) < MinChainKeyLen {
+		return nil, fmt.Errorf("audit: chain key %s is %d bytes, need at least %d", path, len(key), MinChainKeyLen)
+	}
+	return key, nil
+}
+
+func chainHash(algorithm string) (func() hash.Hash, string, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "sha256", "sha-256":
+		return sha256.New, "sha256", nil
+	case "sha384", "sha-384":
+		return sha512.New384, "sha384", nil
+	}
+	return nil, "", fmt.Errorf("audit: unsupported chain algorithm %q (want sha256 or sha384)", algorithm)
+}
+
+// hashForMAC picks the chain hash from the length of a hex MAC.
+func hashForMAC(mac string) (func() hash.Hash, bool) {
+	switch len(mac) {
+	case 2 * sha256.Size:
+		return sha256.New, true
+	case 2 * sha512.Size384:
+		return sha512.New384, true
+	}
+	return nil, false
+}
+
+func computeMAC(newHash func() hash.Hash, key []byte, prev string, body []byte) string {
+	m := hmac.New(newHash, key)
+	m.Write([]byte(prev))
+	m.Write([]byte{'\n'})
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// sealLine appends the MAC to an entry's JSON object.
+func sealLine(body []byte, mac string) []byte {
+	line := make([]byte, 0, len(body)+len(mac)+10)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, `,"mac":"`...)
+	line = append(line, mac...)
+	return append(line, `"}`...)
+}
+
+// splitSealed separates a chained line into the signed JSON and its MAC.
+func splitSealed(line []byte) (body []byte, mac string, ok bool) {
+	i := bytes.LastIndex(line, []byte(`,"mac":"`))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", false
+	}
+	mac = string(line[i+len(`,"mac":"`) : len(line)-2])
+	body = append(append([]byte(nil), line[:i]...), '}')
+	return body, mac, true
+}
+
+// chainEntry assigns the next sequence number and its MAC to evt; the
+// file sink writes the sealed line (see encodeLine). Called with al.mu
+// held.
+func (al *AuditLogger) chainEntry(evt *AuditEvent) error {
+	c := al.chain
+	evt.Seq = c.seq + 1
+	evt.MAC = ""
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	mac := computeMAC(c.newHash, c.key, c.head, body)
+	c.seq, c.head = evt.Seq, mac
+	c.sinceCheckpoint++
+	evt.MAC = mac
+	return nil
+}
+
+// maybeCheckpoint writes a checkpoint when one is due, and after the first
+// chained entry of a log without one, since VerifyLog treats a chained log
+// without a checkpoint as truncated. Called with al.mu held.
+func (al *AuditLogger) maybeCheckpoint(now time.Time) {
+	c := al.chain
+	if c.checkpointed && c.sinceCheckpoint < c.every && now.Sub(c.lastCheckpoint) < c.interval {
+		return
+	}
+	if err := al.writeCheckpoint(now); err != nil {
+		fmt.Fprintf(os.Stderr, "audit: write checkpoint: %v\n", err)
+	}
+}
+
+// writeCheckpoint records the chain head in the checkpoint file. Called
+// with al.mu held.
+func (al *AuditLogger) writeCheckpoint(now time.Time) error {
+	c := al.chain
+	if c.seq == 0 {
+		return nil
+	}
+	if al.file != nil {
+		if err := al.file.Sync(); err != nil {
+			return err
+		}
+	}
+	cp := Checkpoint{
+		Seq:       c.seq,
+		MAC:       c.head,
+		Timestamp: now.UTC().Format(time.RFC3339),
+		Algorithm: c.algorithm,
+		BaseSeq:   c.baseSeq,
+		BaseMAC:   c.baseMAC,
+	}
+	cp.Signature = checkpointSignature(c.newHash, c.key, cp)
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(CheckpointPath(al.FilePath()), append(data, '\n')); err != nil {
+		return err
+	}
+	c.sinceCheckpoint = 0
+	c.lastCheckpoint = now
+	c.checkpointed = true
+	return nil
+}
+
+func checkpointSignature(newHash func() hash.Hash, key []byte, cp Checkpoint) string {
+	m := hmac.New(newHash, key)
+	fmt.Fprintf(m, "checkpoint\n%d\n%s\n%s\n%s\n%d\n%s", cp.Seq, cp.MAC, cp.Timestamp, cp.Algorithm, cp.BaseSeq, cp.BaseMAC)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// CheckpointPath returns the checkpoint file of an audit log.
+func CheckpointPath(logPath string) string {
+	return logPath + ".checkpoint"
+}
+
+// readCheckpoint loads and authenticates a log's checkpoint. It returns
+// nil, nil when there is none.
+func readCheckpoint(logPath string, key []byte) (*Checkpoint, error) {
+	data, err := os.ReadFile(CheckpointPath(logPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, errors.New("malformed")
+	}
+	newHash, _, err := chainHash(cp.Algorithm)
+	if err != nil {
+		return &cp, err
+	}
+	if want := checkpointSignature(newHash, key, cp); !hmac.Equal([]byte(want), []byte(cp.Signature)) {
+		return &cp, errors.New("signature invalid")
+	}
+	return &cp, nil
+}
+
+// resumeChain continues the chain from the last entry in the log file, or
+// from the checkpoint's base when the file has no chained entries yet (as
+// right after a rotation).
+func (al *AuditLogger) resumeChain(path string) error {
+	c := al.chain
+	cp, err := readCheckpoint(path, c.key)
+	if err == nil && cp != nil {
+		c.baseSeq, c.baseMAC = cp.BaseSeq, cp.BaseMAC
+		c.seq, c.head = cp.BaseSeq, cp.BaseMAC
+	}
+	// An unreadable checkpoint is left for VerifyLog to report rather
+	// than replaced.
+	c.checkpointed = cp != nil || err != nil
+	line, err := lastLine(path)
+	if err != nil || len(line) == 0 {
+		return err
+	}
+	var evt AuditEvent
+	if err := json.Unmarshal(line, &evt); err != nil {
+		return fmt.Errorf("audit: cannot resume chain, last entry is malformed: %w", err)
+	}
+	// A log written before chaining was enabled starts a new chain.
+	if evt.MAC == "" {
+		return nil
+	}
+	if !c.checkpointed {
+		fmt.Fprintf(os.Stderr, "audit: %s is hash-chained but has no checkpoint; truncation before seq %d cannot be ruled out\n", path, evt.Seq)
+	}
+	c.seq, c.head = evt.Seq, evt.MAC
+	return nil
+}
+
+// lastLine returns the last complete line of a file.
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	for chunk := int64(64 << 10); ; chunk *= 2 {
+		if chunk > size {
+			chunk = size
+		}
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+			return buf[i+1:], nil
+		}
+		if chunk == size {
+			return buf, nil
+		}
+	}
+}
+
+// VerifyReport is the outcome of verifying an audit log's hash chain.
+type VerifyReport struct {
+	Path string `json:"path"`
+	// Entries counts all lines; Unchained counts lines written before
+	// chaining was enabled.
+	Entries    int         `json:"entries"`
+	Unchained  int         `json:"unchained"`
+	FirstSeq   uint64      `json:"first_seq"`
+	LastSeq    uint64      `json:"last_seq"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	Problems   []string    `json:"problems,omitempty"`
+}
+
+// OK reports whether the chain verified without problems.
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) problem(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// VerifyLog checks an audit log's hash chain and its checkpoint against
+// key. Deleted entries show up as sequence gaps, reordered entries as
+// sequence regressions, modified entries as MAC mismatches, and a
+// truncated tail as a checkpoint past the end of the log. A chained log
+// without a checkpoint is reported too, since deleting the checkpoint would
+// otherwise hide a truncation. A rotated log continues the chain from the
+// base recorded in its checkpoint. A log written entirely without chaining
+// reports a problem; unchained lines before the first chained one are
+// counted but allowed. The returned error is set only when the log cannot
+// be read.
+func VerifyLog(path string, key []byte) (*VerifyReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	report := &VerifyReport{Path: path}
+	cp, err := readCheckpoint(path, key)
+	report.Checkpoint = cp
+	cpMissing := cp == nil && err == nil
+	if err != nil {
+		report.problem("checkpoint: %v", err)
+		cp = nil
+	}
+	macs := make(map[uint64]string)
+	var prevSeq uint64
+	var prevMAC string
+	if cp != nil {
+		prevSeq, prevMAC = cp.BaseSeq, cp.BaseMAC
+	}
+	chained := false
+
+	reader := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A final line without a newline may still be being written.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		report.Entries++
+
+		var evt AuditEvent
+		if err := json.Unmarshal(line, &evt); err != nil {
+			report.problem("line %d: malformed entry", lineNo)
+			continue
+		}
+		body, mac, sealed := splitSealed(line)
+		if !sealed || evt.MAC == "" || mac != evt.MAC {
+			if chained {
+				report.problem("line %d: entry has no chain MAC", lineNo)
+			} else {
+				report.Unchained++
+			}
+			continue
+		}
+		newHash, ok := hashForMAC(mac)
+		if !ok {
+			report.problem("line %d: invalid MAC", lineNo)
+			continue
+		}
+
+		switch {
+		case !chained:
+			report.FirstSeq = evt.Seq
+			if evt.Seq != prevSeq+1 {
+				report.problem("line %d: chain starts at seq %d, want %d (earlier entries missing)", lineNo, evt.Seq, prevSeq+1)
+			} else if want := computeMAC(newHash, key, prevMAC, body); !hmac.Equal([]byte(want), []byte(mac)) {
+				report.problem("line %d (seq %d): MAC mismatch (entry modified or wrong key)", lineNo, evt.Seq)
+			}
+			chained = true
+		case evt.Seq <= prevSeq:
+			report.problem("line %d: seq %d follows seq %d (entries reordered or duplicated)", lineNo, evt.Seq, prevSeq)
+		case evt.Seq > prevSeq+1:
+			report.problem("line %d: seq jumps from %d to %d (entries deleted or reordered)", lineNo, prevSeq, evt.Seq)
+		default:
+			if want := computeMAC(newHash, key, prevMAC, body); !hmac.Equal([]byte(want), []byte(mac)) {
+				report.problem("line %d (seq %d): MAC mismatch (entry modified)", lineNo, evt.Seq)
+			}
+		}
+		// Continue from this entry so one break is reported once.
+		prevSeq, prevMAC = evt.Seq, mac
+		macs[evt.Seq] = mac
+		if evt.Seq > report.LastSeq {
+			report.LastSeq = evt.Seq
+		}
+	}
+
+	if !chained && report.Entries > 0 {
+		report.problem("log is not hash-chained")
+	}
+	if chained && cpMissing {
+		report.problem("checkpoint missing; truncation cannot be ruled out")
+	}
+	if cp != nil {
+		verifyCheckpointHead(report, cp, macs)
+	}
+	return report, nil
+}
+
+// verifyCheckpointHead checks that the log still holds the checkpointed
+// chain head.
+func verifyCheckpointHead(report *VerifyReport, cp *Checkpoint, macs map[uint64]string) {
+	mac, ok := macs[cp.Seq]
+	switch {
+	case cp.Seq == cp.BaseSeq:
+		// Checkpointed right after a rotation, before any new entries.
+	case !ok && cp.Seq > report.LastSeq:
+		report.problem("log truncated: checkpoint at seq %d but log ends at seq %d", cp.Seq, report.LastSeq)
+	case !ok:
+		report.problem("checkpointed entry seq %d missing", cp.Seq)
+	case mac != cp.MAC:
+		report.problem("checkpointed entry seq %d does not match checkpoint", cp.Seq)
+	}
+}
+
+// VerifyChain verifies the logger's own file. Results are cached for a few
+// minutes since the whole log is read. It returns nil, nil when the hash
+// chain is not enabled.
+func (al *AuditLogger) VerifyChain() (*VerifyReport, error) {
+	al.mu.Lock()
+	c := al.chain
+	if c == nil || al.file == nil {
+		al.mu.Unlock()
+		return nil, nil
+	}
+	if time.Since(c.verified) < verifyCacheTTL {
+		report, err := c.verifyReport, c.verifyErr
+		al.mu.Unlock()
+		return report, err
+	}
+	path, key := al.file.Name(), c.key
+	al.mu.Unlock()
+
+	report, err := VerifyLog(path, key)
+
+	al.mu.Lock()
+	c.verified, c.verifyReport, c.verifyErr = time.Now(), report, err
+	al.mu.Unlock()
+	return report, err
+}
+
+// ChainActive returns true if the hash chain is enabled.
+func (al *AuditLogger) ChainActive() bool {
+	return al.chain != nil
+}
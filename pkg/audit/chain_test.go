@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testChainKey = bytes.Repeat([]byte{0x42}, 32)
+
+// writeChainedLog logs n events to a fresh chained log and returns its path.
+func writeChainedLog(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.json")
+	al, err := NewAuditLogger(path, WithHashChain(ChainConfig{Key: testChainKey}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		al.Log(AuditEvent{EventType: "system_event", Severity: "info", Actor: "system", Action: "tick", Detail: strings.Repeat("x", i)})
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verifyProblems(t *testing.T, path string, key []byte) []string {
+	t.Helper()
+	report, err := VerifyLog(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.Problems
+}
+
+func TestHashChainVerifies(t *testing.T) {
+	path := writeChainedLog(t, 5)
+
+	// Reopening continues the chain.
+	al, err := NewAuditLogger(path, WithHashChain(ChainConfig{Key: testChainKey, Algorithm: "sha256"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "restarted"})
+	if got := al.RecentEvents(1)[0]; got.Seq != 6 || got.MAC == "" {
+		t.Errorf("resumed event = %+v, want seq 6 with MAC", got)
+	}
+	al.Close()
+
+	report, err := VerifyLog(path, testChainKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Entries != 6 || report.FirstSeq != 1 || report.LastSeq != 6 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Checkpoint == nil || report.Checkpoint.Seq != 6 {
+		t.Errorf("checkpoint = %+v, want seq 6", report.Checkpoint)
+	}
+}
+
+func TestHashChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]string) []string
+		want   string
+	}{
+		{"modified", func(l []string) []string {
+			l[2] = strings.Replace(l[2], `"action":"tick"`, `"action":"tock"`, 1)
+			return l
+		}, "line 3 (seq 3): MAC mismatch"},
+		{"deleted", func(l []string) []string {
+			return append(l[:2], l[3:]...)
+		}, "seq jumps from 2 to 4"},
+		{"reordered", func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, "seq 2 follows seq 3"},
+		{"first deleted", func(l []string) []string {
+			return l[1:]
+		}, "chain starts at seq 2"},
+		{"truncated", func(l []string) []string {
+			return l[:3]
+		}, "log truncated: checkpoint at seq 5"},
+		{"truncated and checkpoint deleted", func(l []string) []string {
+			return l[:3]
+		}, "checkpoint missing"},
+		{"unchained line inserted", func(l []string) []string {
+			return append(l[:3], append([]string{`{"event_type":"system_event","action":"forged"}`}, l[3:]...)...)
+		}, "line 4: entry has no chain MAC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeChainedLog(t, 5)
+			writeLines(t, path, tt.tamper(readLines(t, path)))
+			if strings.Contains(tt.name, "checkpoint deleted") {
+				if err := os.Remove(CheckpointPath(path)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			problems := verifyProblems(t, path, testChainKey)
+			for _, p := range problems {
+				if strings.Contains(p, tt.want) {
+					return
+				}
+			}
+			t.Errorf("problems = %q, want %q", problems, tt.want)
+		})
+	}
+}
+
+func TestHashChainWrongKeyAndCheckpoint(t *testing.T) {
+	path := writeChainedLog(t, 3)
+	if problems := verifyProblems(t, path, bytes.Repeat([]byte{0x43}, 32)); len(problems) == 0 {
+		t.Error("expected problems with the wrong key")
+	}
+
+	cp, err := os.ReadFile(CheckpointPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := strings.Replace(string(cp), `"seq":3`, `"seq":2`, 1)
+	if err := os.WriteFile(CheckpointPath(path), []byte(forged), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	problems := verifyProblems(t, path, testChainKey)
+	if len(problems) != 1 || problems[0] != "checkpoint: signature invalid" {
+		t.Errorf("problems = %q", problems)
+	}
+}
+
+func TestHashChainCheckpointsFirstEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	al, err := NewAuditLogger(path, WithHashChain(ChainConfig{Key: testChainKey}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	al.Log(AuditEvent{EventType: "system_event", Action: "started"})
+
+	// A running log has a checkpoint before CheckpointEvery entries.
+	report, err := VerifyLog(path, testChainKey)
+	if err != nil || !report.OK() || report.Checkpoint == nil || report.Checkpoint.Seq != 1 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+	if err := os.Remove(CheckpointPath(path)); err != nil {
+		t.Fatal(err)
+	}
+	problems := verifyProblems(t, path, testChainKey)
+	if len(problems) != 1 || problems[0] != "checkpoint missing; truncation cannot be ruled out" {
+		t.Errorf("problems = %q", problems)
+	}
+}
+
+func TestHashChainUnchainedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	al, err := NewAuditLogger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "started"})
+	al.Close()
+	if problems := verifyProblems(t, path, testChainKey); len(problems) != 1 || problems[0] != "log is not hash-chained" {
+		t.Errorf("problems = %q", problems)
+	}
+
+	// Enabling the chain later starts it after the existing entries.
+	al, err = NewAuditLogger(path, WithHashChain(ChainConfig{Key: testChainKey}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "chained"})
+	report, err := al.VerifyChain()
+	al.Close()
+	if err != nil || !report.OK() || report.Unchained != 1 || report.LastSeq != 1 {
+		t.Errorf("report = %+v, %v", report, err)
+	}
+}
+
+func TestHashChainRejectsShortKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	if _, err := NewAuditLogger(path, WithHashChain(ChainConfig{Key: []byte("short")})); err == nil {
+		t.Error("expected error for short key")
+	}
+	if _, err := NewAuditLogger(path, WithHashChain(ChainConfig{Key: testChainKey, Algorithm: "md5"})); err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "chain.key")
+	if err := os.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadChainKey(keyFile)
+	if err != nil || len(key) != 32 {
+		t.Errorf("LoadChainKey = %d bytes, %v", len(key), err)
+	}
+}
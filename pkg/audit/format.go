@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/buildinfo"
+)
+
+// Formatter renders an audit event as a single line, without the trailing
+// newline.
+type Formatter interface {
+	Format(evt AuditEvent) ([]byte, error)
+}
+
+// ParseFormat returns the formatter for name: "json", "cef", or "leef".
+func ParseFormat(name string) (Formatter, error) {
+	switch strings.ToLower(name) {
+	case "", "json":
+		return JSONFormat{}, nil
+	case "cef":
+		return CEFFormat{}, nil
+	case "leef":
+		return LEEFFormat{}, nil
+	}
+	return nil, fmt.Errorf("audit: unknown format %q (want json, cef, or leef)", name)
+}
+
+// WithFormat sets the format of the audit log file and of the legacy
+// syslog writer (see WithSyslog). The default is JSON lines; the hash
+// chain requires JSON.
+func WithFormat(f Formatter) Option {
+	return func(al *AuditLogger) {
+		al.format = f
+	}
+}
+
+// isJSON reports whether f writes the native JSON-lines format.
+func isJSON(f Formatter) bool {
+	if f == nil {
+		return true
+	}
+	_, ok := f.(JSONFormat)
+	return ok
+}
+
+// JSONFormat writes events as JSON objects.
+type JSONFormat struct{}
+
+// Format implements Formatter.
+func (JSONFormat) Format(evt AuditEvent) ([]byte, error) {
+	return json.Marshal(evt)
+}
+
+// Header fields of CEF and LEEF records; empty fields use these defaults.
+const (
+	defaultVendor  = "cloudflared-fips"
+	defaultProduct = "cloudflared-fips"
+)
+
+// CEFFormat writes ArcSight Common Event Format (CEF:0) records. The event
+// type is the signature ID, the action the name, and the remaining fields
+// map to CEF extension keys.
+type CEFFormat struct {
+	Vendor, Product, Version string
+}
+
+// Format implements Formatter.
+func (f CEFFormat) Format(evt AuditEvent) ([]byte, error) {
+	vendor, product, version := headerDefaults(f.Vendor, f.Product, f.Version)
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(vendor), cefHeader(product), cefHeader(version),
+		cefHeader(orDash(evt.EventType)), cefHeader(orDash(evt.Action)), severityLevel(evt.Severity))
+
+	var ext []string
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefValue(value))
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, evt.Timestamp); err == nil {
+		add("rt", strconv.FormatInt(t.UnixMilli(), 10))
+	}
+	add("cat", evt.EventType)
+	add("suser", evt.Actor)
+	add("act", evt.Action)
+	if evt.Resource != "" {
+		add("cs1Label", "resource")
+		add("cs1", evt.Resource)
+	}
+	if evt.NISTRef != "" {
+		add("cs2Label", "nistRef")
+		add("cs2", evt.NISTRef)
+	}
+	add("src", evt.SourceIP)
+	if evt.Before != "" {
+		add("cs4Label", "before")
+		add("cs4", evt.Before)
+	}
+	if evt.After != "" {
+		add("cs5Label", "after")
+		add("cs5", evt.After)
+	}
+	if evt.Seq > 0 {
+		add("cn1Label", "seq")
+		add("cn1", strconv.FormatUint(evt.Seq, 10))
+		add("cs3Label", "mac")
+		add("cs3", evt.MAC)
+	}
+	add("msg", evt.Detail)
+	b.WriteString(strings.Join(ext, " "))
+	return []byte(b.String()), nil
+}
+
+// LEEFFormat writes IBM QRadar Log Event Extended Format (LEEF:2.0)
+// records with tab-delimited attributes. The event type is the event ID.
+type LEEFFormat struct {
+	Vendor, Product, Version string
+}
+
+// Format implements Formatter.
+func (f LEEFFormat) Format(evt AuditEvent) ([]byte, error) {
+	vendor, product, version := headerDefaults(f.Vendor, f.Product, f.Version)
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|x09|",
+		leefHeader(vendor), leefHeader(product), leefHeader(version), leefHeader(orDash(evt.EventType)))
+
+	var attrs []string
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, key+"="+leefValue(value))
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, evt.Timestamp); err == nil {
+		add("devTime", strconv.FormatInt(t.UnixMilli(), 10))
+	}
+	add("cat", evt.EventType)
+	add("sev", strconv.Itoa(severityLevel(evt.Severity)))
+	add("usrName", evt.Actor)
+	add("action", evt.Action)
+	add("resource", evt.Resource)
+	add("nistRef", evt.NISTRef)
+	add("src", evt.SourceIP)
+	add("before", evt.Before)
+	add("after", evt.After)
+	if evt.Seq > 0 {
+		add("seq", strconv.FormatUint(evt.Seq, 10))
+		add("mac", evt.MAC)
+	}
+	add("msg", evt.Detail)
+	b.WriteString(strings.Join(attrs, "\t"))
+	return []byte(b.String()), nil
+}
+
+// severityLevel maps an event severity to the 0-10 scale of CEF and LEEF.
+func severityLevel(severity string) int {
+	switch severity {
+	case "critical":
+		return 10
+	case "warning":
+		return 6
+	}
+	return 3
+}
+
+func headerDefaults(vendor, product, version string) (string, string, string) {
+	if vendor == "" {
+		vendor = defaultVendor
+	}
+	if product == "" {
+		product = defaultProduct
+	}
+	if version == "" {
+		version = buildinfo.Version
+	}
+	return vendor, product, version
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// cefHeader escapes '\' and '|' in a CEF header field; line breaks are
+// not allowed there and become spaces.
+var cefHeader = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ").Replace
+
+// cefValue escapes '\' and '=' in a CEF extension value and encodes line
+// breaks as \n and \r.
+var cefValue = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace
+
+// leefHeader escapes '\' and '|' in a LEEF header field.
+var leefHeader = cefHeader
+
+// leefValue keeps an attribute value on one line and free of the tab
+// delimiter, which LEEF cannot escape.
+var leefValue = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ").Replace
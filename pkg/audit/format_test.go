@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var formatEvent = AuditEvent{
+	Timestamp: "2026-10-18T12:00:00Z",
+	EventType: "auth_attempt",
+	Severity:  "warning",
+	Actor:     "api:10.0.0.1",
+	Resource:  "/api/v1|x",
+	Action:    "login_failed",
+	Detail:    "bad token a=b\\c\nnext\tline",
+	NISTRef:   "AC-7",
+}
+
+func TestCEFFormat(t *testing.T) {
+	out, err := CEFFormat{Version: "1.2.3"}.Format(formatEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `CEF:0|cloudflared-fips|cloudflared-fips|1.2.3|auth_attempt|login_failed|6|` +
+		`rt=1792324800000 cat=auth_attempt suser=api:10.0.0.1 act=login_failed ` +
+		`cs1Label=resource cs1=/api/v1|x cs2Label=nistRef cs2=AC-7 ` +
+		`msg=bad token a\=b\\c\nnext` + "\tline"
+	if string(out) != want {
+		t.Errorf("CEF =\n%s\nwant\n%s", out, want)
+	}
+
+	out, _ = CEFFormat{Vendor: `a|b\c`}.Format(AuditEvent{Severity: "critical"})
+	if !strings.HasPrefix(string(out), `CEF:0|a\|b\\c|cloudflared-fips|`) || !strings.Contains(string(out), "|-|-|10|") {
+		t.Errorf("CEF header escaping = %s", out)
+	}
+}
+
+func TestLEEFFormat(t *testing.T) {
+	evt := formatEvent
+	evt.Seq, evt.MAC = 3, "abcd"
+	out, err := LEEFFormat{Version: "1.2.3"}.Format(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "LEEF:2.0|cloudflared-fips|cloudflared-fips|1.2.3|auth_attempt|x09|" + strings.Join([]string{
+		"devTime=1792324800000", "cat=auth_attempt", "sev=6", "usrName=api:10.0.0.1", "action=login_failed",
+		"resource=/api/v1|x", "nistRef=AC-7", "seq=3", "mac=abcd", `msg=bad token a=b\c next line`,
+	}, "\t")
+	if string(out) != want {
+		t.Errorf("LEEF =\n%q\nwant\n%q", out, want)
+	}
+}
+
+func TestWithFormatFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.cef")
+	f, err := ParseFormat("CEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	al, err := NewAuditLogger(path, WithFormat(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Severity: "info", Actor: "system", Action: "started"})
+	al.Close()
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "CEF:0|") || !strings.HasSuffix(string(data), "act=started\n") {
+		t.Errorf("file = %q", data)
+	}
+
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := NewAuditLogger(path, WithFormat(f), WithHashChain(ChainConfig{Key: testChainKey})); err == nil {
+		t.Error("expected error for hash chain with CEF")
+	}
+}
+
+func TestSyslogFormatCEF(t *testing.T) {
+	c := &syslogClient{cfg: SyslogConfig{AppName: "cloudflared-fips", Format: CEFFormat{Version: "1"}}, hostname: "host1", pid: "42"}
+	msg := string(c.format(AuditEvent{Timestamp: "2026-10-18T12:00:00Z", EventType: "system_event", Severity: "info", Action: "started"}))
+	want := "<38>1 2026-10-18T12:00:00Z host1 cloudflared-fips 42 system_event - " +
+		"CEF:0|cloudflared-fips|cloudflared-fips|1|system_event|started|3|rt=1792324800000 cat=system_event act=started"
+	if msg != want {
+		t.Errorf("format =\n%s\nwant\n%s", msg, want)
+	}
+}
@@ -0,0 +1,453 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTP collector protocols.
+const (
+	HTTPSplunk        = "splunk"        // Splunk HTTP Event Collector
+	HTTPElasticsearch = "elasticsearch" // Elasticsearch/OpenSearch _bulk API
+)
+
+// HTTP sink defaults.
+const (
+	DefaultHTTPBatchSize     = 100
+	DefaultHTTPFlushInterval = time.Second
+	DefaultHTTPSourceType    = "cloudflared-fips:audit"
+	DefaultHTTPIndex         = "cloudflared-fips-audit"
+
+	httpRequestTimeout = 30 * time.Second
+)
+
+// HTTPSinkConfig configures an HTTP event collector sink.
+type HTTPSinkConfig struct {
+	// Protocol is HTTPSplunk or HTTPElasticsearch.
+	Protocol string
+	// URL is the collector endpoint, e.g.
+	// https://splunk:8088/services/collector/event or https://es:9200/_bulk.
+	URL string
+	// Token is sent as "Authorization: Splunk <token>" to Splunk and as
+	// "Authorization: ApiKey <token>" to Elasticsearch.
+	Token string
+	// Index is the Splunk index (default: the token's) or the
+	// Elasticsearch index or data stream (default: DefaultHTTPIndex).
+	Index      string
+	SourceType string // Splunk sourcetype; default: DefaultHTTPSourceType
+	Hostname   string // default: os.Hostname
+
+	// CAFile, PinSHA256, CertFile, and KeyFile configure TLS as for
+	// SyslogConfig.
+	CAFile    string
+	PinSHA256 []string
+	CertFile  string
+	KeyFile   string
+
+	// BatchSize events are sent per request; a partial batch is sent
+	// after FlushInterval.
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+	MaxBackoff    time.Duration
+	// SpoolPath, when set, stores events that do not fit the queue or are
+	// still undelivered at Close. They are sent after the next successful
+	// post, or on the next start.
+	SpoolPath     string
+	MaxSpoolBytes int64
+}
+
+// HTTPSink sends audit events in batches to a Splunk HTTP Event Collector
+// or an Elasticsearch _bulk endpoint, retrying with backoff. Delivery is at
+// least once; Elasticsearch documents carry stable IDs so retried batches
+// are not indexed twice.
+type HTTPSink struct {
+	cfg      HTTPSinkConfig
+	client   *http.Client
+	hostname string
+	idPrefix string
+	nextID   atomic.Uint64
+	tls      bool
+
+	queue   chan []byte
+	spool   *spool
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	dropped atomic.Int64
+}
+
+// WithHTTPSink forwards audit events to an HTTP event collector.
+func WithHTTPSink(cfg HTTPSinkConfig) Option {
+	return func(al *AuditLogger) {
+		s, err := NewHTTPSink(cfg)
+		if err != nil {
+			al.optErr = err
+			return
+		}
+		al.sinks = append(al.sinks, s)
+	}
+}
+
+// NewHTTPSink creates an HTTP sink and starts delivering any events left in
+// its spool by a previous run.
+func NewHTTPSink(cfg HTTPSinkConfig) (*HTTPSink, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("audit: invalid HTTP collector URL %q", cfg.URL)
+	}
+	switch cfg.Protocol {
+	case HTTPSplunk:
+		if cfg.SourceType == "" {
+			cfg.SourceType = DefaultHTTPSourceType
+		}
+	case HTTPElasticsearch:
+		if cfg.Index == "" {
+			cfg.Index = DefaultHTTPIndex
+		}
+	default:
+		return nil, fmt.Errorf("audit: unsupported HTTP collector protocol %q (want splunk or elasticsearch)", cfg.Protocol)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultHTTPBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultHTTPFlushInterval
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultSyslogQueueSize
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultSyslogMaxBackoff
+	}
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if u.Scheme == "https" {
+		tlsCfg, err := clientTLSConfig(cfg.CAFile, cfg.CertFile, cfg.KeyFile, cfg.PinSHA256)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+	var prefix [6]byte
+	if _, err := rand.Read(prefix[:]); err != nil {
+		return nil, fmt.Errorf("audit: generate document ID prefix: %w", err)
+	}
+
+	s := &HTTPSink{
+		cfg:      cfg,
+		client:   &http.Client{Transport: transport, Timeout: httpRequestTimeout},
+		hostname: hostname,
+		idPrefix: hex.EncodeToString(prefix[:]),
+		tls:      u.Scheme == "https",
+		queue:    make(chan []byte, cfg.QueueSize),
+		spool:    newSpool(cfg.SpoolPath, cfg.MaxSpoolBytes),
+		done:     make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Write queues evt without blocking Log. When the queue is full the event
+// is spooled, or dropped if there is no spool.
+func (s *HTTPSink) Write(evt AuditEvent) error {
+	doc, err := s.encode(evt)
+	if err != nil {
+		return err
+	}
+	select {
+	case s.queue <- doc:
+	default:
+		if err := s.spool.append([][]byte{doc}); err != nil {
+			s.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Close stops delivery after a last attempt to send queued events, and
+// spools whatever remains.
+func (s *HTTPSink) Close() error {
+	close(s.done)
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *HTTPSink) encrypted() bool     { return s.tls }
+func (s *HTTPSink) droppedCount() int64 { return s.dropped.Load() }
+
+// encode renders evt as the collector expects it: a HEC event object, or
+// a _bulk create action and document on two lines.
+func (s *HTTPSink) encode(evt AuditEvent) ([]byte, error) {
+	if s.cfg.Protocol == HTTPSplunk {
+		hec := struct {
+			Time       float64    `json:"time,omitempty"`
+			Host       string     `json:"host,omitempty"`
+			Source     string     `json:"source"`
+			SourceType string     `json:"sourcetype"`
+			Index      string     `json:"index,omitempty"`
+			Event      AuditEvent `json:"event"`
+		}{Host: s.hostname, Source: "cloudflared-fips", SourceType: s.cfg.SourceType, Index: s.cfg.Index, Event: evt}
+		if t, err := time.Parse(time.RFC3339Nano, evt.Timestamp); err == nil {
+			hec.Time = float64(t.UnixMilli()) / 1000
+		}
+		return json.Marshal(hec)
+	}
+
+	id := s.idPrefix + "-" + strconv.FormatUint(s.nextID.Add(1), 10)
+	if evt.MAC != "" {
+		id = evt.MAC
+	}
+	action, err := json.Marshal(map[string]map[string]string{
+		"create": {"_index": s.cfg.Index, "_id": id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(struct {
+		Timestamp string `json:"@timestamp"`
+		AuditEvent
+	}{evt.Timestamp, evt})
+	if err != nil {
+		return nil, err
+	}
+	return append(append(action, '\n'), doc...), nil
+}
+
+// permanentError is a collector response that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// run sends the spool, then full batches as they fill and partial batches
+// every flush interval until Close. The spool is replayed again after each
+// successful post while events are waiting in it.
+func (s *HTTPSink) run() {
+	defer s.wg.Done()
+	var pending [][]byte
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	backoff := min(time.Second, s.cfg.MaxBackoff)
+	failing := false
+
+	// send posts one batch, retrying with backoff. A batch the collector
+	// rejects is dropped. It returns false if the sink was closed first.
+	send := func(batch [][]byte) bool {
+		for {
+			err := s.post(s.ctx, batch)
+			var perm *permanentError
+			if errors.As(err, &perm) {
+				s.dropped.Add(int64(len(batch)))
+				fmt.Fprintf(os.Stderr, "audit: http sink: dropped %d events: %v\n", len(batch), err)
+			} else if err != nil {
+				if !failing {
+					fmt.Fprintf(os.Stderr, "audit: http sink: %v; retrying\n", err)
+					failing = true
+				}
+				select {
+				case <-s.done:
+					return false
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, s.cfg.MaxBackoff)
+				continue
+			}
+			failing = false
+			backoff = min(time.Second, s.cfg.MaxBackoff)
+			return true
+		}
+	}
+
+	// replay sends one spool file. Undelivered events stay spooled.
+	replay := func() bool {
+		done, err := s.spool.replay(func(docs [][]byte) int {
+			for i := 0; i < len(docs); i += s.cfg.BatchSize {
+				if !send(docs[i:min(i+s.cfg.BatchSize, len(docs))]) {
+					return i
+				}
+			}
+			return len(docs)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "audit: http sink spool: %v\n", err)
+		}
+		return done
+	}
+
+	// flush sends pending batches. all includes a final partial batch. It
+	// returns false if the sink was closed first.
+	flush := func(all bool) bool {
+		sent := false
+		for len(pending) >= s.cfg.BatchSize || (all && len(pending) > 0) {
+			n := min(len(pending), s.cfg.BatchSize)
+			if !send(pending[:n]) {
+				return false
+			}
+			pending = pending[n:]
+			sent = true
+		}
+		// The collector is reachable: send what was spooled while the
+		// queue was full.
+		if sent && s.spool.pending() {
+			return replay()
+		}
+		return true
+	}
+
+	for s.spool.pending() {
+		if !replay() {
+			s.shutdown(nil)
+			return
+		}
+	}
+	for {
+		select {
+		case doc := <-s.queue:
+			pending = append(pending, doc)
+			if !flush(false) {
+				s.shutdown(pending)
+				return
+			}
+		case <-ticker.C:
+			if !flush(true) {
+				s.shutdown(pending)
+				return
+			}
+		case <-s.done:
+			s.shutdown(pending)
+			return
+		}
+	}
+}
+
+// shutdown makes one last attempt to send pending and queued events and
+// spools whatever remains.
+func (s *HTTPSink) shutdown(pending [][]byte) {
+	for {
+		select {
+		case doc := <-s.queue:
+			pending = append(pending, doc)
+			continue
+		default:
+		}
+		break
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), syslogFlushTimeout)
+	defer cancel()
+	for len(pending) > 0 {
+		n := min(len(pending), s.cfg.BatchSize)
+		var perm *permanentError
+		if err := s.post(ctx, pending[:n]); err != nil && !errors.As(err, &perm) {
+			break
+		}
+		pending = pending[n:]
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if err := s.spool.append(pending); err != nil {
+		s.dropped.Add(int64(len(pending)))
+		fmt.Fprintf(os.Stderr, "audit: http sink: %d undelivered events lost: %v\n", len(pending), err)
+	}
+}
+
+// post sends one batch. Rejected data (400, 422, or Elasticsearch item
+// errors other than conflicts and throttling) is a permanentError.
+func (s *HTTPSink) post(ctx context.Context, batch [][]byte) error {
+	body := append(bytes.Join(batch, []byte("\n")), '\n')
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if s.cfg.Protocol == HTTPSplunk {
+		req.Header.Set("Content-Type", "application/json")
+		if s.cfg.Token != "" {
+			req.Header.Set("Authorization", "Splunk "+s.cfg.Token)
+		}
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if s.cfg.Token != "" {
+			req.Header.Set("Authorization", "ApiKey "+s.cfg.Token)
+		}
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("%s returned %s: %.200s", s.cfg.Protocol, resp.Status, respBody)
+		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+			return &permanentError{err}
+		}
+		return err
+	}
+	if s.cfg.Protocol == HTTPElasticsearch {
+		return bulkErrors(respBody)
+	}
+	return nil
+}
+
+// bulkErrors checks the per-document results of a _bulk response. A 409
+// means the document was indexed by an earlier attempt.
+func bulkErrors(body []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("elasticsearch: parse bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	var retry, rejected int
+	var reason json.RawMessage
+	for _, item := range resp.Items {
+		for _, r := range item {
+			switch {
+			case r.Status < 300 || r.Status == http.StatusConflict:
+			case r.Status == http.StatusTooManyRequests || r.Status >= 500:
+				retry++
+			default:
+				rejected++
+				if reason == nil {
+					reason = r.Error
+				}
+			}
+		}
+	}
+	if retry > 0 {
+		return fmt.Errorf("elasticsearch: %d documents not indexed, will retry", retry)
+	}
+	if rejected > 0 {
+		return &permanentError{fmt.Errorf("elasticsearch rejected %d documents: %.200s", rejected, reason)}
+	}
+	return nil
+}
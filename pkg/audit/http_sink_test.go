@@ -0,0 +1,276 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is an httptest HTTP event collector that records the events it
+// accepts and can be told to fail.
+type collector struct {
+	mu       sync.Mutex
+	requests int
+	failNext int
+	auth     []string
+	bodies   [][]byte
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if c.failNext > 0 {
+		c.failNext--
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+	c.auth = append(c.auth, r.Header.Get("Authorization"))
+	c.bodies = append(c.bodies, body)
+	w.Write([]byte(`{"text":"Success","code":0}`))
+}
+
+// events returns the HEC events received so far.
+func (c *collector) events(t *testing.T) []AuditEvent {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []AuditEvent
+	for _, body := range c.bodies {
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		for sc.Scan() {
+			var hec struct {
+				SourceType string     `json:"sourcetype"`
+				Event      AuditEvent `json:"event"`
+			}
+			if err := json.Unmarshal(sc.Bytes(), &hec); err != nil {
+				t.Fatalf("bad HEC line %q: %v", sc.Bytes(), err)
+			}
+			if hec.SourceType != DefaultHTTPSourceType {
+				t.Errorf("sourcetype = %q", hec.SourceType)
+			}
+			out = append(out, hec.Event)
+		}
+	}
+	return out
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHTTPSink_SplunkBatchesAndRetries(t *testing.T) {
+	c := &collector{failNext: 1}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s, err := NewHTTPSink(HTTPSinkConfig{
+		Protocol: HTTPSplunk, URL: srv.URL, Token: "hec-token",
+		BatchSize: 3, FlushInterval: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []string{"a", "b", "c", "d"} {
+		s.Write(AuditEvent{Timestamp: "2026-10-18T12:00:00Z", EventType: "system_event", Action: a})
+	}
+	waitFor(t, func() bool { return len(c.events(t)) == 4 })
+	s.Close()
+
+	got := c.events(t)
+	for i, want := range []string{"a", "b", "c", "d"} {
+		if got[i].Action != want {
+			t.Errorf("event %d = %q, want %q", i, got[i].Action, want)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.bodies) != 2 || c.requests != 3 {
+		t.Errorf("batches = %d, requests = %d; want 2 batches after one retry", len(c.bodies), c.requests)
+	}
+	if c.auth[0] != "Splunk hec-token" {
+		t.Errorf("Authorization = %q", c.auth[0])
+	}
+	if s.encrypted() {
+		t.Error("http:// sink reported as encrypted")
+	}
+}
+
+func TestHTTPSink_SpoolReplayedAfterRestart(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	spoolPath := filepath.Join(t.TempDir(), "audit.http-buffer")
+	url := srv.URL
+	srv.Close() // collector down
+
+	cfg := HTTPSinkConfig{
+		Protocol: HTTPSplunk, URL: url, SpoolPath: spoolPath,
+		FlushInterval: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond,
+	}
+	s, err := NewHTTPSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write(AuditEvent{EventType: "system_event", Action: "one"})
+	s.Write(AuditEvent{EventType: "system_event", Action: "two"})
+	s.Close()
+
+	srv = httptest.NewServer(c)
+	defer srv.Close()
+	cfg.URL = srv.URL
+	s, err = NewHTTPSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write(AuditEvent{EventType: "system_event", Action: "three"})
+	waitFor(t, func() bool { return len(c.events(t)) == 3 })
+	s.Close()
+
+	var actions []string
+	for _, evt := range c.events(t) {
+		actions = append(actions, evt.Action)
+	}
+	if strings.Join(actions, ",") != "one,two,three" {
+		t.Errorf("delivered %v", actions)
+	}
+	if s.droppedCount() != 0 {
+		t.Errorf("dropped = %d", s.droppedCount())
+	}
+}
+
+func TestHTTPSink_SpoolReplayedAfterOutage(t *testing.T) {
+	c := &collector{failNext: 1 << 20} // collector failing
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	spoolPath := filepath.Join(t.TempDir(), "audit.http-buffer")
+
+	s, err := NewHTTPSink(HTTPSinkConfig{
+		Protocol: HTTPSplunk, URL: srv.URL, SpoolPath: spoolPath,
+		BatchSize: 1, QueueSize: 1, FlushInterval: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, a := range []string{"a", "b", "c", "d", "e"} {
+		s.Write(AuditEvent{EventType: "system_event", Action: a})
+	}
+	waitFor(t, func() bool { _, err := os.Stat(spoolPath); return err == nil })
+
+	// The collector recovers without a restart; the spool is sent once the
+	// queued events are delivered, then removed.
+	c.mu.Lock()
+	c.failNext = 0
+	c.mu.Unlock()
+	waitFor(t, func() bool { return len(c.events(t)) == 5 })
+	waitFor(t, func() bool { return !s.spool.pending() })
+	for _, path := range []string{spoolPath, s.spool.replayPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", filepath.Base(path), err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, evt := range c.events(t) {
+		seen[evt.Action] = true
+	}
+	if len(seen) != 5 || s.droppedCount() != 0 {
+		t.Errorf("delivered %v, dropped %d", seen, s.droppedCount())
+	}
+}
+
+func TestHTTPSink_ElasticsearchBulk(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if r.Header.Get("Authorization") != "ApiKey es-key" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+		if attempts == 1 {
+			// First document indexed, second throttled.
+			w.Write([]byte(`{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":429,"error":{"type":"es_rejected_execution_exception"}}}]}`))
+			return
+		}
+		// The retry conflicts on the first document, which is fine.
+		w.Write([]byte(`{"errors":true,"items":[{"create":{"status":409}},{"create":{"status":201}}]}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewHTTPSink(HTTPSinkConfig{
+		Protocol: HTTPElasticsearch, URL: srv.URL + "/_bulk", Token: "es-key",
+		BatchSize: 2, FlushInterval: time.Hour, MaxBackoff: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write(AuditEvent{Timestamp: "2026-10-18T12:00:00Z", Action: "a", Seq: 1, MAC: "mac1"})
+	s.Write(AuditEvent{Timestamp: "2026-10-18T12:00:01Z", Action: "b"})
+	waitFor(t, func() bool { mu.Lock(); defer mu.Unlock(); return attempts == 2 })
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) != 4 {
+		t.Fatalf("bulk body has %d lines: %q", len(lines), lines)
+	}
+	var action map[string]map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatal(err)
+	}
+	if action["create"]["_index"] != DefaultHTTPIndex || action["create"]["_id"] != "mac1" {
+		t.Errorf("action = %v", action)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(lines[3]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["@timestamp"] != "2026-10-18T12:00:01Z" || doc["action"] != "b" {
+		t.Errorf("doc = %v", doc)
+	}
+	if s.droppedCount() != 0 {
+		t.Errorf("dropped = %d", s.droppedCount())
+	}
+}
+
+func TestHTTPSink_Config(t *testing.T) {
+	if _, err := NewHTTPSink(HTTPSinkConfig{Protocol: HTTPSplunk, URL: "ftp://x"}); err == nil {
+		t.Error("expected error for ftp URL")
+	}
+	if _, err := NewHTTPSink(HTTPSinkConfig{Protocol: "graylog", URL: "https://x"}); err == nil {
+		t.Error("expected error for unknown protocol")
+	}
+
+	srv := httptest.NewTLSServer(&collector{})
+	defer srv.Close()
+	al, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.log"),
+		WithHTTPSink(HTTPSinkConfig{Protocol: HTTPSplunk, URL: srv.URL}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	if !al.ForwardingActive() || !al.ForwardingEncrypted() {
+		t.Error("https sink should count as encrypted forwarding")
+	}
+}
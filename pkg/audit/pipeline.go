@@ -0,0 +1,329 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FsyncPolicy controls when the audit log file is flushed to stable storage.
+type FsyncPolicy string
+
+// Fsync policies.
+const (
+	FsyncNone     FsyncPolicy = "none"     // leave flushing to the OS
+	FsyncInterval FsyncPolicy = "interval" // at most once per interval
+	FsyncAlways   FsyncPolicy = "always"   // after every write (every batch when async)
+)
+
+// OverflowPolicy controls what Log does when the async queue is full.
+type OverflowPolicy string
+
+// Overflow policies.
+const (
+	// OverflowBlock makes callers wait for the writer, so no event is lost
+	// (AU-5 fail-safe).
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDrop discards the event and counts it, so callers never wait.
+	OverflowDrop OverflowPolicy = "drop"
+)
+
+// Pipeline defaults.
+const (
+	DefaultQueueSize     = 4096
+	DefaultFsyncInterval = time.Second
+	maxWriteBatch        = 256
+)
+
+// ParseFsyncPolicy returns the policy named by s: "none", "interval", or
+// "always".
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(s); p {
+	case FsyncNone, FsyncInterval, FsyncAlways:
+		return p, nil
+	}
+	return "", fmt.Errorf("audit: unknown fsync policy %q (want none, interval, or always)", s)
+}
+
+// ParseOverflowPolicy returns the policy named by s: "block" or "drop".
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case OverflowBlock, OverflowDrop:
+		return p, nil
+	}
+	return "", fmt.Errorf("audit: unknown overflow policy %q (want block or drop)", s)
+}
+
+// WithFsync sets the fsync policy of the audit log file. interval applies
+// to FsyncInterval; zero means DefaultFsyncInterval.
+func WithFsync(policy FsyncPolicy, interval time.Duration) Option {
+	return func(al *AuditLogger) {
+		if _, err := ParseFsyncPolicy(string(policy)); err != nil {
+			al.optErr = err
+			return
+		}
+		if interval <= 0 {
+			interval = DefaultFsyncInterval
+		}
+		al.fsync, al.fsyncInterval = policy, interval
+	}
+}
+
+// AsyncConfig configures the asynchronous write pipeline.
+type AsyncConfig struct {
+	QueueSize int            // default: DefaultQueueSize
+	Overflow  OverflowPolicy // default: OverflowBlock
+}
+
+// WithAsync makes Log queue events for a dedicated writer goroutine, which
+// seals, writes, and forwards them in batches and then notifies listeners.
+// Callers no longer wait on the file, sinks, or listeners; events appear in
+// RecentEvents once written (see Flush).
+func WithAsync(cfg AsyncConfig) Option {
+	return func(al *AuditLogger) {
+		if cfg.QueueSize <= 0 {
+			cfg.QueueSize = DefaultQueueSize
+		}
+		if cfg.Overflow == "" {
+			cfg.Overflow = OverflowBlock
+		}
+		if _, err := ParseOverflowPolicy(string(cfg.Overflow)); err != nil {
+			al.optErr = err
+			return
+		}
+		al.pipe = &pipeline{
+			overflow: cfg.Overflow,
+			queue:    make(chan pipeItem, cfg.QueueSize),
+			done:     make(chan struct{}),
+		}
+	}
+}
+
+// pipeline is the queue between Log and the writer goroutine.
+type pipeline struct {
+	overflow OverflowPolicy
+	queue    chan pipeItem
+	done     chan struct{} // closed when the writer exits
+	started  bool
+	mu       sync.RWMutex // guards closed against sends on a closed queue
+	closed   bool
+	dropped  atomic.Int64
+	// writer is the goroutine ID of the writer, whose listeners may log.
+	writer atomic.Uint64
+}
+
+// pipeItem is a queued event, or a Flush marker when flushed is set.
+type pipeItem struct {
+	evt     AuditEvent
+	flushed chan struct{}
+}
+
+// PipelineStats describes the write pipeline for compliance reporting.
+type PipelineStats struct {
+	Async     bool
+	Overflow  OverflowPolicy
+	QueueSize int
+	Queued    int
+	Dropped   int64
+	Fsync     FsyncPolicy
+}
+
+// PipelineStats returns the current state of the write pipeline.
+func (al *AuditLogger) PipelineStats() PipelineStats {
+	st := PipelineStats{Fsync: al.fsync}
+	if p := al.pipe; p != nil {
+		st.Async = true
+		st.Overflow = p.overflow
+		st.QueueSize = cap(p.queue)
+		st.Queued = len(p.queue)
+		st.Dropped = p.dropped.Load()
+	}
+	return st
+}
+
+// enqueue hands evt to the writer. It returns false once the pipeline is
+// closed, or when a listener logs from the writer goroutine into a full
+// queue, since waiting for the writer would deadlock. Log then writes evt
+// itself. Other callers wait or drop as the overflow policy says.
+func (p *pipeline) enqueue(evt AuditEvent) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.queue <- pipeItem{evt: evt}:
+		return true
+	default:
+	}
+	if p.overflow == OverflowBlock {
+		if p.writer.Load() == goroutineID() {
+			return false
+		}
+		p.queue <- pipeItem{evt: evt}
+		return true
+	}
+	if n := p.dropped.Add(1); n == 1 || n%1000 == 0 {
+		fmt.Fprintf(os.Stderr, "audit: queue full, %d events dropped (AU-5)\n", n)
+	}
+	return true
+}
+
+// Flush waits until every event logged before the call has been written,
+// forwarded, and delivered to listeners. It returns at once when Log is
+// synchronous. Listeners must not call Flush: they run on the writer, which
+// would wait for itself.
+func (al *AuditLogger) Flush() {
+	p := al.pipe
+	if p == nil {
+		return
+	}
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return
+	}
+	done := make(chan struct{})
+	p.queue <- pipeItem{flushed: done}
+	p.mu.RUnlock()
+	<-done
+}
+
+// closePipeline stops accepting events and waits for the writer to drain
+// the queue.
+func (al *AuditLogger) closePipeline() {
+	p := al.pipe
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	if p.started {
+		<-p.done
+	}
+}
+
+// startPipeline starts the writer goroutine.
+func (al *AuditLogger) startPipeline() {
+	if al.pipe == nil {
+		return
+	}
+	al.pipe.started = true
+	go al.writeLoop()
+}
+
+// writeLoop drains the queue in batches so that one lock acquisition and,
+// with FsyncAlways, one fsync cover many events.
+func (al *AuditLogger) writeLoop() {
+	p := al.pipe
+	defer close(p.done)
+	p.writer.Store(goroutineID())
+	var tick <-chan time.Time
+	if al.fsync == FsyncInterval {
+		t := time.NewTicker(al.fsyncInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	batch := make([]pipeItem, 0, maxWriteBatch)
+	for {
+		select {
+		case it, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			batch = append(batch[:0], it)
+			open := true
+		drain:
+			for len(batch) < maxWriteBatch {
+				select {
+				case it, ok := <-p.queue:
+					if !ok {
+						open = false
+						break drain
+					}
+					batch = append(batch, it)
+				default:
+					break drain
+				}
+			}
+			al.writeBatch(batch)
+			if !open {
+				return
+			}
+		case now := <-tick:
+			al.mu.Lock()
+			al.syncFile(now)
+			al.mu.Unlock()
+		}
+	}
+}
+
+// writeBatch records a batch of queued events, then notifies listeners and
+// releases Flush callers outside the lock.
+func (al *AuditLogger) writeBatch(batch []pipeItem) {
+	events := make([]AuditEvent, 0, len(batch))
+	al.mu.Lock()
+	for _, it := range batch {
+		if it.flushed == nil {
+			events = append(events, al.record(it.evt))
+		}
+	}
+	al.syncFile(time.Now())
+	listeners := al.listeners
+	al.mu.Unlock()
+
+	for _, evt := range events {
+		for _, fn := range listeners {
+			fn(evt)
+		}
+	}
+	for _, it := range batch {
+		if it.flushed != nil {
+			close(it.flushed)
+		}
+	}
+}
+
+// syncFile fsyncs the log file if it has unsynced writes and the policy
+// calls for it at now. Callers hold al.mu.
+func (al *AuditLogger) syncFile(now time.Time) {
+	if !al.dirty || al.file == nil {
+		return
+	}
+	switch al.fsync {
+	case FsyncInterval:
+		if now.Sub(al.lastSync) < al.fsyncInterval {
+			return
+		}
+	case FsyncAlways:
+	default:
+		return
+	}
+	if err := al.file.Sync(); err != nil {
+		fmt.Fprintf(os.Stderr, "audit: fsync: %v\n", err)
+	}
+	al.dirty = false
+	al.lastSync = now
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the
+// "goroutine N [running]:" header of its stack trace. It is only called
+// when the queue is full.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
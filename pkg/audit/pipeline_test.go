@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncPipeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	al, err := NewAuditLogger(path,
+		WithAsync(AsyncConfig{QueueSize: 8}),
+		WithHashChain(ChainConfig{Key: testChainKey}),
+		WithFsync(FsyncAlways, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var heard []string
+	al.AddListener(func(evt AuditEvent) {
+		mu.Lock()
+		heard = append(heard, evt.Action)
+		mu.Unlock()
+		// Listeners may log from the writer goroutine without deadlocking,
+		// even when the queue is full.
+		if evt.Action == "e0" {
+			for i := 0; i < 20; i++ {
+				al.Log(AuditEvent{EventType: "alert_sent", Action: "nested"})
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				al.Log(AuditEvent{EventType: "system_event", Action: fmt.Sprintf("e%d", g*25+i)})
+			}
+		}(g)
+	}
+	wg.Wait()
+	al.Flush()
+
+	mu.Lock()
+	if len(heard) != 120 {
+		t.Errorf("listener heard %d events, want 120", len(heard))
+	}
+	mu.Unlock()
+	if got := al.RecentEvents(1)[0]; got.Seq != 120 || got.MAC == "" {
+		t.Errorf("latest event = %+v, want seq 120 with MAC", got)
+	}
+	if st := al.PipelineStats(); !st.Async || st.Overflow != OverflowBlock || st.Dropped != 0 || st.Fsync != FsyncAlways {
+		t.Errorf("stats = %+v", st)
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "after_close"}) // must not panic
+
+	report, err := VerifyLog(path, testChainKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Entries != 120 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestAsyncPipelineDropsWhenFull(t *testing.T) {
+	al, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"),
+		WithAsync(AsyncConfig{QueueSize: 1, Overflow: OverflowDrop}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	al.AddListener(func(evt AuditEvent) {
+		if evt.Action == "first" {
+			close(entered)
+			<-release
+		}
+	})
+	al.Log(AuditEvent{Action: "first"})
+	<-entered // the writer is busy with "first"
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		al.Log(AuditEvent{Action: "more"}) // one fits, four are dropped
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Log blocked with the drop policy")
+	}
+	close(release)
+	al.Flush()
+
+	if st := al.PipelineStats(); st.Dropped != 4 {
+		t.Errorf("dropped = %d, want 4", st.Dropped)
+	}
+	if n := len(al.RecentEvents(0)); n != 2 {
+		t.Errorf("recorded %d events, want 2", n)
+	}
+}
+
+func TestAsyncPipelineBlocksOtherCallersWhileNotifying(t *testing.T) {
+	al, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"),
+		WithAsync(AsyncConfig{QueueSize: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	al.AddListener(func(evt AuditEvent) {
+		if evt.Action == "first" {
+			close(entered)
+			<-release
+		}
+	})
+	al.Log(AuditEvent{Action: "first"})
+	<-entered // the writer is running listeners for "first"
+
+	// A request goroutine that finds the queue full waits for the writer
+	// instead of writing ahead of the queued event.
+	logged := make(chan struct{})
+	go func() {
+		al.Log(AuditEvent{Action: "queued"})
+		al.Log(AuditEvent{Action: "blocked"})
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Fatal("Log did not block on a full queue while listeners ran")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-logged
+	al.Flush()
+
+	var order []string
+	for _, evt := range al.RecentEvents(0) {
+		order = append(order, evt.Action)
+	}
+	if fmt.Sprint(order) != "[blocked queued first]" {
+		t.Errorf("recent events = %v, want newest first: blocked, queued, first", order)
+	}
+}
+
+func TestPipelineOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	if _, err := NewAuditLogger(path, WithFsync("sometimes", 0)); err == nil {
+		t.Error("expected error for unknown fsync policy")
+	}
+	if _, err := NewAuditLogger(path, WithAsync(AsyncConfig{Overflow: "spill"})); err == nil {
+		t.Error("expected error for unknown overflow policy")
+	}
+	al, err := NewAuditLogger(path, WithFsync(FsyncInterval, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	al.Log(AuditEvent{Action: "one"})
+	al.Flush() // no-op when synchronous
+	if st := al.PipelineStats(); st.Async || st.Fsync != FsyncInterval {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+// slowSink stands in for a syslog collector that takes a while to accept
+// each message.
+type slowSink struct{}
+
+func (slowSink) Write(AuditEvent) error { time.Sleep(20 * time.Microsecond); return nil }
+func (slowSink) Close() error           { return nil }
+
+// BenchmarkLog measures the time HTTP handlers spend in Log, with a slow
+// sink and with fsync after every write. Async Log returns once the event
+// is queued; with fsync the writer also shares one fsync across a batch.
+func BenchmarkLog(b *testing.B) {
+	cases := []struct {
+		name string
+		opts []Option
+	}{
+		{"sync/slow-sink", []Option{WithSink(slowSink{})}},
+		{"async/slow-sink", []Option{WithSink(slowSink{}), WithAsync(AsyncConfig{QueueSize: 1 << 16})}},
+		{"sync/fsync-always", []Option{WithFsync(FsyncAlways, 0)}},
+		{"async/fsync-always", []Option{WithFsync(FsyncAlways, 0), WithAsync(AsyncConfig{})}},
+	}
+	evt := AuditEvent{EventType: "api_access", Severity: "info", Actor: "api:10.0.0.1", Resource: "/api/v1/compliance", Action: "accessed"}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			al, err := NewAuditLogger(filepath.Join(b.TempDir(), "audit.json"), c.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					al.Log(evt)
+				}
+			})
+			b.StopTimer()
+			al.Close()
+		})
+	}
+}
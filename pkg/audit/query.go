@@ -0,0 +1,80 @@
+package audit
+
+import "time"
+
+// Query limits.
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 50000
+)
+
+// EventQuery filters audit events. Empty fields match everything; Since is
+// inclusive and Until exclusive.
+type EventQuery struct {
+	EventType string
+	Actor     string
+	Severity  string
+	Resource  string
+	NISTRef   string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Offset    int
+}
+
+// Page returns the effective limit and offset.
+func (q EventQuery) Page() (int, int) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	offset := q.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// Matches reports whether evt passes the query's filters.
+func (q EventQuery) Matches(evt AuditEvent) bool {
+	if (q.EventType != "" && evt.EventType != q.EventType) ||
+		(q.Actor != "" && evt.Actor != q.Actor) ||
+		(q.Severity != "" && evt.Severity != q.Severity) ||
+		(q.Resource != "" && evt.Resource != q.Resource) ||
+		(q.NISTRef != "" && evt.NISTRef != q.NISTRef) {
+		return false
+	}
+	if q.Since.IsZero() && q.Until.IsZero() {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, evt.Timestamp)
+	if err != nil {
+		return false
+	}
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || t.Before(q.Until))
+}
+
+// Filter applies the query to events (newest first, as from RecentEvents)
+// for loggers without an index. It returns the requested page and the
+// total number of matches.
+func (q EventQuery) Filter(events []AuditEvent) ([]AuditEvent, int) {
+	matched := []AuditEvent{}
+	for _, evt := range events {
+		if q.Matches(evt) {
+			matched = append(matched, evt)
+		}
+	}
+	total := len(matched)
+	limit, offset := q.Page()
+	if offset >= total {
+		return []AuditEvent{}, total
+	}
+	matched = matched[offset:]
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total
+}
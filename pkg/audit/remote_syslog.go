@@ -0,0 +1,434 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/selftest"
+)
+
+// Remote syslog defaults.
+const (
+	DefaultSyslogQueueSize  = 10000
+	DefaultSyslogMaxBackoff = time.Minute
+	// DefaultSyslogSDID is the structured-data ID of audit fields. 32473 is
+	// the documentation enterprise number from RFC 5612; set SDID to use a
+	// registered one.
+	DefaultSyslogSDID = "audit@32473"
+
+	syslogFacilityAuth = 4
+	syslogWriteTimeout = 10 * time.Second
+	syslogFlushTimeout = 5 * time.Second
+)
+
+// SyslogConfig configures the RFC 5424 syslog client.
+type SyslogConfig struct {
+	// Network is "tls" (RFC 5425), "tcp" (RFC 6587 octet counting), or
+	// "udp" (RFC 5426). Only "tls" protects audit records in transit.
+	Network string
+	Addr    string
+	// CAFile holds the PEM CA certificates trusted for the collector. When
+	// set, system roots are not used.
+	CAFile string
+	// PinSHA256 lists hex SHA-256 hashes of SubjectPublicKeyInfo; when set,
+	// some certificate in the verified chain must match one.
+	PinSHA256 []string
+	// CertFile and KeyFile are an optional client certificate.
+	CertFile   string
+	KeyFile    string
+	ServerName string // default: host part of Addr
+
+	Hostname string // default: os.Hostname
+	AppName  string // default: cloudflared-fips
+	SDID     string // default: DefaultSyslogSDID
+	// Format, when set, renders the message body (e.g. CEFFormat for
+	// ArcSight) in place of the structured-data element and detail.
+	Format Formatter
+
+	// QueueSize bounds the in-memory queue of undelivered messages.
+	QueueSize  int
+	MaxBackoff time.Duration
+	// SpoolPath, when set, stores messages that do not fit the queue or
+	// are still undelivered at Close. They are sent once the collector is
+	// reachable again, or on the next start.
+	SpoolPath     string
+	MaxSpoolBytes int64
+}
+
+// syslogClient delivers RFC 5424 messages from a queue, reconnecting with
+// backoff.
+type syslogClient struct {
+	cfg      SyslogConfig
+	dial     func() (net.Conn, error)
+	queue    chan []byte
+	done     chan struct{}
+	wg       sync.WaitGroup
+	spool    *spool
+	hostname string
+	pid      string
+	tls      bool
+	dropped  atomic.Int64
+}
+
+// WithRemoteSyslog forwards audit events to a syslog collector as RFC 5424
+// messages with the event fields as structured data.
+func WithRemoteSyslog(cfg SyslogConfig) Option {
+	return func(al *AuditLogger) {
+		c, err := newSyslogClient(cfg)
+		if err != nil {
+			al.optErr = err
+			return
+		}
+		al.sinks = append(al.sinks, c)
+	}
+}
+
+func newSyslogClient(cfg SyslogConfig) (*syslogClient, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("audit: syslog address required")
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "cloudflared-fips"
+	}
+	if cfg.SDID == "" {
+		cfg.SDID = DefaultSyslogSDID
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultSyslogQueueSize
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultSyslogMaxBackoff
+	}
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	c := &syslogClient{
+		cfg:      cfg,
+		queue:    make(chan []byte, cfg.QueueSize),
+		spool:    newSpool(cfg.SpoolPath, cfg.MaxSpoolBytes),
+		done:     make(chan struct{}),
+		hostname: headerField(hostname, 255),
+		pid:      strconv.Itoa(os.Getpid()),
+	}
+	switch cfg.Network {
+	case "tls":
+		tlsCfg, err := syslogTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{Timeout: syslogWriteTimeout}
+		c.dial = func() (net.Conn, error) { return tls.DialWithDialer(dialer, "tcp", cfg.Addr, tlsCfg) }
+		c.tls = true
+	case "tcp", "udp":
+		c.dial = func() (net.Conn, error) { return net.DialTimeout(cfg.Network, cfg.Addr, syslogWriteTimeout) }
+	default:
+		return nil, fmt.Errorf("audit: unsupported syslog network %q (want tls, tcp, or udp)", cfg.Network)
+	}
+
+	c.wg.Add(1)
+	go c.run()
+	return c, nil
+}
+
+// syslogTLSConfig builds the FIPS TLS configuration for the collector.
+func syslogTLSConfig(cfg SyslogConfig) (*tls.Config, error) {
+	tlsCfg, err := clientTLSConfig(cfg.CAFile, cfg.CertFile, cfg.KeyFile, cfg.PinSHA256)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg.ServerName = cfg.ServerName
+	if tlsCfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("audit: syslog address: %w", err)
+		}
+		tlsCfg.ServerName = host
+	}
+	return tlsCfg, nil
+}
+
+// clientTLSConfig builds a FIPS TLS client configuration for a collector:
+// caFile replaces the system roots, certFile and keyFile are an optional
+// client certificate, and pins are hex SHA-256 SubjectPublicKeyInfo hashes
+// of which some certificate in the verified chain must match one.
+func clientTLSConfig(caFile, certFile, keyFile string, pins []string) (*tls.Config, error) {
+	tlsCfg := selftest.GetFIPSTLSConfig()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("audit: read collector CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("audit: no certificates in %s", caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("audit: load collector client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if len(pins) > 0 {
+		pinned := make(map[string]bool, len(pins))
+		for _, p := range pins {
+			pinned[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(p), ":", ""))] = true
+		}
+		tlsCfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					if pinned[hex.EncodeToString(sum[:])] {
+						return nil
+					}
+				}
+			}
+			return errors.New("collector certificate does not match any pinned key")
+		}
+	}
+	return tlsCfg, nil
+}
+
+// format renders an event as an RFC 5424 message.
+func (c *syslogClient) format(evt AuditEvent) []byte {
+	severity := 6 // informational
+	switch evt.Severity {
+	case "critical":
+		severity = 2
+	case "warning":
+		severity = 4
+	}
+	ts := evt.Timestamp
+	if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		ts = "-"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ", syslogFacilityAuth*8+severity, ts,
+		c.hostname, headerField(c.cfg.AppName, 48), c.pid, headerField(evt.EventType, 32))
+	if c.cfg.Format != nil {
+		body, err := c.cfg.Format.Format(evt)
+		if err == nil {
+			b.WriteString("- ")
+			b.Write(body)
+			return b.Bytes()
+		}
+	}
+	fmt.Fprintf(&b, "[%s", c.cfg.SDID)
+	for _, p := range []struct{ name, value string }{
+		{"eventType", evt.EventType},
+		{"severity", evt.Severity},
+		{"actor", evt.Actor},
+		{"resource", evt.Resource},
+		{"action", evt.Action},
+		{"nistRef", evt.NISTRef},
+		{"sourceIP", evt.SourceIP},
+		{"before", evt.Before},
+		{"after", evt.After},
+	} {
+		if p.value != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, p.name, escapeSDParam(p.value))
+		}
+	}
+	if evt.Seq > 0 {
+		fmt.Fprintf(&b, ` seq="%d" mac="%s"`, evt.Seq, evt.MAC)
+	}
+	b.WriteString("]")
+	if evt.Detail != "" {
+		b.WriteString(" \ufeff") // BOM marks the message as UTF-8
+		b.WriteString(strings.ToValidUTF8(evt.Detail, "\ufffd"))
+	}
+	return b.Bytes()
+}
+
+// headerField makes s a valid RFC 5424 header field: printable US-ASCII
+// without spaces, at most max characters, "-" when empty.
+func headerField(s string, max int) string {
+	var b strings.Builder
+	for i := 0; i < len(s) && b.Len() < max; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			b.WriteByte(s[i])
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// escapeSDParam escapes '"', '\', and ']' in a structured-data value.
+func escapeSDParam(s string) string {
+	s = strings.ToValidUTF8(s, "\ufffd")
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// send queues an event without blocking Log. When the queue is full the
+// message is spooled, or dropped if there is no spool.
+func (c *syslogClient) send(evt AuditEvent) {
+	msg := c.format(evt)
+	select {
+	case c.queue <- msg:
+	default:
+		if err := c.spool.append([][]byte{msg}); err != nil {
+			c.dropped.Add(1)
+		}
+	}
+}
+
+// run delivers the spool and then queued messages until close, replaying
+// the spool again after each delivery while messages are waiting in it.
+func (c *syslogClient) run() {
+	defer c.wg.Done()
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	backoff := time.Second
+
+	// deliver writes msg, reconnecting with backoff until it is sent. It
+	// returns false if the client was closed first.
+	deliver := func(msg []byte) bool {
+		for {
+			if conn == nil {
+				var err error
+				if conn, err = c.dial(); err != nil {
+					conn = nil
+					select {
+					case <-c.done:
+						return false
+					case <-time.After(backoff):
+					}
+					if backoff *= 2; backoff > c.cfg.MaxBackoff {
+						backoff = c.cfg.MaxBackoff
+					}
+					continue
+				}
+				backoff = time.Second
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+			if _, err := conn.Write(c.frame(msg)); err == nil {
+				return true
+			}
+			conn.Close()
+			conn = nil
+		}
+	}
+
+	// replay sends one spool file. Undelivered messages stay spooled.
+	replay := func() bool {
+		done, err := c.spool.replay(func(msgs [][]byte) int {
+			for i, msg := range msgs {
+				if !deliver(msg) {
+					return i
+				}
+			}
+			return len(msgs)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "audit: syslog spool: %v\n", err)
+		}
+		return done
+	}
+
+	for c.spool.pending() {
+		if !replay() {
+			c.shutdown(c.drain(), conn)
+			return
+		}
+	}
+	for {
+		select {
+		case msg := <-c.queue:
+			if !deliver(msg) {
+				c.shutdown(append([][]byte{msg}, c.drain()...), conn)
+				return
+			}
+			// The collector is reachable: send what was spooled while
+			// the queue was full.
+			if c.spool.pending() && !replay() {
+				c.shutdown(c.drain(), conn)
+				return
+			}
+		case <-c.done:
+			c.shutdown(c.drain(), conn)
+			return
+		}
+	}
+}
+
+// frame applies octet-counting framing on stream transports (RFC 5425,
+// RFC 6587); UDP sends one message per datagram.
+func (c *syslogClient) frame(msg []byte) []byte {
+	if c.cfg.Network == "udp" {
+		return msg
+	}
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+// drain empties the queue.
+func (c *syslogClient) drain() [][]byte {
+	var msgs [][]byte
+	for {
+		select {
+		case msg := <-c.queue:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+// shutdown makes a last attempt to send msgs over an open connection and
+// spools whatever remains.
+func (c *syslogClient) shutdown(msgs [][]byte, conn net.Conn) {
+	if conn != nil {
+		_ = conn.SetWriteDeadline(time.Now().Add(syslogFlushTimeout))
+		for len(msgs) > 0 {
+			if _, err := conn.Write(c.frame(msgs[0])); err != nil {
+				break
+			}
+			msgs = msgs[1:]
+		}
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	if err := c.spool.append(msgs); err != nil {
+		c.dropped.Add(int64(len(msgs)))
+		fmt.Fprintf(os.Stderr, "audit: syslog: %d undelivered messages lost: %v\n", len(msgs), err)
+	}
+}
+
+// Write queues evt for delivery without blocking Log.
+func (c *syslogClient) Write(evt AuditEvent) error {
+	c.send(evt)
+	return nil
+}
+
+// Close stops delivery, spooling anything left undelivered.
+func (c *syslogClient) Close() error {
+	close(c.done)
+	c.wg.Wait()
+	return nil
+}
+
+func (c *syslogClient) encrypted() bool     { return c.tls }
+func (c *syslogClient) droppedCount() int64 { return c.dropped.Load() }
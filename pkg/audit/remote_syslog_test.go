@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	c := &syslogClient{cfg: SyslogConfig{AppName: "cloudflared-fips", SDID: DefaultSyslogSDID}, hostname: "host1", pid: "42"}
+	msg := string(c.format(AuditEvent{
+		Timestamp: "2026-10-18T12:00:00Z",
+		EventType: "auth_attempt",
+		Severity:  "warning",
+		Actor:     `api:"10.0.0.1"`,
+		Action:    "login_failed",
+		Detail:    "bad token]",
+		NISTRef:   "AC-7",
+		Seq:       7,
+		MAC:       "abcd",
+	}))
+	want := `<36>1 2026-10-18T12:00:00Z host1 cloudflared-fips 42 auth_attempt [audit@32473 eventType="auth_attempt" severity="warning" actor="api:\"10.0.0.1\"" action="login_failed" nistRef="AC-7" seq="7" mac="abcd"] ` + "\ufeffbad token]"
+	if msg != want {
+		t.Errorf("format =\n%s\nwant\n%s", msg, want)
+	}
+	if got := headerField("has space", 32); got != "hasspace" {
+		t.Errorf("headerField = %q", got)
+	}
+}
+
+// syslogTLSServer starts a TLS listener with a self-signed certificate and
+// returns its address, the CA file, and the certificate's SPKI pin.
+func syslogTLSServer(t *testing.T) (net.Listener, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "siem"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln, caFile, hex.EncodeToString(sum[:])
+}
+
+// readFrames reads n octet-counted messages from the next connection.
+func readFrames(t *testing.T, ln net.Listener, n int) <-chan []string {
+	t.Helper()
+	out := make(chan []string, 1)
+	go func() {
+		var msgs []string
+		defer func() { out <- msgs }()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for len(msgs) < n {
+			lenStr, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			msgs = append(msgs, string(buf))
+		}
+	}()
+	return out
+}
+
+func TestRemoteSyslogTLS(t *testing.T) {
+	ln, caFile, pin := syslogTLSServer(t)
+	frames := readFrames(t, ln, 2)
+
+	al, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"), WithRemoteSyslog(SyslogConfig{
+		Network: "tls", Addr: ln.Addr().String(), CAFile: caFile, PinSHA256: []string{pin},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	if !al.SyslogActive() || !al.ForwardingEncrypted() {
+		t.Error("expected encrypted syslog forwarding")
+	}
+	al.Log(AuditEvent{EventType: "system_event", Severity: "info", Actor: "system", Action: "started"})
+	al.Log(AuditEvent{EventType: "compliance_change", Severity: "critical", Actor: "system", Resource: "t-1", Action: "status_changed"})
+
+	select {
+	case msgs := <-frames:
+		if len(msgs) != 2 || !strings.HasPrefix(msgs[0], "<38>1 ") || !strings.Contains(msgs[1], `resource="t-1"`) {
+			t.Errorf("messages = %q", msgs)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for syslog messages")
+	}
+}
+
+func TestRemoteSyslogPinMismatchSpools(t *testing.T) {
+	ln, caFile, _ := syslogTLSServer(t)
+	go func() {
+		// Complete handshakes so the client sees the pin mismatch.
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	spool := filepath.Join(t.TempDir(), "syslog.spool")
+	cfg := SyslogConfig{
+		Network: "tls", Addr: ln.Addr().String(), CAFile: caFile,
+		PinSHA256: []string{strings.Repeat("00", 32)}, SpoolPath: spool,
+	}
+	al, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"), WithRemoteSyslog(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "started"})
+	al.Close()
+	if info, err := os.Stat(spool); err != nil || info.Size() == 0 {
+		t.Fatalf("undelivered message not spooled: %v", err)
+	}
+
+	// With the right pin the spooled message is delivered on start.
+	ln2, caFile2, pin2 := syslogTLSServer(t)
+	frames := readFrames(t, ln2, 1)
+	cfg.Addr, cfg.CAFile, cfg.PinSHA256 = ln2.Addr().String(), caFile2, []string{pin2}
+	al, err = NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"), WithRemoteSyslog(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	select {
+	case msgs := <-frames:
+		if len(msgs) != 1 || !strings.Contains(msgs[0], `action="started"`) {
+			t.Errorf("replayed = %q", msgs)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for spooled message")
+	}
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Errorf("spool not removed after replay: %v", err)
+	}
+}
+
+func TestRemoteSyslogSpoolReplayedOnReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // collector down
+
+	spool := filepath.Join(t.TempDir(), "syslog.spool")
+	al, err := NewAuditLogger(filepath.Join(t.TempDir(), "audit.json"), WithRemoteSyslog(SyslogConfig{
+		Network: "tcp", Addr: addr, QueueSize: 1, SpoolPath: spool,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer al.Close()
+	for i := 0; i < 5; i++ {
+		al.Log(AuditEvent{EventType: "system_event", Action: "tick-" + strconv.Itoa(i)})
+	}
+	if info, err := os.Stat(spool); err != nil || info.Size() == 0 {
+		t.Fatalf("overflow not spooled: %v", err)
+	}
+
+	// Once the collector is back, the spool is sent without a restart.
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot reuse collector address: %v", err)
+	}
+	defer ln.Close()
+	select {
+	case msgs := <-readFrames(t, ln, 5):
+		for i := 0; i < 5; i++ {
+			if !strings.Contains(strings.Join(msgs, "\n"), "tick-"+strconv.Itoa(i)) {
+				t.Errorf("tick-%d not delivered: %q", i, msgs)
+			}
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for spooled messages")
+	}
+	waitFor(t, func() bool {
+		_, err := os.Stat(spool)
+		_, rerr := os.Stat(spool + ".replay")
+		return os.IsNotExist(err) && os.IsNotExist(rerr)
+	})
+}
@@ -0,0 +1,530 @@
+package audit
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentTimeFormat names rotated segments, e.g. audit-20261018T120000Z.json.
+const segmentTimeFormat = "20060102T150405Z"
+
+// RotationConfig enables rotation, compression, and retention of the audit
+// log (AU-11). Rotated segments are gzipped into ArchiveDir and recorded
+// with their SHA-256 in a manifest (<stem>.manifest.json). Compression,
+// retention, and bundling run in the background, so Log only holds its
+// lock for the rename and reopen.
+type RotationConfig struct {
+	// MaxSize rotates the log once a write takes it to this many bytes.
+	// Zero disables size-based rotation.
+	MaxSize int64
+	// MaxAge rotates the log after the first write once the current file
+	// has been open this long. Zero disables time-based rotation.
+	MaxAge time.Duration
+	// Retention deletes archived segments rotated longer ago than this.
+	// Zero keeps them forever.
+	Retention time.Duration
+	// ArchiveDir holds rotated segments and the manifest (default: the
+	// log's directory). It must be on the same filesystem as the log.
+	ArchiveDir string
+	// BundleDir, when set together with BundleKey, receives a signed tar
+	// bundle of each archived segment for offload. The bundle holds the
+	// segment and its manifest entry; <bundle>.sig is an ASN.1 ECDSA
+	// signature over its SHA-256, checkable with
+	// openssl dgst -sha256 -verify pub.pem -signature <bundle>.sig <bundle>
+	BundleDir string
+	BundleKey *ecdsa.PrivateKey
+}
+
+// ArchiveManifest lists the archived segments of an audit log.
+type ArchiveManifest struct {
+	Log      string           `json:"log"`
+	Segments []ArchiveSegment `json:"segments"`
+}
+
+// ArchiveSegment describes one rotated, compressed segment.
+type ArchiveSegment struct {
+	// File is the segment's name within the archive directory.
+	File    string `json:"file"`
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+	Entries int    `json:"entries"`
+	// FirstSeq, LastSeq, and LastMAC locate the segment in the hash chain
+	// when chaining is enabled.
+	FirstSeq  uint64 `json:"first_seq,omitempty"`
+	LastSeq   uint64 `json:"last_seq,omitempty"`
+	LastMAC   string `json:"last_mac,omitempty"`
+	RotatedAt string `json:"rotated_at"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// rotator is the rotation state of an AuditLogger. size and opened are
+// guarded by AuditLogger.mu; the archive queue by queueMu.
+type rotator struct {
+	cfg    RotationConfig
+	path   string
+	stem   string // log file name without extension
+	ext    string
+	size   int64
+	opened time.Time
+
+	// Rotated segments are archived in order by a single worker.
+	queueMu sync.Mutex
+	queue   []rotatedSegment
+	running bool
+	pending sync.WaitGroup
+}
+
+type rotatedSegment struct {
+	path      string
+	rotatedAt time.Time
+}
+
+// WithRotation enables audit log rotation and retention.
+func WithRotation(cfg RotationConfig) Option {
+	return func(al *AuditLogger) {
+		if cfg.MaxSize < 0 || cfg.MaxAge < 0 || cfg.Retention < 0 {
+			al.optErr = errors.New("audit: rotation limits must not be negative")
+			return
+		}
+		if (cfg.BundleDir == "") != (cfg.BundleKey == nil) {
+			al.optErr = errors.New("audit: bundle dir and bundle key must be set together")
+			return
+		}
+		path := al.FilePath()
+		if cfg.ArchiveDir == "" {
+			cfg.ArchiveDir = filepath.Dir(path)
+		}
+		for _, dir := range []string{cfg.ArchiveDir, cfg.BundleDir} {
+			if dir == "" {
+				continue
+			}
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				al.optErr = fmt.Errorf("audit: create archive dir: %w", err)
+				return
+			}
+		}
+		var size int64
+		if info, err := al.file.Stat(); err == nil {
+			size = info.Size()
+		}
+		ext := filepath.Ext(path)
+		r := &rotator{
+			cfg:    cfg,
+			path:   path,
+			stem:   strings.TrimSuffix(filepath.Base(path), ext),
+			ext:    ext,
+			size:   size,
+			opened: time.Now(),
+		}
+		al.rotation = r
+
+		// Finish segments left uncompressed by an earlier run and apply
+		// retention.
+		r.enqueue(r.leftovers()...)
+	}
+}
+
+// LoadBundleKey reads a PEM-encoded ECDSA private key ("EC PRIVATE KEY" or
+// PKCS#8 "PRIVATE KEY") for signing archive bundles.
+func LoadBundleKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: read bundle key: %w", err)
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("audit: no ECDSA private key found in %s", path)
+		}
+		switch block.Type {
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("audit: parse bundle key: %w", err)
+			}
+			key, ok := parsed.(*ecdsa.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("audit: bundle key is %T, want ECDSA", parsed)
+			}
+			return key, nil
+		}
+	}
+}
+
+// ManifestPath returns the archive manifest of an audit log.
+func ManifestPath(archiveDir, logPath string) string {
+	base := filepath.Base(logPath)
+	return filepath.Join(archiveDir, strings.TrimSuffix(base, filepath.Ext(base))+".manifest.json")
+}
+
+// rotationDue reports whether the log should be rotated. Called with al.mu
+// held.
+func (r *rotator) rotationDue(now time.Time) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.cfg.MaxSize > 0 && r.size >= r.cfg.MaxSize {
+		return true
+	}
+	return r.cfg.MaxAge > 0 && now.Sub(r.opened) >= r.cfg.MaxAge
+}
+
+// rotate moves the current log into the archive directory, reopens an
+// empty log at the same path, and archives the old segment in the
+// background. On failure the current file is kept. Called with al.mu held.
+func (al *AuditLogger) rotate(now time.Time) error {
+	r := al.rotation
+	if al.chain != nil {
+		// Seal the outgoing segment's chain head.
+		if err := al.writeCheckpoint(now); err != nil {
+			return err
+		}
+	}
+
+	segment := r.segmentPath(now)
+	if err := os.Rename(r.path, segment); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		if rerr := os.Rename(segment, r.path); rerr != nil {
+			return fmt.Errorf("%w (and restoring %s failed: %v)", err, r.path, rerr)
+		}
+		return err
+	}
+	old := al.file
+	al.file = f
+	_ = old.Close()
+	r.size, r.opened = 0, now
+
+	if c := al.chain; c != nil {
+		// The new file continues the chain from the old file's head.
+		c.baseSeq, c.baseMAC = c.seq, c.head
+		c.verified = time.Time{}
+		if err := al.writeCheckpoint(now); err != nil {
+			fmt.Fprintf(os.Stderr, "audit: write checkpoint: %v\n", err)
+		}
+	}
+
+	r.enqueue(rotatedSegment{path: segment, rotatedAt: now})
+	return nil
+}
+
+// enqueue queues segments for archival, starting the worker if needed. An
+// empty call still runs a retention pass.
+func (r *rotator) enqueue(segments ...rotatedSegment) {
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	r.queue = append(r.queue, segments...)
+	if r.running {
+		return
+	}
+	r.running = true
+	r.pending.Add(1)
+	go r.drain()
+}
+
+// drain archives queued segments in rotation order, then applies retention.
+func (r *rotator) drain() {
+	defer r.pending.Done()
+	for {
+		r.queueMu.Lock()
+		if len(r.queue) == 0 {
+			r.running = false
+			r.queueMu.Unlock()
+			break
+		}
+		seg := r.queue[0]
+		r.queue = r.queue[1:]
+		r.queueMu.Unlock()
+
+		if err := r.archive(seg.path, seg.rotatedAt); err != nil {
+			fmt.Fprintf(os.Stderr, "audit: archive %s: %v\n", seg.path, err)
+		}
+	}
+	r.applyRetention(time.Now())
+}
+
+// segmentPath returns an unused archive path for a segment rotated at now.
+func (r *rotator) segmentPath(now time.Time) string {
+	name := r.stem + "-" + now.UTC().Format(segmentTimeFormat)
+	path := filepath.Join(r.cfg.ArchiveDir, name+r.ext)
+	for i := 1; fileExists(path) || fileExists(path+".gz"); i++ {
+		path = filepath.Join(r.cfg.ArchiveDir, fmt.Sprintf("%s-%d%s", name, i, r.ext))
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// archive compresses a rotated segment, records it in the manifest, and
+// writes its offload bundle. Called only from the archive worker.
+func (r *rotator) archive(segment string, rotatedAt time.Time) error {
+	entry, err := compressSegment(segment)
+	if err != nil {
+		return err
+	}
+	entry.RotatedAt = rotatedAt.UTC().Format(time.RFC3339)
+
+	manifest, err := r.readManifest()
+	if err != nil {
+		return err
+	}
+	manifest.Segments = append(manifest.Segments, *entry)
+	if err := r.writeManifest(manifest); err != nil {
+		return err
+	}
+	if r.cfg.BundleKey != nil {
+		if err := r.writeBundle(*entry); err != nil {
+			return fmt.Errorf("bundle: %w", err)
+		}
+	}
+	return nil
+}
+
+// compressSegment gzips a segment to <segment>.gz, removes the original,
+// and describes the result.
+func compressSegment(segment string) (*ArchiveSegment, error) {
+	in, err := os.Open(segment)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	gzPath := segment + ".gz"
+	tmp := gzPath + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	sum := sha256.New()
+	counted := &countingWriter{w: io.MultiWriter(out, sum)}
+	zw := gzip.NewWriter(counted)
+	entry := &ArchiveSegment{File: filepath.Base(gzPath)}
+
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := zw.Write(line); werr != nil {
+				out.Close()
+				return nil, werr
+			}
+			entry.noteLine(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, gzPath); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(segment); err != nil {
+		return nil, err
+	}
+	entry.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	entry.Size = counted.n
+	return entry, nil
+}
+
+// noteLine counts a segment line and tracks its chain position.
+func (s *ArchiveSegment) noteLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	s.Entries++
+	var evt struct {
+		Seq uint64 `json:"seq"`
+		MAC string `json:"mac"`
+	}
+	if json.Unmarshal(line, &evt) != nil || evt.MAC == "" {
+		return
+	}
+	if s.FirstSeq == 0 {
+		s.FirstSeq = evt.Seq
+	}
+	s.LastSeq, s.LastMAC = evt.Seq, evt.MAC
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// leftovers finds segments that were rotated but not archived, e.g.
+// because the process stopped.
+func (r *rotator) leftovers() []rotatedSegment {
+	matches, _ := filepath.Glob(filepath.Join(r.cfg.ArchiveDir, r.stem+"-*"+r.ext))
+	sort.Strings(matches)
+	var segments []rotatedSegment
+	for _, path := range matches {
+		if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tmp") || path == r.path {
+			continue
+		}
+		rotatedAt := time.Now()
+		if info, err := os.Stat(path); err == nil {
+			rotatedAt = info.ModTime()
+		}
+		segments = append(segments, rotatedSegment{path: path, rotatedAt: rotatedAt})
+	}
+	return segments
+}
+
+// applyRetention deletes archived segments past the retention period and
+// marks them deleted in the manifest. Called only from the archive worker.
+func (r *rotator) applyRetention(now time.Time) {
+	if r.cfg.Retention <= 0 {
+		return
+	}
+	manifest, err := r.readManifest()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit: retention: %v\n", err)
+		return
+	}
+	changed := false
+	for i := range manifest.Segments {
+		s := &manifest.Segments[i]
+		rotated, err := time.Parse(time.RFC3339, s.RotatedAt)
+		if s.DeletedAt != "" || err != nil || now.Sub(rotated) < r.cfg.Retention {
+			continue
+		}
+		err = os.Remove(filepath.Join(r.cfg.ArchiveDir, s.File))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "audit: retention: %v\n", err)
+			continue
+		}
+		s.DeletedAt = now.UTC().Format(time.RFC3339)
+		changed = true
+	}
+	if changed {
+		if err := r.writeManifest(manifest); err != nil {
+			fmt.Fprintf(os.Stderr, "audit: retention: %v\n", err)
+		}
+	}
+}
+
+func (r *rotator) readManifest() (*ArchiveManifest, error) {
+	manifest := &ArchiveManifest{Log: r.path}
+	data, err := os.ReadFile(ManifestPath(r.cfg.ArchiveDir, r.path))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (r *rotator) writeManifest(manifest *ArchiveManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ManifestPath(r.cfg.ArchiveDir, r.path), append(data, '\n'))
+}
+
+// writeBundle writes a tar of a segment and its manifest entry to the
+// bundle directory, with a detached ECDSA signature. The signature is
+// written first so a shipper watching for *.tar never sees an unsigned
+// bundle.
+func (r *rotator) writeBundle(entry ArchiveSegment) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	segment, err := os.ReadFile(filepath.Join(r.cfg.ArchiveDir, entry.File))
+	if err != nil {
+		return err
+	}
+	modTime, _ := time.Parse(time.RFC3339, entry.RotatedAt)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"manifest.json", append(meta, '\n')},
+		{entry.File, segment},
+	} {
+		hdr := &tar.Header{Name: f.name, Mode: 0640, Size: int64(len(f.data)), ModTime: modTime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(buf.Bytes())
+	sig, err := ecdsa.SignASN1(rand.Reader, r.cfg.BundleKey, digest[:])
+	if err != nil {
+		return err
+	}
+	bundle := filepath.Join(r.cfg.BundleDir, strings.TrimSuffix(entry.File, ".gz")+".tar")
+	if err := writeFileAtomic(bundle+".sig", sig); err != nil {
+		return err
+	}
+	return writeFileAtomic(bundle, buf.Bytes())
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
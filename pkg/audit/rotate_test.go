@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readManifest(t *testing.T, archiveDir, logPath string) ArchiveManifest {
+	t.Helper()
+	data, err := os.ReadFile(ManifestPath(archiveDir, logPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m ArchiveManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRotationBySizeKeepsChain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.json")
+	archiveDir := filepath.Join(dir, "archive")
+	opts := []Option{
+		WithHashChain(ChainConfig{Key: testChainKey}),
+		WithRotation(RotationConfig{MaxSize: 600, ArchiveDir: archiveDir}),
+	}
+	al, err := NewAuditLogger(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		al.Log(AuditEvent{EventType: "system_event", Severity: "info", Actor: "system", Action: "tick"})
+	}
+	if err := al.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := readManifest(t, archiveDir, path)
+	if len(m.Segments) < 2 {
+		t.Fatalf("segments = %+v, want at least 2", m.Segments)
+	}
+	var archived int
+	var lastSeq uint64
+	for _, s := range m.Segments {
+		data, err := os.ReadFile(filepath.Join(archiveDir, s.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != s.SHA256 || int64(len(data)) != s.Size {
+			t.Errorf("%s: manifest hash/size mismatch", s.File)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, _ := io.ReadAll(zr)
+		if n := strings.Count(string(plain), "\n"); n != s.Entries {
+			t.Errorf("%s: %d lines, manifest says %d", s.File, n, s.Entries)
+		}
+		if s.FirstSeq != lastSeq+1 {
+			t.Errorf("%s starts at seq %d, want %d", s.File, s.FirstSeq, lastSeq+1)
+		}
+		archived += s.Entries
+		lastSeq = s.LastSeq
+	}
+
+	// The active file picks the chain up where the last segment ended,
+	// including after a restart.
+	al, err = NewAuditLogger(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "restarted"})
+	if got := al.RecentEvents(1)[0].Seq; got != 11 {
+		t.Errorf("seq after restart = %d, want 11", got)
+	}
+	al.Close()
+	report, err := VerifyLog(path, testChainKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.LastSeq != 11 || archived+report.Entries != 11 {
+		t.Errorf("active log report = %+v, archived %d", report, archived)
+	}
+}
+
+func TestRotationByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.json")
+	al, err := NewAuditLogger(path, WithRotation(RotationConfig{MaxAge: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "first"})
+	al.mu.Lock()
+	al.rotation.opened = time.Now().Add(-2 * time.Hour)
+	al.mu.Unlock()
+	al.Log(AuditEvent{EventType: "system_event", Action: "second"})
+	al.Close()
+
+	m := readManifest(t, filepath.Dir(path), path)
+	if len(m.Segments) != 1 || m.Segments[0].Entries != 2 {
+		t.Errorf("segments = %+v", m.Segments)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("active log not reset: %v", err)
+	}
+}
+
+func TestRotationRetentionAndLeftovers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.json")
+
+	old := time.Now().Add(-48 * time.Hour).UTC()
+	expired := "audit-" + old.Format(segmentTimeFormat) + ".json.gz"
+	if err := os.WriteFile(filepath.Join(dir, expired), []byte("old"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := json.Marshal(ArchiveManifest{Log: path, Segments: []ArchiveSegment{
+		{File: expired, RotatedAt: old.Format(time.RFC3339)},
+	}})
+	if err := os.WriteFile(ManifestPath(dir, path), manifest, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	// A segment rotated but never compressed, e.g. after a crash.
+	leftover := "audit-" + time.Now().UTC().Format(segmentTimeFormat) + ".json"
+	if err := os.WriteFile(filepath.Join(dir, leftover), []byte("{\"action\":\"a\"}\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	al, err := NewAuditLogger(path, WithRotation(RotationConfig{Retention: 24 * time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Close()
+
+	m := readManifest(t, dir, path)
+	if len(m.Segments) != 2 || m.Segments[0].DeletedAt == "" || m.Segments[1].File != leftover+".gz" {
+		t.Fatalf("segments = %+v", m.Segments)
+	}
+	if _, err := os.Stat(filepath.Join(dir, expired)); !os.IsNotExist(err) {
+		t.Errorf("expired segment not deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, leftover)); !os.IsNotExist(err) {
+		t.Errorf("leftover segment not compressed: %v", err)
+	}
+}
+
+func TestRotationSignedBundle(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "bundle.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBundleKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "audit.json")
+	bundleDir := filepath.Join(dir, "offload")
+	al, err := NewAuditLogger(path, WithRotation(RotationConfig{MaxSize: 1, BundleDir: bundleDir, BundleKey: loaded}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al.Log(AuditEvent{EventType: "system_event", Action: "started"})
+	al.Close()
+
+	bundles, _ := filepath.Glob(filepath.Join(bundleDir, "*.tar"))
+	if len(bundles) != 1 {
+		t.Fatalf("bundles = %v", bundles)
+	}
+	data, err := os.ReadFile(bundles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := os.ReadFile(bundles[0] + ".sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig) {
+		t.Error("bundle signature does not verify")
+	}
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 2 || names[0] != "manifest.json" || !strings.HasSuffix(names[1], ".json.gz") {
+		t.Errorf("bundle contents = %v", names)
+	}
+
+	if _, err := NewAuditLogger(filepath.Join(dir, "other.json"), WithRotation(RotationConfig{BundleDir: bundleDir})); err == nil {
+		t.Error("expected error for bundle dir without key")
+	}
+}
@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Sink is a destination for audit events. The logger calls Write for
+// every event, in order, with its lock held, so Write must not block:
+// sinks that deliver over the network queue events and send them in the
+// background. Close flushes or persists anything still queued.
+type Sink interface {
+	Write(evt AuditEvent) error
+	Close() error
+}
+
+// WithSink adds a sink that receives every event after the audit log file.
+func WithSink(s Sink) Option {
+	return func(al *AuditLogger) {
+		al.sinks = append(al.sinks, s)
+	}
+}
+
+// forwarder is implemented by sinks that send events off the host.
+type forwarder interface {
+	// encrypted reports whether events are protected in transit.
+	encrypted() bool
+	// droppedCount returns the number of events lost because the sink's
+	// queue and spool were full.
+	droppedCount() int64
+}
+
+// ForwardingActive returns true if any sink forwards events off the host.
+func (al *AuditLogger) ForwardingActive() bool {
+	for _, s := range al.sinks {
+		if _, ok := s.(forwarder); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ForwardingEncrypted returns true if events are forwarded and every
+// forwarding sink uses TLS.
+func (al *AuditLogger) ForwardingEncrypted() bool {
+	active := false
+	for _, s := range al.sinks {
+		if f, ok := s.(forwarder); ok {
+			if !f.encrypted() {
+				return false
+			}
+			active = true
+		}
+	}
+	return active
+}
+
+// ForwardingDropped returns the number of events forwarding sinks have
+// lost because their queues and spools were full.
+func (al *AuditLogger) ForwardingDropped() int64 {
+	var n int64
+	for _, s := range al.sinks {
+		if f, ok := s.(forwarder); ok {
+			n += f.droppedCount()
+		}
+	}
+	return n
+}
+
+// fileSink writes the JSON-lines audit log, maintaining the hash chain and
+// rotation. It is always the logger's first sink.
+type fileSink struct {
+	al *AuditLogger
+}
+
+// Write appends evt, already stamped by the hash chain, to the log file.
+func (s fileSink) Write(evt AuditEvent) error {
+	al := s.al
+	if al.file == nil {
+		return nil
+	}
+	line, err := al.encodeLine(evt)
+	if err != nil {
+		return err
+	}
+	n, err := al.file.Write(append(line, '\n'))
+	al.dirty = true
+	now := time.Now()
+	if al.chain != nil {
+		al.maybeCheckpoint(now)
+	}
+	if r := al.rotation; r != nil {
+		r.size += int64(n)
+		if r.rotationDue(now) {
+			al.syncBeforeClose()
+			if rerr := al.rotate(now); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+	}
+	return err
+}
+
+// encodeLine renders evt as a log file line. Chained entries are sealed
+// with their MAC so that the line is exactly what the MAC covers.
+func (al *AuditLogger) encodeLine(evt AuditEvent) ([]byte, error) {
+	if al.chain != nil {
+		mac := evt.MAC
+		evt.MAC = ""
+		body, err := json.Marshal(evt)
+		if err != nil {
+			return nil, err
+		}
+		return sealLine(body, mac), nil
+	}
+	if al.format != nil {
+		return al.format.Format(evt)
+	}
+	return json.Marshal(evt)
+}
+
+// Close writes a final checkpoint, closes the file, and waits for rotated
+// segments to be archived.
+func (s fileSink) Close() error {
+	al := s.al
+	var errs []error
+	if al.chain != nil && al.file != nil && al.chain.sinceCheckpoint > 0 {
+		if err := al.writeCheckpoint(time.Now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if al.file != nil {
+		al.syncBeforeClose()
+		if err := al.file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Let background archival of rotated segments finish.
+	if al.rotation != nil {
+		al.rotation.pending.Wait()
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// syncBeforeClose fsyncs unsynced writes before the log file is closed or
+// rotated, unless the policy is FsyncNone.
+func (al *AuditLogger) syncBeforeClose() {
+	if al.dirty && al.fsync != FsyncNone {
+		if err := al.file.Sync(); err != nil {
+			fmt.Fprintf(os.Stderr, "audit: fsync: %v\n", err)
+		}
+		al.dirty = false
+	}
+}
+
+// legacySyslogSink forwards events through log/syslog (see WithSyslog).
+type legacySyslogSink struct {
+	w  syslogWriter
+	al *AuditLogger
+}
+
+// Write sends evt at the syslog severity matching its own. Delivery errors
+// are ignored; log/syslog reconnects on the next write.
+func (s legacySyslogSink) Write(evt AuditEvent) error {
+	var line []byte
+	if s.al.format != nil {
+		line, _ = s.al.format.Format(evt)
+	} else {
+		line, _ = json.Marshal(evt)
+	}
+	switch evt.Severity {
+	case "critical":
+		_ = s.w.Crit(string(line))
+	case "warning":
+		_ = s.w.Warning(string(line))
+	default:
+		_ = s.w.Info(string(line))
+	}
+	return nil
+}
+
+func (s legacySyslogSink) Close() error { return s.w.Close() }
+
+func (legacySyslogSink) encrypted() bool     { return false }
+func (legacySyslogSink) droppedCount() int64 { return 0 }
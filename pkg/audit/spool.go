@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSpoolBytes caps a network sink's spool file.
+const DefaultSpoolBytes = 64 << 20
+
+// spoolReplayBatch is how many spooled messages are handed to a sink at a
+// time during replay.
+const spoolReplayBatch = 100
+
+// spool is a file of octet-counted messages that a network sink could not
+// deliver. The sink replays it on start and whenever its collector is
+// reachable again. Replay moves the file aside to path + ".replay", so that
+// new messages can still be spooled, and removes it only once every
+// message in it has been delivered.
+type spool struct {
+	path    string
+	max     int64
+	mu      sync.Mutex
+	waiting atomic.Bool // the spool or replay file may hold messages
+}
+
+// newSpool returns a spool at path, or nil if path is empty.
+func newSpool(path string, max int64) *spool {
+	if path == "" {
+		return nil
+	}
+	if max <= 0 {
+		max = DefaultSpoolBytes
+	}
+	s := &spool{path: path, max: max}
+	for _, p := range []string{path, s.replayPath()} {
+		if _, err := os.Stat(p); err == nil {
+			s.waiting.Store(true)
+		}
+	}
+	return s
+}
+
+func (s *spool) replayPath() string { return s.path + ".replay" }
+
+// pending reports whether there are spooled messages to replay.
+func (s *spool) pending() bool {
+	return s != nil && s.waiting.Load()
+}
+
+// append adds msgs to the spool.
+func (s *spool) append(msgs [][]byte) error {
+	if s == nil {
+		return errors.New("no spool configured")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		framed := append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		if size+int64(buf.Len()+len(framed)) > s.max {
+			return fmt.Errorf("spool %s full", s.path)
+		}
+		buf.Write(framed)
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.waiting.Store(true)
+	return nil
+}
+
+// replay hands the spooled messages to send in batches, oldest first. send
+// returns how many of the batch it delivered; a short count stops the
+// replay, and the undelivered messages stay spooled. Messages are removed
+// only after send has delivered them, so a crash during replay sends them
+// again. Each call replays one file: a replay left unfinished by an earlier
+// call, or else the current spool. It reports whether that file was
+// delivered in full.
+func (s *spool) replay(send func([][]byte) int) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	msgs, err := s.takeReplay()
+	if len(msgs) == 0 {
+		if err != nil {
+			// Retry after the next append rather than spin on the error.
+			s.waiting.Store(false)
+		}
+		return true, err
+	}
+	for len(msgs) > 0 {
+		batch := msgs[:min(len(msgs), spoolReplayBatch)]
+		n := send(batch)
+		msgs = msgs[n:]
+		if n < len(batch) {
+			// Keep what is left for the next replay.
+			if werr := writeSpoolFile(s.replayPath(), msgs); werr != nil {
+				err = errors.Join(err, werr)
+			}
+			return false, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rerr := os.Remove(s.replayPath()); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+		return true, errors.Join(err, rerr)
+	}
+	if _, serr := os.Stat(s.path); errors.Is(serr, os.ErrNotExist) {
+		s.waiting.Store(false)
+	}
+	return true, err
+}
+
+// takeReplay returns the messages of the replay file, first moving the
+// spool there if no earlier replay is unfinished.
+func (s *spool) takeReplay() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(s.replayPath()); errors.Is(err, os.ErrNotExist) {
+		err := os.Rename(s.path, s.replayPath())
+		if errors.Is(err, os.ErrNotExist) {
+			s.waiting.Store(false)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.readReplay()
+}
+
+// readReplay parses the replay file. A damaged file is kept for
+// inspection as path + ".corrupt", and the messages before the damage are
+// replayed. Called with s.mu held.
+func (s *spool) readReplay() ([][]byte, error) {
+	f, err := os.Open(s.replayPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var msgs [][]byte
+	r := bufio.NewReader(f)
+	for {
+		lenStr, err := r.ReadString(' ')
+		if err == io.EOF && lenStr == "" {
+			return msgs, nil
+		}
+		n, perr := strconv.Atoi(strings.TrimSuffix(lenStr, " "))
+		if err == nil && perr == nil && n > 0 {
+			msg := make([]byte, n)
+			if _, err = io.ReadFull(r, msg); err == nil {
+				msgs = append(msgs, msg)
+				continue
+			}
+		}
+		f.Close()
+		corrupt := s.path + ".corrupt"
+		if err := os.Rename(s.replayPath(), corrupt); err != nil {
+			return nil, err
+		}
+		if err := writeSpoolFile(s.replayPath(), msgs); err != nil {
+			return nil, err
+		}
+		return msgs, fmt.Errorf("spool damaged after %d messages, moved to %s", len(msgs), corrupt)
+	}
+}
+
+// writeSpoolFile atomically replaces path with msgs, or removes it when
+// msgs is empty.
+func writeSpoolFile(path string, msgs [][]byte) error {
+	if len(msgs) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		buf.WriteString(strconv.Itoa(len(msg)) + " ")
+		buf.Write(msg)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -17,6 +17,6 @@ func WithSyslog(network, addr string) Option {
 			fmt.Fprintf(os.Stderr, "audit: syslog dial %s/%s failed: %v\n", network, addr, err)
 			return
 		}
-		al.syslogW = w
+		al.sinks = append(al.sinks, legacySyslogSink{w: w, al: al})
 	}
 }
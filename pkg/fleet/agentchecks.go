@@ -1,10 +1,13 @@
 package fleet
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
 )
@@ -18,26 +21,91 @@ func NewAgentChecks() *AgentChecks {
 	return &AgentChecks{}
 }
 
+// agentCheck pairs a check's item identity with the function that
+// evaluates it, so a check that never returns can still be reported. run
+// must stop, and kill any command it started, when its context is done.
+type agentCheck struct {
+	id       string
+	name     string
+	severity string
+	run      func(ctx context.Context) compliance.ChecklistItem
+}
+
+// checks returns the agent checks in report order.
+func (a *AgentChecks) checks() []agentCheck {
+	return []agentCheck{
+		{"ag-fips", "OS FIPS Mode", "critical", a.checkOSFIPSMode},
+		{"ag-os", "Operating System", "info", a.checkOSType},
+		{"ag-disk", "Disk Encryption", "high", a.checkDiskEncryption},
+		{"ag-mdm", "MDM Enrollment", "medium", a.checkMDMEnrollment},
+		{"ag-warp", "Cloudflare WARP", "medium", a.checkWARPInstalled},
+		{"ag-tls", "TLS Capabilities", "high", a.checkTLSCapabilities},
+	}
+}
+
 // RunChecks performs all agent checks and returns a compliance section.
 func (a *AgentChecks) RunChecks() compliance.Section {
-	items := []compliance.ChecklistItem{
-		a.checkOSFIPSMode(),
-		a.checkOSType(),
-		a.checkDiskEncryption(),
-		a.checkMDMEnrollment(),
-		a.checkWARPInstalled(),
-		a.checkTLSCapabilities(),
-	}
+	return a.RunChecksContext(context.Background(), 0)
+}
 
+// RunChecksContext performs all agent checks, giving each one at most
+// timeout to complete (0 = no limit). A check that times out or whose
+// context is cancelled is reported with unknown status rather than
+// blocking the whole section.
+func (a *AgentChecks) RunChecksContext(ctx context.Context, timeout time.Duration) compliance.Section {
 	return compliance.Section{
 		ID:          "agent-posture",
 		Name:        "Endpoint FIPS Posture",
 		Description: "FIPS compliance checks for this endpoint",
-		Items:       items,
+		Items:       runAgentChecks(ctx, timeout, a.checks()),
+	}
+}
+
+// runAgentChecks evaluates each check in order, bounding each by timeout.
+func runAgentChecks(ctx context.Context, timeout time.Duration, checks []agentCheck) []compliance.ChecklistItem {
+	items := make([]compliance.ChecklistItem, 0, len(checks))
+	for _, c := range checks {
+		if timeout <= 0 && ctx.Done() == nil {
+			items = append(items, c.run(ctx))
+			continue
+		}
+
+		checkCtx := ctx
+		cancel := func() {}
+		if timeout > 0 {
+			checkCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		// Buffered so the check goroutine can finish and exit after a
+		// timeout; cancelling checkCtx kills the command it is waiting on.
+		done := make(chan compliance.ChecklistItem, 1)
+		go func(run func(context.Context) compliance.ChecklistItem) {
+			done <- run(checkCtx)
+		}(c.run)
+
+		select {
+		case item := <-done:
+			items = append(items, item)
+		case <-checkCtx.Done():
+			reason := "check cancelled"
+			if ctx.Err() == nil {
+				reason = fmt.Sprintf("check timed out after %s", timeout)
+			}
+			items = append(items, compliance.ChecklistItem{
+				ID:                 c.id,
+				Name:               c.name,
+				Status:             compliance.StatusUnknown,
+				Severity:           c.severity,
+				VerificationMethod: compliance.VerifyDirect,
+				Remediation:        reason,
+			})
+		}
+		cancel()
 	}
+	return items
 }
 
-func (a *AgentChecks) checkOSType() compliance.ChecklistItem {
+func (a *AgentChecks) checkOSType(ctx context.Context) compliance.ChecklistItem {
 	item := compliance.ChecklistItem{
 		ID:                 "ag-os",
 		Name:               "Operating System",
@@ -53,7 +121,7 @@ func (a *AgentChecks) checkOSType() compliance.ChecklistItem {
 	return item
 }
 
-func (a *AgentChecks) checkOSFIPSMode() compliance.ChecklistItem {
+func (a *AgentChecks) checkOSFIPSMode(ctx context.Context) compliance.ChecklistItem {
 	item := compliance.ChecklistItem{
 		ID:                 "ag-fips",
 		Name:               "OS FIPS Mode",
@@ -84,7 +152,7 @@ func (a *AgentChecks) checkOSFIPSMode() compliance.ChecklistItem {
 		item.Remediation = "macOS CommonCrypto is always active"
 	case "windows":
 		// Check Windows FIPS registry key
-		out, err := exec.Command("reg", "query",
+		out, err := exec.CommandContext(ctx, "reg", "query",
 			`HKLM\SYSTEM\CurrentControlSet\Control\Lsa\FIPSAlgorithmPolicy`,
 			"/v", "Enabled").Output()
 		if err != nil {
@@ -102,7 +170,7 @@ func (a *AgentChecks) checkOSFIPSMode() compliance.ChecklistItem {
 	return item
 }
 
-func (a *AgentChecks) checkDiskEncryption() compliance.ChecklistItem {
+func (a *AgentChecks) checkDiskEncryption(ctx context.Context) compliance.ChecklistItem {
 	item := compliance.ChecklistItem{
 		ID:                 "ag-disk",
 		Name:               "Disk Encryption",
@@ -118,7 +186,7 @@ func (a *AgentChecks) checkDiskEncryption() compliance.ChecklistItem {
 	case "linux":
 		// Check for LUKS devices
 		if _, err := exec.LookPath("lsblk"); err == nil {
-			out, err := exec.Command("lsblk", "-o", "TYPE", "--noheadings").Output()
+			out, err := exec.CommandContext(ctx, "lsblk", "-o", "TYPE", "--noheadings").Output()
 			if err == nil && strings.Contains(string(out), "crypt") {
 				item.Status = compliance.StatusPass
 				return item
@@ -137,14 +205,14 @@ func (a *AgentChecks) checkDiskEncryption() compliance.ChecklistItem {
 		item.Status = compliance.StatusWarning
 		item.Remediation = "No encrypted volumes detected. Enable LUKS encryption."
 	case "darwin":
-		out, err := exec.Command("fdesetup", "status").Output()
+		out, err := exec.CommandContext(ctx, "fdesetup", "status").Output()
 		if err == nil && strings.Contains(string(out), "FileVault is On") {
 			item.Status = compliance.StatusPass
 		} else {
 			item.Status = compliance.StatusFail
 		}
 	case "windows":
-		out, err := exec.Command("manage-bde", "-status", "C:").Output()
+		out, err := exec.CommandContext(ctx, "manage-bde", "-status", "C:").Output()
 		if err == nil && strings.Contains(string(out), "Fully Encrypted") {
 			item.Status = compliance.StatusPass
 		} else {
@@ -156,7 +224,7 @@ func (a *AgentChecks) checkDiskEncryption() compliance.ChecklistItem {
 	return item
 }
 
-func (a *AgentChecks) checkMDMEnrollment() compliance.ChecklistItem {
+func (a *AgentChecks) checkMDMEnrollment(ctx context.Context) compliance.ChecklistItem {
 	item := compliance.ChecklistItem{
 		ID:                 "ag-mdm",
 		Name:               "MDM Enrollment",
@@ -171,14 +239,14 @@ func (a *AgentChecks) checkMDMEnrollment() compliance.ChecklistItem {
 	switch runtime.GOOS {
 	case "darwin":
 		// Check for MDM enrollment profiles
-		out, err := exec.Command("profiles", "status", "-type", "enrollment").Output()
+		out, err := exec.CommandContext(ctx, "profiles", "status", "-type", "enrollment").Output()
 		if err == nil && (strings.Contains(string(out), "MDM") || strings.Contains(string(out), "enrolled")) {
 			item.Status = compliance.StatusPass
 		} else {
 			item.Status = compliance.StatusWarning
 		}
 	case "windows":
-		out, err := exec.Command("dsregcmd", "/status").Output()
+		out, err := exec.CommandContext(ctx, "dsregcmd", "/status").Output()
 		if err == nil && strings.Contains(string(out), "AzureAdJoined : YES") {
 			item.Status = compliance.StatusPass
 		} else {
@@ -201,7 +269,7 @@ func (a *AgentChecks) checkMDMEnrollment() compliance.ChecklistItem {
 	return item
 }
 
-func (a *AgentChecks) checkWARPInstalled() compliance.ChecklistItem {
+func (a *AgentChecks) checkWARPInstalled(ctx context.Context) compliance.ChecklistItem {
 	item := compliance.ChecklistItem{
 		ID:                 "ag-warp",
 		Name:               "Cloudflare WARP",
@@ -215,7 +283,7 @@ func (a *AgentChecks) checkWARPInstalled() compliance.ChecklistItem {
 
 	// Check if warp-cli is available
 	if _, err := exec.LookPath("warp-cli"); err == nil {
-		out, err := exec.Command("warp-cli", "status").Output()
+		out, err := exec.CommandContext(ctx, "warp-cli", "status").Output()
 		if err == nil && strings.Contains(string(out), "Connected") {
 			item.Status = compliance.StatusPass
 			return item
@@ -243,7 +311,7 @@ func (a *AgentChecks) checkWARPInstalled() compliance.ChecklistItem {
 	return item
 }
 
-func (a *AgentChecks) checkTLSCapabilities() compliance.ChecklistItem {
+func (a *AgentChecks) checkTLSCapabilities(ctx context.Context) compliance.ChecklistItem {
 	item := compliance.ChecklistItem{
 		ID:                 "ag-tls",
 		Name:               "TLS Capabilities",
@@ -1,7 +1,10 @@
 package fleet
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
 )
@@ -55,7 +58,7 @@ func TestAgentChecks_AllItemsHaveValidStatus(t *testing.T) {
 
 func TestAgentChecks_OSType_AlwaysPass(t *testing.T) {
 	a := NewAgentChecks()
-	item := a.checkOSType()
+	item := a.checkOSType(context.Background())
 	if item.ID != "ag-os" {
 		t.Errorf("ID = %q, want ag-os", item.ID)
 	}
@@ -66,7 +69,7 @@ func TestAgentChecks_OSType_AlwaysPass(t *testing.T) {
 
 func TestAgentChecks_TLSCapabilities_AlwaysPass(t *testing.T) {
 	a := NewAgentChecks()
-	item := a.checkTLSCapabilities()
+	item := a.checkTLSCapabilities(context.Background())
 	if item.ID != "ag-tls" {
 		t.Errorf("ID = %q, want ag-tls", item.ID)
 	}
@@ -77,7 +80,7 @@ func TestAgentChecks_TLSCapabilities_AlwaysPass(t *testing.T) {
 
 func TestAgentChecks_OSSFIPSMode_ProducesValidStatus(t *testing.T) {
 	a := NewAgentChecks()
-	item := a.checkOSFIPSMode()
+	item := a.checkOSFIPSMode(context.Background())
 	if item.ID != "ag-fips" {
 		t.Errorf("ID = %q, want ag-fips", item.ID)
 	}
@@ -97,7 +100,7 @@ func TestAgentChecks_OSSFIPSMode_ProducesValidStatus(t *testing.T) {
 
 func TestAgentChecks_DiskEncryption_ProducesValidStatus(t *testing.T) {
 	a := NewAgentChecks()
-	item := a.checkDiskEncryption()
+	item := a.checkDiskEncryption(context.Background())
 	if item.ID != "ag-disk" {
 		t.Errorf("ID = %q, want ag-disk", item.ID)
 	}
@@ -108,7 +111,7 @@ func TestAgentChecks_DiskEncryption_ProducesValidStatus(t *testing.T) {
 
 func TestAgentChecks_WARPInstalled_ProducesValidStatus(t *testing.T) {
 	a := NewAgentChecks()
-	item := a.checkWARPInstalled()
+	item := a.checkWARPInstalled(context.Background())
 	if item.ID != "ag-warp" {
 		t.Errorf("ID = %q, want ag-warp", item.ID)
 	}
@@ -125,7 +128,7 @@ func TestAgentChecks_WARPInstalled_ProducesValidStatus(t *testing.T) {
 
 func TestAgentChecks_MDMEnrollment_ProducesValidStatus(t *testing.T) {
 	a := NewAgentChecks()
-	item := a.checkMDMEnrollment()
+	item := a.checkMDMEnrollment(context.Background())
 	if item.ID != "ag-mdm" {
 		t.Errorf("ID = %q, want ag-mdm", item.ID)
 	}
@@ -133,3 +136,97 @@ func TestAgentChecks_MDMEnrollment_ProducesValidStatus(t *testing.T) {
 		t.Errorf("severity = %q, want medium", item.Severity)
 	}
 }
+
+func TestRunAgentChecks_TimeoutReportsUnknown(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	checks := []agentCheck{
+		{"fast", "Fast Check", "high", func(context.Context) compliance.ChecklistItem {
+			return compliance.ChecklistItem{ID: "fast", Status: compliance.StatusPass}
+		}},
+		{"slow", "Slow Check", "critical", func(context.Context) compliance.ChecklistItem {
+			<-release
+			return compliance.ChecklistItem{ID: "slow", Status: compliance.StatusPass}
+		}},
+	}
+
+	start := time.Now()
+	items := runAgentChecks(context.Background(), 20*time.Millisecond, checks)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("runAgentChecks blocked for %v on a hung check", elapsed)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	if items[0].Status != compliance.StatusPass {
+		t.Errorf("fast check status = %q, want pass", items[0].Status)
+	}
+	slow := items[1]
+	if slow.ID != "slow" || slow.Name != "Slow Check" || slow.Severity != "critical" {
+		t.Errorf("timed-out item identity not preserved: %+v", slow)
+	}
+	if slow.Status != compliance.StatusUnknown {
+		t.Errorf("timed-out check status = %q, want unknown", slow.Status)
+	}
+	if !strings.Contains(slow.Remediation, "timed out") {
+		t.Errorf("timed-out check remediation = %q, want timeout reason", slow.Remediation)
+	}
+}
+
+func TestRunAgentChecks_CancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	block := make(chan struct{})
+	defer close(block)
+	checks := []agentCheck{
+		{"blocked", "Blocked", "high", func(context.Context) compliance.ChecklistItem {
+			<-block
+			return compliance.ChecklistItem{ID: "blocked", Status: compliance.StatusPass}
+		}},
+	}
+
+	items := runAgentChecks(ctx, time.Minute, checks)
+	if len(items) != 1 || items[0].Status != compliance.StatusUnknown {
+		t.Fatalf("expected one unknown item, got %+v", items)
+	}
+	if items[0].Remediation != "check cancelled" {
+		t.Errorf("remediation = %q, want check cancelled", items[0].Remediation)
+	}
+}
+
+func TestRunAgentChecks_TimeoutCancelsCheck(t *testing.T) {
+	exited := make(chan struct{})
+	checks := []agentCheck{
+		{"hung", "Hung Check", "high", func(ctx context.Context) compliance.ChecklistItem {
+			// Stands in for exec.CommandContext, which kills the process.
+			<-ctx.Done()
+			close(exited)
+			return compliance.ChecklistItem{ID: "hung", Status: compliance.StatusPass}
+		}},
+	}
+
+	items := runAgentChecks(context.Background(), 20*time.Millisecond, checks)
+	if items[0].Status != compliance.StatusUnknown {
+		t.Errorf("status = %q, want unknown", items[0].Status)
+	}
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("timed-out check was not cancelled; its goroutine leaks")
+	}
+}
+
+func TestAgentChecks_RunChecksContext_MatchesRunChecks(t *testing.T) {
+	a := NewAgentChecks()
+	section := a.RunChecksContext(context.Background(), 30*time.Second)
+
+	if section.ID != "agent-posture" {
+		t.Errorf("section ID = %q, want agent-posture", section.ID)
+	}
+	if len(section.Items) != len(a.checks()) {
+		t.Errorf("expected %d items, got %d", len(a.checks()), len(section.Items))
+	}
+}
@@ -0,0 +1,139 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrEnrollmentRejected is returned by RequestEnrollment when the controller
+// refuses the token (invalid, expired, or exhausted). Retrying will not help.
+var ErrEnrollmentRejected = errors.New("enrollment rejected")
+
+// AgentState is the enrollment identity an agent persists between runs, so
+// it only needs an enrollment token the first time it starts. The format
+// matches the enrollment.json written by scripts/provision-linux.sh.
+type AgentState struct {
+	NodeID        string    `json:"node_id"`
+	APIKey        string    `json:"api_key"`
+	ControllerURL string    `json:"controller_url"`
+	Role          NodeRole  `json:"role,omitempty"`
+	EnrolledAt    time.Time `json:"enrolled_at,omitempty"`
+}
+
+// LoadAgentState reads a state file written by SaveAgentState. It returns
+// an error wrapping os.ErrNotExist if the agent has not enrolled yet.
+func LoadAgentState(path string) (*AgentState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st AgentState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parse agent state %s: %w", path, err)
+	}
+	if st.NodeID == "" || st.APIKey == "" {
+		return nil, fmt.Errorf("agent state %s: missing node_id or api_key", path)
+	}
+	return &st, nil
+}
+
+// SaveAgentState atomically writes the state file with mode 0600. The API
+// key is a bearer credential, so the file must never be world-readable,
+// even briefly.
+func SaveAgentState(path string, st *AgentState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".agent-state-*")
+	if err != nil {
+		return fmt.Errorf("create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod state file: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close state file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RequestEnrollment redeems an enrollment token with the controller and
+// returns the resulting agent state. It is the client side of
+// POST /api/v1/fleet/enroll.
+func RequestEnrollment(ctx context.Context, client *http.Client, controllerURL string, req EnrollmentRequest) (*AgentState, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	controllerURL = strings.TrimRight(controllerURL, "/")
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", controllerURL+"/api/v1/fleet/enroll", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("enroll: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errBody)
+		if errBody.Error == "" {
+			errBody.Error = http.StatusText(resp.StatusCode)
+		}
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return nil, fmt.Errorf("%w: %s", ErrEnrollmentRejected, errBody.Error)
+		}
+		return nil, fmt.Errorf("enroll: controller returned %d: %s", resp.StatusCode, errBody.Error)
+	}
+
+	var enrolled EnrollmentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&enrolled); err != nil {
+		return nil, fmt.Errorf("enroll: decode response: %w", err)
+	}
+	if enrolled.NodeID == "" || enrolled.APIKey == "" {
+		return nil, fmt.Errorf("enroll: controller response missing node_id or api_key")
+	}
+
+	return &AgentState{
+		NodeID:        enrolled.NodeID,
+		APIKey:        enrolled.APIKey,
+		ControllerURL: controllerURL,
+		Role:          enrolled.Role,
+		EnrolledAt:    time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAgentState_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "agent-state.json")
+	want := &AgentState{NodeID: "n1", APIKey: "k1", ControllerURL: "https://ctrl:8080", Role: RoleServer}
+
+	if err := SaveAgentState(path, want); err != nil {
+		t.Fatalf("SaveAgentState: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("state file mode = %o, want 600", perm)
+	}
+
+	got, err := LoadAgentState(path)
+	if err != nil {
+		t.Fatalf("LoadAgentState: %v", err)
+	}
+	if got.NodeID != want.NodeID || got.APIKey != want.APIKey || got.ControllerURL != want.ControllerURL || got.Role != want.Role {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+
+	// Overwrite leaves no temp files behind.
+	want.APIKey = "k2"
+	if err := SaveAgentState(path, want); err != nil {
+		t.Fatalf("SaveAgentState overwrite: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("state dir has %d entries, want 1", len(entries))
+	}
+}
+
+func TestLoadAgentState_Missing(t *testing.T) {
+	_, err := LoadAgentState(filepath.Join(t.TempDir(), "none.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want ErrNotExist", err)
+	}
+}
+
+func TestLoadAgentState_Incomplete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	_ = os.WriteFile(path, []byte(`{"node_id":"n1"}`), 0600)
+	if _, err := LoadAgentState(path); err == nil {
+		t.Error("expected error for state without api_key")
+	}
+}
+
+func TestRequestEnrollment(t *testing.T) {
+	store := tempDB(t)
+	enrollment := NewEnrollment(store)
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/v1/fleet/enroll" {
+			http.NotFound(w, r)
+			return
+		}
+		var req EnrollmentRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		resp, err := enrollment.Enroll(r.Context(), req)
+		if err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	token, _ := enrollment.CreateToken(ctx, CreateTokenRequest{Role: RoleServer, MaxUses: 1, ExpiresIn: 3600})
+	svc := &ServiceRegistration{Name: "app", Host: "127.0.0.1", Port: 8080}
+
+	state, err := RequestEnrollment(ctx, nil, srv.URL+"/", EnrollmentRequest{Token: token.Token, Name: "origin-1", Service: svc})
+	if err != nil {
+		t.Fatalf("RequestEnrollment: %v", err)
+	}
+	if state.ControllerURL != srv.URL {
+		t.Errorf("ControllerURL = %q, want %q", state.ControllerURL, srv.URL)
+	}
+	if state.Role != RoleServer {
+		t.Errorf("Role = %q, want server", state.Role)
+	}
+	node, err := store.GetNodeByAPIKey(ctx, HashToken(state.APIKey))
+	if err != nil {
+		t.Fatalf("GetNodeByAPIKey: %v", err)
+	}
+	if node.ID != state.NodeID {
+		t.Errorf("node ID = %q, want %q", node.ID, state.NodeID)
+	}
+	if node.Service == nil || node.Service.Port != 8080 {
+		t.Errorf("Service = %+v, want registered service", node.Service)
+	}
+
+	// The token is single-use: a second attempt is rejected, not retried.
+	_, err = RequestEnrollment(ctx, nil, srv.URL, EnrollmentRequest{Token: token.Token, Name: "origin-2"})
+	if !errors.Is(err, ErrEnrollmentRejected) {
+		t.Errorf("err = %v, want ErrEnrollmentRejected", err)
+	}
+}
+
+func TestRequestEnrollment_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := RequestEnrollment(context.Background(), nil, srv.URL, EnrollmentRequest{Token: "t", Name: "n"})
+	if err == nil || errors.Is(err, ErrEnrollmentRejected) {
+		t.Errorf("err = %v, want retryable error", err)
+	}
+}
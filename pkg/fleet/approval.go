@@ -0,0 +1,49 @@
+package fleet
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors returned when approving a remediation request.
+var (
+	ErrNotAwaitingApproval = errors.New("remediation request is not awaiting approval")
+	ErrApprovalExpired     = errors.New("remediation approval window has expired")
+	ErrSelfApproval        = errors.New("requester cannot approve their own request")
+	ErrDuplicateApproval   = errors.New("approver has already approved this request")
+)
+
+// ErrNotPending is returned when a result is recorded for a request that is
+// not pending: one still awaiting approval, expired, or already finished.
+var ErrNotPending = errors.New("remediation request is not pending")
+
+// Approve records an approval on a request awaiting approval. Once
+// RequiredApprovals distinct approvers have signed off, the request becomes
+// pending and agents can pick it up. An approval after ApprovalExpiresAt
+// marks the request expired and returns ErrApprovalExpired.
+func (r *RemediationRequest) Approve(a RemediationApproval) error {
+	if r.Status != RemediationAwaitingApproval {
+		return ErrNotAwaitingApproval
+	}
+	if r.approvalExpired(a.ApprovedAt) {
+		r.Status = RemediationExpired
+		return ErrApprovalExpired
+	}
+	if a.Approver == r.RequestedBy {
+		return ErrSelfApproval
+	}
+	for _, prev := range r.Approvals {
+		if prev.Approver == a.Approver {
+			return ErrDuplicateApproval
+		}
+	}
+	r.Approvals = append(r.Approvals, a)
+	if len(r.Approvals) >= r.RequiredApprovals {
+		r.Status = RemediationPending
+	}
+	return nil
+}
+
+func (r *RemediationRequest) approvalExpired(now time.Time) bool {
+	return r.ApprovalExpiresAt != nil && now.After(*r.ApprovalExpiresAt)
+}
@@ -0,0 +1,53 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemediationRequest_Approve(t *testing.T) {
+	now := time.Now().UTC()
+	expires := now.Add(time.Hour)
+	req := &RemediationRequest{
+		Status:            RemediationAwaitingApproval,
+		RequestedBy:       "approver:alice",
+		RequiredApprovals: 2,
+		ApprovalExpiresAt: &expires,
+	}
+
+	if err := req.Approve(RemediationApproval{Approver: "approver:alice", ApprovedAt: now}); err != ErrSelfApproval {
+		t.Errorf("self approval err = %v, want ErrSelfApproval", err)
+	}
+	if err := req.Approve(RemediationApproval{Approver: "approver:bob", ApprovedAt: now}); err != nil {
+		t.Fatalf("first approval: %v", err)
+	}
+	if req.Status != RemediationAwaitingApproval {
+		t.Errorf("status after 1/2 approvals = %s", req.Status)
+	}
+	if err := req.Approve(RemediationApproval{Approver: "approver:bob", ApprovedAt: now}); err != ErrDuplicateApproval {
+		t.Errorf("duplicate approval err = %v, want ErrDuplicateApproval", err)
+	}
+	if err := req.Approve(RemediationApproval{Approver: "approver:carol", ApprovedAt: now}); err != nil {
+		t.Fatalf("second approval: %v", err)
+	}
+	if req.Status != RemediationPending || len(req.Approvals) != 2 {
+		t.Errorf("status = %s approvals = %v, want pending with 2", req.Status, req.Approvals)
+	}
+	if err := req.Approve(RemediationApproval{Approver: "approver:dave", ApprovedAt: now}); err != ErrNotAwaitingApproval {
+		t.Errorf("approval of released request err = %v, want ErrNotAwaitingApproval", err)
+	}
+}
+
+func TestRemediationRequest_ApproveExpired(t *testing.T) {
+	now := time.Now().UTC()
+	expires := now.Add(-time.Minute)
+	req := &RemediationRequest{
+		Status: RemediationAwaitingApproval, RequestedBy: "admin", RequiredApprovals: 1, ApprovalExpiresAt: &expires,
+	}
+	if err := req.Approve(RemediationApproval{Approver: "approver:bob", ApprovedAt: now}); err != ErrApprovalExpired {
+		t.Errorf("err = %v, want ErrApprovalExpired", err)
+	}
+	if req.Status != RemediationExpired || len(req.Approvals) != 0 {
+		t.Errorf("status = %s approvals = %v", req.Status, req.Approvals)
+	}
+}
@@ -123,6 +123,13 @@ func (e *Enrollment) Enroll(ctx context.Context, req EnrollmentRequest) (*Enroll
 		Version:       req.Version,
 		FIPSBackend:   req.FIPSBackend,
 	}
+	// Only server nodes expose an origin service for routing.
+	if token.Role == RoleServer && req.Service != nil {
+		if err := validateService(req.Service); err != nil {
+			return nil, err
+		}
+		node.Service = req.Service
+	}
 
 	apiKeyHash := hashToken(apiKey)
 	if err := e.store.CreateNode(ctx, node, apiKeyHash); err != nil {
@@ -144,10 +151,25 @@ func (e *Enrollment) Enroll(ctx context.Context, req EnrollmentRequest) (*Enroll
 	return &EnrollmentResponse{
 		NodeID:         nodeID,
 		APIKey:         apiKey,
+		Role:           token.Role,
 		ReportInterval: interval,
 	}, nil
 }
 
+// validateService checks that a service registration is routable.
+func validateService(svc *ServiceRegistration) error {
+	if svc.Name == "" {
+		return fmt.Errorf("service name is required")
+	}
+	if svc.Host == "" {
+		return fmt.Errorf("service host is required")
+	}
+	if svc.Port <= 0 || svc.Port > 65535 {
+		return fmt.Errorf("service port %d out of range", svc.Port)
+	}
+	return nil
+}
+
 // generateSecureToken generates a hex-encoded cryptographic random token.
 func generateSecureToken(bytes int) (string, error) {
 	b := make([]byte, bytes)
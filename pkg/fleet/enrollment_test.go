@@ -381,3 +381,43 @@ func TestHashToken(t *testing.T) {
 		t.Errorf("hash length = %d, want 64 (hex-encoded SHA-256)", len(h1))
 	}
 }
+
+func TestEnrollment_ServiceRegistration(t *testing.T) {
+	store := tempDB(t)
+	enrollment := NewEnrollment(store)
+	ctx := context.Background()
+
+	svc := &ServiceRegistration{Name: "app", Host: "10.0.0.5", Port: 8443, TLS: true}
+
+	serverTok, _ := enrollment.CreateToken(ctx, CreateTokenRequest{Role: RoleServer, MaxUses: 1, ExpiresIn: 3600})
+	resp, err := enrollment.Enroll(ctx, EnrollmentRequest{Token: serverTok.Token, Name: "origin-1", Service: svc})
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if resp.Role != RoleServer {
+		t.Errorf("Role = %q, want server", resp.Role)
+	}
+	node, _ := store.GetNode(ctx, resp.NodeID)
+	if node.Service == nil || *node.Service != *svc {
+		t.Errorf("Service = %+v, want %+v", node.Service, svc)
+	}
+
+	// Non-server roles do not register origin services.
+	clientTok, _ := enrollment.CreateToken(ctx, CreateTokenRequest{Role: RoleClient, MaxUses: 1, ExpiresIn: 3600})
+	resp, err = enrollment.Enroll(ctx, EnrollmentRequest{Token: clientTok.Token, Name: "laptop", Service: svc})
+	if err != nil {
+		t.Fatalf("Enroll client: %v", err)
+	}
+	node, _ = store.GetNode(ctx, resp.NodeID)
+	if node.Service != nil {
+		t.Errorf("client Service = %+v, want nil", node.Service)
+	}
+
+	// Invalid registrations are rejected.
+	badTok, _ := enrollment.CreateToken(ctx, CreateTokenRequest{Role: RoleServer, MaxUses: 1, ExpiresIn: 3600})
+	_, err = enrollment.Enroll(ctx, EnrollmentRequest{Token: badTok.Token, Name: "origin-2",
+		Service: &ServiceRegistration{Name: "app", Host: "10.0.0.6"}})
+	if err == nil {
+		t.Error("expected error for service without port")
+	}
+}
@@ -0,0 +1,295 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SiteLocal is the NodeFilter.Site value selecting only nodes enrolled
+// directly with this controller.
+const SiteLocal = "local"
+
+// FederationSync is pushed by a child controller to its parent. The child
+// authenticates as a node enrolled with the controller role; its node ID
+// at the parent is the site ID.
+type FederationSync struct {
+	SiteName string       `json:"site_name"`
+	Summary  FleetSummary `json:"summary"`
+
+	// Full marks Nodes as the child's complete node set; nodes the parent
+	// holds for this site that are not listed are dropped. Otherwise Nodes
+	// holds only nodes added or changed since the last sync.
+	Full    bool     `json:"full"`
+	Nodes   []Node   `json:"nodes,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+
+	// APIURL and APIToken let the parent proxy drill-down requests
+	// (node details, reports) to the child.
+	APIURL   string `json:"api_url,omitempty"`
+	APIToken string `json:"api_token,omitempty"`
+}
+
+// FederationSyncResponse is the parent's reply to a FederationSync.
+type FederationSyncResponse struct {
+	Status string `json:"status"`
+	// Resync asks the child to send a full sync next, e.g. because the
+	// parent has no baseline for a delta.
+	Resync bool `json:"resync,omitempty"`
+}
+
+// Site is a child controller federated into this controller.
+type Site struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	APIURL    string       `json:"api_url,omitempty"`
+	APIToken  string       `json:"-"`
+	Summary   FleetSummary `json:"summary"`
+	NodeCount int          `json:"node_count"`
+	LastSync  time.Time    `json:"last_sync"`
+}
+
+// MergeSummary adds other's counts into s.
+func MergeSummary(s *FleetSummary, other FleetSummary) {
+	if s.ByRole == nil {
+		s.ByRole = make(map[string]int)
+	}
+	if s.ByRegion == nil {
+		s.ByRegion = make(map[string]int)
+	}
+	s.Availability = mergeAvailability(s.Availability, s.TotalNodes, other.Availability, other.TotalNodes)
+	s.Flapping += other.Flapping
+	s.TotalNodes += other.TotalNodes
+	s.Online += other.Online
+	s.Degraded += other.Degraded
+	s.Offline += other.Offline
+	s.FullyCompliant += other.FullyCompliant
+	for k, v := range other.ByRole {
+		s.ByRole[k] += v
+	}
+	for k, v := range other.ByRegion {
+		s.ByRegion[k] += v
+	}
+}
+
+// MatchesFilter reports whether a node satisfies filter's role, region,
+// status, and site constraints.
+func (n *Node) MatchesFilter(filter NodeFilter) bool {
+	if filter.Role != "" && n.Role != filter.Role {
+		return false
+	}
+	if filter.Region != "" && n.Region != filter.Region {
+		return false
+	}
+	if filter.Status != "" && n.Status != filter.Status {
+		return false
+	}
+	switch filter.Site {
+	case "":
+	case SiteLocal:
+		return n.Site == ""
+	default:
+		return n.Site == filter.Site
+	}
+	return true
+}
+
+// Federator pushes this controller's fleet state to a parent controller:
+// a rolled-up FleetSummary plus the nodes whose compliance state changed
+// since the last successful push. Nodes federated from this controller's
+// own children are forwarded too, so federation can be nested.
+type Federator struct {
+	parentURL string
+	apiKey    string
+	siteName  string
+	apiURL    string
+	apiToken  string
+	store     Store
+	interval  time.Duration
+	fullEvery int
+	logger    *log.Logger
+	client    *http.Client
+
+	sent      map[string][32]byte // node ID -> digest acknowledged by the parent
+	sinceFull int
+	resync    bool
+}
+
+// FederatorConfig holds configuration for the federation uplink.
+type FederatorConfig struct {
+	ParentURL string
+	APIKey    string // This controller's node API key at the parent
+	SiteName  string
+	Store     Store
+	Interval  time.Duration // Push interval (default: 30s)
+	FullEvery int           // Send a full sync every N pushes (default: 20)
+	Logger    *log.Logger
+
+	// APIURL is the base URL at which the parent can reach this
+	// controller's API for drill-down; APIToken is sent as its bearer
+	// token. Leave APIURL empty to disable drill-down.
+	APIURL   string
+	APIToken string
+}
+
+// NewFederator creates a new federation uplink.
+func NewFederator(cfg FederatorConfig) *Federator {
+	if cfg.Interval == 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.FullEvery <= 0 {
+		cfg.FullEvery = 20
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	return &Federator{
+		parentURL: strings.TrimRight(cfg.ParentURL, "/"),
+		apiKey:    cfg.APIKey,
+		siteName:  cfg.SiteName,
+		apiURL:    strings.TrimRight(cfg.APIURL, "/"),
+		apiToken:  cfg.APIToken,
+		store:     cfg.Store,
+		interval:  cfg.Interval,
+		fullEvery: cfg.FullEvery,
+		logger:    cfg.Logger,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		resync:    true,
+	}
+}
+
+// Run pushes to the parent immediately and then every interval. Blocks
+// until the context is cancelled.
+func (f *Federator) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	if err := f.Push(ctx); err != nil {
+		f.logger.Printf("fleet federation: push failed: %v", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Push(ctx); err != nil {
+				f.logger.Printf("fleet federation: push failed: %v", err)
+			}
+		}
+	}
+}
+
+// Push sends one sync to the parent: a full sync on the first push, when
+// the parent asks for one, and every FullEvery pushes; a delta otherwise.
+func (f *Federator) Push(ctx context.Context) error {
+	summary, err := f.store.GetSummary(ctx)
+	if err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	nodes, err := f.store.ListNodes(ctx, NodeFilter{})
+	if err != nil {
+		return fmt.Errorf("list nodes: %w", err)
+	}
+	federated, err := f.store.ListFederatedNodes(ctx, NodeFilter{})
+	if err != nil {
+		return fmt.Errorf("list federated nodes: %w", err)
+	}
+	nodes = append(nodes, federated...)
+	sites, err := f.store.ListSites(ctx)
+	if err != nil {
+		return fmt.Errorf("list sites: %w", err)
+	}
+	for _, site := range sites {
+		MergeSummary(summary, site.Summary)
+	}
+
+	full := f.resync || f.sinceFull+1 >= f.fullEvery
+	digests := make(map[string][32]byte, len(nodes))
+	sync := FederationSync{
+		SiteName: f.siteName,
+		Summary:  *summary,
+		Full:     full,
+		APIURL:   f.apiURL,
+		APIToken: f.apiToken,
+	}
+	for _, n := range nodes {
+		d := nodeDigest(n)
+		digests[n.ID] = d
+		if prev, ok := f.sent[n.ID]; full || !ok || prev != d {
+			sync.Nodes = append(sync.Nodes, n)
+		}
+	}
+	if !full {
+		for id := range f.sent {
+			if _, ok := digests[id]; !ok {
+				sync.Removed = append(sync.Removed, id)
+			}
+		}
+		sort.Strings(sync.Removed)
+	}
+
+	resp, err := f.post(ctx, sync)
+	if err != nil {
+		return err
+	}
+
+	f.sent = digests
+	if full {
+		f.sinceFull = 0
+	} else {
+		f.sinceFull++
+	}
+	f.resync = resp.Resync
+	if resp.Resync {
+		f.logger.Printf("fleet federation: parent requested a full resync")
+	}
+	return nil
+}
+
+func (f *Federator) post(ctx context.Context, sync FederationSync) (*FederationSyncResponse, error) {
+	body, err := json.Marshal(sync)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", f.parentURL+"/api/v1/fleet/federation/sync", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.apiKey)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Force a full sync next time: the parent may have applied
+		// nothing, or only part, of this delta.
+		f.resync = true
+		return nil, fmt.Errorf("parent returned %d", resp.StatusCode)
+	}
+	var out FederationSyncResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		f.resync = true
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &out, nil
+}
+
+// nodeDigest fingerprints the parts of a node the parent tracks. The
+// heartbeat timestamp is excluded so that a healthy, unchanged node is not
+// re-sent on every push; a node going offline still changes its status.
+func nodeDigest(n Node) [32]byte {
+	n.LastHeartbeat = time.Time{}
+	buf, _ := json.Marshal(n)
+	return sha256.Sum256(buf)
+}
@@ -0,0 +1,246 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createTestNode(t *testing.T, store *SQLiteStore, id string, role NodeRole) {
+	t.Helper()
+	now := time.Now().UTC()
+	if err := store.CreateNode(context.Background(), &Node{
+		ID: id, Name: id, Role: role, EnrolledAt: now, LastHeartbeat: now, Status: StatusOnline,
+	}, "hash-"+id); err != nil {
+		t.Fatalf("CreateNode(%s): %v", id, err)
+	}
+}
+
+func TestMergeSummary(t *testing.T) {
+	s := FleetSummary{TotalNodes: 2, Online: 2, ByRole: map[string]int{"server": 2}}
+	MergeSummary(&s, FleetSummary{
+		TotalNodes: 3, Online: 1, Offline: 2, FullyCompliant: 1,
+		ByRole:   map[string]int{"server": 1, "client": 2},
+		ByRegion: map[string]int{"eu": 3},
+	})
+	if s.TotalNodes != 5 || s.Online != 3 || s.Offline != 2 || s.FullyCompliant != 1 {
+		t.Errorf("merged counts = %+v", s)
+	}
+	if s.ByRole["server"] != 3 || s.ByRole["client"] != 2 || s.ByRegion["eu"] != 3 {
+		t.Errorf("merged maps = %v %v", s.ByRole, s.ByRegion)
+	}
+}
+
+func TestNode_MatchesFilter(t *testing.T) {
+	local := Node{Role: RoleServer, Region: "us", Status: StatusOnline}
+	remote := Node{Role: RoleClient, Region: "eu", Status: StatusOffline, Site: "site-1"}
+
+	tests := []struct {
+		filter        NodeFilter
+		local, remote bool
+	}{
+		{NodeFilter{}, true, true},
+		{NodeFilter{Site: SiteLocal}, true, false},
+		{NodeFilter{Site: "site-1"}, false, true},
+		{NodeFilter{Role: RoleClient}, false, true},
+		{NodeFilter{Region: "us"}, true, false},
+		{NodeFilter{Status: StatusOffline, Site: "site-2"}, false, false},
+	}
+	for _, tt := range tests {
+		if got := local.MatchesFilter(tt.filter); got != tt.local {
+			t.Errorf("local.MatchesFilter(%+v) = %v", tt.filter, got)
+		}
+		if got := remote.MatchesFilter(tt.filter); got != tt.remote {
+			t.Errorf("remote.MatchesFilter(%+v) = %v", tt.filter, got)
+		}
+	}
+}
+
+func TestSQLiteStore_ApplyFederationSync(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	createTestNode(t, store, "site-1", RoleController)
+
+	err := store.ApplyFederationSync(ctx, "site-1", &FederationSync{
+		SiteName: "dc1",
+		Summary:  FleetSummary{TotalNodes: 2},
+		Full:     true,
+		Nodes:    []Node{{ID: "a", Name: "a", Status: StatusOnline}, {ID: "b", Name: "b", Status: StatusOnline}},
+		APIURL:   "https://dc1:8080",
+		APIToken: "secret",
+	})
+	if err != nil {
+		t.Fatalf("ApplyFederationSync full: %v", err)
+	}
+
+	site, err := store.GetSite(ctx, "site-1")
+	if err != nil {
+		t.Fatalf("GetSite: %v", err)
+	}
+	if site.Name != "dc1" || site.NodeCount != 2 || site.APIToken != "secret" || site.Summary.TotalNodes != 2 {
+		t.Errorf("site = %+v", site)
+	}
+
+	// Delta: b changed, a removed, c added.
+	err = store.ApplyFederationSync(ctx, "site-1", &FederationSync{
+		SiteName: "dc1",
+		Nodes:    []Node{{ID: "b", Name: "b", Status: StatusOffline}, {ID: "c", Name: "c"}},
+		Removed:  []string{"a"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyFederationSync delta: %v", err)
+	}
+	nodes, err := store.ListFederatedNodes(ctx, NodeFilter{})
+	if err != nil {
+		t.Fatalf("ListFederatedNodes: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("federated nodes = %d, want 2", len(nodes))
+	}
+	b, err := store.GetFederatedNode(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetFederatedNode: %v", err)
+	}
+	if b.Status != StatusOffline || b.Site != "site-1" {
+		t.Errorf("b = %+v, want offline at site-1", b)
+	}
+	if _, err := store.GetFederatedNode(ctx, "a"); err == nil {
+		t.Error("removed node a still present")
+	}
+	if local, _ := store.ListFederatedNodes(ctx, NodeFilter{Site: SiteLocal}); len(local) != 0 {
+		t.Errorf("site=local returned %d federated nodes", len(local))
+	}
+
+	// Removing the child controller drops its site and nodes.
+	if err := store.DeleteNode(ctx, "site-1"); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	sites, _ := store.ListSites(ctx)
+	nodes, _ = store.ListFederatedNodes(ctx, NodeFilter{})
+	if len(sites) != 0 || len(nodes) != 0 {
+		t.Errorf("after delete: %d sites, %d nodes", len(sites), len(nodes))
+	}
+}
+
+func TestFederator_SendsDeltas(t *testing.T) {
+	var mu sync.Mutex
+	var syncs []FederationSync
+	resync := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/fleet/federation/sync" || r.Header.Get("Authorization") != "Bearer parent-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var s FederationSync
+		_ = json.NewDecoder(r.Body).Decode(&s)
+		mu.Lock()
+		syncs = append(syncs, s)
+		resp := FederationSyncResponse{Status: "ok", Resync: resync}
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	store := tempDB(t)
+	ctx := context.Background()
+	createTestNode(t, store, "n1", RoleServer)
+	createTestNode(t, store, "n2", RoleClient)
+
+	f := NewFederator(FederatorConfig{
+		ParentURL: server.URL,
+		APIKey:    "parent-key",
+		SiteName:  "dc1",
+		Store:     store,
+		Logger:    log.New(io.Discard, "", 0),
+	})
+
+	last := func() FederationSync {
+		mu.Lock()
+		defer mu.Unlock()
+		return syncs[len(syncs)-1]
+	}
+
+	// First push is a full sync.
+	if err := f.Push(ctx); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if s := last(); !s.Full || len(s.Nodes) != 2 || s.Summary.TotalNodes != 2 || s.SiteName != "dc1" {
+		t.Errorf("first sync = %+v, want full with 2 nodes", s)
+	}
+
+	// Nothing changed (heartbeats alone do not count).
+	_ = store.UpdateNodeHeartbeat(ctx, "n1", time.Now().Add(time.Minute))
+	if err := f.Push(ctx); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if s := last(); s.Full || len(s.Nodes) != 0 || len(s.Removed) != 0 {
+		t.Errorf("idle sync = %+v, want empty delta", s)
+	}
+
+	// Compliance change on n1, n2 removed.
+	_ = store.UpdateNodeCompliance(ctx, "n1", 5, 1, 0)
+	_ = store.DeleteNode(ctx, "n2")
+	if err := f.Push(ctx); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	s := last()
+	if s.Full || len(s.Nodes) != 1 || s.Nodes[0].ID != "n1" || s.Nodes[0].ComplianceFail != 1 {
+		t.Errorf("delta nodes = %+v, want changed n1", s.Nodes)
+	}
+	if len(s.Removed) != 1 || s.Removed[0] != "n2" {
+		t.Errorf("delta removed = %v, want [n2]", s.Removed)
+	}
+
+	// The parent asks for a resync: the next push is full.
+	mu.Lock()
+	resync = true
+	mu.Unlock()
+	_ = f.Push(ctx)
+	mu.Lock()
+	resync = false
+	mu.Unlock()
+	if err := f.Push(ctx); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if s := last(); !s.Full {
+		t.Error("push after resync request should be full")
+	}
+}
+
+func TestFederator_FullSyncAfterRejectedPush(t *testing.T) {
+	fail := true
+	var fulls []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s FederationSync
+		_ = json.NewDecoder(r.Body).Decode(&s)
+		fulls = append(fulls, s.Full)
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(FederationSyncResponse{Status: "ok"})
+	}))
+	defer server.Close()
+
+	store := tempDB(t)
+	f := NewFederator(FederatorConfig{ParentURL: server.URL, Store: store, Logger: log.New(io.Discard, "", 0)})
+	f.resync = false // pretend a baseline exists
+
+	if err := f.Push(context.Background()); err == nil {
+		t.Fatal("expected error from 500")
+	}
+	fail = false
+	if err := f.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if len(fulls) != 2 || fulls[0] || !fulls[1] {
+		t.Errorf("full flags = %v, want [false true]", fulls)
+	}
+}
@@ -0,0 +1,99 @@
+package fleet
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Availability windows.
+const (
+	AvailabilityDay   = 24 * time.Hour
+	AvailabilityWeek  = 7 * 24 * time.Hour
+	AvailabilityMonth = 30 * 24 * time.Hour
+)
+
+// StatusTransition records a node moving from one operational status to
+// another. From is empty for the node's initial status at enrollment.
+type StatusTransition struct {
+	NodeID string     `json:"node_id"`
+	From   NodeStatus `json:"from"`
+	To     NodeStatus `json:"to"`
+	Time   time.Time  `json:"time"`
+}
+
+// Availability is the percentage of time a node was reachable (online or
+// degraded, i.e. not offline) over trailing windows. Time before the node
+// enrolled is not counted.
+type Availability struct {
+	Day   float64 `json:"24h"`
+	Week  float64 `json:"7d"`
+	Month float64 `json:"30d"`
+}
+
+// NodeAvailability computes a node's availability over the last 24h, 7d,
+// and 30d from its status history.
+func NodeAvailability(ctx context.Context, store Store, node *Node, now time.Time) (*Availability, error) {
+	transitions, err := store.ListStatusTransitions(ctx, node.ID, now.Add(-AvailabilityMonth))
+	if err != nil {
+		return nil, err
+	}
+	a := computeAvailability(transitions, node, now)
+	return &a, nil
+}
+
+func computeAvailability(transitions []StatusTransition, node *Node, now time.Time) Availability {
+	if len(transitions) == 0 {
+		// No recorded history (e.g. enrolled before history was kept):
+		// assume the current status has held since enrollment.
+		transitions = []StatusTransition{{NodeID: node.ID, To: node.Status, Time: node.EnrolledAt}}
+	}
+	return Availability{
+		Day:   availabilityPct(transitions, now.Add(-AvailabilityDay), now),
+		Week:  availabilityPct(transitions, now.Add(-AvailabilityWeek), now),
+		Month: availabilityPct(transitions, now.Add(-AvailabilityMonth), now),
+	}
+}
+
+// availabilityPct returns the percentage of [from, to] spent not offline.
+// transitions must be in chronological order; the first may precede from
+// to establish the status at the start of the window.
+func availabilityPct(transitions []StatusTransition, from, to time.Time) float64 {
+	var total, up time.Duration
+	for i, t := range transitions {
+		start := t.Time
+		end := to
+		if i+1 < len(transitions) {
+			end = transitions[i+1].Time
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		d := end.Sub(start)
+		total += d
+		if t.To != StatusOffline {
+			up += d
+		}
+	}
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(up)/float64(total)*10000) / 100
+}
+
+// mergeAvailability returns the node-weighted mean of two availabilities.
+func mergeAvailability(a Availability, an int, b Availability, bn int) Availability {
+	if an+bn == 0 {
+		return a
+	}
+	avg := func(x, y float64) float64 {
+		return math.Round((x*float64(an)+y*float64(bn))/float64(an+bn)*100) / 100
+	}
+	return Availability{Day: avg(a.Day, b.Day), Week: avg(a.Week, b.Week), Month: avg(a.Month, b.Month)}
+}
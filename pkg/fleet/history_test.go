@@ -0,0 +1,57 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAvailabilityPct(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	from := now.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		transitions []StatusTransition
+		want        float64
+	}{
+		{"no data", nil, 100},
+		{"always online", []StatusTransition{{To: StatusOnline, Time: now.Add(-48 * time.Hour)}}, 100},
+		{"degraded counts as up", []StatusTransition{{To: StatusDegraded, Time: now.Add(-48 * time.Hour)}}, 100},
+		{"offline for 6h", []StatusTransition{
+			{To: StatusOnline, Time: now.Add(-48 * time.Hour)},
+			{From: StatusOnline, To: StatusOffline, Time: now.Add(-12 * time.Hour)},
+			{From: StatusOffline, To: StatusOnline, Time: now.Add(-6 * time.Hour)},
+		}, 75},
+		{"enrolled mid-window", []StatusTransition{
+			{To: StatusOnline, Time: now.Add(-4 * time.Hour)},
+			{From: StatusOnline, To: StatusOffline, Time: now.Add(-1 * time.Hour)},
+		}, 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := availabilityPct(tt.transitions, from, now); got != tt.want {
+				t.Errorf("availabilityPct = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeAvailability_NoHistoryUsesCurrentStatus(t *testing.T) {
+	now := time.Now().UTC()
+	node := &Node{ID: "n1", Status: StatusOffline, EnrolledAt: now.Add(-time.Hour)}
+	a := computeAvailability(nil, node, now)
+	if a.Day != 0 || a.Week != 0 || a.Month != 0 {
+		t.Errorf("availability = %+v, want 0 for a node offline since enrollment", a)
+	}
+}
+
+func TestMergeSummary_WeightsAvailability(t *testing.T) {
+	s := FleetSummary{TotalNodes: 3, Availability: Availability{Day: 100, Week: 100, Month: 100}, Flapping: 1}
+	MergeSummary(&s, FleetSummary{TotalNodes: 1, Availability: Availability{Day: 60, Week: 80, Month: 100}, Flapping: 2})
+	if s.Availability.Day != 90 || s.Availability.Week != 95 || s.Availability.Month != 100 {
+		t.Errorf("merged availability = %+v", s.Availability)
+	}
+	if s.Flapping != 3 {
+		t.Errorf("flapping = %d, want 3", s.Flapping)
+	}
+}
@@ -208,7 +208,7 @@ func TestIntegration_ReporterHTTP(t *testing.T) {
 		received++
 		if r.URL.Path == "/api/v1/fleet/report" {
 			var payload ComplianceReportPayload
-			_ = json.NewDecoder(r.Body).Decode(&payload)
+			_ = decodeTestBody(r, &payload)
 			if payload.NodeID != "test-node" {
 				t.Errorf("unexpected node ID: %s", payload.NodeID)
 			}
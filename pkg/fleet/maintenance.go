@@ -0,0 +1,124 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxWindowDuration bounds a maintenance window's length.
+const MaxWindowDuration = 24 * time.Hour
+
+// ErrWindowNotFound is returned when deleting an unknown maintenance window.
+var ErrWindowNotFound = errors.New("maintenance window not found")
+
+// MaintenanceWindow is a recurring period in which the reboot coordinator
+// may restart matching nodes. A window selects nodes by ID or by labels;
+// a node matches when it has every listed label.
+type MaintenanceWindow struct {
+	ID     string            `json:"id"`
+	NodeID string            `json:"node_id,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Days lists the weekdays the window opens on ("mon".."sun"); empty
+	// means every day.
+	Days []string `json:"days,omitempty"`
+	// Start is the opening time, "HH:MM" in Timezone.
+	Start           string `json:"start"`
+	DurationMinutes int    `json:"duration_minutes"`
+	// Timezone is an IANA zone name (default UTC).
+	Timezone  string    `json:"timezone,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate checks the window's selector, schedule, and time zone, and
+// normalizes day names to lower case.
+func (w *MaintenanceWindow) Validate() error {
+	if w.NodeID == "" && len(w.Labels) == 0 {
+		return errors.New("node_id or labels required")
+	}
+	if _, _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if w.DurationMinutes <= 0 || time.Duration(w.DurationMinutes)*time.Minute > MaxWindowDuration {
+		return fmt.Errorf("duration_minutes must be between 1 and %d", int(MaxWindowDuration.Minutes()))
+	}
+	for i, d := range w.Days {
+		d = strings.ToLower(d)
+		if _, ok := weekdays[d]; !ok {
+			return fmt.Errorf("invalid day %q (want mon, tue, ...)", w.Days[i])
+		}
+		w.Days[i] = d
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	return nil
+}
+
+// Matches reports whether the window applies to a node.
+func (w *MaintenanceWindow) Matches(n Node) bool {
+	if w.NodeID != "" && w.NodeID != n.ID {
+		return false
+	}
+	for k, v := range w.Labels {
+		if n.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether t falls inside an occurrence of the window. An
+// occurrence may run past midnight into the next day.
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	hour, minute, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	// Windows are at most a day long, so only today's and yesterday's
+	// occurrences can contain t.
+	for _, offset := range []int{0, -1} {
+		day := t.AddDate(0, 0, offset)
+		if !w.opensOn(day.Weekday()) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+		if !t.Before(start) && t.Before(start.Add(duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *MaintenanceWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "HH:MM".
+func parseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("start must be HH:MM, got %q", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
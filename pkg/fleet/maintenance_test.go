@@ -0,0 +1,78 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindow_Validate(t *testing.T) {
+	ok := MaintenanceWindow{Labels: map[string]string{"env": "prod"}, Days: []string{"Sat"}, Start: "02:00", DurationMinutes: 120, Timezone: "America/New_York"}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if ok.Days[0] != "sat" {
+		t.Errorf("days not normalized: %v", ok.Days)
+	}
+
+	for name, w := range map[string]MaintenanceWindow{
+		"no selector": {Start: "02:00", DurationMinutes: 60},
+		"bad start":   {NodeID: "n1", Start: "2am", DurationMinutes: 60},
+		"no duration": {NodeID: "n1", Start: "02:00"},
+		"too long":    {NodeID: "n1", Start: "02:00", DurationMinutes: 1441},
+		"bad day":     {NodeID: "n1", Start: "02:00", DurationMinutes: 60, Days: []string{"someday"}},
+		"bad zone":    {NodeID: "n1", Start: "02:00", DurationMinutes: 60, Timezone: "Mars/Olympus"},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMaintenanceWindow_Matches(t *testing.T) {
+	node := Node{ID: "n1", Labels: map[string]string{"env": "prod", "tier": "edge"}}
+	tests := []struct {
+		w    MaintenanceWindow
+		want bool
+	}{
+		{MaintenanceWindow{NodeID: "n1"}, true},
+		{MaintenanceWindow{NodeID: "n2"}, false},
+		{MaintenanceWindow{Labels: map[string]string{"env": "prod"}}, true},
+		{MaintenanceWindow{Labels: map[string]string{"env": "prod", "tier": "core"}}, false},
+		{MaintenanceWindow{NodeID: "n1", Labels: map[string]string{"env": "dev"}}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.w.Matches(node); got != tt.want {
+			t.Errorf("case %d: Matches = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	// Saturdays 23:00-01:00 UTC, crossing midnight.
+	w := MaintenanceWindow{NodeID: "n1", Days: []string{"sat"}, Start: "23:00", DurationMinutes: 120}
+	sat := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC) // a Saturday
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{sat.Add(22*time.Hour + 59*time.Minute), false},
+		{sat.Add(23 * time.Hour), true},
+		{sat.Add(24*time.Hour + 30*time.Minute), true}, // Sunday 00:30
+		{sat.Add(25 * time.Hour), false},               // Sunday 01:00, closed
+		{sat.Add(-time.Hour), false},                   // Friday 23:00
+	}
+	for _, tt := range tests {
+		if got := w.Contains(tt.t); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.t.Format(time.RFC3339), got, tt.want)
+		}
+	}
+
+	// Start is interpreted in the window's time zone.
+	ny := MaintenanceWindow{NodeID: "n1", Start: "02:00", DurationMinutes: 60, Timezone: "America/New_York"}
+	if !ny.Contains(time.Date(2026, 10, 17, 6, 30, 0, 0, time.UTC)) { // 02:30 EDT
+		t.Error("expected 06:30 UTC inside a 02:00 New York window")
+	}
+	if ny.Contains(time.Date(2026, 10, 17, 2, 30, 0, 0, time.UTC)) {
+		t.Error("expected 02:30 UTC outside a 02:00 New York window")
+	}
+}
@@ -6,14 +6,20 @@ import (
 	"time"
 )
 
-// Monitor periodically checks for stale nodes and marks them degraded or offline.
+// Monitor periodically checks for stale nodes and marks them degraded or
+// offline. It also flags nodes whose status flaps: while a node is flapping,
+// its individual status-change events are suppressed in favour of a single
+// "node_flapping" event, followed by "node_stable" once it settles.
 type Monitor struct {
 	store          Store
 	degradedAfter  time.Duration
 	offlineAfter   time.Duration
 	checkInterval  time.Duration
+	flapThreshold  int
+	flapWindow     time.Duration
 	logger         *log.Logger
 	eventCh        chan<- FleetEvent
+	lastPrune      time.Time
 }
 
 // MonitorConfig holds configuration for the fleet monitor.
@@ -22,6 +28,8 @@ type MonitorConfig struct {
 	DegradedAfter time.Duration // Time without heartbeat before "degraded" (default 90s)
 	OfflineAfter  time.Duration // Time without heartbeat before "offline" (default 180s)
 	CheckInterval time.Duration // How often to check (default 30s)
+	FlapThreshold int           // Transitions within FlapWindow that mark a node flapping (default 5)
+	FlapWindow    time.Duration // Flap detection window (default 15m)
 	Logger        *log.Logger
 	EventCh       chan<- FleetEvent
 }
@@ -37,6 +45,12 @@ func NewMonitor(cfg MonitorConfig) *Monitor {
 	if cfg.CheckInterval == 0 {
 		cfg.CheckInterval = 30 * time.Second
 	}
+	if cfg.FlapThreshold <= 0 {
+		cfg.FlapThreshold = 5
+	}
+	if cfg.FlapWindow == 0 {
+		cfg.FlapWindow = 15 * time.Minute
+	}
 	if cfg.Logger == nil {
 		cfg.Logger = log.Default()
 	}
@@ -45,6 +59,8 @@ func NewMonitor(cfg MonitorConfig) *Monitor {
 		degradedAfter: cfg.DegradedAfter,
 		offlineAfter:  cfg.OfflineAfter,
 		checkInterval: cfg.CheckInterval,
+		flapThreshold: cfg.FlapThreshold,
+		flapWindow:    cfg.FlapWindow,
 		logger:        cfg.Logger,
 		eventCh:       cfg.EventCh,
 	}
@@ -89,17 +105,58 @@ func (m *Monitor) check(ctx context.Context) {
 				continue
 			}
 			node.Status = newStatus
-			if m.eventCh != nil {
-				select {
-				case m.eventCh <- FleetEvent{
-					Type: "node_" + string(newStatus),
-					Node: node,
-					Time: now,
-				}:
-				default:
-					// Don't block if channel is full
-				}
+			if !node.Flapping {
+				m.emit("node_"+string(newStatus), node, now)
 			}
 		}
 	}
+
+	m.checkFlapping(ctx, nodes, now)
+
+	// History older than the longest availability window is not needed.
+	if now.Sub(m.lastPrune) >= time.Hour {
+		if err := m.store.PruneStatusHistory(ctx, now.Add(-AvailabilityMonth)); err != nil {
+			m.logger.Printf("fleet monitor: prune status history: %v", err)
+		} else {
+			m.lastPrune = now
+		}
+	}
+}
+
+// checkFlapping sets or clears each node's flapping flag from the number of
+// status transitions within the flap window.
+func (m *Monitor) checkFlapping(ctx context.Context, nodes []Node, now time.Time) {
+	counts, err := m.store.CountStatusTransitions(ctx, now.Add(-m.flapWindow))
+	if err != nil {
+		m.logger.Printf("fleet monitor: count status transitions: %v", err)
+		return
+	}
+	for _, node := range nodes {
+		flapping := counts[node.ID] >= m.flapThreshold
+		if flapping == node.Flapping {
+			continue
+		}
+		if err := m.store.SetNodeFlapping(ctx, node.ID, flapping); err != nil {
+			m.logger.Printf("fleet monitor: update node %s flapping: %v", node.ID, err)
+			continue
+		}
+		node.Flapping = flapping
+		if flapping {
+			m.logger.Printf("fleet monitor: node %s is flapping (%d status changes in %s)", node.ID, counts[node.ID], m.flapWindow)
+			m.emit("node_flapping", node, now)
+		} else {
+			m.emit("node_stable", node, now)
+		}
+	}
+}
+
+func (m *Monitor) emit(eventType string, node Node, now time.Time) {
+	if m.eventCh == nil {
+		return
+	}
+	select {
+	case m.eventCh <- FleetEvent{Type: eventType, Node: node, Time: now}:
+	default:
+		// Don't block if channel is full
+	}
 }
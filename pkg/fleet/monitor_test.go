@@ -22,6 +22,9 @@ func TestNewMonitor_Defaults(t *testing.T) {
 	if m.checkInterval != 30*time.Second {
 		t.Errorf("checkInterval = %v, want 30s", m.checkInterval)
 	}
+	if m.flapThreshold != 5 || m.flapWindow != 15*time.Minute {
+		t.Errorf("flap detection = %d in %v, want 5 in 15m", m.flapThreshold, m.flapWindow)
+	}
 }
 
 func TestNewMonitor_CustomConfig(t *testing.T) {
@@ -228,3 +231,64 @@ func TestMonitor_OnlineNodeStaysOnline(t *testing.T) {
 		// Good: fresh node stays online
 	}
 }
+
+func TestMonitor_DetectsFlapping(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	node := &Node{ID: "flappy", Name: "Flappy", Role: RoleServer, Status: StatusOnline, EnrolledAt: now, LastHeartbeat: now}
+	if err := store.CreateNode(ctx, node, "hash-flappy"); err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		_ = store.UpdateNodeStatus(ctx, "flappy", StatusOffline)
+		_ = store.UpdateNodeHeartbeat(ctx, "flappy", now)
+	}
+
+	eventCh := make(chan FleetEvent, 10)
+	m := NewMonitor(MonitorConfig{
+		Store:         store,
+		FlapThreshold: 6,
+		FlapWindow:    time.Hour,
+		Logger:        log.New(io.Discard, "", 0),
+		EventCh:       eventCh,
+	})
+
+	m.check(ctx)
+	got, _ := store.GetNode(ctx, "flappy")
+	if !got.Flapping {
+		t.Fatal("node not marked flapping after 6 transitions")
+	}
+	if e := <-eventCh; e.Type != "node_flapping" {
+		t.Errorf("event = %q, want node_flapping", e.Type)
+	}
+	summary, _ := store.GetSummary(ctx)
+	if summary.Flapping != 1 {
+		t.Errorf("summary flapping = %d, want 1", summary.Flapping)
+	}
+
+	// While flapping, status changes are applied but not announced.
+	_, _ = store.db.Exec(`UPDATE nodes SET last_heartbeat = ? WHERE id = 'flappy'`,
+		now.Add(-10*time.Minute).Format(time.RFC3339))
+	m.check(ctx)
+	got, _ = store.GetNode(ctx, "flappy")
+	if got.Status != StatusOffline {
+		t.Errorf("status = %s, want offline", got.Status)
+	}
+	select {
+	case e := <-eventCh:
+		t.Errorf("unexpected event while flapping: %q", e.Type)
+	default:
+	}
+
+	// Once transitions fall below the threshold the node is stable again.
+	m.flapThreshold = 100
+	m.check(ctx)
+	got, _ = store.GetNode(ctx, "flappy")
+	if got.Flapping {
+		t.Error("node still flapping below threshold")
+	}
+	if e := <-eventCh; e.Type != "node_stable" {
+		t.Errorf("event = %q, want node_stable", e.Type)
+	}
+}
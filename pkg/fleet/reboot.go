@@ -0,0 +1,429 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// RebootStatus tracks a node reboot from request to verification.
+type RebootStatus string
+
+const (
+	// RebootPending waits for the node's maintenance window and for enough
+	// healthy servers to remain routable.
+	RebootPending RebootStatus = "pending"
+	// RebootScheduled has a reboot request queued for the agent.
+	RebootScheduled RebootStatus = "scheduled"
+	// RebootRebooting has been acknowledged by the agent; the node restarts
+	// at RebootAt.
+	RebootRebooting RebootStatus = "rebooting"
+	// RebootVerified came back with its checks passing.
+	RebootVerified RebootStatus = "verified"
+	// RebootFailed did not reboot, did not return, or came back with its
+	// checks still failing.
+	RebootFailed RebootStatus = "failed"
+)
+
+// RebootCoordinatorActor is the RequestedBy of reboot requests the
+// coordinator creates.
+const RebootCoordinatorActor = "reboot-coordinator"
+
+// Reboot is a node reboot needed to complete a remediation, e.g. enabling
+// OS FIPS mode, which only takes effect once the kernel restarts.
+type Reboot struct {
+	ID     string `json:"id"`
+	NodeID string `json:"node_id"`
+	// RemediationID is the request whose actions need the reboot.
+	RemediationID string `json:"remediation_id"`
+	// CheckIDs are the compliance checks expected to pass after the reboot.
+	CheckIDs []string     `json:"check_ids,omitempty"`
+	Status   RebootStatus `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	// RebootRequestID is the "reboot" remediation request sent to the agent.
+	RebootRequestID string     `json:"reboot_request_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	RebootAt        *time.Time `json:"reboot_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// RebootFilter specifies criteria for listing reboots.
+type RebootFilter struct {
+	NodeID   string
+	Statuses []RebootStatus
+}
+
+// RebootCoordinator completes needs_reboot remediation. Reboots wait for
+// a maintenance window covering the node, at most MaxConcurrent nodes
+// reboot at once, and a routable server is only rebooted while at least
+// MinHealthyServers other servers stay routable. Once the node heartbeats
+// again, the checks its remediation fixed are read from its latest report
+// to verify the change took effect.
+type RebootCoordinator struct {
+	store         Store
+	maxConcurrent int
+	minHealthy    int
+	routable      func(Node) bool
+	returnTimeout time.Duration
+	verifyGrace   time.Duration
+	checkInterval time.Duration
+	logger        *log.Logger
+	eventCh       chan<- FleetEvent
+}
+
+// RebootCoordinatorConfig holds configuration for the reboot coordinator.
+type RebootCoordinatorConfig struct {
+	Store             Store
+	MaxConcurrent     int             // Nodes rebooting at once (default 1)
+	MinHealthyServers int             // Other routable servers required while a server reboots
+	Routable          func(Node) bool // Whether a server takes traffic (default: online)
+	ReturnTimeout     time.Duration   // Time for a node to acknowledge and come back (default 30m)
+	VerifyGrace       time.Duration   // Time after returning for checks to pass (default 5m)
+	CheckInterval     time.Duration   // How often to check (default 30s)
+	Logger            *log.Logger
+	EventCh           chan<- FleetEvent
+}
+
+// NewRebootCoordinator creates a reboot coordinator.
+func NewRebootCoordinator(cfg RebootCoordinatorConfig) *RebootCoordinator {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 1
+	}
+	if cfg.Routable == nil {
+		cfg.Routable = func(n Node) bool { return n.Status == StatusOnline }
+	}
+	if cfg.ReturnTimeout == 0 {
+		cfg.ReturnTimeout = 30 * time.Minute
+	}
+	if cfg.VerifyGrace == 0 {
+		cfg.VerifyGrace = 5 * time.Minute
+	}
+	if cfg.CheckInterval == 0 {
+		cfg.CheckInterval = 30 * time.Second
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	return &RebootCoordinator{
+		store:         cfg.Store,
+		maxConcurrent: cfg.MaxConcurrent,
+		minHealthy:    cfg.MinHealthyServers,
+		routable:      cfg.Routable,
+		returnTimeout: cfg.ReturnTimeout,
+		verifyGrace:   cfg.VerifyGrace,
+		checkInterval: cfg.CheckInterval,
+		logger:        cfg.Logger,
+		eventCh:       cfg.EventCh,
+	}
+}
+
+// Run starts the coordinator loop. Blocks until context is cancelled.
+func (c *RebootCoordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.check(ctx, time.Now().UTC())
+		}
+	}
+}
+
+// openStatuses are the statuses of reboots still in progress.
+var openStatuses = []RebootStatus{RebootPending, RebootScheduled, RebootRebooting}
+
+// RequestReboot records that a node needs a reboot to complete remediation
+// remediationID, after which checkIDs should pass. A node with a reboot
+// already waiting for its window has the checks added to it instead.
+func (c *RebootCoordinator) RequestReboot(ctx context.Context, nodeID, remediationID string, checkIDs []string) (*Reboot, error) {
+	pending, err := c.store.ListReboots(ctx, RebootFilter{NodeID: nodeID, Statuses: []RebootStatus{RebootPending}})
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		rb := pending[0]
+		rb.CheckIDs = mergeIDs(rb.CheckIDs, checkIDs)
+		if err := c.store.UpdateReboot(ctx, &rb); err != nil {
+			return nil, err
+		}
+		return &rb, nil
+	}
+
+	id, err := generateSecureToken(8)
+	if err != nil {
+		return nil, err
+	}
+	rb := &Reboot{
+		ID:            "rb-" + id,
+		NodeID:        nodeID,
+		RemediationID: remediationID,
+		CheckIDs:      mergeIDs(nil, checkIDs),
+		Status:        RebootPending,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := c.store.CreateReboot(ctx, rb); err != nil {
+		return nil, err
+	}
+	c.logger.Printf("fleet: node %s needs a reboot to complete remediation %s", nodeID, remediationID)
+	return rb, nil
+}
+
+// RebootStarted records the agent's acknowledgement of reboot request
+// reqID: the node restarts at rebootAt. A non-nil rebootErr means the
+// agent could not schedule the reboot.
+func (c *RebootCoordinator) RebootStarted(ctx context.Context, nodeID, reqID string, rebootAt time.Time, rebootErr error) error {
+	scheduled, err := c.store.ListReboots(ctx, RebootFilter{NodeID: nodeID, Statuses: []RebootStatus{RebootScheduled}})
+	if err != nil {
+		return err
+	}
+	for i := range scheduled {
+		rb := &scheduled[i]
+		if rb.RebootRequestID != reqID {
+			continue
+		}
+		if rebootErr != nil {
+			return c.finish(ctx, rb, RebootFailed, "agent could not reboot: "+rebootErr.Error(), time.Now().UTC())
+		}
+		at := rebootAt.UTC()
+		rb.Status = RebootRebooting
+		rb.RebootAt = &at
+		return c.store.UpdateReboot(ctx, rb)
+	}
+	return fmt.Errorf("no scheduled reboot for request %s", reqID)
+}
+
+func (c *RebootCoordinator) check(ctx context.Context, now time.Time) {
+	reboots, err := c.store.ListReboots(ctx, RebootFilter{Statuses: openStatuses})
+	if err != nil {
+		c.logger.Printf("fleet: reboot coordinator: list reboots: %v", err)
+		return
+	}
+	if len(reboots) == 0 {
+		return
+	}
+	nodes, err := c.store.ListNodes(ctx, NodeFilter{})
+	if err != nil {
+		c.logger.Printf("fleet: reboot coordinator: list nodes: %v", err)
+		return
+	}
+	byID := make(map[string]Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+
+	inFlight := make(map[string]bool)
+	var pending []Reboot
+	for i := range reboots {
+		rb := &reboots[i]
+		switch rb.Status {
+		case RebootPending:
+			pending = append(pending, *rb)
+		case RebootScheduled:
+			if now.Sub(*rb.ScheduledAt) > c.returnTimeout {
+				c.finishLogged(ctx, rb, RebootFailed, "agent did not acknowledge the reboot request", now)
+				continue
+			}
+			inFlight[rb.NodeID] = true
+		case RebootRebooting:
+			if c.verify(ctx, rb, byID[rb.NodeID], now) {
+				continue
+			}
+			inFlight[rb.NodeID] = true
+		}
+	}
+	if len(pending) == 0 || len(inFlight) >= c.maxConcurrent {
+		return
+	}
+
+	windows, err := c.store.ListMaintenanceWindows(ctx)
+	if err != nil {
+		c.logger.Printf("fleet: reboot coordinator: list maintenance windows: %v", err)
+		return
+	}
+	for i := range pending {
+		if len(inFlight) >= c.maxConcurrent {
+			return
+		}
+		rb := &pending[i]
+		node, ok := byID[rb.NodeID]
+		if !ok || inFlight[rb.NodeID] || !inWindow(windows, node, now) {
+			continue
+		}
+		if detail := c.capacityShortfall(node, nodes, inFlight); detail != "" {
+			if rb.Detail != detail {
+				rb.Detail = detail
+				if err := c.store.UpdateReboot(ctx, rb); err != nil {
+					c.logger.Printf("fleet: reboot coordinator: update reboot %s: %v", rb.ID, err)
+				}
+			}
+			continue
+		}
+		if err := c.schedule(ctx, rb, now); err != nil {
+			c.logger.Printf("fleet: reboot coordinator: schedule reboot of node %s: %v", rb.NodeID, err)
+			continue
+		}
+		inFlight[rb.NodeID] = true
+		c.emit("reboot_scheduled", node, now)
+	}
+}
+
+// capacityShortfall explains why rebooting node now would leave too few
+// routable servers, or returns "" if it would not.
+func (c *RebootCoordinator) capacityShortfall(node Node, nodes []Node, inFlight map[string]bool) string {
+	if node.Role != RoleServer || !c.routable(node) {
+		return ""
+	}
+	healthy := 0
+	for _, n := range nodes {
+		if n.Role == RoleServer && n.ID != node.ID && !inFlight[n.ID] && c.routable(n) {
+			healthy++
+		}
+	}
+	if healthy >= c.minHealthy {
+		return ""
+	}
+	return fmt.Sprintf("waiting: %d other routable server(s), %d required", healthy, c.minHealthy)
+}
+
+// schedule queues a reboot request for the node's agent.
+func (c *RebootCoordinator) schedule(ctx context.Context, rb *Reboot, now time.Time) error {
+	id, err := generateSecureToken(8)
+	if err != nil {
+		return err
+	}
+	req := &RemediationRequest{
+		ID:          "reboot-" + id,
+		NodeID:      rb.NodeID,
+		Actions:     []string{"reboot"}, // remediate.ActionReboot
+		Status:      RemediationPending,
+		CreatedAt:   now,
+		RequestedBy: RebootCoordinatorActor,
+	}
+	if err := c.store.CreateRemediationRequest(ctx, req); err != nil {
+		return err
+	}
+	rb.Status = RebootScheduled
+	rb.RebootRequestID = req.ID
+	rb.ScheduledAt = &now
+	rb.Detail = ""
+	c.logger.Printf("fleet: reboot of node %s scheduled (request %s)", rb.NodeID, req.ID)
+	return c.store.UpdateReboot(ctx, rb)
+}
+
+// verify checks on a rebooting node, finishing the reboot once its outcome
+// is known. It reports whether the reboot finished.
+func (c *RebootCoordinator) verify(ctx context.Context, rb *Reboot, node Node, now time.Time) bool {
+	rebootAt := *rb.RebootAt
+	if node.ID == "" || !node.LastHeartbeat.After(rebootAt) {
+		if now.Sub(rebootAt) > c.returnTimeout {
+			c.finishLogged(ctx, rb, RebootFailed, fmt.Sprintf("node did not return within %s", c.returnTimeout), now)
+			return true
+		}
+		return false
+	}
+
+	failing, err := c.failingChecks(ctx, rb)
+	switch {
+	case err == nil && len(failing) == 0:
+		c.finishLogged(ctx, rb, RebootVerified, "node returned with checks passing", now)
+		c.emit("reboot_verified", node, now)
+		return true
+	case node.LastHeartbeat.After(rebootAt.Add(c.verifyGrace)):
+		detail := fmt.Sprintf("node returned but %v still failing", failing)
+		if err != nil {
+			detail = "node returned but its report could not be read: " + err.Error()
+		}
+		c.finishLogged(ctx, rb, RebootFailed, detail, now)
+		c.emit("reboot_failed", node, now)
+		return true
+	}
+	return false
+}
+
+// failingChecks returns the reboot's checks that do not pass in the node's
+// latest report.
+func (c *RebootCoordinator) failingChecks(ctx context.Context, rb *Reboot) ([]string, error) {
+	data, err := c.store.GetLatestReport(ctx, rb.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	var report struct {
+		Sections []struct {
+			Items []struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			} `json:"items"`
+		} `json:"sections"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	status := make(map[string]string)
+	for _, s := range report.Sections {
+		for _, item := range s.Items {
+			status[item.ID] = item.Status
+		}
+	}
+	var failing []string
+	for _, id := range rb.CheckIDs {
+		if status[id] != "pass" {
+			failing = append(failing, id)
+		}
+	}
+	return failing, nil
+}
+
+func (c *RebootCoordinator) finish(ctx context.Context, rb *Reboot, status RebootStatus, detail string, now time.Time) error {
+	rb.Status = status
+	rb.Detail = detail
+	rb.CompletedAt = &now
+	c.logger.Printf("fleet: reboot of node %s %s: %s", rb.NodeID, status, detail)
+	return c.store.UpdateReboot(ctx, rb)
+}
+
+func (c *RebootCoordinator) finishLogged(ctx context.Context, rb *Reboot, status RebootStatus, detail string, now time.Time) {
+	if err := c.finish(ctx, rb, status, detail, now); err != nil {
+		c.logger.Printf("fleet: reboot coordinator: update reboot %s: %v", rb.ID, err)
+	}
+}
+
+func (c *RebootCoordinator) emit(eventType string, node Node, now time.Time) {
+	if c.eventCh == nil {
+		return
+	}
+	select {
+	case c.eventCh <- FleetEvent{Type: eventType, Node: node, Time: now}:
+	default:
+	}
+}
+
+// inWindow reports whether any maintenance window for the node is open.
+func inWindow(windows []MaintenanceWindow, node Node, now time.Time) bool {
+	for i := range windows {
+		if windows[i].Matches(node) && windows[i].Contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeIDs returns the sorted union of two ID lists.
+func mergeIDs(a, b []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, id := range append(append([]string(nil), a...), b...) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
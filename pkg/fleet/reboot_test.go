@@ -0,0 +1,244 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// rebootFixture is a store with three online servers, all inside an
+// always-open maintenance window, and a coordinator over it.
+func rebootFixture(t *testing.T, maxConcurrent, minHealthy int) (*SQLiteStore, *RebootCoordinator, chan FleetEvent) {
+	t.Helper()
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, id := range []string{"s1", "s2", "s3"} {
+		node := &Node{ID: id, Name: id, Role: RoleServer, Status: StatusOnline, LastHeartbeat: now,
+			Labels: map[string]string{"env": "prod"}}
+		if err := store.CreateNode(ctx, node, "hash-"+id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.CreateMaintenanceWindow(ctx, &MaintenanceWindow{
+		ID: "w1", Labels: map[string]string{"env": "prod"}, Start: "00:00", DurationMinutes: 1440, CreatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan FleetEvent, 10)
+	c := NewRebootCoordinator(RebootCoordinatorConfig{
+		Store:             store,
+		MaxConcurrent:     maxConcurrent,
+		MinHealthyServers: minHealthy,
+		ReturnTimeout:     time.Hour,
+		VerifyGrace:       10 * time.Minute,
+		Logger:            log.New(io.Discard, "", 0),
+		EventCh:           events,
+	})
+	return store, c, events
+}
+
+func rebootsByStatus(t *testing.T, store Store, status RebootStatus) []Reboot {
+	t.Helper()
+	reboots, err := store.ListReboots(context.Background(), RebootFilter{Statuses: []RebootStatus{status}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reboots
+}
+
+func TestRebootCoordinator_RateLimitsAndKeepsHealthyServers(t *testing.T) {
+	store, c, _ := rebootFixture(t, 2, 2)
+	ctx := context.Background()
+
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if _, err := c.RequestReboot(ctx, id, "rem-"+id, []string{"ag-fips"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A second request for a waiting node merges into the same reboot.
+	if rb, err := c.RequestReboot(ctx, "s1", "rem-x", []string{"ag-ntp"}); err != nil || len(rb.CheckIDs) != 2 {
+		t.Fatalf("merged reboot = %+v, %v", rb, err)
+	}
+
+	c.check(ctx, time.Now().UTC())
+
+	// Two may reboot at once, but rebooting a second server would leave
+	// only one routable server of the two required.
+	scheduled := rebootsByStatus(t, store, RebootScheduled)
+	if len(scheduled) != 1 {
+		t.Fatalf("scheduled = %+v", scheduled)
+	}
+	pending := rebootsByStatus(t, store, RebootPending)
+	if len(pending) != 2 || pending[0].Detail == "" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	reqs, err := store.GetPendingRemediations(ctx, scheduled[0].NodeID)
+	if err != nil || len(reqs) != 1 || reqs[0].Actions[0] != "reboot" || reqs[0].RequestedBy != RebootCoordinatorActor {
+		t.Fatalf("reboot request = %+v, %v", reqs, err)
+	}
+	if reqs[0].ID != scheduled[0].RebootRequestID {
+		t.Errorf("reboot request %s not linked to %s", reqs[0].ID, scheduled[0].RebootRequestID)
+	}
+}
+
+func TestRebootCoordinator_WaitsForWindow(t *testing.T) {
+	store, c, _ := rebootFixture(t, 1, 0)
+	ctx := context.Background()
+	if err := store.DeleteMaintenanceWindow(ctx, "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteMaintenanceWindow(ctx, "w1"); !errors.Is(err, ErrWindowNotFound) {
+		t.Errorf("second delete = %v, want ErrWindowNotFound", err)
+	}
+	now := time.Now().UTC()
+	closed := now.Add(2 * time.Hour)
+	if err := store.CreateMaintenanceWindow(ctx, &MaintenanceWindow{
+		ID: "w2", NodeID: "s1", Start: closed.Format("15:04"), DurationMinutes: 30, CreatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RequestReboot(ctx, "s1", "rem-1", []string{"ag-fips"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.check(ctx, now)
+	if got := rebootsByStatus(t, store, RebootScheduled); len(got) != 0 {
+		t.Fatalf("scheduled outside window: %+v", got)
+	}
+	c.check(ctx, closed.Add(time.Minute))
+	if got := rebootsByStatus(t, store, RebootScheduled); len(got) != 1 {
+		t.Fatalf("not scheduled inside window: %+v", got)
+	}
+}
+
+func TestRebootCoordinator_VerifiesFIPSAfterReboot(t *testing.T) {
+	store, c, events := rebootFixture(t, 1, 0)
+	ctx := context.Background()
+
+	for _, id := range []string{"s1", "s2"} {
+		if _, err := c.RequestReboot(ctx, id, "rem-"+id, []string{"ag-fips"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c.check(ctx, now)
+
+	scheduled := rebootsByStatus(t, store, RebootScheduled)
+	if len(scheduled) != 1 {
+		t.Fatalf("scheduled = %+v", scheduled)
+	}
+	first, second := scheduled[0].NodeID, "s2"
+	if first == "s2" {
+		second = "s1"
+	}
+	if err := c.RebootStarted(ctx, first, "no-such-request", now, nil); err == nil {
+		t.Error("expected error for unknown reboot request")
+	}
+	rebootAt := now.Add(time.Minute)
+	if err := c.RebootStarted(ctx, first, scheduled[0].RebootRequestID, rebootAt, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Still down: the other node waits behind the in-flight reboot.
+	c.check(ctx, now.Add(2*time.Minute))
+	if got := rebootsByStatus(t, store, RebootRebooting); len(got) != 1 {
+		t.Fatalf("rebooting = %+v", got)
+	}
+	if got := rebootsByStatus(t, store, RebootPending); len(got) != 1 {
+		t.Fatalf("pending = %+v", got)
+	}
+
+	// Back up with fips_enabled=1.
+	if err := store.StoreReport(ctx, first, []byte(`{"sections":[{"items":[{"id":"ag-fips","status":"pass"}]}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateNodeHeartbeat(ctx, first, rebootAt.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	c.check(ctx, now.Add(4*time.Minute))
+
+	verified := rebootsByStatus(t, store, RebootVerified)
+	if len(verified) != 1 || verified[0].NodeID != first || verified[0].CompletedAt == nil {
+		t.Fatalf("verified = %+v", verified)
+	}
+	// With the first node done, the second is scheduled in the same pass.
+	if got := rebootsByStatus(t, store, RebootScheduled); len(got) != 1 || got[0].NodeID != second {
+		t.Fatalf("scheduled = %+v", got)
+	}
+
+	var types []string
+	for len(events) > 0 {
+		types = append(types, (<-events).Type)
+	}
+	want := []string{"reboot_scheduled", "reboot_verified", "reboot_scheduled"}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("events = %v, want %v", types, want)
+		}
+	}
+}
+
+func TestRebootCoordinator_FailsWhenFIPSUnchanged(t *testing.T) {
+	store, c, _ := rebootFixture(t, 1, 0)
+	ctx := context.Background()
+
+	if _, err := c.RequestReboot(ctx, "s1", "rem-1", []string{"ag-fips"}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c.check(ctx, now)
+	rb := rebootsByStatus(t, store, RebootScheduled)[0]
+	rebootAt := now.Add(time.Minute)
+	if err := c.RebootStarted(ctx, "s1", rb.RebootRequestID, rebootAt, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreReport(ctx, "s1", []byte(`{"sections":[{"items":[{"id":"ag-fips","status":"fail"}]}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Returned, but within the grace period: keep waiting for a report.
+	if err := store.UpdateNodeHeartbeat(ctx, "s1", rebootAt.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	c.check(ctx, rebootAt.Add(time.Minute))
+	if got := rebootsByStatus(t, store, RebootRebooting); len(got) != 1 {
+		t.Fatalf("rebooting = %+v", got)
+	}
+
+	if err := store.UpdateNodeHeartbeat(ctx, "s1", rebootAt.Add(11*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	c.check(ctx, rebootAt.Add(11*time.Minute))
+	failed := rebootsByStatus(t, store, RebootFailed)
+	if len(failed) != 1 || failed[0].Detail != "node returned but [ag-fips] still failing" {
+		t.Fatalf("failed = %+v", failed)
+	}
+}
+
+func TestRebootCoordinator_FailsWhenNodeDoesNotReturn(t *testing.T) {
+	store, c, _ := rebootFixture(t, 1, 0)
+	ctx := context.Background()
+
+	if _, err := c.RequestReboot(ctx, "s1", "rem-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c.check(ctx, now)
+	rb := rebootsByStatus(t, store, RebootScheduled)[0]
+	if err := c.RebootStarted(ctx, "s1", rb.RebootRequestID, now.Add(time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c.check(ctx, now.Add(2*time.Hour))
+	if failed := rebootsByStatus(t, store, RebootFailed); len(failed) != 1 {
+		t.Fatalf("failed = %+v", failed)
+	}
+}
@@ -0,0 +1,268 @@
+package remediate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for operator-defined actions.
+const (
+	DefaultCustomTimeout = 5 * time.Minute
+	// MaxCustomOutput caps the script output recorded in the result.
+	MaxCustomOutput = 64 << 10
+	// customPath is the PATH given to scripts unless PATH is allowlisted.
+	customPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// CustomAction is an operator-defined remediation action: a script, signed
+// by a trusted ECDSA key, that fixes a failing check.
+type CustomAction struct {
+	ID          ActionID `yaml:"id"`
+	CheckID     string   `yaml:"check_id"`
+	Description string   `yaml:"description"`
+	// Script is the absolute path of the script to run.
+	Script string `yaml:"script"`
+	// Signature is the path of the script's detached ECDSA signature over
+	// its SHA-256 digest, DER or base64-encoded DER (default Script+".sig").
+	// Produce one with: openssl dgst -sha256 -sign key.pem -out script.sig script
+	Signature string `yaml:"signature"`
+	// Timeout bounds the script's run time (default 5m).
+	Timeout time.Duration `yaml:"timeout"`
+	// Env lists the agent environment variables passed to the script. All
+	// others are dropped.
+	Env []string `yaml:"env"`
+	// NeedsReboot marks a successful run as needing a reboot to take effect.
+	NeedsReboot bool `yaml:"needs_reboot"`
+}
+
+// customActionsFile is the layout of the custom actions config file.
+type customActionsFile struct {
+	Actions []CustomAction `yaml:"actions"`
+}
+
+// LoadCustomActions reads operator-defined actions from a YAML file:
+//
+//	actions:
+//	  - id: restart_chronyd
+//	    check_id: ag-ntp
+//	    description: Restart chronyd
+//	    script: /etc/cloudflared-fips/remediate/restart-chronyd.sh
+//	    timeout: 30s
+//	    env: [HTTPS_PROXY]
+//
+// Signatures are not checked here; they are verified each time an action
+// runs.
+func LoadCustomActions(path string) ([]CustomAction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read custom actions: %w", err)
+	}
+	var f customActionsFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse custom actions: %w", err)
+	}
+
+	seen := make(map[ActionID]bool)
+	for i := range f.Actions {
+		a := &f.Actions[i]
+		switch {
+		case a.ID == "":
+			return nil, fmt.Errorf("custom action %d: id required", i+1)
+		case isBuiltinAction(a.ID):
+			return nil, fmt.Errorf("custom action %s: id is a built-in action", a.ID)
+		case seen[a.ID]:
+			return nil, fmt.Errorf("custom action %s: duplicate id", a.ID)
+		case a.CheckID == "":
+			return nil, fmt.Errorf("custom action %s: check_id required", a.ID)
+		case !filepath.IsAbs(a.Script):
+			return nil, fmt.Errorf("custom action %s: script must be an absolute path", a.ID)
+		case a.Timeout < 0:
+			return nil, fmt.Errorf("custom action %s: negative timeout", a.ID)
+		}
+		seen[a.ID] = true
+		if a.Signature == "" {
+			a.Signature = a.Script + ".sig"
+		}
+		if a.Timeout == 0 {
+			a.Timeout = DefaultCustomTimeout
+		}
+		if a.Description == "" {
+			a.Description = "Run " + filepath.Base(a.Script)
+		}
+	}
+	return f.Actions, nil
+}
+
+// LoadTrustedKeys reads PEM-encoded ECDSA public keys (PKIX "PUBLIC KEY"
+// blocks) trusted to sign custom action scripts.
+func LoadTrustedKeys(path string) ([]*ecdsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read trusted keys: %w", err)
+	}
+	var keys []*ecdsa.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse trusted key: %w", err)
+		}
+		key, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("trusted key is %T, want ECDSA", pub)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no ECDSA public keys found in " + path)
+	}
+	return keys, nil
+}
+
+// verifyScript reads a custom action's script and checks its signature
+// against the trusted keys, returning the verified contents.
+func verifyScript(a CustomAction, keys []*ecdsa.PublicKey) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no trusted signing keys configured")
+	}
+	script, err := os.ReadFile(a.Script)
+	if err != nil {
+		return nil, fmt.Errorf("read script: %w", err)
+	}
+	sig, err := os.ReadFile(a.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("read signature: %w", err)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = decoded
+	}
+	digest := sha256.Sum256(script)
+	for _, key := range keys {
+		if ecdsa.VerifyASN1(key, digest[:], sig) {
+			return script, nil
+		}
+	}
+	return nil, fmt.Errorf("signature %s does not match %s under any trusted key", a.Signature, a.Script)
+}
+
+// runCustomAction verifies and runs a custom action's script. The verified
+// bytes are copied to a private file and executed from there, so the script
+// cannot be swapped between verification and execution.
+func runCustomAction(a CustomAction, keys []*ecdsa.PublicKey) (string, error) {
+	script, err := verifyScript(a, keys)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "remediate-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, filepath.Base(a.Script))
+	if err := os.WriteFile(path, script, 0o700); err != nil {
+		return "", err
+	}
+
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCustomTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = "/"
+	cmd.Env = customEnv(a)
+	var out limitedBuffer
+	out.max = MaxCustomOutput
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = 5 * time.Second
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out.String(), fmt.Errorf("script timed out after %s", timeout)
+	}
+	if err != nil {
+		return out.String(), fmt.Errorf("script failed: %w", err)
+	}
+	return out.String(), nil
+}
+
+// customEnv builds a script's environment from the allowlisted agent
+// variables plus the action and check IDs.
+func customEnv(a CustomAction) []string {
+	env := []string{
+		"REMEDIATION_ACTION_ID=" + string(a.ID),
+		"REMEDIATION_CHECK_ID=" + a.CheckID,
+	}
+	hasPath := false
+	for _, name := range a.Env {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+			hasPath = hasPath || name == "PATH"
+		}
+	}
+	if !hasPath {
+		env = append(env, "PATH="+customPath)
+	}
+	return env
+}
+
+// limitedBuffer keeps the first max bytes written and notes truncation.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
+
+func isBuiltinAction(id ActionID) bool {
+	switch id {
+	case ActionEnableOSFIPS, ActionInstallWARP, ActionConnectWARP,
+		ActionFixEdgeCiphers, ActionFixMinTLS, ActionEnableHSTS,
+		ActionEnableDiskEnc, ActionEnrollMDM,
+		ActionFixSecretPerms, ActionFixAuditLogPerms, ActionReboot:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package remediate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+)
+
+// signedScript writes a script and its base64 signature under dir.
+func signedScript(t *testing.T, dir, name, body string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(body))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".sig", []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newSigningKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func customExecutor(actions []CustomAction, keys ...*ecdsa.PrivateKey) *Executor {
+	var pubs []*ecdsa.PublicKey
+	for _, k := range keys {
+		pubs = append(pubs, &k.PublicKey)
+	}
+	return NewExecutor(log.New(io.Discard, "", 0), WithCustomActions(actions, pubs))
+}
+
+func failing(ids ...string) compliance.Section {
+	s := compliance.Section{ID: "agent"}
+	for _, id := range ids {
+		s.Items = append(s.Items, compliance.ChecklistItem{ID: id, Status: compliance.StatusFail})
+	}
+	return s
+}
+
+func TestLoadCustomActions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "actions.yaml")
+	write := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`actions:
+  - id: restart_chronyd
+    check_id: ag-ntp
+    script: /opt/fix/restart-chronyd.sh
+    timeout: 30s
+    env: [HTTPS_PROXY]
+`)
+	actions, err := LoadCustomActions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := actions[0]
+	if a.Timeout != 30*time.Second || a.Signature != "/opt/fix/restart-chronyd.sh.sig" ||
+		a.Description != "Run restart-chronyd.sh" || len(a.Env) != 1 {
+		t.Errorf("loaded action = %+v", a)
+	}
+
+	for name, body := range map[string]string{
+		"builtin":   "actions:\n  - {id: enable_os_fips, check_id: ag-fips, script: /x.sh}\n",
+		"no check":  "actions:\n  - {id: fix, script: /x.sh}\n",
+		"relative":  "actions:\n  - {id: fix, check_id: ag-x, script: x.sh}\n",
+		"duplicate": "actions:\n  - {id: fix, check_id: ag-x, script: /x.sh}\n  - {id: fix, check_id: ag-y, script: /y.sh}\n",
+	} {
+		write(body)
+		if _, err := LoadCustomActions(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadTrustedKeys(t *testing.T) {
+	key := newSigningKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadTrustedKeys(path)
+	if err != nil || len(keys) != 1 || !keys[0].Equal(&key.PublicKey) {
+		t.Fatalf("LoadTrustedKeys = %v, %v", keys, err)
+	}
+
+	if err := os.WriteFile(path, []byte("not a key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTrustedKeys(path); err == nil {
+		t.Error("expected error for file without keys")
+	}
+}
+
+func TestCustomAction_PlanAndExecute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts")
+	}
+	key := newSigningKey(t)
+	dir := t.TempDir()
+	script := signedScript(t, dir, "fix.sh", "#!/bin/sh\necho \"fixing $REMEDIATION_CHECK_ID allowed=$ALLOWED secret=$SECRET\"\n", key)
+	t.Setenv("ALLOWED", "yes")
+	t.Setenv("SECRET", "leaked")
+
+	e := customExecutor([]CustomAction{{
+		ID: "fix_ntp", CheckID: "ag-ntp", Description: "Fix NTP", Script: script,
+		Signature: script + ".sig", Timeout: 10 * time.Second, Env: []string{"ALLOWED"},
+	}}, key)
+
+	plan := e.Plan(failing("ag-ntp"))
+	if len(plan) != 1 || plan[0].ID != "fix_ntp" || plan[0].CheckID != "ag-ntp" || !plan[0].AutoExec {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	dry := e.Execute(RemediationRequest{Actions: []ActionID{"fix_ntp"}, DryRun: true}, plan)
+	if a := dry.Actions[0]; a.Status != StatusPending || !strings.Contains(a.Output, "signature verified") {
+		t.Errorf("dry run = %s: %q", a.Status, a.Output)
+	}
+
+	result := e.Execute(RemediationRequest{Actions: []ActionID{"fix_ntp"}}, e.Plan(failing("ag-ntp")))
+	a := result.Actions[0]
+	if a.Status != StatusSuccess {
+		t.Fatalf("status = %s: %q", a.Status, a.Output)
+	}
+	if want := "fixing ag-ntp allowed=yes secret="; strings.TrimSpace(a.Output) != want {
+		t.Errorf("output = %q, want %q", a.Output, want)
+	}
+}
+
+func TestCustomAction_RejectsUntrustedScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts")
+	}
+	trusted, other := newSigningKey(t), newSigningKey(t)
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+
+	tampered := signedScript(t, dir, "tampered.sh", "#!/bin/sh\necho ok\n", trusted)
+	if err := os.WriteFile(tampered, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	untrusted := signedScript(t, dir, "untrusted.sh", "#!/bin/sh\ntouch "+marker+"\n", other)
+
+	e := customExecutor([]CustomAction{
+		{ID: "tampered", CheckID: "ag-x", Script: tampered, Signature: tampered + ".sig", Timeout: time.Second},
+		{ID: "untrusted", CheckID: "ag-x", Script: untrusted, Signature: untrusted + ".sig", Timeout: time.Second},
+	}, trusted)
+	result := e.Execute(RemediationRequest{Actions: []ActionID{"tampered", "untrusted"}}, e.Plan(failing("ag-x")))
+	for _, a := range result.Actions {
+		if a.Status != StatusFailed || !strings.Contains(a.Output, "signature") {
+			t.Errorf("%s: status=%s output=%q", a.ID, a.Status, a.Output)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("unverified script was executed")
+	}
+}
+
+func TestCustomAction_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts")
+	}
+	key := newSigningKey(t)
+	script := signedScript(t, t.TempDir(), "slow.sh", "#!/bin/sh\necho started\nexec sleep 10\n", key)
+	e := customExecutor([]CustomAction{{
+		ID: "slow", CheckID: "ag-x", Script: script, Signature: script + ".sig", Timeout: 200 * time.Millisecond,
+	}}, key)
+
+	start := time.Now()
+	result := e.Execute(RemediationRequest{Actions: []ActionID{"slow"}}, e.Plan(failing("ag-x")))
+	a := result.Actions[0]
+	if a.Status != StatusFailed || !strings.Contains(a.Output, "timed out") || !strings.Contains(a.Output, "started") {
+		t.Errorf("status=%s output=%q", a.Status, a.Output)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout not enforced: took %s", elapsed)
+	}
+}
@@ -0,0 +1,308 @@
+package remediate
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/cfapi"
+)
+
+// Desired edge settings applied by edge remediation.
+const (
+	EdgeMinTLSVersion = "1.2"
+	EdgeHSTSMaxAge    = 31536000 // one year
+)
+
+// edgeCheckActions maps cfapi edge checklist items to the action fixing them.
+var edgeCheckActions = map[string]ActionID{
+	"ce-5": ActionFixEdgeCiphers,
+	"ce-6": ActionFixMinTLS,
+	"ce-8": ActionEnableHSTS,
+}
+
+// EdgeClient is the subset of the Cloudflare API client used to read and
+// change zone settings. *cfapi.Client implements it.
+type EdgeClient interface {
+	GetCiphers(zoneID string) ([]string, error)
+	GetMinTLSVersion(zoneID string) (string, error)
+	GetSecurityHeader(zoneID string) (*cfapi.SecurityHeader, error)
+	SetCiphers(zoneID string, ciphers []string) error
+	SetMinTLSVersion(zoneID, version string) error
+	SetHSTS(zoneID string, enabled bool, maxAge int, includeSubdomains, preload bool) error
+}
+
+// EdgeRemediator fixes Cloudflare edge settings for a zone from the
+// controller. Unlike node actions, these run against the Cloudflare API
+// rather than on an agent.
+type EdgeRemediator struct {
+	client EdgeClient
+	zoneID string
+	check  func() compliance.Section
+	logger *log.Logger
+}
+
+// EdgeRemediatorConfig holds configuration for edge remediation.
+type EdgeRemediatorConfig struct {
+	Client EdgeClient
+	ZoneID string
+	// Check runs the edge compliance checks. It feeds Current and is re-run
+	// to verify changes once applied. Typically
+	// (*cfapi.ComplianceChecker).RunEdgeChecks.
+	Check  func() compliance.Section
+	Logger *log.Logger
+}
+
+// NewEdgeRemediator creates an edge remediator for one zone.
+func NewEdgeRemediator(cfg EdgeRemediatorConfig) *EdgeRemediator {
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	return &EdgeRemediator{
+		client: cfg.Client,
+		zoneID: cfg.ZoneID,
+		check:  cfg.Check,
+		logger: cfg.Logger,
+	}
+}
+
+// ZoneID returns the zone the remediator changes.
+func (er *EdgeRemediator) ZoneID() string {
+	return er.zoneID
+}
+
+// Current runs the edge compliance checks, returning an empty section when
+// no check function is configured.
+func (er *EdgeRemediator) Current() compliance.Section {
+	if er.check == nil {
+		return compliance.Section{ID: "edge"}
+	}
+	return er.check()
+}
+
+// Plan returns the edge actions for the failing items of an edge compliance
+// section, each with a diff of the current and desired zone settings.
+// Items whose status is unknown (the API could not be queried) are skipped.
+func (er *EdgeRemediator) Plan(section compliance.Section) []RemediationAction {
+	var actions []RemediationAction
+	for _, item := range section.Items {
+		id, ok := edgeCheckActions[item.ID]
+		if !ok || item.Status == compliance.StatusPass || item.Status == compliance.StatusUnknown {
+			continue
+		}
+		action := RemediationAction{
+			ID:           id,
+			Description:  edgeActionDescription(id),
+			AutoExec:     true,
+			Instructions: item.Remediation,
+			Status:       StatusPending,
+			CheckID:      item.ID,
+		}
+		changes, err := er.diff(id)
+		if err != nil {
+			action.Output = fmt.Sprintf("cannot read current setting: %v", err)
+		}
+		action.Changes = changes
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// Execute applies the requested edge actions. A dry run only reports the
+// diff. Each applied action records the prior zone setting for rollback.
+// After applying, the edge checks are re-run and each action records
+// whether its check now passes.
+func (er *EdgeRemediator) Execute(req RemediationRequest, available []RemediationAction) RemediationResult {
+	result := RemediationResult{
+		RequestID: req.ID,
+		NodeID:    req.NodeID,
+		DryRun:    req.DryRun,
+	}
+
+	actionMap := make(map[ActionID]*RemediationAction)
+	for i := range available {
+		actionMap[available[i].ID] = &available[i]
+	}
+
+	applied := false
+	for _, actionID := range req.Actions {
+		action, ok := actionMap[actionID]
+		if !ok {
+			result.Actions = append(result.Actions, RemediationAction{
+				ID:     actionID,
+				Status: StatusSkipped,
+				Output: "action not applicable to current edge compliance state",
+			})
+			continue
+		}
+
+		changes, err := er.diff(actionID)
+		if err != nil {
+			action.Status = StatusFailed
+			action.Output = fmt.Sprintf("error: read current setting: %v", err)
+			result.Actions = append(result.Actions, *action)
+			continue
+		}
+		action.Changes = changes
+
+		if req.DryRun {
+			action.Status = StatusPending
+			action.Output = "dry run — would change:\n" + FormatChanges(changes)
+			result.Actions = append(result.Actions, *action)
+			continue
+		}
+
+		prior, err := er.capture(actionID)
+		if err != nil {
+			action.Status = StatusFailed
+			action.Output = fmt.Sprintf("error: capture prior state: %v", err)
+			result.Actions = append(result.Actions, *action)
+			continue
+		}
+		action.PriorState = prior
+
+		er.logger.Printf("remediate: executing %s on zone %s", actionID, er.zoneID)
+		if err := er.apply(actionID); err != nil {
+			action.Status = StatusFailed
+			action.Output = fmt.Sprintf("error: %v", err)
+			er.logger.Printf("remediate: %s failed: %v", actionID, err)
+		} else {
+			action.Status = StatusSuccess
+			action.Output = "changed:\n" + FormatChanges(changes)
+			applied = true
+			er.logger.Printf("remediate: %s succeeded", actionID)
+		}
+		result.Actions = append(result.Actions, *action)
+	}
+
+	if applied && er.check != nil {
+		recordVerification(result.Actions, er.check(), true)
+	}
+
+	result.CompletedAt = time.Now().UTC()
+	return result
+}
+
+// diff reads the current zone setting an action changes and returns it
+// alongside the desired value. Settings already at the desired value are
+// omitted.
+func (er *EdgeRemediator) diff(id ActionID) ([]SettingChange, error) {
+	switch id {
+	case ActionFixEdgeCiphers:
+		current, err := er.client.GetCiphers(er.zoneID)
+		if err != nil {
+			return nil, err
+		}
+		if sameSet(current, cfapi.FIPSApprovedCipherList) {
+			return nil, nil
+		}
+		return []SettingChange{{Setting: "ciphers", Current: current, Desired: cfapi.FIPSApprovedCipherList}}, nil
+	case ActionFixMinTLS:
+		current, err := er.client.GetMinTLSVersion(er.zoneID)
+		if err != nil {
+			return nil, err
+		}
+		if current == EdgeMinTLSVersion {
+			return nil, nil
+		}
+		return []SettingChange{{Setting: "min_tls_version", Current: current, Desired: EdgeMinTLSVersion}}, nil
+	case ActionEnableHSTS:
+		header, err := er.client.GetSecurityHeader(er.zoneID)
+		if err != nil {
+			return nil, err
+		}
+		current := *header
+		desired := current
+		desired.StrictTransportSecurity.Enabled = true
+		desired.StrictTransportSecurity.IncludeSubdomains = true
+		desired.StrictTransportSecurity.NoSniff = true // always sent by SetHSTS
+		if desired.StrictTransportSecurity.MaxAge < EdgeHSTSMaxAge {
+			desired.StrictTransportSecurity.MaxAge = EdgeHSTSMaxAge
+		}
+		if desired == current {
+			return nil, nil
+		}
+		return []SettingChange{{Setting: "security_header", Current: current, Desired: desired}}, nil
+	}
+	return nil, fmt.Errorf("no edge executor for action %s", id)
+}
+
+// apply changes the zone setting for an action.
+func (er *EdgeRemediator) apply(id ActionID) error {
+	switch id {
+	case ActionFixEdgeCiphers:
+		return er.client.SetCiphers(er.zoneID, cfapi.FIPSApprovedCipherList)
+	case ActionFixMinTLS:
+		return er.client.SetMinTLSVersion(er.zoneID, EdgeMinTLSVersion)
+	case ActionEnableHSTS:
+		header, err := er.client.GetSecurityHeader(er.zoneID)
+		if err != nil {
+			return err
+		}
+		maxAge := header.StrictTransportSecurity.MaxAge
+		if maxAge < EdgeHSTSMaxAge {
+			maxAge = EdgeHSTSMaxAge
+		}
+		// Preload is left as-is: submitting to browser preload lists is
+		// hard to undo and is an operator decision.
+		return er.client.SetHSTS(er.zoneID, true, maxAge, true, header.StrictTransportSecurity.Preload)
+	}
+	return fmt.Errorf("no edge executor for action %s", id)
+}
+
+// IsEdgeAction reports whether an action changes Cloudflare zone settings
+// and so runs on the controller rather than an agent.
+func IsEdgeAction(id ActionID) bool {
+	switch id {
+	case ActionFixEdgeCiphers, ActionFixMinTLS, ActionEnableHSTS:
+		return true
+	}
+	return false
+}
+
+func edgeActionDescription(id ActionID) string {
+	switch id {
+	case ActionFixEdgeCiphers:
+		return "Restrict edge cipher suites to the FIPS-approved list"
+	case ActionFixMinTLS:
+		return "Set edge minimum TLS version to " + EdgeMinTLSVersion
+	case ActionEnableHSTS:
+		return "Enable HSTS on the edge"
+	}
+	return string(id)
+}
+
+// FormatChanges renders setting changes as "setting: current -> desired"
+// lines.
+func FormatChanges(changes []SettingChange) string {
+	if len(changes) == 0 {
+		return "  (no change: already at desired value)"
+	}
+	var b strings.Builder
+	for i, c := range changes {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "  %s: %v -> %v", c.Setting, c.Current, c.Desired)
+	}
+	return b.String()
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if !strings.EqualFold(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package remediate
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/cfapi"
+)
+
+// fakeEdge is an in-memory zone whose settings feed a simplified edge check.
+type fakeEdge struct {
+	ciphers  []string
+	minTLS   string
+	header   cfapi.SecurityHeader
+	setErr   error
+	setCalls int
+}
+
+func (f *fakeEdge) GetCiphers(string) ([]string, error)     { return f.ciphers, nil }
+func (f *fakeEdge) GetMinTLSVersion(string) (string, error) { return f.minTLS, nil }
+func (f *fakeEdge) GetSecurityHeader(string) (*cfapi.SecurityHeader, error) {
+	h := f.header
+	return &h, nil
+}
+
+func (f *fakeEdge) SetCiphers(_ string, ciphers []string) error {
+	f.setCalls++
+	if f.setErr != nil {
+		return f.setErr
+	}
+	f.ciphers = ciphers
+	return nil
+}
+
+func (f *fakeEdge) SetMinTLSVersion(_ string, version string) error {
+	f.setCalls++
+	if f.setErr != nil {
+		return f.setErr
+	}
+	f.minTLS = version
+	return nil
+}
+
+func (f *fakeEdge) SetHSTS(_ string, enabled bool, maxAge int, includeSubdomains, preload bool) error {
+	f.setCalls++
+	if f.setErr != nil {
+		return f.setErr
+	}
+	hsts := &f.header.StrictTransportSecurity
+	hsts.Enabled = enabled
+	hsts.MaxAge = maxAge
+	hsts.IncludeSubdomains = includeSubdomains
+	hsts.Preload = preload
+	return nil
+}
+
+func (f *fakeEdge) section() compliance.Section {
+	status := func(ok bool, notOK compliance.Status) compliance.Status {
+		if ok {
+			return compliance.StatusPass
+		}
+		return notOK
+	}
+	return compliance.Section{ID: "edge", Items: []compliance.ChecklistItem{
+		{ID: "ce-5", Status: status(sameSet(f.ciphers, cfapi.FIPSApprovedCipherList), compliance.StatusWarning)},
+		{ID: "ce-6", Status: status(f.minTLS == "1.2" || f.minTLS == "1.3", compliance.StatusFail)},
+		{ID: "ce-8", Status: status(f.header.StrictTransportSecurity.Enabled, compliance.StatusFail)},
+	}}
+}
+
+func newTestEdge() (*fakeEdge, *EdgeRemediator) {
+	f := &fakeEdge{minTLS: "1.0"}
+	f.header.StrictTransportSecurity.MaxAge = 300
+	f.header.StrictTransportSecurity.Preload = true
+	er := NewEdgeRemediator(EdgeRemediatorConfig{
+		Client: f,
+		ZoneID: "zone-1",
+		Check:  f.section,
+		Logger: log.New(io.Discard, "", 0),
+	})
+	return f, er
+}
+
+func TestEdgePlan(t *testing.T) {
+	f, er := newTestEdge()
+	f.header.StrictTransportSecurity.Enabled = true
+	f.header.StrictTransportSecurity.IncludeSubdomains = true
+	f.header.StrictTransportSecurity.MaxAge = EdgeHSTSMaxAge
+
+	section := er.Current()
+	section.Items = append(section.Items, compliance.ChecklistItem{ID: "ce-7", Status: compliance.StatusFail})
+	actions := er.Plan(section)
+	if len(actions) != 2 {
+		t.Fatalf("Plan returned %d actions, want 2: %+v", len(actions), actions)
+	}
+	if actions[0].ID != ActionFixEdgeCiphers || actions[0].CheckID != "ce-5" {
+		t.Errorf("actions[0] = %s/%s, want fix_edge_ciphers/ce-5", actions[0].ID, actions[0].CheckID)
+	}
+	if actions[1].ID != ActionFixMinTLS {
+		t.Errorf("actions[1] = %s, want fix_min_tls", actions[1].ID)
+	}
+	if c := actions[1].Changes; len(c) != 1 || c[0].Current != "1.0" || c[0].Desired != "1.2" {
+		t.Errorf("min TLS changes = %+v", c)
+	}
+
+	unknown := compliance.Section{Items: []compliance.ChecklistItem{{ID: "ce-6", Status: compliance.StatusUnknown}}}
+	if got := er.Plan(unknown); len(got) != 0 {
+		t.Errorf("unknown items should not be planned, got %+v", got)
+	}
+}
+
+func TestEdgeExecute_DryRun(t *testing.T) {
+	f, er := newTestEdge()
+	req := RemediationRequest{ID: "r1", Actions: []ActionID{ActionFixMinTLS, ActionEnableHSTS}, DryRun: true}
+	result := er.Execute(req, er.Plan(er.Current()))
+
+	if f.setCalls != 0 {
+		t.Errorf("dry run made %d API writes", f.setCalls)
+	}
+	if len(result.Actions) != 2 {
+		t.Fatalf("got %d actions, want 2", len(result.Actions))
+	}
+	for _, a := range result.Actions {
+		if a.Status != StatusPending || len(a.Changes) == 0 {
+			t.Errorf("%s: status=%s changes=%v", a.ID, a.Status, a.Changes)
+		}
+		if !strings.Contains(a.Output, "dry run") {
+			t.Errorf("%s output = %q", a.ID, a.Output)
+		}
+	}
+	hsts := result.Actions[1].Changes[0].Desired.(cfapi.SecurityHeader).StrictTransportSecurity
+	if !hsts.Enabled || !hsts.IncludeSubdomains || hsts.MaxAge != EdgeHSTSMaxAge || !hsts.Preload {
+		t.Errorf("desired HSTS = %+v", hsts)
+	}
+}
+
+func TestEdgeExecute_AppliesAndVerifies(t *testing.T) {
+	f, er := newTestEdge()
+	req := RemediationRequest{ID: "r2", Actions: []ActionID{ActionFixEdgeCiphers, ActionFixMinTLS, ActionEnableHSTS, ActionEnableOSFIPS}}
+	result := er.Execute(req, er.Plan(er.Current()))
+
+	if len(result.Actions) != 4 {
+		t.Fatalf("got %d actions, want 4", len(result.Actions))
+	}
+	for _, a := range result.Actions[:3] {
+		if a.Status != StatusSuccess {
+			t.Errorf("%s: status = %s, output = %q", a.ID, a.Status, a.Output)
+		}
+		if a.Verification == nil || a.Verification.Status != string(compliance.StatusPass) {
+			t.Errorf("%s: verification = %+v", a.ID, a.Verification)
+		}
+	}
+	if result.Actions[3].Status != StatusSkipped {
+		t.Errorf("non-edge action status = %s, want skipped", result.Actions[3].Status)
+	}
+	hsts := f.header.StrictTransportSecurity
+	if f.minTLS != "1.2" || !hsts.Enabled || !hsts.Preload || hsts.MaxAge != EdgeHSTSMaxAge {
+		t.Errorf("zone not updated: minTLS=%s hsts=%+v", f.minTLS, hsts)
+	}
+}
+
+func TestEdgeExecute_VerificationFailure(t *testing.T) {
+	f, er := newTestEdge()
+	// The check keeps reporting the old setting, as if the change did not stick.
+	er.check = func() compliance.Section {
+		s := f.section()
+		s.Items[1].Status = compliance.StatusFail
+		return s
+	}
+	result := er.Execute(RemediationRequest{Actions: []ActionID{ActionFixMinTLS}}, er.Plan(f.section()))
+	a := result.Actions[0]
+	if a.Status != StatusFailed || !strings.Contains(a.Output, "verification failed") {
+		t.Errorf("status=%s output=%q", a.Status, a.Output)
+	}
+}
+
+func TestEdgeExecute_APIError(t *testing.T) {
+	f, er := newTestEdge()
+	available := er.Plan(er.Current())
+	f.setErr = errors.New("403 forbidden")
+	result := er.Execute(RemediationRequest{Actions: []ActionID{ActionFixMinTLS}}, available)
+	a := result.Actions[0]
+	if a.Status != StatusFailed || !strings.Contains(a.Output, "403") {
+		t.Errorf("status=%s output=%q", a.Status, a.Output)
+	}
+	if a.Verification != nil {
+		t.Errorf("failed action should not be verified: %+v", a.Verification)
+	}
+}
+
+func TestFormatChanges(t *testing.T) {
+	if got := FormatChanges(nil); !strings.Contains(got, "no change") {
+		t.Errorf("FormatChanges(nil) = %q", got)
+	}
+	got := FormatChanges([]SettingChange{{Setting: "min_tls_version", Current: "1.0", Desired: "1.2"}})
+	if got != "  min_tls_version: 1.0 -> 1.2" {
+		t.Errorf("FormatChanges = %q", got)
+	}
+}
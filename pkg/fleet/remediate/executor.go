@@ -1,6 +1,7 @@
 package remediate
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"log"
 	"time"
@@ -10,15 +11,33 @@ import (
 
 // Executor plans and executes remediation actions based on compliance failures.
 type Executor struct {
-	logger *log.Logger
+	logger      *log.Logger
+	custom      []CustomAction
+	trustedKeys []*ecdsa.PublicKey
+}
+
+// ExecutorOption configures an Executor.
+type ExecutorOption func(*Executor)
+
+// WithCustomActions adds operator-defined actions. Their scripts must be
+// signed by one of keys; the signature is verified before every run.
+func WithCustomActions(actions []CustomAction, keys []*ecdsa.PublicKey) ExecutorOption {
+	return func(e *Executor) {
+		e.custom = actions
+		e.trustedKeys = keys
+	}
 }
 
 // NewExecutor creates a new remediation executor.
-func NewExecutor(logger *log.Logger) *Executor {
+func NewExecutor(logger *log.Logger, opts ...ExecutorOption) *Executor {
 	if logger == nil {
 		logger = log.Default()
 	}
-	return &Executor{logger: logger}
+	e := &Executor{logger: logger}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Plan analyzes a compliance section and returns available remediation actions.
@@ -35,6 +54,7 @@ func (e *Executor) Plan(section compliance.Section) []RemediationAction {
 		case "ag-fips":
 			actions = append(actions, RemediationAction{
 				ID:           ActionEnableOSFIPS,
+				CheckID:      "ag-fips",
 				Description:  "Enable OS FIPS mode",
 				AutoExec:     true,
 				Instructions: osFIPSInstructions(),
@@ -45,6 +65,7 @@ func (e *Executor) Plan(section compliance.Section) []RemediationAction {
 				item.Remediation == "WARP found but not on PATH or not running" {
 				actions = append(actions, RemediationAction{
 					ID:           ActionConnectWARP,
+					CheckID:      "ag-warp",
 					Description:  "Connect Cloudflare WARP",
 					AutoExec:     true,
 					Instructions: "Run: warp-cli connect",
@@ -53,6 +74,7 @@ func (e *Executor) Plan(section compliance.Section) []RemediationAction {
 			} else {
 				actions = append(actions, RemediationAction{
 					ID:           ActionInstallWARP,
+					CheckID:      "ag-warp",
 					Description:  "Install Cloudflare WARP client",
 					AutoExec:     true,
 					Instructions: warpInstallInstructions(),
@@ -62,6 +84,7 @@ func (e *Executor) Plan(section compliance.Section) []RemediationAction {
 		case "ag-disk":
 			actions = append(actions, RemediationAction{
 				ID:           ActionEnableDiskEnc,
+				CheckID:      "ag-disk",
 				Description:  "Enable full-disk encryption",
 				AutoExec:     false,
 				Instructions: diskEncInstructions(),
@@ -70,12 +93,26 @@ func (e *Executor) Plan(section compliance.Section) []RemediationAction {
 		case "ag-mdm":
 			actions = append(actions, RemediationAction{
 				ID:           ActionEnrollMDM,
+				CheckID:      "ag-mdm",
 				Description:  "Enroll device in MDM",
 				AutoExec:     false,
 				Instructions: "Contact your IT department to enroll this device in\nMicrosoft Intune or Jamf Pro.",
 				Status:       StatusManualOnly,
 			})
 		}
+
+		for _, c := range e.custom {
+			if c.CheckID == item.ID {
+				actions = append(actions, RemediationAction{
+					ID:           c.ID,
+					CheckID:      c.CheckID,
+					Description:  c.Description,
+					AutoExec:     true,
+					Instructions: "Run signed script: " + c.Script,
+					Status:       StatusPending,
+				})
+			}
+		}
 	}
 
 	return actions
@@ -95,6 +132,17 @@ func (e *Executor) Execute(req RemediationRequest, available []RemediationAction
 	for i := range available {
 		actionMap[available[i].ID] = &available[i]
 	}
+	// A reboot is always available; the controller only requests it to
+	// complete earlier needs_reboot actions.
+	if _, ok := actionMap[ActionReboot]; !ok {
+		actionMap[ActionReboot] = &RemediationAction{
+			ID:           ActionReboot,
+			Description:  "Reboot to complete remediation",
+			AutoExec:     true,
+			Instructions: "Reboot the host",
+			Status:       StatusPending,
+		}
+	}
 
 	for _, actionID := range req.Actions {
 		action, ok := actionMap[actionID]
@@ -113,13 +161,25 @@ func (e *Executor) Execute(req RemediationRequest, available []RemediationAction
 			continue
 		}
 
+		custom, isCustom := e.customAction(actionID)
 		if req.DryRun {
 			action.Status = StatusPending
 			action.Output = "dry run — would execute"
+			if isCustom {
+				if _, err := verifyScript(custom, e.trustedKeys); err != nil {
+					action.Status = StatusFailed
+					action.Output = fmt.Sprintf("error: %v", err)
+				} else {
+					action.Output = "dry run — signature verified, would run " + custom.Script
+				}
+			}
 			result.Actions = append(result.Actions, *action)
 			continue
 		}
 
+		// Capture what the action changes so it can be rolled back
+		action.PriorState = capturePriorState(actionID)
+
 		// Execute the action
 		action.Status = StatusExecuting
 		e.logger.Printf("remediate: executing %s", actionID)
@@ -128,13 +188,20 @@ func (e *Executor) Execute(req RemediationRequest, available []RemediationAction
 		var output string
 		var needsReboot bool
 
-		switch actionID {
-		case ActionEnableOSFIPS:
+		switch {
+		case isCustom:
+			output, err = runCustomAction(custom, e.trustedKeys)
+			needsReboot = custom.NeedsReboot
+		case actionID == ActionEnableOSFIPS:
 			output, needsReboot, err = enableOSFIPS()
-		case ActionInstallWARP:
+		case actionID == ActionInstallWARP:
 			output, err = installWARP()
-		case ActionConnectWARP:
+		case actionID == ActionConnectWARP:
 			output, err = connectWARP()
+		case actionID == ActionReboot:
+			output, err = scheduleReboot()
+		case IsEdgeAction(actionID):
+			err = fmt.Errorf("%s changes Cloudflare zone settings and runs on the controller (EdgeRemediator)", actionID)
 		default:
 			err = fmt.Errorf("no executor for action %s", actionID)
 		}
@@ -158,3 +225,13 @@ func (e *Executor) Execute(req RemediationRequest, available []RemediationAction
 	result.CompletedAt = time.Now().UTC()
 	return result
 }
+
+// customAction returns the operator-defined action with the given ID.
+func (e *Executor) customAction(id ActionID) (CustomAction, bool) {
+	for _, c := range e.custom {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return CustomAction{}, false
+}
@@ -20,6 +20,7 @@ func TestIsAutoRemediable(t *testing.T) {
 		{ActionEnableHSTS, true},
 		{ActionEnableDiskEnc, false},
 		{ActionEnrollMDM, false},
+		{ActionReboot, true},
 		{ActionID("unknown"), false},
 	}
 	for _, tt := range tests {
@@ -156,6 +157,25 @@ func TestExecuteUnknownAction(t *testing.T) {
 	}
 }
 
+func TestExecuteRebootAlwaysAvailable(t *testing.T) {
+	exec := NewExecutor(log.Default())
+	req := RemediationRequest{
+		ID:      "test-req-3",
+		NodeID:  "node-1",
+		Actions: []ActionID{ActionReboot},
+		DryRun:  true,
+	}
+
+	// The reboot is never planned, but the controller may still request it.
+	result := exec.Execute(req, nil)
+	if len(result.Actions) != 1 {
+		t.Fatalf("expected 1 action, got %d", len(result.Actions))
+	}
+	if result.Actions[0].Status != StatusPending {
+		t.Errorf("expected pending status, got %s", result.Actions[0].Status)
+	}
+}
+
 func TestPlanWARPNotInstalled(t *testing.T) {
 	section := compliance.Section{
 		Items: []compliance.ChecklistItem{
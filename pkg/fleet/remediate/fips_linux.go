@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 func enableOSFIPS() (output string, needsReboot bool, err error) {
@@ -46,6 +47,106 @@ func enableOSFIPS() (output string, needsReboot bool, err error) {
 	return "", false, fmt.Errorf("no FIPS enablement tool found (need fips-mode-setup or ua/pro)")
 }
 
+// captureOSFIPSState records FIPS mode, the system crypto policy, and the
+// default boot entry's kernel arguments before FIPS mode is enabled.
+func captureOSFIPSState() *PriorState {
+	st := &PriorState{CapturedAt: time.Now().UTC()}
+	if data, err := os.ReadFile("/proc/sys/crypto/fips_enabled"); err == nil {
+		st.FIPSEnabled = strings.TrimSpace(string(data)) == "1"
+	}
+	if path, err := exec.LookPath("update-crypto-policies"); err == nil {
+		if out, err := exec.Command(path, "--show").Output(); err == nil {
+			st.CryptoPolicy = strings.TrimSpace(string(out))
+		}
+	}
+	if path, err := exec.LookPath("grubby"); err == nil {
+		if out, err := exec.Command(path, "--info=DEFAULT").Output(); err == nil {
+			st.KernelArgs, _ = parseGrubbyArgs(string(out))
+		}
+	}
+	if st.KernelArgs == "" {
+		if data, err := os.ReadFile("/etc/default/grub"); err == nil {
+			st.KernelArgs, _ = parseGrubDefault(string(data))
+		}
+	}
+	return st
+}
+
+// restoreOSFIPS undoes enableOSFIPS from the captured state: FIPS mode is
+// disabled, a non-default crypto policy is restored, and fips=1 is removed
+// from the kernel arguments if it was not there before.
+func restoreOSFIPS(prior *PriorState) (output string, needsReboot bool, err error) {
+	if prior.FIPSEnabled {
+		return "OS FIPS mode was already enabled before remediation; nothing to roll back", false, nil
+	}
+
+	var out strings.Builder
+	run := func(path string, args ...string) error {
+		b, cmdErr := exec.Command(path, args...).CombinedOutput()
+		out.Write(b)
+		if cmdErr != nil {
+			return fmt.Errorf("%s %s failed: %w", path, strings.Join(args, " "), cmdErr)
+		}
+		return nil
+	}
+
+	path, lookErr := exec.LookPath("fips-mode-setup")
+	if lookErr != nil {
+		if _, proErr := exec.LookPath("pro"); proErr == nil {
+			return "", false, fmt.Errorf("Ubuntu Pro FIPS cannot be disabled automatically; reinstall the generic kernel (see: pro help fips)")
+		}
+		return "", false, fmt.Errorf("no FIPS rollback tool found (need fips-mode-setup)")
+	}
+	if err := run(path, "--disable"); err != nil {
+		return out.String(), false, err
+	}
+	// fips-mode-setup --disable resets the policy to DEFAULT.
+	if prior.CryptoPolicy != "" && prior.CryptoPolicy != "DEFAULT" {
+		if p, e := exec.LookPath("update-crypto-policies"); e == nil {
+			if err := run(p, "--set", prior.CryptoPolicy); err != nil {
+				return out.String(), true, err
+			}
+		}
+	}
+	if !hasKernelArg(prior.KernelArgs, "fips=1") {
+		if p, e := exec.LookPath("grubby"); e == nil {
+			if err := run(p, "--update-kernel=ALL", "--remove-args=fips=1"); err != nil {
+				return out.String(), true, err
+			}
+		}
+	}
+	return out.String() + "\nFIPS mode disabled and prior crypto policy restored. A reboot is required.", true, nil
+}
+
+// parseGrubbyArgs extracts the args="..." value from grubby --info output.
+func parseGrubbyArgs(out string) (string, bool) {
+	for _, line := range strings.Split(out, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "args="); ok {
+			return strings.Trim(v, `"`), true
+		}
+	}
+	return "", false
+}
+
+// parseGrubDefault extracts GRUB_CMDLINE_LINUX from /etc/default/grub.
+func parseGrubDefault(data string) (string, bool) {
+	for _, line := range strings.Split(data, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "GRUB_CMDLINE_LINUX="); ok {
+			return strings.Trim(v, `"'`), true
+		}
+	}
+	return "", false
+}
+
+func hasKernelArg(args, arg string) bool {
+	for _, f := range strings.Fields(args) {
+		if f == arg {
+			return true
+		}
+	}
+	return false
+}
+
 func osFIPSInstructions() string {
 	return "RHEL/CentOS/Alma: sudo fips-mode-setup --enable && sudo reboot\n" +
 		"Ubuntu Pro: sudo pro enable fips && sudo reboot\n" +
@@ -0,0 +1,24 @@
+//go:build linux
+
+package remediate
+
+import "testing"
+
+func TestParseKernelArgs(t *testing.T) {
+	grubby := "index=0\nkernel=\"/boot/vmlinuz-5.14.0\"\nargs=\"ro crashkernel=auto fips=1 rhgb quiet\"\nroot=\"/dev/mapper/rhel-root\"\n"
+	if got, ok := parseGrubbyArgs(grubby); !ok || got != "ro crashkernel=auto fips=1 rhgb quiet" {
+		t.Errorf("parseGrubbyArgs = %q, %v", got, ok)
+	}
+	if _, ok := parseGrubbyArgs("index=0\n"); ok {
+		t.Error("parseGrubbyArgs without args line should fail")
+	}
+
+	grub := "GRUB_TIMEOUT=5\nGRUB_CMDLINE_LINUX_DEFAULT=\"quiet splash\"\nGRUB_CMDLINE_LINUX=\"console=ttyS0\"\n"
+	if got, ok := parseGrubDefault(grub); !ok || got != "console=ttyS0" {
+		t.Errorf("parseGrubDefault = %q, %v", got, ok)
+	}
+
+	if !hasKernelArg("ro fips=1 quiet", "fips=1") || hasKernelArg("ro fips=10", "fips=1") {
+		t.Error("hasKernelArg matched incorrectly")
+	}
+}
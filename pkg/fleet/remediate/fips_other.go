@@ -21,6 +21,14 @@ func enableOSFIPS() (output string, needsReboot bool, err error) {
 	}
 }
 
+// captureOSFIPSState returns nil: enableOSFIPS changes nothing on these
+// platforms, so there is nothing to roll back.
+func captureOSFIPSState() *PriorState { return nil }
+
+func restoreOSFIPS(*PriorState) (output string, needsReboot bool, err error) {
+	return "", false, fmt.Errorf("OS FIPS rollback not supported on %s", runtime.GOOS)
+}
+
 func osFIPSInstructions() string {
 	switch runtime.GOOS {
 	case "darwin":
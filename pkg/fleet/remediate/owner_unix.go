@@ -0,0 +1,16 @@
+//go:build !windows
+
+package remediate
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns a file's numeric owner and group.
+func fileOwner(info os.FileInfo) (uid, gid int) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return -1, -1
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package remediate
+
+import "os"
+
+// fileOwner reports no ownership; Windows files have ACLs rather than
+// numeric owners.
+func fileOwner(os.FileInfo) (uid, gid int) {
+	return -1, -1
+}
@@ -0,0 +1,363 @@
+package remediate
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+)
+
+// MaxSecretFileMode is the widest mode left on secret files and the audit
+// log: owner read/write, group read.
+const MaxSecretFileMode os.FileMode = 0o640
+
+// permCheckActions maps security operations checklist items to the action
+// fixing them.
+var permCheckActions = map[string]ActionID{
+	"so-5":  ActionFixSecretPerms,
+	"so-11": ActionFixAuditLogPerms,
+}
+
+// PermRemediator tightens the modes and ownership of secret files and the
+// audit log on the dashboard host.
+type PermRemediator struct {
+	secretFiles  func() []string
+	auditLogPath func() string
+	uid, gid     int // -1 leaves ownership unchanged
+	check        func() compliance.Section
+	logger       *log.Logger
+}
+
+// PermRemediatorConfig holds configuration for file permission remediation.
+type PermRemediatorConfig struct {
+	// SecretFiles lists the secret files to tighten. Typically
+	// (*compliance.LiveChecker).SecretFiles.
+	SecretFiles func() []string
+	// AuditLogPath returns the audit log file. Typically
+	// (*compliance.LiveChecker).AuditLogPath.
+	AuditLogPath func() string
+	// Owner is "user:group" (names or numeric IDs) to chown files to. Empty
+	// leaves ownership unchanged; "user" alone leaves the group unchanged.
+	Owner string
+	// Check runs the security operations checks. It feeds Current and is
+	// re-run to verify changes once applied.
+	Check  func() compliance.Section
+	Logger *log.Logger
+}
+
+// NewPermRemediator creates a file permission remediator. It fails if the
+// configured owner cannot be resolved.
+func NewPermRemediator(cfg PermRemediatorConfig) (*PermRemediator, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	uid, gid, err := lookupOwner(cfg.Owner)
+	if err != nil {
+		return nil, err
+	}
+	return &PermRemediator{
+		secretFiles:  cfg.SecretFiles,
+		auditLogPath: cfg.AuditLogPath,
+		uid:          uid,
+		gid:          gid,
+		check:        cfg.Check,
+		logger:       cfg.Logger,
+	}, nil
+}
+
+// Current runs the security operations checks, returning an empty section
+// when no check function is configured.
+func (pr *PermRemediator) Current() compliance.Section {
+	if pr.check == nil {
+		return compliance.Section{ID: "security-ops"}
+	}
+	return pr.check()
+}
+
+// Plan returns the permission actions for failing items of a security
+// operations section, each with the files it would change.
+func (pr *PermRemediator) Plan(section compliance.Section) []RemediationAction {
+	var actions []RemediationAction
+	for _, item := range section.Items {
+		id, ok := permCheckActions[item.ID]
+		if !ok || item.Status == compliance.StatusPass || item.Status == compliance.StatusUnknown {
+			continue
+		}
+		action := RemediationAction{
+			ID:           id,
+			Description:  permActionDescription(id),
+			AutoExec:     true,
+			Instructions: item.Remediation,
+			Status:       StatusPending,
+			CheckID:      item.ID,
+		}
+		_, action.Changes = pr.diff(id)
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// Execute applies the requested permission actions. A dry run only reports
+// the diff. Each applied action records the prior mode and ownership of
+// the files it changed for rollback, and the checks are re-run afterwards
+// to verify the fix.
+func (pr *PermRemediator) Execute(req RemediationRequest, available []RemediationAction) RemediationResult {
+	result := RemediationResult{
+		RequestID: req.ID,
+		NodeID:    req.NodeID,
+		DryRun:    req.DryRun,
+	}
+
+	actionMap := make(map[ActionID]*RemediationAction)
+	for i := range available {
+		actionMap[available[i].ID] = &available[i]
+	}
+
+	applied := false
+	for _, actionID := range req.Actions {
+		action, ok := actionMap[actionID]
+		if !ok {
+			result.Actions = append(result.Actions, RemediationAction{
+				ID:     actionID,
+				Status: StatusSkipped,
+				Output: "action not applicable to current compliance state",
+			})
+			continue
+		}
+
+		targets, changes := pr.diff(actionID)
+		action.Changes = changes
+		if req.DryRun {
+			action.Status = StatusPending
+			action.Output = "dry run — would change:\n" + FormatChanges(changes)
+			result.Actions = append(result.Actions, *action)
+			continue
+		}
+
+		prior := &PriorState{CapturedAt: time.Now().UTC()}
+		for _, t := range targets {
+			prior.Files = append(prior.Files, t.current)
+		}
+		action.PriorState = prior
+
+		pr.logger.Printf("remediate: executing %s (%d files)", actionID, len(targets))
+		var err error
+		for _, t := range targets {
+			if err = applyFileState(t.current, t.desired); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			action.Status = StatusFailed
+			action.Output = fmt.Sprintf("error: %v", err)
+			pr.logger.Printf("remediate: %s failed: %v", actionID, err)
+		} else {
+			action.Status = StatusSuccess
+			action.Output = "changed:\n" + FormatChanges(changes)
+			applied = true
+			pr.logger.Printf("remediate: %s succeeded", actionID)
+		}
+		result.Actions = append(result.Actions, *action)
+	}
+
+	if applied && pr.check != nil {
+		recordVerification(result.Actions, pr.check(), true)
+	}
+
+	result.CompletedAt = time.Now().UTC()
+	return result
+}
+
+// Rollback restores the file modes and ownership captured before the
+// actions of req.Previous were applied.
+func (pr *PermRemediator) Rollback(req RemediationRequest) RemediationResult {
+	result := newRollbackResult(req)
+	if req.Previous == nil {
+		result.Actions = append(result.Actions, RemediationAction{
+			Status: StatusFailed,
+			Output: "rollback request carries no previous result",
+		})
+		return result
+	}
+
+	changed := false
+	for _, prev := range rollbackTargets(req) {
+		action := rollbackAction(prev)
+		if action.Status != StatusPending {
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+
+		var errs []string
+		for _, want := range prev.PriorState.Files {
+			now, err := statFile(want.Path)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if now == want {
+				continue
+			}
+			action.Changes = append(action.Changes, fileChange(now, want))
+			if req.DryRun {
+				continue
+			}
+			if err := applyFileState(now, want); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		switch {
+		case req.DryRun:
+			action.Output = "dry run — would restore:\n" + FormatChanges(action.Changes)
+		case len(errs) > 0:
+			action.Status = StatusFailed
+			action.Output = "error: " + strings.Join(errs, "; ")
+			pr.logger.Printf("remediate: rollback of %s failed: %s", prev.ID, action.Output)
+		default:
+			action.Status = StatusSuccess
+			action.Output = "restored:\n" + FormatChanges(action.Changes)
+			changed = true
+			pr.logger.Printf("remediate: rolled back %s", prev.ID)
+		}
+		result.Actions = append(result.Actions, action)
+	}
+
+	if changed && pr.check != nil {
+		recordVerification(result.Actions, pr.check(), false)
+	}
+	result.CompletedAt = time.Now().UTC()
+	return result
+}
+
+// fileTarget is a file an action changes.
+type fileTarget struct {
+	current, desired FileState
+}
+
+// diff returns the files an action would change and the corresponding
+// setting changes. Files already at the desired state are omitted, as are
+// files that cannot be read.
+func (pr *PermRemediator) diff(id ActionID) ([]fileTarget, []SettingChange) {
+	var paths []string
+	switch id {
+	case ActionFixSecretPerms:
+		if pr.secretFiles != nil {
+			paths = pr.secretFiles()
+		}
+	case ActionFixAuditLogPerms:
+		if pr.auditLogPath != nil {
+			if p := pr.auditLogPath(); p != "" {
+				paths = []string{p}
+			}
+		}
+	}
+
+	var targets []fileTarget
+	var changes []SettingChange
+	for _, path := range paths {
+		current, err := statFile(path)
+		if err != nil {
+			continue
+		}
+		desired := current
+		desired.Mode = current.Mode & MaxSecretFileMode
+		if pr.uid >= 0 {
+			desired.UID = pr.uid
+		}
+		if pr.gid >= 0 {
+			desired.GID = pr.gid
+		}
+		if desired == current {
+			continue
+		}
+		targets = append(targets, fileTarget{current: current, desired: desired})
+		changes = append(changes, fileChange(current, desired))
+	}
+	return targets, changes
+}
+
+func statFile(path string) (FileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileState{}, err
+	}
+	uid, gid := fileOwner(info)
+	return FileState{Path: path, Mode: info.Mode().Perm(), UID: uid, GID: gid}, nil
+}
+
+// applyFileState changes a file from current to desired mode and ownership.
+func applyFileState(current, desired FileState) error {
+	if desired.UID != current.UID || desired.GID != current.GID {
+		if err := os.Chown(desired.Path, desired.UID, desired.GID); err != nil {
+			return err
+		}
+	}
+	if desired.Mode != current.Mode {
+		if err := os.Chmod(desired.Path, desired.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileChange(current, desired FileState) SettingChange {
+	format := func(f FileState) string {
+		return fmt.Sprintf("%04o %d:%d", f.Mode, f.UID, f.GID)
+	}
+	return SettingChange{Setting: current.Path, Current: format(current), Desired: format(desired)}
+}
+
+// lookupOwner resolves "user:group" to numeric IDs, returning -1 for parts
+// left unset.
+func lookupOwner(spec string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if spec == "" {
+		return uid, gid, nil
+	}
+	userName, groupName, _ := strings.Cut(spec, ":")
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return -1, -1, fmt.Errorf("owner: %w", err)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return -1, -1, fmt.Errorf("owner: %w", err)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// IsPermAction reports whether an action changes file permissions on the
+// dashboard host.
+func IsPermAction(id ActionID) bool {
+	switch id {
+	case ActionFixSecretPerms, ActionFixAuditLogPerms:
+		return true
+	}
+	return false
+}
+
+func permActionDescription(id ActionID) string {
+	switch id {
+	case ActionFixSecretPerms:
+		return "Restrict secret file permissions to 0640 or stricter"
+	case ActionFixAuditLogPerms:
+		return "Restrict audit log permissions to 0640 or stricter"
+	}
+	return string(id)
+}
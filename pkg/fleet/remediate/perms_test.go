@@ -0,0 +1,156 @@
+package remediate
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+)
+
+// permSection is a simplified so-5/so-11 check over the given files.
+func permSection(secrets []string, auditLog string) compliance.Section {
+	status := func(paths ...string) compliance.Status {
+		for _, p := range paths {
+			info, err := os.Stat(p)
+			if err != nil {
+				return compliance.StatusUnknown
+			}
+			if info.Mode().Perm()&^MaxSecretFileMode != 0 {
+				return compliance.StatusFail
+			}
+		}
+		return compliance.StatusPass
+	}
+	return compliance.Section{ID: "security-ops", Items: []compliance.ChecklistItem{
+		{ID: "so-5", Status: status(secrets...)},
+		{ID: "so-11", Status: status(auditLog)},
+	}}
+}
+
+func newTestPerms(t *testing.T) (*PermRemediator, []string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX file modes")
+	}
+	dir := t.TempDir()
+	secrets := []string{filepath.Join(dir, "node-api-key"), filepath.Join(dir, "tls.key")}
+	modes := []os.FileMode{0o644, 0o600}
+	for i, p := range secrets {
+		if err := os.WriteFile(p, []byte("secret"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, modes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auditLog := filepath.Join(dir, "audit.json")
+	if err := os.WriteFile(auditLog, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(auditLog, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := NewPermRemediator(PermRemediatorConfig{
+		SecretFiles:  func() []string { return secrets },
+		AuditLogPath: func() string { return auditLog },
+		Check:        func() compliance.Section { return permSection(secrets, auditLog) },
+		Logger:       log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr, secrets, auditLog
+}
+
+func mode(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestPermRemediator_PlanAndDryRun(t *testing.T) {
+	pr, secrets, auditLog := newTestPerms(t)
+
+	plan := pr.Plan(pr.Current())
+	if len(plan) != 2 || plan[0].ID != ActionFixSecretPerms || plan[1].ID != ActionFixAuditLogPerms {
+		t.Fatalf("plan = %+v", plan)
+	}
+	// Only the world-readable secret needs changing.
+	if c := plan[0].Changes; len(c) != 1 || c[0].Setting != secrets[0] || !strings.HasPrefix(c[0].Desired.(string), "0640 ") {
+		t.Errorf("secret changes = %+v", c)
+	}
+
+	result := pr.Execute(RemediationRequest{Actions: []ActionID{ActionFixSecretPerms, ActionFixAuditLogPerms}, DryRun: true}, plan)
+	for _, a := range result.Actions {
+		if a.Status != StatusPending || !strings.Contains(a.Output, "dry run") {
+			t.Errorf("%s: status=%s output=%q", a.ID, a.Status, a.Output)
+		}
+	}
+	if mode(t, secrets[0]) != 0o644 || mode(t, auditLog) != 0o666 {
+		t.Error("dry run changed file modes")
+	}
+}
+
+func TestPermRemediator_ExecuteAndRollback(t *testing.T) {
+	pr, secrets, auditLog := newTestPerms(t)
+
+	req := RemediationRequest{ID: "r1", Actions: []ActionID{ActionFixSecretPerms, ActionFixAuditLogPerms}}
+	result := pr.Execute(req, pr.Plan(pr.Current()))
+	for _, a := range result.Actions {
+		if a.Status != StatusSuccess {
+			t.Errorf("%s: status=%s output=%q", a.ID, a.Status, a.Output)
+		}
+		if a.Verification == nil || a.Verification.Status != string(compliance.StatusPass) {
+			t.Errorf("%s: verification = %+v", a.ID, a.Verification)
+		}
+		if a.PriorState == nil {
+			t.Errorf("%s: no prior state recorded", a.ID)
+		}
+	}
+	if got := mode(t, secrets[0]); got != 0o640 {
+		t.Errorf("secret mode = %04o, want 0640", got)
+	}
+	if got := mode(t, secrets[1]); got != 0o600 {
+		t.Errorf("already-strict secret mode = %04o, want 0600", got)
+	}
+	if got := mode(t, auditLog); got != 0o640 {
+		t.Errorf("audit log mode = %04o, want 0640", got)
+	}
+	if prior := result.Actions[0].PriorState.Files; len(prior) != 1 || prior[0].Mode != 0o644 {
+		t.Errorf("prior files = %+v", prior)
+	}
+
+	rb := pr.Rollback(RemediationRequest{ID: "r2", Previous: &result})
+	for _, a := range rb.Actions {
+		if a.Status != StatusSuccess {
+			t.Errorf("rollback %s: status=%s output=%q", a.ID, a.Status, a.Output)
+		}
+	}
+	if mode(t, secrets[0]) != 0o644 || mode(t, auditLog) != 0o666 {
+		t.Errorf("rollback did not restore modes: %04o %04o", mode(t, secrets[0]), mode(t, auditLog))
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	if uid, gid, err := lookupOwner(""); err != nil || uid != -1 || gid != -1 {
+		t.Errorf("lookupOwner(\"\") = %d, %d, %v", uid, gid, err)
+	}
+	if uid, gid, err := lookupOwner("1000:1001"); err != nil || uid != 1000 || gid != 1001 {
+		t.Errorf("lookupOwner(numeric) = %d, %d, %v", uid, gid, err)
+	}
+	if uid, gid, err := lookupOwner("1000"); err != nil || uid != 1000 || gid != -1 {
+		t.Errorf("lookupOwner(user only) = %d, %d, %v", uid, gid, err)
+	}
+	if _, _, err := lookupOwner("no-such-user-xyz"); err == nil {
+		t.Error("expected error for unknown user")
+	}
+}
@@ -0,0 +1,33 @@
+package remediate
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// rebootMessage is broadcast to logged-in users by shutdown.
+const rebootMessage = "cloudflared-fips: rebooting to complete remediation"
+
+// scheduleReboot asks the OS to restart the host after RebootDelay.
+func scheduleReboot() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd":
+		cmd = exec.Command("shutdown", "-r", fmt.Sprintf("+%d", int(RebootDelay.Minutes())), rebootMessage)
+	case "windows":
+		cmd = exec.Command("shutdown", "/r", "/t", fmt.Sprintf("%d", int(RebootDelay.Seconds())), "/c", rebootMessage)
+	default:
+		return "", fmt.Errorf("reboot not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		return output, fmt.Errorf("shutdown: %w", err)
+	}
+	if output == "" {
+		output = fmt.Sprintf("reboot scheduled in %s", RebootDelay)
+	}
+	return output, nil
+}
@@ -3,10 +3,16 @@
 // Each remediation action is classified as either auto-remediable (safe to execute
 // programmatically) or manual-only (the system can only provide instructions).
 // Actions that require a reboot return a needs_reboot status rather than
-// automatically rebooting.
+// automatically rebooting; the controller schedules the reboot itself (the
+// reboot action) inside a maintenance window.
 package remediate
 
-import "time"
+import (
+	"os"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/pkg/cfapi"
+)
 
 // ActionID identifies a specific remediation action.
 type ActionID string
@@ -22,6 +28,21 @@ const (
 	ActionEnrollMDM       ActionID = "enroll_mdm"
 )
 
+// Host actions fix the dashboard host itself (see PermRemediator).
+const (
+	ActionFixSecretPerms   ActionID = "fix_secret_permissions"
+	ActionFixAuditLogPerms ActionID = "fix_audit_log_permissions"
+)
+
+// ActionReboot restarts the node. It is never planned from compliance
+// failures; the controller's reboot coordinator requests it to complete
+// needs_reboot actions.
+const ActionReboot ActionID = "reboot"
+
+// RebootDelay is how long after the reboot action succeeds the node
+// restarts, leaving the agent time to report the result.
+const RebootDelay = time.Minute
+
 // ActionStatus represents the outcome of a remediation action.
 type ActionStatus string
 
@@ -35,6 +56,14 @@ const (
 	StatusSkipped      ActionStatus = "skipped"
 )
 
+// RequestType distinguishes applying actions from undoing them.
+type RequestType string
+
+const (
+	RequestRemediate RequestType = "remediate"
+	RequestRollback  RequestType = "rollback"
+)
+
 // RemediationAction describes a single fix that can be applied.
 type RemediationAction struct {
 	ID           ActionID     `json:"id"`
@@ -43,6 +72,60 @@ type RemediationAction struct {
 	Instructions string       `json:"instructions"`    // Human-readable steps (always provided)
 	Status       ActionStatus `json:"status"`
 	Output       string       `json:"output,omitempty"` // Command output or error message
+
+	// CheckID is the compliance item the action fixes.
+	CheckID string `json:"check_id,omitempty"`
+	// Changes lists the settings the action changes, current vs desired.
+	Changes []SettingChange `json:"changes,omitempty"`
+	// Verification is the check's result re-run after the action.
+	Verification *Verification `json:"verification,omitempty"`
+	// PriorState is the state captured before the action ran; a rollback
+	// request restores it.
+	PriorState *PriorState `json:"prior_state,omitempty"`
+}
+
+// PriorState is the pre-change state of whatever an action modifies. Only
+// the fields relevant to the action are set.
+type PriorState struct {
+	CapturedAt time.Time `json:"captured_at"`
+
+	// OS FIPS mode (enable_os_fips)
+	FIPSEnabled  bool   `json:"fips_enabled,omitempty"`
+	CryptoPolicy string `json:"crypto_policy,omitempty"` // update-crypto-policies --show
+	KernelArgs   string `json:"kernel_args,omitempty"`   // default boot entry's kernel arguments
+
+	// WARP (connect_warp)
+	WARPConnected bool `json:"warp_connected,omitempty"`
+
+	// Edge zone settings (fix_edge_ciphers, fix_min_tls, enable_hsts)
+	Ciphers        []string              `json:"ciphers,omitempty"`
+	MinTLSVersion  string                `json:"min_tls_version,omitempty"`
+	SecurityHeader *cfapi.SecurityHeader `json:"security_header,omitempty"`
+
+	// File modes and ownership (fix_secret_permissions, fix_audit_log_permissions)
+	Files []FileState `json:"files,omitempty"`
+}
+
+// FileState is a file's permission bits and ownership.
+type FileState struct {
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+}
+
+// SettingChange is one setting an action changes.
+type SettingChange struct {
+	Setting string      `json:"setting"`
+	Current interface{} `json:"current"`
+	Desired interface{} `json:"desired"`
+}
+
+// Verification is the status of a compliance check re-run after an action.
+type Verification struct {
+	CheckID string `json:"check_id"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 // RemediationRequest is sent from the controller to an agent.
@@ -51,6 +134,13 @@ type RemediationRequest struct {
 	NodeID  string     `json:"node_id"`
 	Actions []ActionID `json:"actions"`
 	DryRun  bool       `json:"dry_run"`
+
+	// Type is RequestRemediate when empty.
+	Type RequestType `json:"type,omitempty"`
+	// Previous is the result being undone by a rollback request; its
+	// actions carry the captured prior state. Actions, if set, limits the
+	// rollback to those actions.
+	Previous *RemediationResult `json:"previous,omitempty"`
 }
 
 // RemediationResult is sent from the agent back to the controller.
@@ -60,13 +150,16 @@ type RemediationResult struct {
 	Actions     []RemediationAction `json:"actions"`
 	CompletedAt time.Time           `json:"completed_at"`
 	DryRun      bool                `json:"dry_run"`
+	Type        RequestType         `json:"type,omitempty"`
+	RollbackOf  string              `json:"rollback_of,omitempty"` // request ID rolled back
 }
 
 // IsAutoRemediable returns true if the action can be safely auto-executed.
 func IsAutoRemediable(id ActionID) bool {
 	switch id {
 	case ActionEnableOSFIPS, ActionInstallWARP, ActionConnectWARP,
-		ActionFixEdgeCiphers, ActionFixMinTLS, ActionEnableHSTS:
+		ActionFixEdgeCiphers, ActionFixMinTLS, ActionEnableHSTS,
+		ActionFixSecretPerms, ActionFixAuditLogPerms, ActionReboot:
 		return true
 	case ActionEnableDiskEnc, ActionEnrollMDM:
 		return false
@@ -0,0 +1,293 @@
+package remediate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+)
+
+// capturePriorState records the state an agent action is about to change.
+// It returns nil for actions with nothing to restore.
+func capturePriorState(id ActionID) *PriorState {
+	switch id {
+	case ActionEnableOSFIPS:
+		return captureOSFIPSState()
+	case ActionConnectWARP:
+		return &PriorState{CapturedAt: time.Now().UTC(), WARPConnected: warpConnected()}
+	}
+	return nil
+}
+
+// Rollback undoes the actions of req.Previous using their captured prior
+// state, then re-runs check (if non-nil) and records each rolled-back
+// action's check status. Verification is informational: a rolled-back fix
+// is expected to fail its check again.
+func (e *Executor) Rollback(req RemediationRequest, check func() compliance.Section) RemediationResult {
+	result := newRollbackResult(req)
+	if req.Previous == nil {
+		result.Actions = append(result.Actions, RemediationAction{
+			Status: StatusFailed,
+			Output: "rollback request carries no previous result",
+		})
+		return result
+	}
+
+	changed := false
+	for _, prev := range rollbackTargets(req) {
+		action := rollbackAction(prev)
+		if action.Status != StatusPending {
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+		if req.DryRun {
+			action.Output = "dry run — would restore prior state"
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+
+		e.logger.Printf("remediate: rolling back %s", prev.ID)
+		var err error
+		var output string
+		var needsReboot bool
+
+		switch prev.ID {
+		case ActionEnableOSFIPS:
+			output, needsReboot, err = restoreOSFIPS(prev.PriorState)
+		case ActionConnectWARP:
+			if prev.PriorState.WARPConnected {
+				output = "WARP was already connected before remediation; nothing to roll back"
+			} else {
+				output, err = disconnectWARP()
+			}
+		case ActionInstallWARP:
+			action.Status = StatusManualOnly
+			action.Output = "WARP installation is not rolled back automatically; remove the cloudflare-warp package to undo it"
+			result.Actions = append(result.Actions, action)
+			continue
+		default:
+			err = fmt.Errorf("no rollback for action %s", prev.ID)
+		}
+
+		action.Output = output
+		switch {
+		case err != nil:
+			action.Status = StatusFailed
+			action.Output = fmt.Sprintf("error: %v\n%s", err, output)
+			e.logger.Printf("remediate: rollback of %s failed: %v", prev.ID, err)
+		case needsReboot:
+			action.Status = StatusNeedsReboot
+			changed = true
+			e.logger.Printf("remediate: rolled back %s (reboot required)", prev.ID)
+		default:
+			action.Status = StatusSuccess
+			changed = true
+			e.logger.Printf("remediate: rolled back %s", prev.ID)
+		}
+		result.Actions = append(result.Actions, action)
+	}
+
+	if changed && check != nil {
+		recordVerification(result.Actions, check(), false)
+	}
+	result.CompletedAt = time.Now().UTC()
+	return result
+}
+
+// Rollback restores the zone settings captured before the edge actions of
+// req.Previous were applied, then re-runs the edge checks and records each
+// action's check status.
+func (er *EdgeRemediator) Rollback(req RemediationRequest) RemediationResult {
+	result := newRollbackResult(req)
+	if req.Previous == nil {
+		result.Actions = append(result.Actions, RemediationAction{
+			Status: StatusFailed,
+			Output: "rollback request carries no previous result",
+		})
+		return result
+	}
+
+	changed := false
+	for _, prev := range rollbackTargets(req) {
+		action := rollbackAction(prev)
+		if action.Status != StatusPending {
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+		prior := prev.PriorState
+		action.Changes = er.restoreChanges(prev.ID, prior)
+		if req.DryRun {
+			action.Output = "dry run — would restore:\n" + FormatChanges(action.Changes)
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+
+		er.logger.Printf("remediate: rolling back %s on zone %s", prev.ID, er.zoneID)
+		var err error
+		switch prev.ID {
+		case ActionFixEdgeCiphers:
+			ciphers := prior.Ciphers
+			if ciphers == nil {
+				ciphers = []string{} // Cloudflare default cipher set
+			}
+			err = er.client.SetCiphers(er.zoneID, ciphers)
+		case ActionFixMinTLS:
+			err = er.client.SetMinTLSVersion(er.zoneID, prior.MinTLSVersion)
+		case ActionEnableHSTS:
+			if prior.SecurityHeader == nil {
+				err = fmt.Errorf("no prior security header captured")
+				break
+			}
+			hsts := prior.SecurityHeader.StrictTransportSecurity
+			err = er.client.SetHSTS(er.zoneID, hsts.Enabled, hsts.MaxAge, hsts.IncludeSubdomains, hsts.Preload)
+		default:
+			err = fmt.Errorf("no edge rollback for action %s", prev.ID)
+		}
+
+		if err != nil {
+			action.Status = StatusFailed
+			action.Output = fmt.Sprintf("error: %v", err)
+			er.logger.Printf("remediate: rollback of %s failed: %v", prev.ID, err)
+		} else {
+			action.Status = StatusSuccess
+			action.Output = "restored:\n" + FormatChanges(action.Changes)
+			changed = true
+			er.logger.Printf("remediate: rolled back %s", prev.ID)
+		}
+		result.Actions = append(result.Actions, action)
+	}
+
+	if changed && er.check != nil {
+		recordVerification(result.Actions, er.check(), false)
+	}
+	result.CompletedAt = time.Now().UTC()
+	return result
+}
+
+// capture records the zone setting an edge action is about to change.
+func (er *EdgeRemediator) capture(id ActionID) (*PriorState, error) {
+	st := &PriorState{CapturedAt: time.Now().UTC()}
+	var err error
+	switch id {
+	case ActionFixEdgeCiphers:
+		st.Ciphers, err = er.client.GetCiphers(er.zoneID)
+	case ActionFixMinTLS:
+		st.MinTLSVersion, err = er.client.GetMinTLSVersion(er.zoneID)
+	case ActionEnableHSTS:
+		st.SecurityHeader, err = er.client.GetSecurityHeader(er.zoneID)
+	default:
+		return nil, fmt.Errorf("no edge executor for action %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// restoreChanges describes a rollback as current -> prior setting changes.
+// Current values that cannot be read are reported as unknown.
+func (er *EdgeRemediator) restoreChanges(id ActionID, prior *PriorState) []SettingChange {
+	now, err := er.capture(id)
+	if err != nil {
+		now = nil
+	}
+	current := func(v func(*PriorState) interface{}) interface{} {
+		if now == nil {
+			return "unknown"
+		}
+		return v(now)
+	}
+	switch id {
+	case ActionFixEdgeCiphers:
+		return []SettingChange{{Setting: "ciphers", Current: current(func(p *PriorState) interface{} { return p.Ciphers }), Desired: prior.Ciphers}}
+	case ActionFixMinTLS:
+		return []SettingChange{{Setting: "min_tls_version", Current: current(func(p *PriorState) interface{} { return p.MinTLSVersion }), Desired: prior.MinTLSVersion}}
+	case ActionEnableHSTS:
+		var desired interface{}
+		if prior.SecurityHeader != nil {
+			desired = *prior.SecurityHeader
+		}
+		return []SettingChange{{Setting: "security_header", Current: current(func(p *PriorState) interface{} { return *p.SecurityHeader }), Desired: desired}}
+	}
+	return nil
+}
+
+func newRollbackResult(req RemediationRequest) RemediationResult {
+	result := RemediationResult{
+		RequestID: req.ID,
+		NodeID:    req.NodeID,
+		DryRun:    req.DryRun,
+		Type:      RequestRollback,
+	}
+	if req.Previous != nil {
+		result.RollbackOf = req.Previous.RequestID
+	}
+	return result
+}
+
+// rollbackTargets returns the actions of req.Previous to undo, limited to
+// req.Actions when set.
+func rollbackTargets(req RemediationRequest) []RemediationAction {
+	if len(req.Actions) == 0 {
+		return req.Previous.Actions
+	}
+	want := make(map[ActionID]bool, len(req.Actions))
+	for _, id := range req.Actions {
+		want[id] = true
+	}
+	var out []RemediationAction
+	for _, a := range req.Previous.Actions {
+		if want[a.ID] {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// rollbackAction starts the rollback record for a previous action. Its
+// status is pending if the action changed something and has captured
+// state to restore, and skipped otherwise.
+func rollbackAction(prev RemediationAction) RemediationAction {
+	action := RemediationAction{
+		ID:          prev.ID,
+		Description: "Roll back: " + prev.Description,
+		AutoExec:    prev.AutoExec,
+		CheckID:     prev.CheckID,
+		Status:      StatusPending,
+		PriorState:  prev.PriorState,
+	}
+	switch {
+	case prev.Status != StatusSuccess && prev.Status != StatusNeedsReboot:
+		action.Status = StatusSkipped
+		action.Output = fmt.Sprintf("nothing to roll back: action was %s", prev.Status)
+	case prev.PriorState == nil:
+		action.Status = StatusSkipped
+		action.Output = "no prior state captured"
+	}
+	return action
+}
+
+// recordVerification records the post-change status of each completed
+// action's check. With requirePass, an action whose check does not pass is
+// marked failed.
+func recordVerification(actions []RemediationAction, section compliance.Section, requirePass bool) {
+	items := make(map[string]compliance.ChecklistItem, len(section.Items))
+	for _, item := range section.Items {
+		items[item.ID] = item
+	}
+	for i := range actions {
+		a := &actions[i]
+		if (a.Status != StatusSuccess && a.Status != StatusNeedsReboot) || a.CheckID == "" {
+			continue
+		}
+		item, ok := items[a.CheckID]
+		if !ok {
+			continue
+		}
+		a.Verification = &Verification{CheckID: item.ID, Status: string(item.Status), Detail: item.What}
+		if requirePass && item.Status != compliance.StatusPass {
+			a.Status = StatusFailed
+			a.Output += fmt.Sprintf("\nverification failed: %s is %s (%s)", item.ID, item.Status, item.What)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package remediate
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+)
+
+func TestEdgeRollback(t *testing.T) {
+	f, er := newTestEdge()
+	f.ciphers = []string{"ECDHE-RSA-CHACHA20-POLY1305"}
+	prev := er.Execute(RemediationRequest{ID: "r1", Actions: []ActionID{ActionFixEdgeCiphers, ActionFixMinTLS, ActionEnableHSTS}}, er.Plan(er.Current()))
+	for _, a := range prev.Actions {
+		if a.PriorState == nil {
+			t.Fatalf("%s: no prior state captured", a.ID)
+		}
+	}
+	if got := prev.Actions[1].PriorState.MinTLSVersion; got != "1.0" {
+		t.Errorf("captured min TLS = %q, want 1.0", got)
+	}
+
+	// Dry run reports the restore without writing.
+	writes := f.setCalls
+	dry := er.Rollback(RemediationRequest{ID: "rb0", Previous: &prev, DryRun: true})
+	if f.setCalls != writes {
+		t.Errorf("dry-run rollback made %d API writes", f.setCalls-writes)
+	}
+	if c := dry.Actions[1].Changes; len(c) != 1 || c[0].Current != "1.2" || c[0].Desired != "1.0" {
+		t.Errorf("dry-run changes = %+v", c)
+	}
+
+	result := er.Rollback(RemediationRequest{ID: "rb1", Previous: &prev})
+	if result.Type != RequestRollback || result.RollbackOf != "r1" {
+		t.Errorf("result type=%q rollback_of=%q", result.Type, result.RollbackOf)
+	}
+	for _, a := range result.Actions {
+		if a.Status != StatusSuccess {
+			t.Errorf("%s: status=%s output=%q", a.ID, a.Status, a.Output)
+		}
+		if a.Verification == nil {
+			t.Errorf("%s: no verification", a.ID)
+		}
+	}
+	// The restored settings fail their checks again, as before remediation.
+	if v := result.Actions[1].Verification; v != nil && v.Status != string(compliance.StatusFail) {
+		t.Errorf("ce-6 after rollback = %s, want fail", v.Status)
+	}
+	hsts := f.header.StrictTransportSecurity
+	if f.minTLS != "1.0" || len(f.ciphers) != 1 || hsts.Enabled || hsts.MaxAge != 300 || !hsts.Preload {
+		t.Errorf("zone not restored: minTLS=%s ciphers=%v hsts=%+v", f.minTLS, f.ciphers, hsts)
+	}
+}
+
+func TestEdgeRollback_SelectedActions(t *testing.T) {
+	f, er := newTestEdge()
+	prev := er.Execute(RemediationRequest{ID: "r1", Actions: []ActionID{ActionFixMinTLS, ActionEnableHSTS}}, er.Plan(er.Current()))
+
+	result := er.Rollback(RemediationRequest{Actions: []ActionID{ActionEnableHSTS}, Previous: &prev})
+	if len(result.Actions) != 1 || result.Actions[0].ID != ActionEnableHSTS {
+		t.Fatalf("actions = %+v", result.Actions)
+	}
+	if f.minTLS != "1.2" || f.header.StrictTransportSecurity.Enabled {
+		t.Errorf("minTLS=%s hsts enabled=%v", f.minTLS, f.header.StrictTransportSecurity.Enabled)
+	}
+}
+
+func TestExecutorRollback_Skips(t *testing.T) {
+	prev := RemediationResult{
+		RequestID: "r1",
+		Actions: []RemediationAction{
+			{ID: ActionEnableOSFIPS, CheckID: "ag-fips", Status: StatusFailed, PriorState: &PriorState{}},
+			{ID: ActionConnectWARP, CheckID: "ag-warp", Status: StatusSuccess},
+			{ID: ActionInstallWARP, CheckID: "ag-warp", Status: StatusSuccess, PriorState: &PriorState{}},
+			{ID: ActionEnableOSFIPS, CheckID: "ag-fips", Status: StatusNeedsReboot, PriorState: &PriorState{FIPSEnabled: true}},
+		},
+	}
+	checked := false
+	check := func() compliance.Section {
+		checked = true
+		return compliance.Section{Items: []compliance.ChecklistItem{{ID: "ag-fips", Status: compliance.StatusPass}}}
+	}
+
+	e := NewExecutor(log.New(io.Discard, "", 0))
+	result := e.Rollback(RemediationRequest{ID: "rb1", Previous: &prev}, check)
+
+	want := []ActionStatus{StatusSkipped, StatusSkipped, StatusManualOnly, StatusSuccess}
+	if len(result.Actions) != len(want) {
+		t.Fatalf("got %d actions, want %d", len(result.Actions), len(want))
+	}
+	for i, a := range result.Actions {
+		if a.Status != want[i] {
+			t.Errorf("action %d (%s): status=%s, want %s (%s)", i, a.ID, a.Status, want[i], a.Output)
+		}
+	}
+	if !strings.Contains(result.Actions[3].Output, "already enabled") {
+		t.Errorf("output = %q", result.Actions[3].Output)
+	}
+	if !checked || result.Actions[3].Verification == nil || result.Actions[3].Verification.Status != "pass" {
+		t.Errorf("rollback not verified: %+v", result.Actions[3].Verification)
+	}
+}
+
+func TestExecutorRollback_NoPrevious(t *testing.T) {
+	e := NewExecutor(log.New(io.Discard, "", 0))
+	result := e.Rollback(RemediationRequest{ID: "rb1", Type: RequestRollback}, nil)
+	if len(result.Actions) != 1 || result.Actions[0].Status != StatusFailed {
+		t.Errorf("actions = %+v", result.Actions)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
+	"strings"
 )
 
 func installWARP() (string, error) {
@@ -87,6 +88,29 @@ func connectWARP() (string, error) {
 	return string(out), nil
 }
 
+// warpConnected reports whether warp-cli shows the client connected.
+func warpConnected() bool {
+	path, err := exec.LookPath("warp-cli")
+	if err != nil {
+		return false
+	}
+	out, err := exec.Command(path, "status").Output()
+	return err == nil && strings.Contains(string(out), "Connected") && !strings.Contains(string(out), "Disconnected")
+}
+
+func disconnectWARP() (string, error) {
+	path, err := exec.LookPath("warp-cli")
+	if err != nil {
+		return "", fmt.Errorf("warp-cli not found on PATH")
+	}
+
+	out, err := exec.Command(path, "disconnect").CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("warp-cli disconnect failed: %w", err)
+	}
+	return string(out), nil
+}
+
 func warpInstallInstructions() string {
 	switch runtime.GOOS {
 	case "linux":
@@ -2,11 +2,14 @@ package fleet
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
@@ -23,6 +26,15 @@ type Reporter struct {
 	interval      time.Duration
 	logger        *log.Logger
 	client        *http.Client
+	refresh       func(ctx context.Context) []compliance.Section
+	checkInterval time.Duration
+	lastStatus    map[string]compliance.Status
+	spool         *Spool
+	onAuthFailure func(status int)
+
+	fullReportEvery int
+	ackedHash       string // ReportHash of the last report the controller accepted
+	sinceFull       int    // reports skipped as unchanged since the last upload
 }
 
 // ReporterConfig holds configuration for the fleet reporter.
@@ -33,6 +45,27 @@ type ReporterConfig struct {
 	Checker       *compliance.Checker
 	Interval      time.Duration
 	Logger        *log.Logger
+
+	// Refresh, if set, re-runs the node's checks and returns fresh sections
+	// which replace those of the same ID in Checker. It is called before
+	// every report and every CheckInterval in between; any item changing
+	// status triggers an immediate out-of-cycle report.
+	Refresh       func(ctx context.Context) []compliance.Section
+	CheckInterval time.Duration // How often Refresh runs between reports (default: Interval)
+
+	// Spool, if set, queues reports and heartbeats that could not be
+	// delivered and replays them in order once the controller is reachable.
+	Spool *Spool
+
+	// OnAuthFailure, if set, is called when the controller rejects the
+	// node's credentials (401 or 403), typically because the node was
+	// removed and its API key revoked. The agent uses it to re-enroll.
+	OnAuthFailure func(status int)
+
+	// FullReportEvery forces a full upload after this many consecutive
+	// reports were skipped as unchanged, so the controller re-evaluates
+	// them against its current policy (default: 10).
+	FullReportEvery int
 }
 
 // NewReporter creates a new fleet reporter.
@@ -43,6 +76,12 @@ func NewReporter(cfg ReporterConfig) *Reporter {
 	if cfg.Logger == nil {
 		cfg.Logger = log.Default()
 	}
+	if cfg.CheckInterval == 0 {
+		cfg.CheckInterval = cfg.Interval
+	}
+	if cfg.FullReportEvery <= 0 {
+		cfg.FullReportEvery = 10
+	}
 	return &Reporter{
 		controllerURL: cfg.ControllerURL,
 		nodeID:        cfg.NodeID,
@@ -53,11 +92,19 @@ func NewReporter(cfg ReporterConfig) *Reporter {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		refresh:       cfg.Refresh,
+		checkInterval: cfg.CheckInterval,
+		spool:         cfg.Spool,
+		onAuthFailure: cfg.OnAuthFailure,
+
+		fullReportEvery: cfg.FullReportEvery,
 	}
 }
 
 // Run starts the reporter loop. It pushes a full compliance report at the
 // configured interval and a lightweight heartbeat at half that interval.
+// When a Refresh function is configured, checks are re-run on every report
+// and every check interval, and a status change is reported immediately.
 // Blocks until the context is cancelled.
 func (r *Reporter) Run(ctx context.Context) {
 	reportTicker := time.NewTicker(r.interval)
@@ -65,7 +112,15 @@ func (r *Reporter) Run(ctx context.Context) {
 	defer reportTicker.Stop()
 	defer heartbeatTicker.Stop()
 
+	var checkC <-chan time.Time
+	if r.refresh != nil {
+		checkTicker := time.NewTicker(r.checkInterval)
+		defer checkTicker.Stop()
+		checkC = checkTicker.C
+	}
+
 	// Send initial report immediately
+	r.refreshChecks(ctx)
 	r.sendReport(ctx)
 
 	for {
@@ -73,13 +128,51 @@ func (r *Reporter) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-reportTicker.C:
+			r.refreshChecks(ctx)
 			r.sendReport(ctx)
+		case <-checkC:
+			if changed := r.refreshChecks(ctx); len(changed) > 0 {
+				r.logger.Printf("fleet reporter: status changed (%s), sending out-of-cycle report",
+					strings.Join(changed, ", "))
+				r.sendReport(ctx)
+				reportTicker.Reset(r.interval)
+			}
 		case <-heartbeatTicker.C:
 			r.sendHeartbeat(ctx)
 		}
 	}
 }
 
+// refreshChecks re-runs the configured checks, swaps the results into the
+// checker, and returns a description of every item whose status changed
+// since the previous run. The first run establishes the baseline and never
+// reports changes. Only called from the Run goroutine.
+func (r *Reporter) refreshChecks(ctx context.Context) []string {
+	if r.refresh == nil {
+		return nil
+	}
+
+	current := make(map[string]compliance.Status)
+	for _, section := range r.refresh(ctx) {
+		r.checker.ReplaceSection(section)
+		for _, item := range section.Items {
+			current[item.ID] = item.Status
+		}
+	}
+
+	var changed []string
+	if r.lastStatus != nil {
+		for id, status := range current {
+			if prev, ok := r.lastStatus[id]; !ok || prev != status {
+				changed = append(changed, fmt.Sprintf("%s: %s -> %s", id, prev, status))
+			}
+		}
+		sort.Strings(changed)
+	}
+	r.lastStatus = current
+	return changed
+}
+
 func (r *Reporter) sendReport(ctx context.Context) {
 	report := r.checker.GenerateReport()
 	info := fipsbackend.DetectInfo()
@@ -97,43 +190,203 @@ func (r *Reporter) sendReport(ctx context.Context) {
 		return
 	}
 
-	url := fmt.Sprintf("%s/api/v1/fleet/report", r.controllerURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		r.logger.Printf("fleet reporter: request error: %v", err)
+	if !r.replaySpool(ctx) {
+		r.spoolRequest(SpoolKindReport, payload)
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+r.apiKey)
 
-	resp, err := r.client.Do(req)
+	// Unchanged since the controller last accepted a report: confirm with
+	// a hash-carrying heartbeat instead of re-uploading.
+	hash := ReportHash(*report)
+	if hash == r.ackedHash && r.sinceFull+1 < r.fullReportEvery {
+		if r.confirmUnchanged(ctx, hash) {
+			r.sinceFull++
+			return
+		}
+	}
+
+	status, err := r.post(ctx, "/api/v1/fleet/report", body, true)
 	if err != nil {
 		r.logger.Printf("fleet reporter: report push failed: %v", err)
+		r.spoolRequest(SpoolKindReport, payload)
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		r.logger.Printf("fleet reporter: report push returned %d", status)
+		r.spoolRequest(SpoolKindReport, payload)
 		return
 	}
-	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		r.logger.Printf("fleet reporter: report push returned %d", resp.StatusCode)
+	if status != http.StatusOK {
+		r.logger.Printf("fleet reporter: report push returned %d", status)
+		r.checkAuth(status)
+		return
 	}
+	r.ackedHash = hash
+	r.sinceFull = 0
+}
+
+// confirmUnchanged sends a heartbeat carrying the report hash and returns
+// true if the controller answered 304, i.e. it already holds that report.
+func (r *Reporter) confirmUnchanged(ctx context.Context, hash string) bool {
+	body, _ := json.Marshal(HeartbeatRequest{NodeID: r.nodeID, Timestamp: time.Now().UTC(), ReportHash: hash})
+	status, err := r.post(ctx, "/api/v1/fleet/heartbeat", body, false)
+	if err != nil || status != http.StatusNotModified {
+		return false
+	}
+	return true
 }
 
 func (r *Reporter) sendHeartbeat(ctx context.Context) {
-	payload := HeartbeatRequest{NodeID: r.nodeID}
+	payload := HeartbeatRequest{NodeID: r.nodeID, Timestamp: time.Now().UTC(), ReportHash: r.ackedHash}
 	body, _ := json.Marshal(payload)
 
-	url := fmt.Sprintf("%s/api/v1/fleet/heartbeat", r.controllerURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if !r.replaySpool(ctx) {
+		r.spoolRequest(SpoolKindHeartbeat, payload)
+		return
+	}
+
+	status, err := r.post(ctx, "/api/v1/fleet/heartbeat", body, false)
 	if err != nil {
+		r.logger.Printf("fleet reporter: heartbeat failed: %v", err)
+		r.spoolRequest(SpoolKindHeartbeat, payload)
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		r.spoolRequest(SpoolKindHeartbeat, payload)
+		return
+	}
+	if status == http.StatusOK && payload.ReportHash != "" {
+		// The controller does not hold our last report (or predates delta
+		// reporting); upload the next one in full.
+		r.ackedHash = ""
+	}
+	r.checkAuth(status)
+}
+
+// checkAuth reports a credential rejection to the OnAuthFailure hook.
+func (r *Reporter) checkAuth(status int) {
+	if status != http.StatusUnauthorized && status != http.StatusForbidden {
 		return
 	}
+	r.logger.Printf("fleet reporter: controller rejected node credentials (%d)", status)
+	if r.onAuthFailure != nil {
+		r.onAuthFailure(status)
+	}
+}
+
+// post sends a JSON body to the controller, gzip-compressed if compress is
+// set, and returns the response status.
+func (r *Reporter) post(ctx context.Context, path string, body []byte, compress bool) (int, error) {
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		body = buf.Bytes()
+	}
+
+	url := fmt.Sprintf("%s%s", r.controllerURL, path)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
 	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("Authorization", "Bearer "+r.apiKey)
 
 	resp, err := r.client.Do(req)
 	if err != nil {
-		r.logger.Printf("fleet reporter: heartbeat failed: %v", err)
-		return
+		return 0, err
 	}
 	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// spoolRequest queues an undelivered report or heartbeat for later replay.
+// The payload is marked as replayed so the controller files it as history.
+func (r *Reporter) spoolRequest(kind string, payload interface{}) {
+	if r.spool == nil {
+		return
+	}
+
+	var ts time.Time
+	switch p := payload.(type) {
+	case ComplianceReportPayload:
+		p.Replayed = true
+		payload = p
+		ts, _ = time.Parse(time.RFC3339, p.Report.Timestamp)
+	case HeartbeatRequest:
+		p.Replayed = true
+		p.ReportHash = ""
+		payload = p
+		ts = p.Timestamp
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		r.logger.Printf("fleet reporter: spool marshal error: %v", err)
+		return
+	}
+	if err := r.spool.Push(SpoolEntry{Kind: kind, Timestamp: ts, Payload: body}); err != nil {
+		r.logger.Printf("fleet reporter: spool %s failed: %v", kind, err)
+	}
+}
+
+// replaySpool delivers spooled requests in order. It returns true when the
+// spool is empty afterwards, meaning live requests may be sent without
+// overtaking older ones.
+func (r *Reporter) replaySpool(ctx context.Context) bool {
+	if r.spool == nil || r.spool.Len() == 0 {
+		return true
+	}
+
+	authStatus := 0
+	discarded := r.spool.Discarded()
+	delivered, err := r.spool.Replay(func(entry SpoolEntry) error {
+		path := "/api/v1/fleet/report"
+		if entry.Kind == SpoolKindHeartbeat {
+			path = "/api/v1/fleet/heartbeat"
+		}
+		status, err := r.post(ctx, path, entry.Payload, entry.Kind == SpoolKindReport)
+		if err != nil {
+			return err
+		}
+		if status >= http.StatusInternalServerError {
+			return fmt.Errorf("controller returned %d", status)
+		}
+		if status == http.StatusUnauthorized || status == http.StatusForbidden {
+			// Keep the entry: the rejection is about the credentials,
+			// not the entry itself.
+			authStatus = status
+			return fmt.Errorf("controller rejected credentials (%d)", status)
+		}
+		if status != http.StatusOK {
+			// The controller rejected the entry outright; retrying it
+			// would block the spool forever.
+			r.logger.Printf("fleet reporter: spooled %s from %s rejected with %d, discarding",
+				entry.Kind, entry.Timestamp.Format(time.RFC3339), status)
+		}
+		return nil
+	})
+	if delivered > 0 {
+		r.logger.Printf("fleet reporter: replayed %d spooled request(s)", delivered)
+	}
+	if n := r.spool.Discarded() - discarded; n > 0 {
+		r.logger.Printf("fleet reporter: discarded %d spooled request(s) that could not be decrypted", n)
+	}
+	if err != nil {
+		r.logger.Printf("fleet reporter: spool replay stopped: %v (%d queued)", err, r.spool.Len())
+		if authStatus != 0 {
+			r.checkAuth(authStatus)
+		}
+		return false
+	}
+	return true
 }
@@ -1,12 +1,14 @@
 package fleet
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -26,6 +28,20 @@ func testComplianceChecker() *compliance.Checker {
 	return c
 }
 
+// decodeTestBody decodes a reporter request body, inflating gzip.
+func decodeTestBody(r *http.Request, v interface{}) error {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		body = zr
+	}
+	return json.NewDecoder(body).Decode(v)
+}
+
 func TestNewReporter_Defaults(t *testing.T) {
 	r := NewReporter(ReporterConfig{
 		ControllerURL: "http://localhost:8080",
@@ -70,10 +86,12 @@ func TestReporter_SendsInitialReport(t *testing.T) {
 			if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
 				t.Errorf("auth = %q, want Bearer test-key", auth)
 			}
-			// Verify body is valid JSON with node_id
-			body, _ := io.ReadAll(r.Body)
+			// Verify body is gzip-compressed JSON with node_id
+			if enc := r.Header.Get("Content-Encoding"); enc != "gzip" {
+				t.Errorf("Content-Encoding = %q, want gzip", enc)
+			}
 			var payload ComplianceReportPayload
-			if err := json.Unmarshal(body, &payload); err != nil {
+			if err := decodeTestBody(r, &payload); err != nil {
 				t.Errorf("invalid report payload: %v", err)
 			}
 			if payload.NodeID != "node-1" {
@@ -176,3 +194,357 @@ func TestReporter_StopsOnContextCancel(t *testing.T) {
 		t.Fatal("Run did not stop after context cancel")
 	}
 }
+
+func TestReporter_RefreshUpdatesReportedSections(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []compliance.Status
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/fleet/report" {
+			var payload ComplianceReportPayload
+			_ = decodeTestBody(r, &payload)
+			mu.Lock()
+			for _, s := range payload.Report.Sections {
+				if s.ID == "agent-posture" && len(s.Items) > 0 {
+					statuses = append(statuses, s.Items[0].Status)
+				}
+			}
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var calls atomic.Int32
+	r := NewReporter(ReporterConfig{
+		ControllerURL: server.URL,
+		NodeID:        "node-1",
+		APIKey:        "key",
+		Checker:       compliance.NewChecker(),
+		Interval:      1 * time.Hour,
+		CheckInterval: 20 * time.Millisecond,
+		Logger:        log.New(io.Discard, "", 0),
+		Refresh: func(ctx context.Context) []compliance.Section {
+			status := compliance.StatusPass
+			if calls.Add(1) > 1 {
+				status = compliance.StatusFail
+			}
+			return []compliance.Section{{
+				ID:    "agent-posture",
+				Items: []compliance.ChecklistItem{{ID: "ag-fips", Status: status}},
+			}}
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	r.Run(ctx)
+
+	if calls.Load() < 2 {
+		t.Fatalf("Refresh called %d times, want >= 2", calls.Load())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The initial report plus exactly one out-of-cycle report for the
+	// pass -> fail transition; steady fail status must not re-trigger.
+	if len(statuses) != 2 {
+		t.Fatalf("got %d reports (%v), want 2", len(statuses), statuses)
+	}
+	if statuses[0] != compliance.StatusPass || statuses[1] != compliance.StatusFail {
+		t.Errorf("reported statuses = %v, want [pass fail]", statuses)
+	}
+}
+
+func TestReporter_RefreshChecksDetectsChanges(t *testing.T) {
+	status := compliance.StatusPass
+	r := NewReporter(ReporterConfig{
+		ControllerURL: "http://localhost:8080",
+		NodeID:        "node-1",
+		Checker:       compliance.NewChecker(),
+		Refresh: func(ctx context.Context) []compliance.Section {
+			return []compliance.Section{{
+				ID:    "s",
+				Items: []compliance.ChecklistItem{{ID: "i-1", Status: status}},
+			}}
+		},
+	})
+
+	if changed := r.refreshChecks(context.Background()); len(changed) != 0 {
+		t.Errorf("first refresh reported changes: %v", changed)
+	}
+	if changed := r.refreshChecks(context.Background()); len(changed) != 0 {
+		t.Errorf("unchanged refresh reported changes: %v", changed)
+	}
+
+	status = compliance.StatusWarning
+	changed := r.refreshChecks(context.Background())
+	if len(changed) != 1 || changed[0] != "i-1: pass -> warning" {
+		t.Errorf("changed = %v, want [i-1: pass -> warning]", changed)
+	}
+
+	report := r.checker.GenerateReport()
+	if len(report.Sections) != 1 || report.Sections[0].Items[0].Status != compliance.StatusWarning {
+		t.Errorf("checker not updated with refreshed section: %+v", report.Sections)
+	}
+}
+
+func TestNewReporter_CheckIntervalDefaultsToInterval(t *testing.T) {
+	r := NewReporter(ReporterConfig{
+		ControllerURL: "http://localhost:8080",
+		NodeID:        "node-1",
+		Checker:       testComplianceChecker(),
+		Interval:      10 * time.Second,
+	})
+	if r.checkInterval != 10*time.Second {
+		t.Errorf("checkInterval = %v, want 10s", r.checkInterval)
+	}
+}
+
+func TestReporter_SpoolsAndReplaysWhenControllerReturns(t *testing.T) {
+	var up atomic.Bool
+	var mu sync.Mutex
+	var received []string // "live:<path>" or "replayed:<path>"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Replayed bool `json:"replayed"`
+		}
+		_ = decodeTestBody(r, &body)
+		kind := "live"
+		if body.Replayed {
+			kind = "replayed"
+		}
+		mu.Lock()
+		received = append(received, kind+":"+r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	spool, err := NewSpool(t.TempDir(), testSpoolKey(t), 100)
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+
+	r := NewReporter(ReporterConfig{
+		ControllerURL: server.URL,
+		NodeID:        "node-1",
+		APIKey:        "key",
+		Checker:       testComplianceChecker(),
+		Interval:      time.Hour,
+		Logger:        log.New(io.Discard, "", 0),
+		Spool:         spool,
+	})
+
+	ctx := context.Background()
+	r.sendReport(ctx)
+	r.sendHeartbeat(ctx)
+	if spool.Len() != 2 {
+		t.Fatalf("spool Len = %d after outage, want 2", spool.Len())
+	}
+
+	up.Store(true)
+	r.sendReport(ctx)
+
+	if spool.Len() != 0 {
+		t.Errorf("spool Len = %d after recovery, want 0", spool.Len())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"replayed:/api/v1/fleet/report",
+		"replayed:/api/v1/fleet/heartbeat",
+		"live:/api/v1/fleet/report",
+	}
+	if len(received) != len(want) {
+		t.Fatalf("received %v, want %v", received, want)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("request %d = %s, want %s", i, received[i], want[i])
+		}
+	}
+}
+
+func TestReporter_DoesNotSpoolRejectedReports(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	spool, _ := NewSpool(t.TempDir(), testSpoolKey(t), 100)
+	r := NewReporter(ReporterConfig{
+		ControllerURL: server.URL,
+		NodeID:        "node-1",
+		APIKey:        "key",
+		Checker:       testComplianceChecker(),
+		Logger:        log.New(io.Discard, "", 0),
+		Spool:         spool,
+	})
+
+	r.sendReport(context.Background())
+	if spool.Len() != 0 {
+		t.Errorf("spool Len = %d, want 0 for a 403 response", spool.Len())
+	}
+}
+
+func TestReporter_OnAuthFailure(t *testing.T) {
+	var authorized atomic.Bool
+	authorized.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	spool, err := NewSpool(t.TempDir(), testSpoolKey(t), 100)
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+
+	var failures []int
+	r := NewReporter(ReporterConfig{
+		ControllerURL: server.URL,
+		NodeID:        "node-1",
+		APIKey:        "revoked",
+		Checker:       testComplianceChecker(),
+		Interval:      time.Hour,
+		Logger:        log.New(io.Discard, "", 0),
+		Spool:         spool,
+		OnAuthFailure: func(status int) { failures = append(failures, status) },
+	})
+
+	ctx := context.Background()
+	r.sendHeartbeat(ctx) // 503: spooled, not an auth failure
+	if len(failures) != 0 {
+		t.Fatalf("OnAuthFailure called on 503")
+	}
+
+	authorized.Store(false)
+	r.sendReport(ctx)
+	if len(failures) != 1 || failures[0] != http.StatusForbidden {
+		t.Errorf("failures = %v, want [403]", failures)
+	}
+	// A credential rejection during replay keeps the spooled entry.
+	if spool.Len() == 0 {
+		t.Error("spooled heartbeat discarded on 403 replay")
+	}
+}
+
+func TestReportHash_IgnoresTimestamp(t *testing.T) {
+	a := testComplianceChecker().GenerateReport()
+	b := *a
+	b.Timestamp = "2000-01-01T00:00:00Z"
+	if ReportHash(*a) != ReportHash(b) {
+		t.Error("hash should not depend on the timestamp")
+	}
+	b.Summary.Failed++
+	if ReportHash(*a) == ReportHash(b) {
+		t.Error("hash should change with report content")
+	}
+}
+
+// deltaController emulates the controller's delta-reporting endpoints.
+type deltaController struct {
+	mu         sync.Mutex
+	hash       string
+	reports    int
+	heartbeats int
+	legacy     bool // predates delta reporting: never answers 304
+}
+
+func (c *deltaController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/v1/fleet/report":
+		var p ComplianceReportPayload
+		_ = decodeTestBody(r, &p)
+		c.reports++
+		c.hash = ReportHash(p.Report)
+	case "/api/v1/fleet/heartbeat":
+		var hb HeartbeatRequest
+		_ = decodeTestBody(r, &hb)
+		c.heartbeats++
+		if !c.legacy && hb.ReportHash != "" && hb.ReportHash == c.hash {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestReporter_SkipsUnchangedReports(t *testing.T) {
+	ctrl := &deltaController{}
+	server := httptest.NewServer(ctrl)
+	defer server.Close()
+
+	checker := testComplianceChecker()
+	r := NewReporter(ReporterConfig{
+		ControllerURL:   server.URL,
+		NodeID:          "node-1",
+		APIKey:          "key",
+		Checker:         checker,
+		Interval:        time.Hour,
+		Logger:          log.New(io.Discard, "", 0),
+		FullReportEvery: 3,
+	})
+	ctx := context.Background()
+
+	r.sendReport(ctx) // first report is always uploaded
+	r.sendReport(ctx) // unchanged: confirmed by heartbeat
+	r.sendReport(ctx) // unchanged
+	if ctrl.reports != 1 || ctrl.heartbeats != 2 {
+		t.Errorf("reports=%d heartbeats=%d, want 1 upload and 2 confirmations", ctrl.reports, ctrl.heartbeats)
+	}
+
+	r.sendReport(ctx) // FullReportEvery reached: uploaded again
+	if ctrl.reports != 2 {
+		t.Errorf("reports = %d after FullReportEvery, want 2", ctrl.reports)
+	}
+
+	checker.ReplaceSection(compliance.Section{
+		ID:    "test",
+		Items: []compliance.ChecklistItem{{ID: "t-1", Status: compliance.StatusFail}},
+	})
+	r.sendReport(ctx) // changed content is uploaded immediately
+	if ctrl.reports != 3 {
+		t.Errorf("reports = %d after change, want 3", ctrl.reports)
+	}
+}
+
+func TestReporter_LegacyControllerGetsFullReports(t *testing.T) {
+	ctrl := &deltaController{legacy: true}
+	server := httptest.NewServer(ctrl)
+	defer server.Close()
+
+	r := NewReporter(ReporterConfig{
+		ControllerURL: server.URL,
+		NodeID:        "node-1",
+		APIKey:        "key",
+		Checker:       testComplianceChecker(),
+		Interval:      time.Hour,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	ctx := context.Background()
+
+	r.sendReport(ctx)
+	r.sendReport(ctx)
+	if ctrl.reports != 2 {
+		t.Errorf("reports = %d, want 2 (no 304 support)", ctrl.reports)
+	}
+
+	// A plain heartbeat answered 200 means the controller lacks the report.
+	r.sendHeartbeat(ctx)
+	if r.ackedHash != "" {
+		t.Error("ackedHash should be cleared when the controller does not confirm it")
+	}
+}
@@ -0,0 +1,20 @@
+package fleet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/cloudflared-fips/cloudflared-fips/internal/compliance"
+)
+
+// ReportHash returns a content hash of a compliance report. The generation
+// timestamp is excluded, so two reports with identical results hash the
+// same. Agents send it with heartbeats and the controller compares it with
+// the last stored report to skip unchanged uploads.
+func ReportHash(report compliance.ComplianceReport) string {
+	report.Timestamp = ""
+	buf, _ := json.Marshal(report)
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,330 @@
+package fleet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Spool entry kinds.
+const (
+	SpoolKindReport    = "report"
+	SpoolKindHeartbeat = "heartbeat"
+)
+
+// spoolAAD binds spool ciphertexts to this file format so they cannot be
+// confused with other data encrypted under the same key.
+const spoolAAD = "cloudflared-fips-spool-v1"
+
+// SpoolEntry is a report or heartbeat that could not be delivered to the
+// controller. Payload is the JSON request body, replayed verbatim.
+type SpoolEntry struct {
+	Kind      string          `json:"kind"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Spool is a bounded on-disk FIFO of undelivered controller requests.
+// Each entry is stored in its own file, encrypted with AES-256-GCM, so
+// compliance evidence collected while the controller is unreachable is
+// protected at rest (SC-28) and survives agent restarts.
+type Spool struct {
+	dir        string
+	maxEntries int
+	aead       cipher.AEAD
+	mu         sync.Mutex
+	seq        uint64
+	dropped    int
+	discarded  int
+}
+
+// NewSpool opens (or creates) a spool in dir. key must be 32 bytes.
+// When more than maxEntries are queued, the oldest entries are discarded
+// first (default 5000).
+func NewSpool(dir string, key []byte, maxEntries int) (*Spool, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("spool: key must be 32 bytes, got %d", len(key))
+	}
+	if maxEntries <= 0 {
+		maxEntries = 5000
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("spool: create dir: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("spool: cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("spool: gcm: %w", err)
+	}
+
+	s := &Spool{dir: dir, maxEntries: maxEntries, aead: aead}
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		s.seq = spoolSeq(files[len(files)-1])
+	}
+	return s, nil
+}
+
+// LoadSpoolKey reads the hex spool key in path, creating a random one
+// (mode 0600) if the file does not exist; created reports which. The key
+// is independent of the node's API key, so spooled evidence can still be
+// replayed after the agent re-enrolls with new credentials.
+func LoadSpoolKey(path string) (key []byte, created bool, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, false, fmt.Errorf("spool: key file %s: want 64 hex characters", path)
+		}
+		return key, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("spool: read key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, fmt.Errorf("spool: generate key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("spool: create key dir: %w", err)
+	}
+	// O_EXCL: never overwrite a key another process just created.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, false, fmt.Errorf("spool: create key: %w", err)
+	}
+	_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, false, fmt.Errorf("spool: write key: %w", err)
+	}
+	return key, true, nil
+}
+
+// SpoolKeyFromAPIKey derives the spool key that earlier agents used from
+// the node's API key with HKDF-SHA-256. It is only needed to Reencrypt a
+// spool written by such an agent under its own key.
+func SpoolKeyFromAPIKey(apiKey string) ([]byte, error) {
+	return hkdf.Key(sha256.New, []byte(apiKey), nil, spoolAAD, 32)
+}
+
+// Reencrypt rewrites entries that decrypt under oldKey, but not under the
+// spool's key, with the spool's key. It returns how many were rewritten.
+func (s *Spool) Reencrypt(oldKey []byte) (int, error) {
+	if len(oldKey) != 32 {
+		return 0, fmt.Errorf("spool: key must be 32 bytes, got %d", len(oldKey))
+	}
+	block, err := aes.NewCipher(oldKey)
+	if err != nil {
+		return 0, fmt.Errorf("spool: cipher: %w", err)
+	}
+	old, err := cipher.NewGCM(block)
+	if err != nil {
+		return 0, fmt.Errorf("spool: gcm: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.files()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, name := range files {
+		sealed, err := os.ReadFile(name)
+		if err != nil || len(sealed) < old.NonceSize() {
+			continue
+		}
+		if _, err := openSealed(s.aead, sealed); err == nil {
+			continue // already under the current key
+		}
+		plain, err := openSealed(old, sealed)
+		if err != nil {
+			continue
+		}
+		resealed, err := s.seal(plain)
+		if err != nil {
+			return n, err
+		}
+		tmp := name + ".tmp"
+		if err := os.WriteFile(tmp, resealed, 0600); err != nil {
+			return n, fmt.Errorf("spool: write: %w", err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			os.Remove(tmp)
+			return n, fmt.Errorf("spool: write: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Push appends an entry, evicting the oldest entries if the spool is full.
+func (s *Spool) Push(entry SpoolEntry) error {
+	plain, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("spool: marshal: %w", err)
+	}
+	sealed, err := s.seal(plain)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	name := filepath.Join(s.dir, fmt.Sprintf("%020d.spool", s.seq))
+	if err := os.WriteFile(name, sealed, 0600); err != nil {
+		return fmt.Errorf("spool: write: %w", err)
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	for len(files) > s.maxEntries {
+		_ = os.Remove(files[0])
+		files = files[1:]
+		s.dropped++
+	}
+	return nil
+}
+
+// Len returns the number of queued entries.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, _ := s.files()
+	return len(files)
+}
+
+// Dropped returns how many entries have been evicted because the spool was
+// full since it was opened.
+func (s *Spool) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Discarded returns how many entries Replay has discarded because they
+// could not be read or decrypted since the spool was opened.
+func (s *Spool) Discarded() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.discarded
+}
+
+// Replay hands queued entries to send in the order they were pushed,
+// removing each once send succeeds. It stops at the first send error,
+// leaving that entry and everything after it queued. Entries that can no
+// longer be decrypted are discarded and counted (see Discarded). Returns
+// the number delivered.
+func (s *Spool) Replay(send func(SpoolEntry) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.files()
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, name := range files {
+		entry, err := s.read(name)
+		if err != nil {
+			_ = os.Remove(name)
+			s.discarded++
+			continue
+		}
+		if err := send(entry); err != nil {
+			return delivered, err
+		}
+		if err := os.Remove(name); err != nil {
+			return delivered, fmt.Errorf("spool: remove delivered entry: %w", err)
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+func (s *Spool) read(name string) (SpoolEntry, error) {
+	var entry SpoolEntry
+	sealed, err := os.ReadFile(name)
+	if err != nil {
+		return entry, err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return entry, fmt.Errorf("spool: truncated entry %s", filepath.Base(name))
+	}
+	plain, err := openSealed(s.aead, sealed)
+	if err != nil {
+		return entry, fmt.Errorf("spool: decrypt %s: %w", filepath.Base(name), err)
+	}
+	if err := json.Unmarshal(plain, &entry); err != nil {
+		return entry, fmt.Errorf("spool: decode %s: %w", filepath.Base(name), err)
+	}
+	return entry, nil
+}
+
+// seal encrypts plain under the spool's key with a random nonce, which
+// prefixes the result.
+func (s *Spool) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("spool: nonce: %w", err)
+	}
+	return s.aead.Seal(nonce, nonce, plain, []byte(spoolAAD)), nil
+}
+
+// openSealed decrypts a sealed entry with aead. sealed must hold a full nonce.
+func openSealed(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], []byte(spoolAAD))
+}
+
+// files returns spool entry paths sorted oldest-first.
+func (s *Spool) files() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("spool: read dir: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".spool") {
+			files = append(files, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// spoolSeq extracts the sequence number from a spool file path.
+func spoolSeq(path string) uint64 {
+	n, _ := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), ".spool"), 10, 64)
+	return n
+}
@@ -0,0 +1,226 @@
+package fleet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSpoolKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := SpoolKeyFromAPIKey("test-api-key")
+	if err != nil {
+		t.Fatalf("SpoolKeyFromAPIKey: %v", err)
+	}
+	return key
+}
+
+func TestNewSpool_RejectsShortKey(t *testing.T) {
+	if _, err := NewSpool(t.TempDir(), []byte("short"), 10); err == nil {
+		t.Error("expected error for non-32-byte key")
+	}
+}
+
+func TestSpool_ReplayInOrder(t *testing.T) {
+	s, err := NewSpool(t.TempDir(), testSpoolKey(t), 10)
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		payload, _ := json.Marshal(map[string]int{"n": i})
+		if err := s.Push(SpoolEntry{Kind: SpoolKindReport, Timestamp: base.Add(time.Duration(i) * time.Minute), Payload: payload}); err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+	if s.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", s.Len())
+	}
+
+	var got []time.Time
+	n, err := s.Replay(func(e SpoolEntry) error {
+		got = append(got, e.Timestamp)
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("Replay = %d, %v; want 3, nil", n, err)
+	}
+	for i, ts := range got {
+		if !ts.Equal(base.Add(time.Duration(i) * time.Minute)) {
+			t.Errorf("entry %d timestamp = %v, out of order", i, ts)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len after replay = %d, want 0", s.Len())
+	}
+}
+
+func TestSpool_ReplayStopsAtFirstError(t *testing.T) {
+	s, _ := NewSpool(t.TempDir(), testSpoolKey(t), 10)
+	for i := 0; i < 3; i++ {
+		_ = s.Push(SpoolEntry{Kind: SpoolKindHeartbeat, Payload: json.RawMessage(`{}`)})
+	}
+
+	calls := 0
+	n, err := s.Replay(func(SpoolEntry) error {
+		calls++
+		if calls == 2 {
+			return errors.New("controller down")
+		}
+		return nil
+	})
+	if err == nil || n != 1 {
+		t.Fatalf("Replay = %d, %v; want 1 and an error", n, err)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len = %d, want 2 (failed entry must stay queued)", s.Len())
+	}
+}
+
+func TestSpool_EvictsOldestWhenFull(t *testing.T) {
+	s, _ := NewSpool(t.TempDir(), testSpoolKey(t), 2)
+	for i := 0; i < 4; i++ {
+		payload, _ := json.Marshal(i)
+		_ = s.Push(SpoolEntry{Kind: SpoolKindReport, Payload: payload})
+	}
+
+	if s.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", s.Len())
+	}
+	if s.Dropped() != 2 {
+		t.Errorf("Dropped = %d, want 2", s.Dropped())
+	}
+
+	var got []string
+	_, _ = s.Replay(func(e SpoolEntry) error {
+		got = append(got, string(e.Payload))
+		return nil
+	})
+	if len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("remaining payloads = %v, want [2 3]", got)
+	}
+}
+
+func TestSpool_EncryptedAtRest(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewSpool(dir, testSpoolKey(t), 10)
+	secret := []byte(`{"marker":"plaintext-compliance-evidence"}`)
+	_ = s.Push(SpoolEntry{Kind: SpoolKindReport, Payload: secret})
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.spool"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 spool file, got %d", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("plaintext-compliance-evidence")) {
+		t.Error("spool file contains plaintext payload")
+	}
+	info, _ := os.Stat(files[0])
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("spool file mode = %o, want 600", perm)
+	}
+}
+
+func TestSpool_PersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	key := testSpoolKey(t)
+
+	s1, _ := NewSpool(dir, key, 10)
+	_ = s1.Push(SpoolEntry{Kind: SpoolKindReport, Payload: json.RawMessage(`1`)})
+
+	s2, err := NewSpool(dir, key, 10)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	_ = s2.Push(SpoolEntry{Kind: SpoolKindReport, Payload: json.RawMessage(`2`)})
+
+	var got []string
+	_, _ = s2.Replay(func(e SpoolEntry) error {
+		got = append(got, string(e.Payload))
+		return nil
+	})
+	if len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("payloads after reopen = %v, want [1 2]", got)
+	}
+}
+
+func TestSpool_WrongKeyDiscardsEntries(t *testing.T) {
+	dir := t.TempDir()
+	s1, _ := NewSpool(dir, testSpoolKey(t), 10)
+	_ = s1.Push(SpoolEntry{Kind: SpoolKindReport, Payload: json.RawMessage(`1`)})
+
+	otherKey, _ := SpoolKeyFromAPIKey("rotated-key")
+	s2, _ := NewSpool(dir, otherKey, 10)
+	n, err := s2.Replay(func(SpoolEntry) error {
+		t.Error("entry encrypted under another key must not be delivered")
+		return nil
+	})
+	if err != nil || n != 0 {
+		t.Errorf("Replay = %d, %v; want 0, nil", n, err)
+	}
+	if s2.Len() != 0 || s2.Discarded() != 1 {
+		t.Errorf("undecryptable entry not discarded, Len = %d, Discarded = %d", s2.Len(), s2.Discarded())
+	}
+}
+
+func TestLoadSpoolKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "spool.key")
+	key, created, err := LoadSpoolKey(path)
+	if err != nil || !created || len(key) != 32 {
+		t.Fatalf("LoadSpoolKey = %x, %v, %v", key, created, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	again, created, err := LoadSpoolKey(path)
+	if err != nil || created || !bytes.Equal(again, key) {
+		t.Errorf("reload = %x, %v, %v; want the same key", again, created, err)
+	}
+
+	os.WriteFile(path, []byte("not-hex"), 0600)
+	if _, _, err := LoadSpoolKey(path); err == nil {
+		t.Error("expected error for malformed key file")
+	}
+}
+
+func TestSpool_SurvivesAPIKeyChange(t *testing.T) {
+	dir := t.TempDir()
+	// An earlier agent spooled under a key derived from its API key.
+	old, _ := NewSpool(dir, testSpoolKey(t), 10)
+	_ = old.Push(SpoolEntry{Kind: SpoolKindReport, Payload: json.RawMessage(`1`)})
+
+	key, _, err := LoadSpoolKey(filepath.Join(t.TempDir(), "spool.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewSpool(dir, key, 10)
+	if n, err := s.Reencrypt(testSpoolKey(t)); err != nil || n != 1 {
+		t.Fatalf("Reencrypt = %d, %v; want 1", n, err)
+	}
+	_ = s.Push(SpoolEntry{Kind: SpoolKindReport, Payload: json.RawMessage(`2`)})
+	if n, err := s.Reencrypt(testSpoolKey(t)); err != nil || n != 0 {
+		t.Errorf("second Reencrypt = %d, %v; want 0", n, err)
+	}
+
+	// The entries no longer depend on the API key, so they replay after
+	// the agent re-enrolls.
+	s, _ = NewSpool(dir, key, 10)
+	var got []string
+	if _, err := s.Replay(func(e SpoolEntry) error {
+		got = append(got, string(e.Payload))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "1" || got[1] != "2" || s.Discarded() != 0 {
+		t.Errorf("replayed %v, discarded %d; want [1 2], 0", got, s.Discarded())
+	}
+}
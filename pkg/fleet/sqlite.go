@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -64,7 +67,8 @@ func (s *SQLiteStore) migrate() error {
 		compliance_warn   INTEGER NOT NULL DEFAULT 0,
 		compliance_status TEXT NOT NULL DEFAULT 'unknown',
 		service_json      TEXT NOT NULL DEFAULT '',
-		grace_period_end  TEXT NOT NULL DEFAULT ''
+		grace_period_end  TEXT NOT NULL DEFAULT '',
+		flapping          INTEGER NOT NULL DEFAULT 0
 	);
 
 	CREATE TABLE IF NOT EXISTS enrollment_tokens (
@@ -85,6 +89,26 @@ func (s *SQLiteStore) migrate() error {
 		report    TEXT NOT NULL
 	);
 
+	CREATE TABLE IF NOT EXISTS report_hashes (
+		node_id    TEXT PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+		hash       TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS heartbeat_history (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		node_id   TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+		timestamp TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS node_status_history (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		node_id     TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+		from_status TEXT NOT NULL DEFAULT '',
+		to_status   TEXT NOT NULL,
+		timestamp   TEXT NOT NULL
+	);
+
 	CREATE TABLE IF NOT EXISTS remediation_requests (
 		id           TEXT PRIMARY KEY,
 		node_id      TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
@@ -93,15 +117,128 @@ func (s *SQLiteStore) migrate() error {
 		status       TEXT NOT NULL DEFAULT 'pending',
 		created_at   TEXT NOT NULL,
 		completed_at TEXT NOT NULL DEFAULT '',
-		result       TEXT NOT NULL DEFAULT ''
+		result       TEXT NOT NULL DEFAULT '',
+		type         TEXT NOT NULL DEFAULT 'remediate',
+		rollback_of  TEXT NOT NULL DEFAULT '',
+		requested_by TEXT NOT NULL DEFAULT '',
+		required_approvals  INTEGER NOT NULL DEFAULT 0,
+		approvals           TEXT NOT NULL DEFAULT '[]',
+		approval_expires_at TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS edge_remediations (
+		id           TEXT PRIMARY KEY,
+		zone_id      TEXT NOT NULL,
+		actions      TEXT NOT NULL DEFAULT '[]',
+		dry_run      INTEGER NOT NULL DEFAULT 0,
+		status       TEXT NOT NULL DEFAULT 'pending',
+		created_at   TEXT NOT NULL,
+		completed_at TEXT NOT NULL DEFAULT '',
+		result       TEXT NOT NULL DEFAULT '',
+		type         TEXT NOT NULL DEFAULT 'remediate',
+		rollback_of  TEXT NOT NULL DEFAULT '',
+		requested_by TEXT NOT NULL DEFAULT '',
+		required_approvals  INTEGER NOT NULL DEFAULT 0,
+		approvals           TEXT NOT NULL DEFAULT '[]',
+		approval_expires_at TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS federated_sites (
+		site_id      TEXT PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+		name         TEXT NOT NULL DEFAULT '',
+		api_url      TEXT NOT NULL DEFAULT '',
+		api_token    TEXT NOT NULL DEFAULT '',
+		summary_json TEXT NOT NULL DEFAULT '{}',
+		last_sync    TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS federated_nodes (
+		site_id    TEXT NOT NULL REFERENCES federated_sites(site_id) ON DELETE CASCADE,
+		node_id    TEXT NOT NULL,
+		node_json  TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		PRIMARY KEY (site_id, node_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id               TEXT PRIMARY KEY,
+		node_id          TEXT NOT NULL DEFAULT '',
+		labels           TEXT NOT NULL DEFAULT '{}',
+		days             TEXT NOT NULL DEFAULT '[]',
+		start            TEXT NOT NULL,
+		duration_minutes INTEGER NOT NULL,
+		timezone         TEXT NOT NULL DEFAULT '',
+		created_at       TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS reboots (
+		id                TEXT PRIMARY KEY,
+		node_id           TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+		remediation_id    TEXT NOT NULL DEFAULT '',
+		check_ids         TEXT NOT NULL DEFAULT '[]',
+		status            TEXT NOT NULL DEFAULT 'pending',
+		detail            TEXT NOT NULL DEFAULT '',
+		reboot_request_id TEXT NOT NULL DEFAULT '',
+		created_at        TEXT NOT NULL,
+		scheduled_at      TEXT NOT NULL DEFAULT '',
+		reboot_at         TEXT NOT NULL DEFAULT '',
+		completed_at      TEXT NOT NULL DEFAULT ''
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_reports_node_time ON compliance_reports(node_id, timestamp DESC);
+	CREATE INDEX IF NOT EXISTS idx_heartbeats_node_time ON heartbeat_history(node_id, timestamp);
 	CREATE INDEX IF NOT EXISTS idx_nodes_status ON nodes(status);
 	CREATE INDEX IF NOT EXISTS idx_nodes_role ON nodes(role);
 	CREATE INDEX IF NOT EXISTS idx_remediation_node ON remediation_requests(node_id, status);
+	CREATE INDEX IF NOT EXISTS idx_status_history_node_time ON node_status_history(node_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_status_history_time ON node_status_history(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_reboots_status ON reboots(status, node_id);
 	`
-	_, err := s.db.Exec(schema)
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+	// Columns added after the initial schema; CREATE TABLE IF NOT EXISTS
+	// leaves existing databases without them.
+	for _, c := range []struct{ table, column, decl string }{
+		{"nodes", "flapping", "INTEGER NOT NULL DEFAULT 0"},
+		{"remediation_requests", "type", "TEXT NOT NULL DEFAULT 'remediate'"},
+		{"remediation_requests", "rollback_of", "TEXT NOT NULL DEFAULT ''"},
+		{"remediation_requests", "requested_by", "TEXT NOT NULL DEFAULT ''"},
+		{"remediation_requests", "required_approvals", "INTEGER NOT NULL DEFAULT 0"},
+		{"remediation_requests", "approvals", "TEXT NOT NULL DEFAULT '[]'"},
+		{"remediation_requests", "approval_expires_at", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		if err := s.addColumn(c.table, c.column, c.decl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to an existing table if it is missing.
+func (s *SQLiteStore) addColumn(table, column, decl string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
 	return err
 }
 
@@ -116,15 +253,32 @@ func (s *SQLiteStore) CreateNode(ctx context.Context, node *Node, apiKeyHash str
 	defer s.mu.Unlock()
 
 	labels, _ := json.Marshal(node.Labels)
-	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO nodes (id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, api_key_hash)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	var serviceJSON string
+	if node.Service != nil {
+		buf, _ := json.Marshal(node.Service)
+		serviceJSON = string(buf)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO nodes (id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, api_key_hash, service_json)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		node.ID, node.Name, string(node.Role), node.Region, string(labels),
 		node.EnrolledAt.UTC().Format(time.RFC3339),
 		node.LastHeartbeat.UTC().Format(time.RFC3339),
-		string(node.Status), node.Version, node.FIPSBackend, apiKeyHash,
+		string(node.Status), node.Version, node.FIPSBackend, apiKeyHash, serviceJSON,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if err := recordTransition(ctx, tx, node.ID, "", node.Status, node.EnrolledAt); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetNode retrieves a node by ID.
@@ -137,7 +291,7 @@ func (s *SQLiteStore) GetNode(ctx context.Context, id string) (*Node, error) {
 
 func (s *SQLiteStore) getNodeLocked(ctx context.Context, id string) (*Node, error) {
 	row := s.db.QueryRowContext(ctx,
-		`SELECT id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, compliance_pass, compliance_fail, compliance_warn, compliance_status, service_json, grace_period_end
+		`SELECT id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, compliance_pass, compliance_fail, compliance_warn, compliance_status, service_json, grace_period_end, flapping
 		 FROM nodes WHERE id = ?`, id)
 	return scanNode(row)
 }
@@ -147,7 +301,7 @@ func (s *SQLiteStore) ListNodes(ctx context.Context, filter NodeFilter) ([]Node,
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	query := "SELECT id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, compliance_pass, compliance_fail, compliance_warn, compliance_status, service_json, grace_period_end FROM nodes WHERE 1=1"
+	query := "SELECT id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, compliance_pass, compliance_fail, compliance_warn, compliance_status, service_json, grace_period_end, flapping FROM nodes WHERE 1=1"
 	var args []interface{}
 
 	if filter.Role != "" {
@@ -181,15 +335,15 @@ func (s *SQLiteStore) ListNodes(ctx context.Context, filter NodeFilter) ([]Node,
 	return nodes, rows.Err()
 }
 
-// UpdateNodeHeartbeat updates the last heartbeat timestamp and sets status to online.
+// UpdateNodeHeartbeat updates the last heartbeat timestamp and marks the
+// node live: online, or degraded if its last report had failing checks.
 func (s *SQLiteStore) UpdateNodeHeartbeat(ctx context.Context, id string, t time.Time) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx,
-		`UPDATE nodes SET last_heartbeat = ?, status = 'online' WHERE id = ?`,
+	return s.setStatusLocked(ctx, id, t,
+		`UPDATE nodes SET last_heartbeat = ?, status = CASE WHEN compliance_fail > 0 THEN 'degraded' ELSE 'online' END WHERE id = ?`,
 		t.UTC().Format(time.RFC3339), id)
-	return err
 }
 
 // UpdateNodeStatus updates the node's operational status.
@@ -197,11 +351,135 @@ func (s *SQLiteStore) UpdateNodeStatus(ctx context.Context, id string, status No
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx,
+	return s.setStatusLocked(ctx, id, time.Now(),
 		`UPDATE nodes SET status = ? WHERE id = ?`, string(status), id)
+}
+
+// setStatusLocked runs a status-changing update and records a transition
+// in the status history if the node's status changed.
+func (s *SQLiteStore) setStatusLocked(ctx context.Context, id string, at time.Time, query string, args ...interface{}) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM nodes WHERE id = ?`, id).Scan(&before); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	var after string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM nodes WHERE id = ?`, id).Scan(&after); err != nil {
+		return err
+	}
+	if after != before {
+		if err := recordTransition(ctx, tx, id, NodeStatus(before), NodeStatus(after), at); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func recordTransition(ctx context.Context, tx *sql.Tx, id string, from, to NodeStatus, at time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO node_status_history (node_id, from_status, to_status, timestamp) VALUES (?, ?, ?, ?)`,
+		id, string(from), string(to), at.UTC().Format(time.RFC3339))
+	return err
+}
+
+// SetNodeFlapping sets or clears the node's flapping flag.
+func (s *SQLiteStore) SetNodeFlapping(ctx context.Context, id string, flapping bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE nodes SET flapping = ? WHERE id = ?`, flapping, id)
 	return err
 }
 
+// ListStatusTransitions returns a node's status transitions since the given
+// time in chronological order, preceded by the last transition before it
+// (which establishes the status at the start of the window).
+func (s *SQLiteStore) ListStatusTransitions(ctx context.Context, nodeID string, since time.Time) ([]StatusTransition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ts := since.UTC().Format(time.RFC3339)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT node_id, from_status, to_status, timestamp FROM node_status_history
+		 WHERE node_id = ? AND (timestamp >= ? OR id = (
+			SELECT MAX(id) FROM node_status_history WHERE node_id = ? AND timestamp < ?))
+		 ORDER BY timestamp, id`, nodeID, ts, nodeID, ts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTransitions(rows)
+}
+
+// CountStatusTransitions returns the number of status transitions per node
+// since the given time. Nodes without transitions are omitted.
+func (s *SQLiteStore) CountStatusTransitions(ctx context.Context, since time.Time) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT node_id, COUNT(*) FROM node_status_history
+		 WHERE timestamp >= ? AND from_status != '' GROUP BY node_id`,
+		since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
+// PruneStatusHistory deletes status transitions older than before, keeping
+// each node's most recent transition so its status at any later time is
+// still known.
+func (s *SQLiteStore) PruneStatusHistory(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM node_status_history WHERE timestamp < ? AND id NOT IN (
+			SELECT MAX(id) FROM node_status_history GROUP BY node_id)`,
+		before.UTC().Format(time.RFC3339))
+	return err
+}
+
+func scanTransitions(rows *sql.Rows) ([]StatusTransition, error) {
+	var out []StatusTransition
+	for rows.Next() {
+		var t StatusTransition
+		var from, to, ts string
+		if err := rows.Scan(&t.NodeID, &from, &to, &ts); err != nil {
+			return nil, err
+		}
+		t.From = NodeStatus(from)
+		t.To = NodeStatus(to)
+		t.Time, _ = time.Parse(time.RFC3339, ts)
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
 // UpdateNodeCompliance updates the node's compliance summary counts.
 func (s *SQLiteStore) UpdateNodeCompliance(ctx context.Context, id string, pass, fail, warn int) error {
 	s.mu.Lock()
@@ -238,7 +516,7 @@ func (s *SQLiteStore) GetNodeByAPIKey(ctx context.Context, apiKeyHash string) (*
 	defer s.mu.RUnlock()
 
 	row := s.db.QueryRowContext(ctx,
-		`SELECT id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, compliance_pass, compliance_fail, compliance_warn, compliance_status, service_json, grace_period_end
+		`SELECT id, name, role, region, labels, enrolled_at, last_heartbeat, status, version, fips_backend, compliance_pass, compliance_fail, compliance_warn, compliance_status, service_json, grace_period_end, flapping
 		 FROM nodes WHERE api_key_hash = ?`, apiKeyHash)
 	return scanNode(row)
 }
@@ -327,12 +605,81 @@ func (s *SQLiteStore) DeleteToken(ctx context.Context, id string) error {
 
 // StoreReport saves a compliance report JSON for a node.
 func (s *SQLiteStore) StoreReport(ctx context.Context, nodeID string, report []byte) error {
+	return s.StoreReportAt(ctx, nodeID, time.Now().UTC(), report)
+}
+
+// StoreReportAt saves a compliance report JSON under the given timestamp.
+// Used for reports replayed from an agent's offline spool, which keep the
+// time they were generated rather than the time they arrived.
+func (s *SQLiteStore) StoreReportAt(ctx context.Context, nodeID string, ts time.Time, report []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	_, err := s.db.ExecContext(ctx,
 		`INSERT INTO compliance_reports (node_id, timestamp, report) VALUES (?, ?, ?)`,
-		nodeID, time.Now().UTC().Format(time.RFC3339), string(report))
+		nodeID, ts.UTC().Format(time.RFC3339), string(report))
+	return err
+}
+
+// StoreReportIfChanged stores a live report unless its content hash
+// matches the node's last stored report.
+func (s *SQLiteStore) StoreReportIfChanged(ctx context.Context, nodeID, hash string, report []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var prev string
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM report_hashes WHERE node_id = ?`, nodeID).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if prev == hash {
+		return false, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO compliance_reports (node_id, timestamp, report) VALUES (?, ?, ?)`,
+		nodeID, now, string(report)); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO report_hashes (node_id, hash, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT(node_id) DO UPDATE SET hash = excluded.hash, updated_at = excluded.updated_at`,
+		nodeID, hash, now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetReportHash returns the content hash of the node's last stored live
+// report, or "" if none has been stored.
+func (s *SQLiteStore) GetReportHash(ctx context.Context, nodeID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hash string
+	err := s.db.QueryRowContext(ctx, `SELECT hash FROM report_hashes WHERE node_id = ?`, nodeID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// StoreHeartbeatAt records a heartbeat the node sent at ts while the
+// controller was unreachable. It does not touch the node's live status.
+func (s *SQLiteStore) StoreHeartbeatAt(ctx context.Context, nodeID string, ts time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO heartbeat_history (node_id, timestamp) VALUES (?, ?)`,
+		nodeID, ts.UTC().Format(time.RFC3339))
 	return err
 }
 
@@ -343,7 +690,7 @@ func (s *SQLiteStore) GetLatestReport(ctx context.Context, nodeID string) ([]byt
 
 	var report string
 	err := s.db.QueryRowContext(ctx,
-		`SELECT report FROM compliance_reports WHERE node_id = ? ORDER BY timestamp DESC LIMIT 1`,
+		`SELECT report FROM compliance_reports WHERE node_id = ? ORDER BY timestamp DESC, id DESC LIMIT 1`,
 		nodeID).Scan(&report)
 	if err != nil {
 		return nil, err
@@ -422,9 +769,81 @@ func (s *SQLiteStore) GetSummary(ctx context.Context) (*FleetSummary, error) {
 		return nil, err
 	}
 
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM nodes WHERE flapping != 0`).Scan(&summary.Flapping)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Availability, err = s.fleetAvailabilityLocked(ctx, summary.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
 	return summary, nil
 }
 
+// fleetAvailabilityLocked returns the mean availability across all nodes.
+func (s *SQLiteStore) fleetAvailabilityLocked(ctx context.Context, now time.Time) (Availability, error) {
+	var avail Availability
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, enrolled_at FROM nodes`)
+	if err != nil {
+		return avail, err
+	}
+	var nodes []Node
+	for rows.Next() {
+		var n Node
+		var status, enrolledAt string
+		if err := rows.Scan(&n.ID, &status, &enrolledAt); err != nil {
+			rows.Close()
+			return avail, err
+		}
+		n.Status = NodeStatus(status)
+		n.EnrolledAt, _ = time.Parse(time.RFC3339, enrolledAt)
+		nodes = append(nodes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return avail, err
+	}
+	if len(nodes) == 0 {
+		return Availability{Day: 100, Week: 100, Month: 100}, nil
+	}
+
+	ts := now.Add(-AvailabilityMonth).UTC().Format(time.RFC3339)
+	hrows, err := s.db.QueryContext(ctx,
+		`SELECT node_id, from_status, to_status, timestamp FROM node_status_history
+		 WHERE timestamp >= ? OR id IN (
+			SELECT MAX(id) FROM node_status_history WHERE timestamp < ? GROUP BY node_id)
+		 ORDER BY node_id, timestamp, id`, ts, ts)
+	if err != nil {
+		return avail, err
+	}
+	defer hrows.Close()
+	transitions, err := scanTransitions(hrows)
+	if err != nil {
+		return avail, err
+	}
+	byNode := make(map[string][]StatusTransition)
+	for _, t := range transitions {
+		byNode[t.NodeID] = append(byNode[t.NodeID], t)
+	}
+
+	for i := range nodes {
+		a := computeAvailability(byNode[nodes[i].ID], &nodes[i], now)
+		avail.Day += a.Day
+		avail.Week += a.Week
+		avail.Month += a.Month
+	}
+	count := float64(len(nodes))
+	return Availability{
+		Day:   math.Round(avail.Day/count*100) / 100,
+		Week:  math.Round(avail.Week/count*100) / 100,
+		Month: math.Round(avail.Month/count*100) / 100,
+	}, nil
+}
+
 // populateNodeExtras fills in the extended node fields from their stored
 // string representations after the core fields have been scanned.
 func populateNodeExtras(n *Node, compStatus, serviceJSON, gracePeriodEnd string) {
@@ -454,7 +873,7 @@ func scanNode(row *sql.Row) (*Node, error) {
 	err := row.Scan(&n.ID, &n.Name, &roleStr, &n.Region, &labelsStr,
 		&enrolledAt, &lastHB, &statusStr, &n.Version, &n.FIPSBackend,
 		&n.CompliancePass, &n.ComplianceFail, &n.ComplianceWarn,
-		&compStatus, &serviceJSON, &gracePeriodEnd)
+		&compStatus, &serviceJSON, &gracePeriodEnd, &n.Flapping)
 	if err != nil {
 		return nil, err
 	}
@@ -475,7 +894,7 @@ func scanNodeRows(rows *sql.Rows) (*Node, error) {
 	err := rows.Scan(&n.ID, &n.Name, &roleStr, &n.Region, &labelsStr,
 		&enrolledAt, &lastHB, &statusStr, &n.Version, &n.FIPSBackend,
 		&n.CompliancePass, &n.ComplianceFail, &n.ComplianceWarn,
-		&compStatus, &serviceJSON, &gracePeriodEnd)
+		&compStatus, &serviceJSON, &gracePeriodEnd, &n.Flapping)
 	if err != nil {
 		return nil, err
 	}
@@ -490,31 +909,69 @@ func scanNodeRows(rows *sql.Rows) (*Node, error) {
 
 // CreateRemediationRequest stores a new remediation request for a node.
 func (s *SQLiteStore) CreateRemediationRequest(ctx context.Context, req *RemediationRequest) error {
+	return s.insertRemediation(ctx, "remediation_requests", "node_id", req.NodeID, req)
+}
+
+// insertRemediation stores req in table, with target (a node or zone ID)
+// in targetColumn.
+func (s *SQLiteStore) insertRemediation(ctx context.Context, table, targetColumn, target string, req *RemediationRequest) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	actionsJSON, _ := json.Marshal(req.Actions)
+	approvalsJSON := marshalApprovals(req.Approvals)
 	dryRun := 0
 	if req.DryRun {
 		dryRun = 1
 	}
+	reqType := req.Type
+	if reqType == "" {
+		reqType = RemediationTypeRemediate
+	}
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO remediation_requests (id, node_id, actions, dry_run, status, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		req.ID, req.NodeID, string(actionsJSON), dryRun,
-		string(req.Status), req.CreatedAt.UTC().Format(time.RFC3339))
+		`INSERT INTO `+table+` (id, `+targetColumn+`, actions, dry_run, status, created_at, type, rollback_of,
+		   requested_by, required_approvals, approvals, approval_expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.ID, target, string(actionsJSON), dryRun,
+		string(req.Status), req.CreatedAt.UTC().Format(time.RFC3339),
+		string(reqType), req.RollbackOf,
+		req.RequestedBy, req.RequiredApprovals, string(approvalsJSON), formatOptionalTime(req.ApprovalExpiresAt))
 	return err
 }
 
+// remediationColumns is the column list scanned by scanRemediation.
+const remediationColumns = `id, node_id, ` + remediationFields
+
+// edgeRemediationColumns is the column list scanned by scanEdgeRemediation.
+const edgeRemediationColumns = `id, zone_id, ` + remediationFields
+
+const remediationFields = `actions, dry_run, status, created_at, completed_at, result, type, rollback_of,
+	requested_by, required_approvals, approvals, approval_expires_at`
+
 // GetPendingRemediations returns pending remediation requests for a node.
 func (s *SQLiteStore) GetPendingRemediations(ctx context.Context, nodeID string) ([]RemediationRequest, error) {
+	return s.ListRemediationRequests(ctx, RemediationFilter{NodeID: nodeID, Status: RemediationPending})
+}
+
+// ListRemediationRequests returns remediation requests matching the filter,
+// oldest first.
+func (s *SQLiteStore) ListRemediationRequests(ctx context.Context, filter RemediationFilter) ([]RemediationRequest, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, node_id, actions, dry_run, status, created_at, completed_at, result
-		 FROM remediation_requests WHERE node_id = ? AND status = 'pending'
-		 ORDER BY created_at ASC`, nodeID)
+	query := "SELECT " + remediationColumns + " FROM remediation_requests WHERE 1=1"
+	var args []interface{}
+	if filter.NodeID != "" {
+		query += " AND node_id = ?"
+		args = append(args, filter.NodeID)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, string(filter.Status))
+	}
+	query += " ORDER BY created_at ASC, id ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -522,35 +979,38 @@ func (s *SQLiteStore) GetPendingRemediations(ctx context.Context, nodeID string)
 
 	var reqs []RemediationRequest
 	for rows.Next() {
-		var r RemediationRequest
-		var actionsStr, createdAt, completedAt, resultStr string
-		var dryRun int
-		if err := rows.Scan(&r.ID, &r.NodeID, &actionsStr, &dryRun, &r.Status,
-			&createdAt, &completedAt, &resultStr); err != nil {
+		r, err := scanRemediation(rows)
+		if err != nil {
 			return nil, err
 		}
-		r.DryRun = dryRun == 1
-		_ = json.Unmarshal([]byte(actionsStr), &r.Actions)
-		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		if completedAt != "" {
-			t, _ := time.Parse(time.RFC3339, completedAt)
-			r.CompletedAt = &t
-		}
-		reqs = append(reqs, r)
+		reqs = append(reqs, *r)
 	}
 	return reqs, rows.Err()
 }
 
-// CompleteRemediation marks a remediation request as completed and stores the result.
+// CompleteRemediation marks a pending remediation request as completed and
+// stores the result. It returns ErrNotPending if the request is awaiting
+// approval, expired, or already finished.
 func (s *SQLiteStore) CompleteRemediation(ctx context.Context, reqID string, result []byte) error {
+	return s.finishRemediation(ctx, "remediation_requests", reqID, RemediationCompleted, result)
+}
+
+// finishRemediation moves a pending request to status and stores result.
+func (s *SQLiteStore) finishRemediation(ctx context.Context, table, reqID string, status RemediationStatus, result []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx,
-		`UPDATE remediation_requests SET status = 'completed', completed_at = ?, result = ?
-		 WHERE id = ?`,
-		time.Now().UTC().Format(time.RFC3339), string(result), reqID)
-	return err
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE `+table+` SET status = ?, completed_at = ?, result = ?
+		 WHERE id = ? AND status = ?`,
+		string(status), time.Now().UTC().Format(time.RFC3339), string(result), reqID, string(RemediationPending))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotPending
+	}
+	return nil
 }
 
 // GetRemediationRequest returns a specific remediation request.
@@ -558,27 +1018,538 @@ func (s *SQLiteStore) GetRemediationRequest(ctx context.Context, id string) (*Re
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	row := s.db.QueryRowContext(ctx,
-		`SELECT id, node_id, actions, dry_run, status, created_at, completed_at, result
-		 FROM remediation_requests WHERE id = ?`, id)
+	return scanRemediation(s.db.QueryRowContext(ctx,
+		"SELECT "+remediationColumns+" FROM remediation_requests WHERE id = ?", id))
+}
+
+// ApproveRemediation atomically applies an approval to a request (see
+// RemediationRequest.Approve) and returns the updated request. When the
+// approval window has passed, the request is stored as expired and
+// ErrApprovalExpired is returned with it.
+func (s *SQLiteStore) ApproveRemediation(ctx context.Context, id string, approval RemediationApproval) (*RemediationRequest, error) {
+	return s.approveRemediation(ctx, "remediation_requests", remediationColumns, scanRemediation, id, approval)
+}
+
+func (s *SQLiteStore) approveRemediation(ctx context.Context, table, columns string, scan func(rowScanner) (*RemediationRequest, error),
+	id string, approval RemediationApproval) (*RemediationRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
+	req, err := scan(tx.QueryRowContext(ctx,
+		"SELECT "+columns+" FROM "+table+" WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	approveErr := req.Approve(approval)
+	if approveErr != nil && approveErr != ErrApprovalExpired {
+		return req, approveErr
+	}
+
+	approvalsJSON := marshalApprovals(req.Approvals)
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE `+table+` SET status = ?, approvals = ? WHERE id = ?`,
+		string(req.Status), string(approvalsJSON), id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return req, approveErr
+}
+
+// ExpireRemediationApprovals marks node and edge requests still awaiting
+// approval after their approval window as expired and returns them.
+func (s *SQLiteStore) ExpireRemediationApprovals(ctx context.Context, now time.Time) ([]RemediationRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var expired []RemediationRequest
+	for _, t := range []struct {
+		table, columns string
+		scan           func(rowScanner) (*RemediationRequest, error)
+	}{
+		{"remediation_requests", remediationColumns, scanRemediation},
+		{"edge_remediations", edgeRemediationColumns, scanEdgeRemediation},
+	} {
+		// RFC3339 UTC timestamps compare correctly as text.
+		rows, err := tx.QueryContext(ctx,
+			"SELECT "+t.columns+" FROM "+t.table+`
+			 WHERE status = 'awaiting_approval' AND approval_expires_at != '' AND approval_expires_at < ?`,
+			now.UTC().Format(time.RFC3339))
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for rows.Next() {
+			r, err := t.scan(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			r.Status = RemediationExpired
+			expired = append(expired, *r)
+			ids = append(ids, r.ID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE `+t.table+` SET status = 'expired' WHERE id = ?`, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return expired, tx.Commit()
+}
+
+// CreateEdgeRemediation stores an edge remediation or rollback request;
+// req.ZoneID identifies the zone.
+func (s *SQLiteStore) CreateEdgeRemediation(ctx context.Context, req *RemediationRequest) error {
+	return s.insertRemediation(ctx, "edge_remediations", "zone_id", req.ZoneID, req)
+}
+
+// GetEdgeRemediation returns a specific edge remediation request.
+func (s *SQLiteStore) GetEdgeRemediation(ctx context.Context, id string) (*RemediationRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return scanEdgeRemediation(s.db.QueryRowContext(ctx,
+		"SELECT "+edgeRemediationColumns+" FROM edge_remediations WHERE id = ?", id))
+}
+
+// ListEdgeRemediations returns edge remediation requests matching the
+// filter's status, oldest first.
+func (s *SQLiteStore) ListEdgeRemediations(ctx context.Context, filter RemediationFilter) ([]RemediationRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := "SELECT " + edgeRemediationColumns + " FROM edge_remediations WHERE 1=1"
+	var args []interface{}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, string(filter.Status))
+	}
+	query += " ORDER BY created_at ASC, id ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reqs []RemediationRequest
+	for rows.Next() {
+		r, err := scanEdgeRemediation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *r)
+	}
+	return reqs, rows.Err()
+}
+
+// ApproveEdgeRemediation applies an approval to an edge request, as
+// ApproveRemediation does for node requests.
+func (s *SQLiteStore) ApproveEdgeRemediation(ctx context.Context, id string, approval RemediationApproval) (*RemediationRequest, error) {
+	return s.approveRemediation(ctx, "edge_remediations", edgeRemediationColumns, scanEdgeRemediation, id, approval)
+}
+
+// CompleteEdgeRemediation marks a pending edge remediation request as
+// completed and stores its result, which a rollback later restores from.
+func (s *SQLiteStore) CompleteEdgeRemediation(ctx context.Context, reqID string, result []byte) error {
+	return s.finishRemediation(ctx, "edge_remediations", reqID, RemediationCompleted, result)
+}
+
+// FailEdgeRemediation marks a pending edge remediation request as failed
+// and stores result, which describes the error.
+func (s *SQLiteStore) FailEdgeRemediation(ctx context.Context, reqID string, result []byte) error {
+	return s.finishRemediation(ctx, "edge_remediations", reqID, RemediationFailed, result)
+}
+
+// scanEdgeRemediation scans a row selected with edgeRemediationColumns.
+func scanEdgeRemediation(row rowScanner) (*RemediationRequest, error) {
+	r, err := scanRemediation(row)
+	if err != nil {
+		return nil, err
+	}
+	r.ZoneID, r.NodeID = r.NodeID, ""
+	return r, nil
+}
+
+func scanRemediation(row rowScanner) (*RemediationRequest, error) {
 	var r RemediationRequest
-	var actionsStr, createdAt, completedAt, resultStr string
+	var actionsStr, createdAt, completedAt, resultStr, approvalsStr, expiresAt string
 	var dryRun int
-	err := row.Scan(&r.ID, &r.NodeID, &actionsStr, &dryRun, &r.Status,
-		&createdAt, &completedAt, &resultStr)
-	if err != nil {
+	if err := row.Scan(&r.ID, &r.NodeID, &actionsStr, &dryRun, &r.Status,
+		&createdAt, &completedAt, &resultStr, &r.Type, &r.RollbackOf,
+		&r.RequestedBy, &r.RequiredApprovals, &approvalsStr, &expiresAt); err != nil {
 		return nil, err
 	}
 	r.DryRun = dryRun == 1
 	_ = json.Unmarshal([]byte(actionsStr), &r.Actions)
+	_ = json.Unmarshal([]byte(approvalsStr), &r.Approvals)
 	r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 	if completedAt != "" {
 		t, _ := time.Parse(time.RFC3339, completedAt)
 		r.CompletedAt = &t
 	}
+	if expiresAt != "" {
+		t, _ := time.Parse(time.RFC3339, expiresAt)
+		r.ApprovalExpiresAt = &t
+	}
 	if resultStr != "" {
 		r.Result = json.RawMessage(resultStr)
 	}
 	return &r, nil
 }
+
+func marshalApprovals(approvals []RemediationApproval) []byte {
+	if approvals == nil {
+		approvals = []RemediationApproval{}
+	}
+	b, _ := json.Marshal(approvals)
+	return b
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// CreateMaintenanceWindow stores a maintenance window.
+func (s *SQLiteStore) CreateMaintenanceWindow(ctx context.Context, w *MaintenanceWindow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	labelsJSON, _ := json.Marshal(w.Labels)
+	days := w.Days
+	if days == nil {
+		days = []string{}
+	}
+	daysJSON, _ := json.Marshal(days)
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO maintenance_windows (id, node_id, labels, days, start, duration_minutes, timezone, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.ID, w.NodeID, string(labelsJSON), string(daysJSON), w.Start, w.DurationMinutes, w.Timezone,
+		w.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// ListMaintenanceWindows returns all maintenance windows, oldest first.
+func (s *SQLiteStore) ListMaintenanceWindows(ctx context.Context) ([]MaintenanceWindow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, node_id, labels, days, start, duration_minutes, timezone, created_at
+		 FROM maintenance_windows ORDER BY created_at ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []MaintenanceWindow
+	for rows.Next() {
+		var w MaintenanceWindow
+		var labelsStr, daysStr, createdAt string
+		if err := rows.Scan(&w.ID, &w.NodeID, &labelsStr, &daysStr, &w.Start, &w.DurationMinutes, &w.Timezone, &createdAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(labelsStr), &w.Labels)
+		_ = json.Unmarshal([]byte(daysStr), &w.Days)
+		w.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// DeleteMaintenanceWindow removes a maintenance window, returning
+// ErrWindowNotFound if it does not exist.
+func (s *SQLiteStore) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWindowNotFound
+	}
+	return nil
+}
+
+// CreateReboot stores a new node reboot.
+func (s *SQLiteStore) CreateReboot(ctx context.Context, rb *Reboot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO reboots (id, node_id, remediation_id, check_ids, status, detail, reboot_request_id,
+		   created_at, scheduled_at, reboot_at, completed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rb.ID, rb.NodeID, rb.RemediationID, string(marshalIDs(rb.CheckIDs)), string(rb.Status), rb.Detail,
+		rb.RebootRequestID, rb.CreatedAt.UTC().Format(time.RFC3339),
+		formatOptionalTime(rb.ScheduledAt), formatOptionalTime(rb.RebootAt), formatOptionalTime(rb.CompletedAt))
+	return err
+}
+
+// UpdateReboot saves a reboot's checks, status, and progress.
+func (s *SQLiteStore) UpdateReboot(ctx context.Context, rb *Reboot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE reboots SET check_ids = ?, status = ?, detail = ?, reboot_request_id = ?,
+		   scheduled_at = ?, reboot_at = ?, completed_at = ?
+		 WHERE id = ?`,
+		string(marshalIDs(rb.CheckIDs)), string(rb.Status), rb.Detail, rb.RebootRequestID,
+		formatOptionalTime(rb.ScheduledAt), formatOptionalTime(rb.RebootAt), formatOptionalTime(rb.CompletedAt),
+		rb.ID)
+	return err
+}
+
+// ListReboots returns reboots matching the filter, oldest first.
+func (s *SQLiteStore) ListReboots(ctx context.Context, filter RebootFilter) ([]Reboot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT id, node_id, remediation_id, check_ids, status, detail, reboot_request_id,
+		created_at, scheduled_at, reboot_at, completed_at FROM reboots WHERE 1=1`
+	var args []interface{}
+	if filter.NodeID != "" {
+		query += " AND node_id = ?"
+		args = append(args, filter.NodeID)
+	}
+	if len(filter.Statuses) > 0 {
+		query += " AND status IN (?" + strings.Repeat(", ?", len(filter.Statuses)-1) + ")"
+		for _, st := range filter.Statuses {
+			args = append(args, string(st))
+		}
+	}
+	query += " ORDER BY created_at ASC, id ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reboots []Reboot
+	for rows.Next() {
+		var rb Reboot
+		var checkIDs, createdAt, scheduledAt, rebootAt, completedAt string
+		if err := rows.Scan(&rb.ID, &rb.NodeID, &rb.RemediationID, &checkIDs, &rb.Status, &rb.Detail,
+			&rb.RebootRequestID, &createdAt, &scheduledAt, &rebootAt, &completedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(checkIDs), &rb.CheckIDs)
+		rb.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		rb.ScheduledAt = parseOptionalTime(scheduledAt)
+		rb.RebootAt = parseOptionalTime(rebootAt)
+		rb.CompletedAt = parseOptionalTime(completedAt)
+		reboots = append(reboots, rb)
+	}
+	return reboots, rows.Err()
+}
+
+func marshalIDs(ids []string) []byte {
+	if ids == nil {
+		ids = []string{}
+	}
+	b, _ := json.Marshal(ids)
+	return b
+}
+
+func parseOptionalTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// ApplyFederationSync records a child controller's sync: its summary and
+// drill-down endpoint, and its nodes (replaced wholesale for a full sync,
+// upserted and removed for a delta). Federated nodes are tagged with the
+// site ID.
+func (s *SQLiteStore) ApplyFederationSync(ctx context.Context, siteID string, sync *FederationSync) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	summaryJSON, _ := json.Marshal(sync.Summary)
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO federated_sites (site_id, name, api_url, api_token, summary_json, last_sync)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(site_id) DO UPDATE SET name = excluded.name, api_url = excluded.api_url,
+		   api_token = excluded.api_token, summary_json = excluded.summary_json, last_sync = excluded.last_sync`,
+		siteID, sync.SiteName, sync.APIURL, sync.APIToken, string(summaryJSON), now); err != nil {
+		return err
+	}
+
+	if sync.Full {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM federated_nodes WHERE site_id = ?`, siteID); err != nil {
+			return err
+		}
+	}
+	for _, id := range sync.Removed {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM federated_nodes WHERE site_id = ? AND node_id = ?`, siteID, id); err != nil {
+			return err
+		}
+	}
+	for _, n := range sync.Nodes {
+		n.Site = siteID
+		nodeJSON, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO federated_nodes (site_id, node_id, node_json, updated_at) VALUES (?, ?, ?, ?)
+			 ON CONFLICT(site_id, node_id) DO UPDATE SET node_json = excluded.node_json, updated_at = excluded.updated_at`,
+			siteID, n.ID, string(nodeJSON), now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSite returns a federated child controller by its site ID.
+func (s *SQLiteStore) GetSite(ctx context.Context, id string) (*Site, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT site_id, name, api_url, api_token, summary_json, last_sync,
+		   (SELECT COUNT(*) FROM federated_nodes f WHERE f.site_id = federated_sites.site_id)
+		 FROM federated_sites WHERE site_id = ?`, id)
+	return scanSite(row)
+}
+
+// ListSites returns all federated child controllers.
+func (s *SQLiteStore) ListSites(ctx context.Context) ([]Site, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT site_id, name, api_url, api_token, summary_json, last_sync,
+		   (SELECT COUNT(*) FROM federated_nodes f WHERE f.site_id = federated_sites.site_id)
+		 FROM federated_sites ORDER BY name, site_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []Site
+	for rows.Next() {
+		site, err := scanSite(rows)
+		if err != nil {
+			return nil, err
+		}
+		sites = append(sites, *site)
+	}
+	return sites, rows.Err()
+}
+
+// ListFederatedNodes returns nodes reported by child controllers that
+// match the filter.
+func (s *SQLiteStore) ListFederatedNodes(ctx context.Context, filter NodeFilter) ([]Node, error) {
+	if filter.Site == SiteLocal {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT node_json FROM federated_nodes`
+	var args []interface{}
+	if filter.Site != "" {
+		query += ` WHERE site_id = ?`
+		args = append(args, filter.Site)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []Node
+	for rows.Next() {
+		var nodeJSON string
+		if err := rows.Scan(&nodeJSON); err != nil {
+			return nil, err
+		}
+		var n Node
+		if err := json.Unmarshal([]byte(nodeJSON), &n); err != nil {
+			continue
+		}
+		if n.MatchesFilter(filter) {
+			nodes = append(nodes, n)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].EnrolledAt.After(nodes[j].EnrolledAt) })
+	return nodes, nil
+}
+
+// GetFederatedNode returns a node reported by a child controller.
+func (s *SQLiteStore) GetFederatedNode(ctx context.Context, id string) (*Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var nodeJSON string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT node_json FROM federated_nodes WHERE node_id = ? ORDER BY updated_at DESC LIMIT 1`, id).Scan(&nodeJSON)
+	if err != nil {
+		return nil, err
+	}
+	var n Node
+	if err := json.Unmarshal([]byte(nodeJSON), &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSite(row rowScanner) (*Site, error) {
+	var site Site
+	var summaryJSON, lastSync string
+	if err := row.Scan(&site.ID, &site.Name, &site.APIURL, &site.APIToken, &summaryJSON, &lastSync, &site.NodeCount); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal([]byte(summaryJSON), &site.Summary)
+	site.LastSync, _ = time.Parse(time.RFC3339, lastSync)
+	return &site, nil
+}
@@ -2,6 +2,7 @@ package fleet
 
 import (
 	"context"
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -553,3 +554,403 @@ func TestSQLiteStore_PersistReopen(t *testing.T) {
 		t.Errorf("database file should exist: %v", err)
 	}
 }
+
+func TestSQLiteStore_StoreReportAt_DoesNotOvertakeLatest(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	node := &Node{ID: "n1", Name: "test", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusOnline}
+	if err := store.CreateNode(ctx, node, "h1"); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	live := []byte(`{"live":true}`)
+	if err := store.StoreReport(ctx, "n1", live); err != nil {
+		t.Fatalf("StoreReport: %v", err)
+	}
+	// A replayed report from an hour ago arrives after the live one.
+	if err := store.StoreReportAt(ctx, "n1", now.Add(-time.Hour), []byte(`{"live":false}`)); err != nil {
+		t.Fatalf("StoreReportAt: %v", err)
+	}
+
+	got, err := store.GetLatestReport(ctx, "n1")
+	if err != nil {
+		t.Fatalf("GetLatestReport: %v", err)
+	}
+	if string(got) != string(live) {
+		t.Errorf("latest report = %s, want the live report", got)
+	}
+}
+
+func TestSQLiteStore_StoreHeartbeatAt(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	node := &Node{ID: "n1", Name: "test", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusOffline}
+	if err := store.CreateNode(ctx, node, "h1"); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	if err := store.StoreHeartbeatAt(ctx, "n1", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("StoreHeartbeatAt: %v", err)
+	}
+
+	got, _ := store.GetNode(ctx, "n1")
+	if got.Status != StatusOffline {
+		t.Errorf("status = %s, historical heartbeat must not change it", got.Status)
+	}
+	if !got.LastHeartbeat.Equal(now) {
+		t.Errorf("last_heartbeat = %v, want unchanged %v", got.LastHeartbeat, now)
+	}
+}
+
+func TestSQLiteStore_StoreReportIfChanged(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	_ = store.CreateNode(ctx, &Node{ID: "n1", Name: "n1", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusOnline}, "h1")
+
+	if h, err := store.GetReportHash(ctx, "n1"); err != nil || h != "" {
+		t.Fatalf("GetReportHash before any report = %q, %v", h, err)
+	}
+
+	stored, err := store.StoreReportIfChanged(ctx, "n1", "aaa", []byte(`{"v":1}`))
+	if err != nil || !stored {
+		t.Fatalf("first store = %v, %v; want stored", stored, err)
+	}
+	stored, err = store.StoreReportIfChanged(ctx, "n1", "aaa", []byte(`{"v":1}`))
+	if err != nil || stored {
+		t.Fatalf("unchanged store = %v, %v; want skipped", stored, err)
+	}
+	stored, err = store.StoreReportIfChanged(ctx, "n1", "bbb", []byte(`{"v":2}`))
+	if err != nil || !stored {
+		t.Fatalf("changed store = %v, %v; want stored", stored, err)
+	}
+
+	var count int
+	_ = store.db.QueryRow(`SELECT COUNT(*) FROM compliance_reports WHERE node_id = 'n1'`).Scan(&count)
+	if count != 2 {
+		t.Errorf("stored reports = %d, want 2", count)
+	}
+	if h, _ := store.GetReportHash(ctx, "n1"); h != "bbb" {
+		t.Errorf("GetReportHash = %q, want bbb", h)
+	}
+	latest, _ := store.GetLatestReport(ctx, "n1")
+	if string(latest) != `{"v":2}` {
+		t.Errorf("latest report = %s", latest)
+	}
+}
+
+func TestSQLiteStore_StatusHistory(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	enrolled := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	_ = store.CreateNode(ctx, &Node{ID: "n1", Name: "n1", Role: RoleServer, EnrolledAt: enrolled, LastHeartbeat: enrolled, Status: StatusOnline}, "h1")
+
+	// A heartbeat on an online node is not a transition.
+	_ = store.UpdateNodeHeartbeat(ctx, "n1", time.Now())
+	_ = store.UpdateNodeStatus(ctx, "n1", StatusDegraded)
+	_ = store.UpdateNodeStatus(ctx, "n1", StatusDegraded)
+	_ = store.UpdateNodeStatus(ctx, "n1", StatusOffline)
+	_ = store.UpdateNodeHeartbeat(ctx, "n1", time.Now())
+
+	got, err := store.ListStatusTransitions(ctx, "n1", enrolled.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ListStatusTransitions: %v", err)
+	}
+	want := []struct{ from, to NodeStatus }{
+		{"", StatusOnline},
+		{StatusOnline, StatusDegraded},
+		{StatusDegraded, StatusOffline},
+		{StatusOffline, StatusOnline},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("transitions = %+v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].From != w.from || got[i].To != w.to {
+			t.Errorf("transition %d = %s->%s, want %s->%s", i, got[i].From, got[i].To, w.from, w.to)
+		}
+	}
+	if !got[0].Time.Equal(enrolled) {
+		t.Errorf("initial transition at %v, want enrollment time %v", got[0].Time, enrolled)
+	}
+
+	// A window starting after every transition still yields the latest one.
+	got, _ = store.ListStatusTransitions(ctx, "n1", time.Now().Add(time.Hour))
+	if len(got) != 1 || got[0].To != StatusOnline {
+		t.Errorf("transitions after window = %+v, want the latest only", got)
+	}
+
+	counts, err := store.CountStatusTransitions(ctx, enrolled.Add(-time.Minute))
+	if err != nil || counts["n1"] != 3 {
+		t.Errorf("CountStatusTransitions = %v, %v; want 3 (initial status excluded)", counts, err)
+	}
+
+	if err := store.PruneStatusHistory(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PruneStatusHistory: %v", err)
+	}
+	got, _ = store.ListStatusTransitions(ctx, "n1", enrolled.Add(-time.Minute))
+	if len(got) != 1 || got[0].To != StatusOnline {
+		t.Errorf("after prune = %+v, want the latest transition kept", got)
+	}
+}
+
+func TestSQLiteStore_HeartbeatKeepsFailingNodeDegraded(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	_ = store.CreateNode(ctx, &Node{ID: "n1", Name: "n1", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusDegraded}, "h1")
+	_ = store.UpdateNodeCompliance(ctx, "n1", 3, 1, 0)
+
+	_ = store.UpdateNodeHeartbeat(ctx, "n1", now)
+	n, _ := store.GetNode(ctx, "n1")
+	if n.Status != StatusDegraded {
+		t.Errorf("status = %s, want degraded", n.Status)
+	}
+	counts, _ := store.CountStatusTransitions(ctx, now.Add(-time.Minute))
+	if counts["n1"] != 0 {
+		t.Errorf("transitions = %d, want 0", counts["n1"])
+	}
+}
+
+func TestSQLiteStore_Flapping(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	_ = store.CreateNode(ctx, &Node{ID: "n1", Name: "n1", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusOnline}, "h1")
+
+	if err := store.SetNodeFlapping(ctx, "n1", true); err != nil {
+		t.Fatalf("SetNodeFlapping: %v", err)
+	}
+	n, _ := store.GetNode(ctx, "n1")
+	if !n.Flapping {
+		t.Error("node not flagged as flapping")
+	}
+	summary, _ := store.GetSummary(ctx)
+	if summary.Flapping != 1 {
+		t.Errorf("summary flapping = %d, want 1", summary.Flapping)
+	}
+
+	_ = store.SetNodeFlapping(ctx, "n1", false)
+	nodes, _ := store.ListNodes(ctx, NodeFilter{})
+	if len(nodes) != 1 || nodes[0].Flapping {
+		t.Errorf("nodes = %+v, want flapping cleared", nodes)
+	}
+}
+
+func TestSQLiteStore_SummaryAvailability(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	enrolled := now.Add(-48 * time.Hour)
+	for _, id := range []string{"up", "down"} {
+		_ = store.CreateNode(ctx, &Node{ID: id, Name: id, Role: RoleServer, EnrolledAt: enrolled, LastHeartbeat: now, Status: StatusOnline}, "h-"+id)
+	}
+	_ = store.UpdateNodeStatus(ctx, "down", StatusOffline)
+	// Backdate the outage so "down" has been offline for the last 12h.
+	_, _ = store.db.Exec(`UPDATE node_status_history SET timestamp = ? WHERE node_id = 'down' AND to_status = 'offline'`,
+		now.Add(-12*time.Hour).Format(time.RFC3339))
+
+	summary, err := store.GetSummary(ctx)
+	if err != nil {
+		t.Fatalf("GetSummary: %v", err)
+	}
+	// up: 100%; down: 50% of the last 24h -> mean 75%.
+	if summary.Availability.Day < 74.9 || summary.Availability.Day > 75.1 {
+		t.Errorf("24h availability = %v, want 75", summary.Availability.Day)
+	}
+	// down: 12h offline of 48h since enrollment -> 75%; mean 87.5%.
+	if summary.Availability.Month < 87.4 || summary.Availability.Month > 87.6 {
+		t.Errorf("30d availability = %v, want 87.5", summary.Availability.Month)
+	}
+}
+
+func TestSQLiteStore_MigratesAddedColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A nodes table as created before the flapping column existed.
+	_, err = db.Exec(`CREATE TABLE nodes (
+		id TEXT PRIMARY KEY, name TEXT NOT NULL, role TEXT NOT NULL,
+		region TEXT NOT NULL DEFAULT '', labels TEXT NOT NULL DEFAULT '{}',
+		enrolled_at TEXT NOT NULL, last_heartbeat TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'online', version TEXT NOT NULL DEFAULT '',
+		fips_backend TEXT NOT NULL DEFAULT '', api_key_hash TEXT NOT NULL UNIQUE,
+		compliance_pass INTEGER NOT NULL DEFAULT 0, compliance_fail INTEGER NOT NULL DEFAULT 0,
+		compliance_warn INTEGER NOT NULL DEFAULT 0, compliance_status TEXT NOT NULL DEFAULT 'unknown',
+		service_json TEXT NOT NULL DEFAULT '', grace_period_end TEXT NOT NULL DEFAULT '')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// remediation_requests as created before rollback requests existed.
+	_, err = db.Exec(`CREATE TABLE remediation_requests (
+		id TEXT PRIMARY KEY, node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+		actions TEXT NOT NULL DEFAULT '[]', dry_run INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'pending', created_at TEXT NOT NULL,
+		completed_at TEXT NOT NULL DEFAULT '', result TEXT NOT NULL DEFAULT '')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore on old schema: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetNodeFlapping(context.Background(), "x", true); err != nil {
+		t.Errorf("flapping column missing after migration: %v", err)
+	}
+	if _, err := store.GetRemediationRequest(context.Background(), "x"); err != sql.ErrNoRows {
+		t.Errorf("remediation_requests columns missing after migration: %v", err)
+	}
+}
+
+func TestSQLiteStore_RollbackRequest(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	node := &Node{ID: "n1", Name: "n1", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusOnline}
+	if err := store.CreateNode(ctx, node, "h1"); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := &RemediationRequest{ID: "rem-1", NodeID: "n1", Actions: []string{"enable_os_fips"}, Status: RemediationPending, CreatedAt: now}
+	if err := store.CreateRemediationRequest(ctx, orig); err != nil {
+		t.Fatal(err)
+	}
+	rb := &RemediationRequest{ID: "rem-2", NodeID: "n1", Status: RemediationPending, CreatedAt: now,
+		Type: RemediationTypeRollback, RollbackOf: "rem-1"}
+	if err := store.CreateRemediationRequest(ctx, rb); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetRemediationRequest(ctx, "rem-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != RemediationTypeRemediate {
+		t.Errorf("default type = %q, want remediate", got.Type)
+	}
+	pending, err := store.GetPendingRemediations(ctx, "n1")
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("pending = %v, %v", pending, err)
+	}
+	if pending[1].Type != RemediationTypeRollback || pending[1].RollbackOf != "rem-1" {
+		t.Errorf("rollback request = %+v", pending[1])
+	}
+}
+
+func TestSQLiteStore_RemediationApproval(t *testing.T) {
+	store := tempDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	node := &Node{ID: "n1", Name: "n1", Role: RoleServer, EnrolledAt: now, LastHeartbeat: now, Status: StatusOnline}
+	if err := store.CreateNode(ctx, node, "h1"); err != nil {
+		t.Fatal(err)
+	}
+
+	expires := now.Add(time.Hour)
+	staleExpiry := now.Add(-time.Hour)
+	for _, req := range []*RemediationRequest{
+		{ID: "rem-1", NodeID: "n1", Actions: []string{"enable_os_fips"}, Status: RemediationAwaitingApproval, CreatedAt: now,
+			RequestedBy: "admin", RequiredApprovals: 1, ApprovalExpiresAt: &expires},
+		{ID: "rem-2", NodeID: "n1", Actions: []string{"connect_warp"}, Status: RemediationAwaitingApproval, CreatedAt: now,
+			RequestedBy: "admin", RequiredApprovals: 1, ApprovalExpiresAt: &staleExpiry},
+	} {
+		if err := store.CreateRemediationRequest(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if pending, _ := store.GetPendingRemediations(ctx, "n1"); len(pending) != 0 {
+		t.Errorf("requests awaiting approval visible to agent: %+v", pending)
+	}
+
+	got, err := store.ApproveRemediation(ctx, "rem-1", RemediationApproval{Approver: "approver:bob", ApprovedAt: now})
+	if err != nil {
+		t.Fatalf("ApproveRemediation: %v", err)
+	}
+	if got.Status != RemediationPending || len(got.Approvals) != 1 {
+		t.Errorf("approved request = %+v", got)
+	}
+	if pending, _ := store.GetPendingRemediations(ctx, "n1"); len(pending) != 1 || pending[0].Approvals[0].Approver != "approver:bob" {
+		t.Errorf("pending after approval = %+v", pending)
+	}
+
+	// Edge requests expire the same way.
+	if err := store.CreateEdgeRemediation(ctx, &RemediationRequest{ID: "rem-3", ZoneID: "zone-1", Actions: []string{"fix_min_tls"},
+		Status: RemediationAwaitingApproval, CreatedAt: now, RequestedBy: "admin", RequiredApprovals: 1, ApprovalExpiresAt: &staleExpiry}); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := store.ExpireRemediationApprovals(ctx, now)
+	if err != nil || len(expired) != 2 || expired[0].ID != "rem-2" || expired[1].ZoneID != "zone-1" {
+		t.Fatalf("ExpireRemediationApprovals = %+v, %v", expired, err)
+	}
+	if _, err := store.ApproveEdgeRemediation(ctx, "rem-3", RemediationApproval{Approver: "approver:bob", ApprovedAt: now}); err != ErrNotAwaitingApproval {
+		t.Errorf("approve expired edge request err = %v", err)
+	}
+	stale, _ := store.GetRemediationRequest(ctx, "rem-2")
+	if stale.Status != RemediationExpired {
+		t.Errorf("rem-2 status = %s, want expired", stale.Status)
+	}
+	if _, err := store.ApproveRemediation(ctx, "rem-2", RemediationApproval{Approver: "approver:bob", ApprovedAt: now}); err != ErrNotAwaitingApproval {
+		t.Errorf("approve expired err = %v", err)
+	}
+
+	list, err := store.ListRemediationRequests(ctx, RemediationFilter{Status: RemediationExpired})
+	if err != nil || len(list) != 1 {
+		t.Errorf("ListRemediationRequests(expired) = %+v, %v", list, err)
+	}
+}
+
+func TestSQLiteStore_EdgeRemediation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Edge requests have no node.
+	req := &RemediationRequest{ID: "rem-1", ZoneID: "zone-1", Actions: []string{"fix_min_tls"},
+		Status: RemediationPending, CreatedAt: now, RequestedBy: "admin"}
+	if err := store.CreateEdgeRemediation(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	result := []byte(`{"request_id":"rem-1","actions":[{"id":"fix_min_tls","prior_state":{"min_tls_version":"1.0"}}]}`)
+	if err := store.CompleteEdgeRemediation(ctx, "rem-1", result); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FailEdgeRemediation(ctx, "rem-1", []byte(`{}`)); err != ErrNotPending {
+		t.Errorf("finishing a completed request: %v, want ErrNotPending", err)
+	}
+	if _, err := store.GetRemediationRequest(ctx, "rem-1"); err != sql.ErrNoRows {
+		t.Errorf("edge request listed as node remediation: %v", err)
+	}
+	store.Close()
+
+	// The result, with its prior state, survives a restart.
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	got, err := store.GetEdgeRemediation(ctx, "rem-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ZoneID != "zone-1" || got.NodeID != "" || got.Status != RemediationCompleted || string(got.Result) != string(result) {
+		t.Errorf("edge request = %+v", got)
+	}
+	if _, err := store.GetEdgeRemediation(ctx, "rem-2"); err != sql.ErrNoRows {
+		t.Errorf("unknown edge request err = %v", err)
+	}
+}
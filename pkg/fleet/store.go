@@ -18,6 +18,13 @@ type Store interface {
 	UpdateNodeComplianceStatus(ctx context.Context, id string, status string) error
 	DeleteNode(ctx context.Context, id string) error
 	GetNodeByAPIKey(ctx context.Context, apiKeyHash string) (*Node, error)
+	SetNodeFlapping(ctx context.Context, id string, flapping bool) error
+
+	// Status history (transitions are recorded by the status-changing
+	// methods above)
+	ListStatusTransitions(ctx context.Context, nodeID string, since time.Time) ([]StatusTransition, error)
+	CountStatusTransitions(ctx context.Context, since time.Time) (map[string]int, error)
+	PruneStatusHistory(ctx context.Context, before time.Time) error
 
 	// Enrollment tokens
 	CreateToken(ctx context.Context, token *EnrollmentToken, tokenHash string) error
@@ -28,7 +35,15 @@ type Store interface {
 
 	// Compliance reports
 	StoreReport(ctx context.Context, nodeID string, report []byte) error
+	StoreReportAt(ctx context.Context, nodeID string, ts time.Time, report []byte) error
 	GetLatestReport(ctx context.Context, nodeID string) ([]byte, error)
+	// StoreReportIfChanged stores a live report only when its content hash
+	// differs from the node's last stored report, returning whether it did.
+	StoreReportIfChanged(ctx context.Context, nodeID, hash string, report []byte) (bool, error)
+	GetReportHash(ctx context.Context, nodeID string) (string, error)
+
+	// Heartbeat history (replayed heartbeats from an agent's offline spool)
+	StoreHeartbeatAt(ctx context.Context, nodeID string, ts time.Time) error
 
 	// Fleet summary
 	GetSummary(ctx context.Context) (*FleetSummary, error)
@@ -38,6 +53,32 @@ type Store interface {
 	GetPendingRemediations(ctx context.Context, nodeID string) ([]RemediationRequest, error)
 	CompleteRemediation(ctx context.Context, reqID string, result []byte) error
 	GetRemediationRequest(ctx context.Context, id string) (*RemediationRequest, error)
+	ListRemediationRequests(ctx context.Context, filter RemediationFilter) ([]RemediationRequest, error)
+	ApproveRemediation(ctx context.Context, id string, approval RemediationApproval) (*RemediationRequest, error)
+	ExpireRemediationApprovals(ctx context.Context, now time.Time) ([]RemediationRequest, error)
+
+	// Edge remediation (Cloudflare zone changes applied by the controller)
+	CreateEdgeRemediation(ctx context.Context, req *RemediationRequest) error
+	GetEdgeRemediation(ctx context.Context, id string) (*RemediationRequest, error)
+	ListEdgeRemediations(ctx context.Context, filter RemediationFilter) ([]RemediationRequest, error)
+	ApproveEdgeRemediation(ctx context.Context, id string, approval RemediationApproval) (*RemediationRequest, error)
+	CompleteEdgeRemediation(ctx context.Context, reqID string, result []byte) error
+	FailEdgeRemediation(ctx context.Context, reqID string, result []byte) error
+
+	// Maintenance windows and reboot coordination
+	CreateMaintenanceWindow(ctx context.Context, w *MaintenanceWindow) error
+	ListMaintenanceWindows(ctx context.Context) ([]MaintenanceWindow, error)
+	DeleteMaintenanceWindow(ctx context.Context, id string) error
+	CreateReboot(ctx context.Context, rb *Reboot) error
+	UpdateReboot(ctx context.Context, rb *Reboot) error
+	ListReboots(ctx context.Context, filter RebootFilter) ([]Reboot, error)
+
+	// Federation (nodes reported by child controllers)
+	ApplyFederationSync(ctx context.Context, siteID string, sync *FederationSync) error
+	GetSite(ctx context.Context, id string) (*Site, error)
+	ListSites(ctx context.Context) ([]Site, error)
+	ListFederatedNodes(ctx context.Context, filter NodeFilter) ([]Node, error)
+	GetFederatedNode(ctx context.Context, id string) (*Node, error)
 
 	// Lifecycle
 	Close() error
@@ -76,6 +76,17 @@ type Node struct {
 	ComplianceStatus NodeComplianceStatus `json:"compliance_status"`
 	Service          *ServiceRegistration `json:"service,omitempty"`
 	GracePeriodEnd   *time.Time           `json:"grace_period_end,omitempty"`
+
+	// Flapping is set by the monitor while the node changes status more
+	// often than the flap threshold allows.
+	Flapping bool `json:"flapping"`
+
+	// Availability is populated on single-node API responses.
+	Availability *Availability `json:"availability,omitempty"`
+
+	// Site is the ID of the child controller a federated node was reported
+	// by. Empty for nodes enrolled directly with this controller.
+	Site string `json:"site,omitempty"`
 }
 
 // EnrollmentToken is used for zero-trust node enrollment.
@@ -103,9 +114,10 @@ type EnrollmentRequest struct {
 
 // EnrollmentResponse is returned after successful enrollment.
 type EnrollmentResponse struct {
-	NodeID         string `json:"node_id"`
-	APIKey         string `json:"api_key"`
-	ReportInterval int    `json:"report_interval"` // seconds
+	NodeID         string   `json:"node_id"`
+	APIKey         string   `json:"api_key"`
+	Role           NodeRole `json:"role"`
+	ReportInterval int      `json:"report_interval"` // seconds
 }
 
 // ComplianceReportPayload wraps a compliance report with node identity.
@@ -114,11 +126,23 @@ type ComplianceReportPayload struct {
 	Report  compliance.ComplianceReport `json:"report"`
 	Version string                   `json:"version"`
 	Backend string                   `json:"fips_backend"`
+
+	// Replayed marks a report that was spooled while the controller was
+	// unreachable. It is stored as history under Report.Timestamp and does
+	// not change the node's current status.
+	Replayed bool `json:"replayed,omitempty"`
 }
 
 // HeartbeatRequest is a lightweight keepalive from a node.
 type HeartbeatRequest struct {
-	NodeID string `json:"node_id"`
+	NodeID    string    `json:"node_id"`
+	Timestamp time.Time `json:"timestamp,omitempty"` // When the node sent it (original time for replays)
+	Replayed  bool      `json:"replayed,omitempty"`  // Spooled while the controller was unreachable
+
+	// ReportHash is the ReportHash of the last report the controller
+	// acknowledged. The controller answers 304 Not Modified when it still
+	// holds that report, so an unchanged report need not be re-sent.
+	ReportHash string `json:"report_hash,omitempty"`
 }
 
 // FleetSummary provides aggregate fleet statistics.
@@ -130,6 +154,8 @@ type FleetSummary struct {
 	ByRole      map[string]int `json:"by_role"`
 	ByRegion    map[string]int `json:"by_region"`
 	FullyCompliant int         `json:"fully_compliant"`
+	Flapping       int          `json:"flapping"`
+	Availability   Availability `json:"availability"` // Mean across nodes
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
@@ -138,11 +164,12 @@ type NodeFilter struct {
 	Role   NodeRole   `json:"role,omitempty"`
 	Region string     `json:"region,omitempty"`
 	Status NodeStatus `json:"status,omitempty"`
+	Site   string     `json:"site,omitempty"` // federated nodes only; "local" selects directly enrolled nodes
 }
 
 // FleetEvent is sent via SSE when fleet state changes.
 type FleetEvent struct {
-	Type string      `json:"type"` // "node_joined", "node_updated", "node_offline", "node_removed"
+	Type string      `json:"type"` // "node_joined", "node_updated", "node_offline", "node_removed", "node_flapping", "node_stable"
 	Node Node        `json:"node"`
 	Time time.Time   `json:"time"`
 }
@@ -165,13 +192,55 @@ type RemediationRequest struct {
 	CreatedAt   time.Time       `json:"created_at"`
 	CompletedAt *time.Time      `json:"completed_at,omitempty"`
 	Result      json.RawMessage `json:"result,omitempty"`
+
+	// ZoneID is set instead of NodeID on edge requests, which change a
+	// Cloudflare zone and are applied by the controller rather than an agent.
+	ZoneID string `json:"zone_id,omitempty"`
+
+	// Type is RemediationTypeRollback for requests that undo an earlier
+	// request (RollbackOf); empty or RemediationTypeRemediate otherwise.
+	Type       RemediationType `json:"type,omitempty"`
+	RollbackOf string          `json:"rollback_of,omitempty"`
+	// Previous is the result of the RollbackOf request, attached when a
+	// rollback is delivered to the agent. Not stored.
+	Previous json.RawMessage `json:"previous,omitempty"`
+
+	// Two-person approval. A request awaiting approval is released to the
+	// agent (status pending) once RequiredApprovals distinct approvers other
+	// than RequestedBy have approved it before ApprovalExpiresAt.
+	RequestedBy       string                `json:"requested_by,omitempty"`
+	RequiredApprovals int                   `json:"required_approvals,omitempty"`
+	Approvals         []RemediationApproval `json:"approvals,omitempty"`
+	ApprovalExpiresAt *time.Time            `json:"approval_expires_at,omitempty"`
+}
+
+// RemediationApproval records one approver's sign-off on a request.
+type RemediationApproval struct {
+	Approver   string    `json:"approver"`
+	ApprovedAt time.Time `json:"approved_at"`
 }
 
+// RemediationFilter specifies criteria for listing remediation requests.
+type RemediationFilter struct {
+	NodeID string
+	Status RemediationStatus
+}
+
+// RemediationType distinguishes remediation from rollback requests.
+type RemediationType string
+
+const (
+	RemediationTypeRemediate RemediationType = "remediate"
+	RemediationTypeRollback  RemediationType = "rollback"
+)
+
 // RemediationStatus represents the status of a remediation request.
 type RemediationStatus string
 
 const (
-	RemediationPending   RemediationStatus = "pending"
-	RemediationCompleted RemediationStatus = "completed"
-	RemediationFailed    RemediationStatus = "failed"
+	RemediationPending          RemediationStatus = "pending"
+	RemediationCompleted        RemediationStatus = "completed"
+	RemediationFailed           RemediationStatus = "failed"
+	RemediationAwaitingApproval RemediationStatus = "awaiting_approval"
+	RemediationExpired          RemediationStatus = "expired"
 )
@@ -0,0 +1,310 @@
+// Package metrics provides a minimal Prometheus text-format registry.
+//
+// It covers the three metric shapes the dashboard and controller need —
+// labelled counters, labelled histograms, and gauges collected at scrape
+// time — without pulling in the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, suited to check and
+// request durations.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// collector writes one metric family in text exposition format.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them for scraping.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+	names      map[string]bool
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[c.name()] {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.names[c.name()] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo renders all registered metrics, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	cs := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	sort.Slice(cs, func(i, j int) bool { return cs[i].name() < cs[j].name() })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range cs {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP serves the registry in Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// --- Counter ---
+
+// CounterVec is a family of monotonically increasing counters.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// NewCounter registers a counter family with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{n: name, help: help, labels: labels}, values: make(map[string]*series)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *CounterVec) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds v (which must be non-negative) to the counter.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.desc.series(c.values, labelValues)
+	s.value += v
+}
+
+// Value returns the current value of the counter with the given labels.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[key(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range sortedSeries(c.values) {
+		writeSample(w, c.n, c.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// --- Histogram ---
+
+// HistogramVec is a family of histograms with fixed buckets.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*series
+}
+
+// NewHistogram registers a histogram family. Nil buckets selects
+// DefaultBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{desc: desc{n: name, help: help, labels: labels}, buckets: buckets, values: make(map[string]*series)}
+	r.register(h)
+	return h
+}
+
+// Observe records a value in the histogram with the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.desc.series(h.values, labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range sortedSeries(h.values) {
+		for i, b := range h.buckets {
+			writeSample(w, h.n+"_bucket", h.labels, s.labelValues, "le", formatFloat(b), float64(s.counts[i]))
+		}
+		writeSample(w, h.n+"_bucket", h.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.n+"_sum", h.labels, s.labelValues, "", "", s.value)
+		writeSample(w, h.n+"_count", h.labels, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// --- Gauge ---
+
+// GaugeFunc is a gauge family whose samples are produced at scrape time.
+type GaugeFunc struct {
+	desc
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc registers a gauge family. collect is called on every scrape
+// and must call emit once per series.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{n: name, help: help, labels: labels}, collect: collect}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	values := make(map[string]*series)
+	g.collect(func(v float64, labelValues ...string) {
+		s := g.desc.series(values, labelValues)
+		s.value = v
+	})
+	g.header(w, "gauge")
+	for _, s := range sortedSeries(values) {
+		writeSample(w, g.n, g.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// --- shared ---
+
+type desc struct {
+	n      string
+	help   string
+	labels []string
+}
+
+func (d *desc) name() string { return d.n }
+
+func (d *desc) header(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.n, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.n, typ)
+}
+
+// series returns the series for labelValues, creating it if needed. Missing
+// label values are treated as empty; extra ones are dropped.
+func (d *desc) series(m map[string]*series, labelValues []string) *series {
+	lv := make([]string, len(d.labels))
+	copy(lv, labelValues)
+	k := key(lv)
+	s, ok := m[k]
+	if !ok {
+		s = &series{labelValues: lv}
+		m[k] = s
+	}
+	return s
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter/gauge value, or histogram sum
+	count       uint64   // histogram observation count
+	counts      []uint64 // histogram cumulative bucket counts
+}
+
+func key(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedSeries(m map[string]*series) []*series {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = m[k]
+	}
+	return out
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	return b.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_events_total", "Events seen.", "type")
+	c.Inc("a")
+	c.Inc("a")
+	c.Add(3, "b")
+	c.Add(-1, "b") // ignored
+
+	out := render(t, r)
+	for _, want := range []string{
+		"# HELP test_events_total Events seen.\n",
+		"# TYPE test_events_total counter\n",
+		`test_events_total{type="a"} 2` + "\n",
+		`test_events_total{type="b"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if c.Value("a") != 2 {
+		t.Errorf("Value(a) = %v, want 2", c.Value("a"))
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_duration_seconds", "Durations.", []float64{1, 0.1}, "op")
+	h.Observe(0.05, "x")
+	h.Observe(0.5, "x")
+	h.Observe(5, "x")
+
+	out := render(t, r)
+	for _, want := range []string{
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{op="x",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{op="x",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{op="x",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{op="x"} 5.55` + "\n",
+		`test_duration_seconds_count{op="x"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGaugeFunc_CollectedAtScrape(t *testing.T) {
+	r := NewRegistry()
+	n := 1.0
+	r.NewGaugeFunc("test_clients", "Connected clients.", []string{"stream"}, func(emit func(float64, ...string)) {
+		emit(n, "sse")
+	})
+	if out := render(t, r); !strings.Contains(out, `test_clients{stream="sse"} 1`) {
+		t.Errorf("first scrape:\n%s", out)
+	}
+	n = 4
+	if out := render(t, r); !strings.Contains(out, `test_clients{stream="sse"} 4`) {
+		t.Errorf("second scrape:\n%s", out)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "x", "v")
+	c.Inc("a\"b\\c\nd")
+	if out := render(t, r); !strings.Contains(out, `test_total{v="a\"b\\c\nd"} 1`) {
+		t.Errorf("escaping:\n%s", out)
+	}
+}
+
+func TestRegistry_SortedAndServed(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("zz_total", "z").Inc()
+	r.NewCounter("aa_total", "a").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	if strings.Index(body, "aa_total") > strings.Index(body, "zz_total") {
+		t.Errorf("families not sorted:\n%s", body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup_total", "x")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	r.NewCounter("dup_total", "x")
+}
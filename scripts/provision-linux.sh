@@ -884,6 +884,8 @@ NoNewPrivileges=yes
 ProtectSystem=strict
 ProtectHome=yes
 ReadOnlyPaths=${CONFIG_DIR}
+# Agent state file (self-enrollment) and offline report spool
+ReadWritePaths=${DATA_DIR}
 PrivateTmp=yes
 
 [Install]